@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"rabbit_ai/internal/fakellm"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "监听地址")
+	apiKey := flag.String("api-key", "", "校验的API Key，为空时不校验")
+	replies := flag.String("replies", "", "按顺序返回的脚本回复，使用 || 分隔；耗尽后回显用户消息")
+	errorCode := flag.Int("error-code", 0, "所有请求返回的 base_resp 错误码，例如 1002、1004、1008、1039")
+	chunkSize := flag.Int("chunk-size", 4, "流式响应每个片段的字符数")
+	chunkDelay := flag.Duration("chunk-delay", 50*time.Millisecond, "流式响应片段之间的间隔")
+	flag.Parse()
+
+	config := fakellm.Config{
+		APIKey:     *apiKey,
+		ErrorCode:  *errorCode,
+		ChunkSize:  *chunkSize,
+		ChunkDelay: *chunkDelay,
+	}
+	if *replies != "" {
+		for _, content := range strings.Split(*replies, "||") {
+			config.Replies = append(config.Replies, fakellm.Reply{Content: content})
+		}
+	}
+
+	log.Printf("Fake MiniMax server listening on %s", *addr)
+	log.Printf("Point the backend at it with: MINIMAX_BASE_URL=%s", baseURL(*addr))
+	log.Fatal(http.ListenAndServe(*addr, fakellm.NewServer(config)))
+}
+
+// baseURL 根据监听地址生成可直接使用的MiniMax BaseURL
+func baseURL(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	return fmt.Sprintf("http://%s/v1", addr)
+}
@@ -8,20 +8,31 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 
+	"rabbit_ai/internal/attachment"
 	"rabbit_ai/internal/auth"
+	"rabbit_ai/internal/batch"
 	"rabbit_ai/internal/cache"
 	"rabbit_ai/internal/conversation"
 	"rabbit_ai/internal/device"
+	"rabbit_ai/internal/guardrail"
+	"rabbit_ai/internal/knowledge"
+	"rabbit_ai/internal/llm"
 	"rabbit_ai/internal/middleware"
 	"rabbit_ai/internal/minimax"
 	"rabbit_ai/internal/model"
+	"rabbit_ai/internal/openai"
+	"rabbit_ai/internal/prompt"
 	"rabbit_ai/internal/repository"
+	"rabbit_ai/internal/search"
+	"rabbit_ai/internal/storage"
+	"rabbit_ai/internal/tool"
 	"rabbit_ai/internal/user"
 )
 
@@ -61,9 +72,69 @@ type Config struct {
 		RedirectURL  string `yaml:"redirect_url"`
 	} `yaml:"github"`
 	MiniMax struct {
-		APIKey  string `yaml:"api_key"`
-		BaseURL string `yaml:"base_url"`
+		APIKey             string `yaml:"api_key"`
+		BaseURL            string `yaml:"base_url"`
+		RetryMaxAttempts   int    `yaml:"retry_max_attempts"`   // 瞬时故障最大尝试次数（含首次）
+		RetryBudgetSeconds int    `yaml:"retry_budget_seconds"` // 单次调用重试总时间预算（秒）
 	} `yaml:"minimax"`
+	OpenAI struct {
+		APIKey  string   `yaml:"api_key"`
+		BaseURL string   `yaml:"base_url"`
+		Models  []string `yaml:"models"`
+	} `yaml:"openai"`
+	LLM struct {
+		Provider           string  `yaml:"provider"`             // 对话使用的大模型提供方: minimax/openai
+		ModelCatalog       string  `yaml:"model_catalog"`        // 模型目录文件路径，为空时使用内置目录
+		BreakerFailureRate float64 `yaml:"breaker_failure_rate"` // 触发熔断的错误率阈值
+		BreakerMinRequests int     `yaml:"breaker_min_requests"` // 计算错误率所需的最少请求数
+		BreakerOpenSeconds int     `yaml:"breaker_open_seconds"` // 熔断持续时间（秒）
+		ToolsEnabled       bool    `yaml:"tools_enabled"`        // 是否向模型声明服务端工具
+		MaxToolSteps       int     `yaml:"max_tool_steps"`       // 单次发送消息最多工具调用轮数
+		SummaryThreshold   int     `yaml:"summary_threshold"`    // 触发滚动摘要的较早轮次token数，0表示关闭
+		SummaryKeepTurns   int     `yaml:"summary_keep_turns"`   // 始终以原文发送的最近轮数
+		SummaryModel       string  `yaml:"summary_model"`        // 生成摘要使用的模型，为空时使用对话模型
+		TitleEnabled       bool    `yaml:"title_enabled"`        // 首轮对话后是否由模型生成对话标题
+		TitleModel         string  `yaml:"title_model"`          // 生成标题使用的模型，为空时使用对话模型
+	} `yaml:"llm"`
+	ResponseCache struct {
+		Enabled    bool     `yaml:"enabled"`     // 是否缓存 /ai/chat 与 /ai/chat/simple 温度为0的非流式回复
+		TTLSeconds int      `yaml:"ttl_seconds"` // 缓存有效期（秒）
+		Models     []string `yaml:"models"`      // 启用缓存的模型，为空时所有模型均启用
+	} `yaml:"response_cache"`
+	Search struct {
+		Enabled              bool   `yaml:"enabled"`                // 是否启用对话历史语义搜索及后台向量化任务
+		EmbeddingModel       string `yaml:"embedding_model"`        // 向量模型
+		IndexBatchSize       int    `yaml:"index_batch_size"`       // 每批向量化的消息数
+		IndexIntervalSeconds int    `yaml:"index_interval_seconds"` // 没有新消息时向量化任务的轮询间隔（秒）
+	} `yaml:"search"`
+	Knowledge struct {
+		Enabled               bool    `yaml:"enabled"`                 // 是否启用知识库及后台文档处理任务
+		EmbeddingModel        string  `yaml:"embedding_model"`         // 向量模型
+		TopK                  int     `yaml:"top_k"`                   // 每条消息检索的片段数
+		MinScore              float64 `yaml:"min_score"`               // 片段最低相似度，低于该值的片段不注入上下文
+		ChunkSize             int     `yaml:"chunk_size"`              // 片段最大字符数
+		ChunkOverlap          int     `yaml:"chunk_overlap"`           // 相邻片段重叠字符数
+		MaxDocumentBytes      int     `yaml:"max_document_bytes"`      // 单个文档最大字节数
+		IngestIntervalSeconds int     `yaml:"ingest_interval_seconds"` // 没有待处理文档时后台任务的轮询间隔（秒）
+	} `yaml:"knowledge"`
+	Batch struct {
+		Enabled     bool `yaml:"enabled"`      // 是否启用批量任务接口及后台工作池
+		Workers     int  `yaml:"workers"`      // 每个实例的工作协程数
+		RateLimit   int  `yaml:"rate_limit"`   // 所有实例合计每分钟最多调用模型的次数，0表示不限制
+		MaxAttempts int  `yaml:"max_attempts"` // 单个条目最多调用模型的次数（含首次）
+		MaxItems    int  `yaml:"max_items"`    // 单个任务最多条目数
+	} `yaml:"batch"`
+	Upload struct {
+		Dir           string `yaml:"dir"`             // 上传图片的本地保存目录
+		MaxImageBytes int    `yaml:"max_image_bytes"` // 单张图片最大字节数
+	} `yaml:"upload"`
+	Guardrail struct {
+		KeywordsFile   string `yaml:"keywords_file"`   // 关键词/正则规则文件路径，为空时不启用关键词过滤
+		SensitiveFlags bool   `yaml:"sensitive_flags"` // 是否屏蔽提供方标记为敏感的回复
+	} `yaml:"guardrail"`
+	Admin struct {
+		UserIDs []int64 `yaml:"user_ids"` // 管理员用户ID，可访问内容安全复核等管理接口
+	} `yaml:"admin"`
 }
 
 func main() {
@@ -139,27 +210,190 @@ func main() {
 	minimaxConfig := minimax.MiniMaxConfig{
 		APIKey:  config.MiniMax.APIKey,
 		BaseURL: config.MiniMax.BaseURL,
+		Retry:   minimax.DefaultRetryPolicy(),
 	}
 	if minimaxConfig.BaseURL == "" {
 		minimaxConfig.BaseURL = "https://api.minimaxi.com/v1"
 	}
+	minimaxConfig.Retry.MaxAttempts = config.MiniMax.RetryMaxAttempts
+	minimaxConfig.Retry.Budget = time.Duration(config.MiniMax.RetryBudgetSeconds) * time.Second
 	minimaxService := minimax.NewMiniMaxService(minimaxConfig)
 
+	// 根据配置选择大模型提供方
+	llmProvider, err := newLLMProvider(config, config.LLM.Provider, minimaxService)
+	if err != nil {
+		log.Fatal("Failed to create LLM provider:", err)
+	}
+	log.Printf("Using LLM provider: %s", llmProvider.Name())
+
+	// 加载模型目录，对话只使用当前提供方的模型
+	modelCatalog := llm.DefaultCatalog()
+	if config.LLM.ModelCatalog != "" {
+		modelCatalog, err = llm.LoadCatalog(config.LLM.ModelCatalog)
+		if err != nil {
+			log.Fatal("Failed to load model catalog:", err)
+		}
+	}
+	conversationCatalog, err := modelCatalog.ForProvider(llmProvider.Name())
+	if err != nil {
+		log.Fatal("Failed to load model catalog:", err)
+	}
+	log.Printf("Model catalog: %d models enabled, default %s", len(conversationCatalog.Models()), conversationCatalog.DefaultModel())
+
+	// 为大模型调用增加熔断保护，上游故障时快速失败
+	breakerConfig := llm.DefaultBreakerConfig()
+	breakerConfig.FailureRate = config.LLM.BreakerFailureRate
+	breakerConfig.MinRequests = config.LLM.BreakerMinRequests
+	breakerConfig.OpenTimeout = time.Duration(config.LLM.BreakerOpenSeconds) * time.Second
+	llmBreaker := llm.NewCircuitBreaker(llmProvider, breakerConfig)
+
+	// 模型目录配置了故障转移链时，为备用模型涉及的提供方各自创建熔断器
+	var fallbackBreakers []*llm.CircuitBreaker
+	var fallbackProviders []llm.Provider
+	for _, name := range modelCatalog.FallbackProviders() {
+		if name == llmProvider.Name() {
+			continue
+		}
+		provider, err := newLLMProvider(config, name, minimaxService)
+		if err != nil {
+			log.Fatal("Failed to create failover LLM provider:", err)
+		}
+		breaker := llm.NewCircuitBreaker(provider, breakerConfig)
+		fallbackBreakers = append(fallbackBreakers, breaker)
+		fallbackProviders = append(fallbackProviders, breaker)
+		log.Printf("Failover LLM provider enabled: %s", name)
+	}
+	failoverProvider, err := llm.NewFailoverProvider(modelCatalog, llmBreaker, fallbackProviders...)
+	if err != nil {
+		log.Fatal("Failed to create failover LLM provider:", err)
+	}
+
 	// 初始化对话服务
 	conversationService := conversation.NewService(
 		conversationRepo,
 		messageRepo,
 		userRepo,
 		conversationCache,
-		minimaxService,
+		failoverProvider,
 	)
+	conversationService.SetModelCatalog(conversationCatalog)
+	if config.LLM.ToolsEnabled {
+		toolRegistry := tool.NewRegistry()
+		tool.RegisterBuiltins(toolRegistry)
+		conversationService.SetToolRegistry(toolRegistry, config.LLM.MaxToolSteps)
+		log.Printf("Tool calling enabled with %d tools", toolRegistry.Len())
+	}
+	conversationService.SetSummaryPolicy(conversation.SummaryPolicy{
+		ThresholdTokens: config.LLM.SummaryThreshold,
+		KeepTurns:       config.LLM.SummaryKeepTurns,
+		Model:           config.LLM.SummaryModel,
+	})
+	conversationService.SetTitlePolicy(conversation.TitlePolicy{
+		Enabled: config.LLM.TitleEnabled,
+		Model:   config.LLM.TitleModel,
+	})
+
+	// 关键词搜索：启动后为早期消息和对话补齐全文索引
+	conversationService.SetFullTextSearch(model.NewFullTextRepository(db))
+	go conversationService.BackfillSearchIndex(context.Background())
+
+	// 订阅其他实例广播的取消生成信号
+	go conversationService.ListenGenerationCancels(context.Background())
+
+	// 内容安全护栏：调用大模型前后检查输入与回复，命中的内容记录审核事件供管理员复核
+	guardrails, err := newGuardrailPipeline(config)
+	if err != nil {
+		log.Fatal("Failed to create guardrails:", err)
+	}
+	log.Printf("Guardrails enabled with %d guards", guardrails.Len())
+	moderationRepo := model.NewModerationRepository(db)
+	conversationService.SetGuardrails(guardrails, moderationRepo)
+	reviewService := guardrail.NewReviewService(moderationRepo, messageRepo, conversationCache)
 
 	// 初始化处理器
 	userHandler := user.NewHandler(userService)
 	authHandler := auth.NewHandler(authService)
 	deviceHandler := device.NewHandler(deviceService)
-	minimaxHandler := minimax.NewHandler(minimaxService)
+	minimaxHandler := minimax.NewHandler(minimaxService, modelCatalog)
+	minimaxHandler.SetGuardrails(guardrails)
+	// /ai 接口直接调用MiniMax服务，与对话接口共用MiniMax提供方的熔断器
+	for _, breaker := range append([]*llm.CircuitBreaker{llmBreaker}, fallbackBreakers...) {
+		if breaker.Name() == minimax.ProviderName {
+			minimaxHandler.SetCircuitBreaker(breaker)
+		}
+	}
+	if config.ResponseCache.Enabled {
+		minimaxHandler.SetResponseCache(conversationCache, minimax.ResponseCachePolicy{
+			Enabled: true,
+			TTL:     time.Duration(config.ResponseCache.TTLSeconds) * time.Second,
+			Models:  config.ResponseCache.Models,
+		})
+		log.Printf("AI response cache enabled (ttl %ds)", config.ResponseCache.TTLSeconds)
+	}
 	conversationHandler := conversation.NewHandler(conversationService)
+	reviewHandler := guardrail.NewHandler(reviewService)
+
+	// 语义搜索：后台任务为新消息生成向量，搜索时在服务端计算余弦相似度
+	var searchHandler *search.Handler
+	if config.Search.Enabled {
+		embeddingRepo := model.NewEmbeddingRepository(db)
+		embedder := minimax.NewEmbedder(minimaxService, config.Search.EmbeddingModel)
+		indexer := search.NewIndexer(embeddingRepo, embedder, search.IndexerConfig{
+			BatchSize: config.Search.IndexBatchSize,
+			Interval:  time.Duration(config.Search.IndexIntervalSeconds) * time.Second,
+		})
+		go indexer.Run(context.Background())
+		searchHandler = search.NewHandler(search.NewService(embeddingRepo, messageRepo, conversationRepo, embedder))
+		log.Printf("Semantic search enabled with embedding model %s", embedder.Model())
+	}
+
+	// 知识库：后台任务切分并向量化上传的文档，发送消息时检索关联知识库的片段注入上下文
+	var knowledgeHandler *knowledge.Handler
+	if config.Knowledge.Enabled {
+		embedder := minimax.NewEmbedder(minimaxService, config.Knowledge.EmbeddingModel)
+		knowledgeService := knowledge.NewService(model.NewKnowledgeRepository(db), embedder, knowledge.Config{
+			ChunkSize:        config.Knowledge.ChunkSize,
+			ChunkOverlap:     config.Knowledge.ChunkOverlap,
+			MaxDocumentBytes: config.Knowledge.MaxDocumentBytes,
+			IngestInterval:   time.Duration(config.Knowledge.IngestIntervalSeconds) * time.Second,
+		})
+		go knowledgeService.RunIngestion(context.Background())
+		conversationService.SetKnowledge(knowledgeService, conversation.KnowledgePolicy{
+			TopK:     config.Knowledge.TopK,
+			MinScore: config.Knowledge.MinScore,
+		})
+		knowledgeHandler = knowledge.NewHandler(knowledgeService)
+		log.Printf("Knowledge bases enabled with embedding model %s", embedder.Model())
+	}
+
+	// 图片上传：保存在本地目录，发送图文消息时引用，只向支持图片的模型回放
+	uploadStorage, err := storage.NewLocalStorage(config.Upload.Dir)
+	if err != nil {
+		log.Fatal("Failed to create upload storage:", err)
+	}
+	imageService := attachment.NewService(uploadStorage, attachment.Config{MaxImageBytes: config.Upload.MaxImageBytes})
+	conversationService.SetImageStore(imageService)
+	imageHandler := attachment.NewHandler(imageService)
+
+	// 提示词模板：系统模板由管理员维护，用户可创建自己的模板，以模板创建对话或发送消息
+	promptService := prompt.NewService(model.NewPromptTemplateRepository(db))
+	conversationService.SetTemplates(promptService)
+	promptHandler := prompt.NewHandler(promptService)
+
+	// 批量任务：条目保存在数据库并加入Redis队列，由后台工作池限流调用模型，失败时退避重试
+	var batchHandler *batch.Handler
+	if config.Batch.Enabled {
+		batchService := batch.NewService(model.NewBatchRepository(db), conversationCache, failoverProvider, conversationCatalog, batch.Config{
+			MaxItems:    config.Batch.MaxItems,
+			Workers:     config.Batch.Workers,
+			RateLimit:   config.Batch.RateLimit,
+			MaxAttempts: config.Batch.MaxAttempts,
+		})
+		batchService.SetGuardrails(guardrails)
+		go batchService.Run(context.Background())
+		batchHandler = batch.NewHandler(batchService)
+		log.Printf("Batch jobs enabled with %d workers", config.Batch.Workers)
+	}
 
 	// 初始化设备中间件配置
 	deviceConfig := middleware.DefaultDeviceConfig()
@@ -201,6 +435,18 @@ func main() {
 		// AI相关路由
 		minimaxHandler.RegisterRoutes(api)
 
+		// 模型目录（供客户端展示模型选择器）
+		api.GET("/ai/models", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{
+				"code":    200,
+				"message": "Success",
+				"data": gin.H{
+					"default_model": conversationCatalog.DefaultModel(),
+					"models":        conversationCatalog.Models(),
+				},
+			})
+		})
+
 		// 需要JWT认证的路由组
 		authorized := api.Group("/")
 		authorized.Use(middleware.JWTMiddleware(jwtConfig))
@@ -208,6 +454,33 @@ func main() {
 			// 对话相关路由（需要认证）
 			conversationHandler.RegisterRoutes(authorized)
 
+			// 对话历史语义搜索
+			if searchHandler != nil {
+				searchHandler.RegisterRoutes(authorized)
+			}
+
+			// 知识库管理
+			if knowledgeHandler != nil {
+				knowledgeHandler.RegisterRoutes(authorized)
+			}
+
+			// 图片上传
+			imageHandler.RegisterRoutes(authorized)
+
+			// 提示词模板
+			promptHandler.RegisterRoutes(authorized)
+
+			// 批量任务
+			if batchHandler != nil {
+				batchHandler.RegisterRoutes(authorized)
+			}
+
+			// 管理员路由：内容安全复核、系统提示词模板
+			admin := authorized.Group("/admin")
+			admin.Use(middleware.AdminMiddleware(config.Admin.UserIDs))
+			reviewHandler.RegisterRoutes(admin)
+			promptHandler.RegisterAdminRoutes(admin)
+
 			// 这里可以添加需要认证的路由
 			authorized.GET("/profile", func(c *gin.Context) {
 				userID, _ := middleware.GetUserIDFromContext(c)
@@ -222,10 +495,30 @@ func main() {
 
 	// 健康检查端点
 	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status": "ok",
+		llmStats := llmBreaker.Stats()
+		status := "ok"
+		if llmStats.State != llm.StateClosed.String() {
+			status = "degraded"
+		}
+		response := gin.H{
+			"status": status,
 			"time":   time.Now().Format(time.RFC3339),
-		})
+			"llm":    llmStats,
+		}
+		if len(fallbackBreakers) > 0 {
+			fallbackStats := make([]llm.BreakerStats, 0, len(fallbackBreakers))
+			for _, breaker := range fallbackBreakers {
+				fallbackStats = append(fallbackStats, breaker.Stats())
+			}
+			response["llm_fallbacks"] = fallbackStats
+		}
+		c.JSON(http.StatusOK, response)
+	})
+
+	// 监控指标端点（Prometheus文本格式）
+	r.GET("/metrics", func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain; version=0.0.4")
+		llm.WriteMetrics(c.Writer, append([]*llm.CircuitBreaker{llmBreaker}, fallbackBreakers...)...)
 	})
 
 	// 启动服务器
@@ -289,10 +582,225 @@ func loadConfig() Config {
 	// MiniMax配置从.env文件获取
 	config.MiniMax.APIKey = getEnv("MINIMAX_API_KEY", "")
 	config.MiniMax.BaseURL = getEnv("MINIMAX_BASE_URL", "https://api.minimaxi.com/v1")
+	config.MiniMax.RetryMaxAttempts = 3
+	if attemptsStr := getEnv("MINIMAX_RETRY_MAX_ATTEMPTS", ""); attemptsStr != "" {
+		if attempts, err := strconv.Atoi(attemptsStr); err == nil {
+			config.MiniMax.RetryMaxAttempts = attempts
+		}
+	}
+	config.MiniMax.RetryBudgetSeconds = 20
+	if budgetStr := getEnv("MINIMAX_RETRY_BUDGET_SECONDS", ""); budgetStr != "" {
+		if budget, err := strconv.Atoi(budgetStr); err == nil {
+			config.MiniMax.RetryBudgetSeconds = budget
+		}
+	}
+
+	// OpenAI兼容接口配置（OpenAI、DeepSeek、vLLM、Ollama等）
+	config.OpenAI.APIKey = getEnv("OPENAI_API_KEY", "")
+	config.OpenAI.BaseURL = getEnv("OPENAI_BASE_URL", "https://api.openai.com/v1")
+	if modelsStr := getEnv("OPENAI_MODELS", ""); modelsStr != "" {
+		for _, m := range strings.Split(modelsStr, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				config.OpenAI.Models = append(config.OpenAI.Models, m)
+			}
+		}
+	}
+
+	config.LLM.Provider = getEnv("LLM_PROVIDER", minimax.ProviderName)
+	config.LLM.ModelCatalog = getEnv("LLM_MODEL_CATALOG", "")
+	config.LLM.BreakerFailureRate = 0.5
+	if rateStr := getEnv("LLM_BREAKER_FAILURE_RATE", ""); rateStr != "" {
+		if rate, err := strconv.ParseFloat(rateStr, 64); err == nil {
+			config.LLM.BreakerFailureRate = rate
+		}
+	}
+	config.LLM.BreakerMinRequests = 10
+	if minStr := getEnv("LLM_BREAKER_MIN_REQUESTS", ""); minStr != "" {
+		if min, err := strconv.Atoi(minStr); err == nil {
+			config.LLM.BreakerMinRequests = min
+		}
+	}
+	config.LLM.ToolsEnabled = getEnv("LLM_TOOLS_ENABLED", "false") == "true"
+	config.LLM.MaxToolSteps = conversation.DefaultMaxToolSteps
+	if stepsStr := getEnv("LLM_MAX_TOOL_STEPS", ""); stepsStr != "" {
+		if steps, err := strconv.Atoi(stepsStr); err == nil {
+			config.LLM.MaxToolSteps = steps
+		}
+	}
+	config.LLM.BreakerOpenSeconds = 30
+	if secondsStr := getEnv("LLM_BREAKER_OPEN_SECONDS", ""); secondsStr != "" {
+		if seconds, err := strconv.Atoi(secondsStr); err == nil {
+			config.LLM.BreakerOpenSeconds = seconds
+		}
+	}
+	config.LLM.SummaryThreshold = conversation.DefaultSummaryThresholdTokens
+	if thresholdStr := getEnv("LLM_SUMMARY_THRESHOLD_TOKENS", ""); thresholdStr != "" {
+		if threshold, err := strconv.Atoi(thresholdStr); err == nil {
+			config.LLM.SummaryThreshold = threshold
+		}
+	}
+	config.LLM.SummaryKeepTurns = conversation.DefaultSummaryKeepTurns
+	if turnsStr := getEnv("LLM_SUMMARY_KEEP_TURNS", ""); turnsStr != "" {
+		if turns, err := strconv.Atoi(turnsStr); err == nil {
+			config.LLM.SummaryKeepTurns = turns
+		}
+	}
+	config.LLM.SummaryModel = getEnv("LLM_SUMMARY_MODEL", "")
+	config.LLM.TitleEnabled = getEnv("LLM_TITLE_ENABLED", "true") == "true"
+	config.LLM.TitleModel = getEnv("LLM_TITLE_MODEL", "")
+
+	config.ResponseCache.Enabled = getEnv("RESPONSE_CACHE_ENABLED", "false") == "true"
+	config.ResponseCache.TTLSeconds = int(minimax.DefaultResponseCacheTTL / time.Second)
+	if ttlStr := getEnv("RESPONSE_CACHE_TTL_SECONDS", ""); ttlStr != "" {
+		if ttl, err := strconv.Atoi(ttlStr); err == nil {
+			config.ResponseCache.TTLSeconds = ttl
+		}
+	}
+	if modelsStr := getEnv("RESPONSE_CACHE_MODELS", ""); modelsStr != "" {
+		for _, m := range strings.Split(modelsStr, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				config.ResponseCache.Models = append(config.ResponseCache.Models, m)
+			}
+		}
+	}
+
+	config.Search.Enabled = getEnv("SEARCH_ENABLED", "false") == "true"
+	config.Search.EmbeddingModel = getEnv("SEARCH_EMBEDDING_MODEL", minimax.DefaultEmbeddingModel)
+	config.Search.IndexBatchSize = search.DefaultIndexBatchSize
+	if sizeStr := getEnv("SEARCH_INDEX_BATCH_SIZE", ""); sizeStr != "" {
+		if size, err := strconv.Atoi(sizeStr); err == nil {
+			config.Search.IndexBatchSize = size
+		}
+	}
+	config.Search.IndexIntervalSeconds = int(search.DefaultIndexInterval / time.Second)
+	if intervalStr := getEnv("SEARCH_INDEX_INTERVAL_SECONDS", ""); intervalStr != "" {
+		if interval, err := strconv.Atoi(intervalStr); err == nil {
+			config.Search.IndexIntervalSeconds = interval
+		}
+	}
+
+	config.Knowledge.Enabled = getEnv("KNOWLEDGE_ENABLED", "false") == "true"
+	config.Knowledge.EmbeddingModel = getEnv("KNOWLEDGE_EMBEDDING_MODEL", minimax.DefaultEmbeddingModel)
+	config.Knowledge.TopK = conversation.DefaultKnowledgeTopK
+	if topKStr := getEnv("KNOWLEDGE_TOP_K", ""); topKStr != "" {
+		if topK, err := strconv.Atoi(topKStr); err == nil {
+			config.Knowledge.TopK = topK
+		}
+	}
+	if scoreStr := getEnv("KNOWLEDGE_MIN_SCORE", ""); scoreStr != "" {
+		if score, err := strconv.ParseFloat(scoreStr, 64); err == nil {
+			config.Knowledge.MinScore = score
+		}
+	}
+	config.Knowledge.ChunkSize = knowledge.DefaultChunkSize
+	if sizeStr := getEnv("KNOWLEDGE_CHUNK_SIZE", ""); sizeStr != "" {
+		if size, err := strconv.Atoi(sizeStr); err == nil {
+			config.Knowledge.ChunkSize = size
+		}
+	}
+	config.Knowledge.ChunkOverlap = knowledge.DefaultChunkOverlap
+	if overlapStr := getEnv("KNOWLEDGE_CHUNK_OVERLAP", ""); overlapStr != "" {
+		if overlap, err := strconv.Atoi(overlapStr); err == nil {
+			config.Knowledge.ChunkOverlap = overlap
+		}
+	}
+	config.Knowledge.MaxDocumentBytes = knowledge.DefaultMaxDocumentBytes
+	if bytesStr := getEnv("KNOWLEDGE_MAX_DOCUMENT_BYTES", ""); bytesStr != "" {
+		if maxBytes, err := strconv.Atoi(bytesStr); err == nil {
+			config.Knowledge.MaxDocumentBytes = maxBytes
+		}
+	}
+	config.Knowledge.IngestIntervalSeconds = int(knowledge.DefaultIngestInterval / time.Second)
+	if intervalStr := getEnv("KNOWLEDGE_INGEST_INTERVAL_SECONDS", ""); intervalStr != "" {
+		if interval, err := strconv.Atoi(intervalStr); err == nil {
+			config.Knowledge.IngestIntervalSeconds = interval
+		}
+	}
+
+	config.Batch.Enabled = getEnv("BATCH_ENABLED", "false") == "true"
+	config.Batch.Workers = batch.DefaultWorkers
+	if workersStr := getEnv("BATCH_WORKERS", ""); workersStr != "" {
+		if workers, err := strconv.Atoi(workersStr); err == nil {
+			config.Batch.Workers = workers
+		}
+	}
+	config.Batch.RateLimit = batch.DefaultRateLimit
+	if rateStr := getEnv("BATCH_RATE_LIMIT", ""); rateStr != "" {
+		if rate, err := strconv.Atoi(rateStr); err == nil {
+			config.Batch.RateLimit = rate
+		}
+	}
+	config.Batch.MaxAttempts = batch.DefaultMaxAttempts
+	if attemptsStr := getEnv("BATCH_MAX_ATTEMPTS", ""); attemptsStr != "" {
+		if attempts, err := strconv.Atoi(attemptsStr); err == nil {
+			config.Batch.MaxAttempts = attempts
+		}
+	}
+	config.Batch.MaxItems = batch.DefaultMaxItems
+	if itemsStr := getEnv("BATCH_MAX_ITEMS", ""); itemsStr != "" {
+		if items, err := strconv.Atoi(itemsStr); err == nil {
+			config.Batch.MaxItems = items
+		}
+	}
+
+	config.Upload.Dir = getEnv("UPLOAD_DIR", "uploads")
+	config.Upload.MaxImageBytes = attachment.DefaultMaxImageBytes
+	if bytesStr := getEnv("UPLOAD_MAX_IMAGE_BYTES", ""); bytesStr != "" {
+		if maxBytes, err := strconv.Atoi(bytesStr); err == nil {
+			config.Upload.MaxImageBytes = maxBytes
+		}
+	}
+
+	config.Guardrail.KeywordsFile = getEnv("GUARDRAIL_KEYWORDS_FILE", "")
+	config.Guardrail.SensitiveFlags = getEnv("GUARDRAIL_SENSITIVE_FLAGS", "true") == "true"
+
+	if idsStr := getEnv("ADMIN_USER_IDS", ""); idsStr != "" {
+		for _, idStr := range strings.Split(idsStr, ",") {
+			if id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64); err == nil {
+				config.Admin.UserIDs = append(config.Admin.UserIDs, id)
+			}
+		}
+	}
 
 	return config
 }
 
+// newGuardrailPipeline 根据配置创建内容安全护栏链
+func newGuardrailPipeline(config Config) (*guardrail.Pipeline, error) {
+	var guards []guardrail.Guard
+	if config.Guardrail.KeywordsFile != "" {
+		rules, err := guardrail.LoadKeywordRules(config.Guardrail.KeywordsFile)
+		if err != nil {
+			return nil, err
+		}
+		keywordGuard, err := guardrail.NewKeywordGuard(rules)
+		if err != nil {
+			return nil, fmt.Errorf("invalid guardrail rules: %w", err)
+		}
+		guards = append(guards, keywordGuard)
+	}
+	if config.Guardrail.SensitiveFlags {
+		guards = append(guards, guardrail.NewSensitiveFlagGuard())
+	}
+	return guardrail.NewPipeline(guards...), nil
+}
+
+// newLLMProvider 根据配置创建指定名称的大模型提供方
+func newLLMProvider(config Config, name string, minimaxService *minimax.MiniMaxService) (llm.Provider, error) {
+	switch name {
+	case minimax.ProviderName:
+		return minimax.NewProvider(minimaxService), nil
+	case openai.ProviderName:
+		return openai.NewProvider(openai.Config{
+			APIKey:  config.OpenAI.APIKey,
+			BaseURL: config.OpenAI.BaseURL,
+			Models:  config.OpenAI.Models,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider: %s", name)
+	}
+}
+
 // getEnv 获取环境变量，如果不存在则返回默认值
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -122,6 +122,7 @@ func main() {
 	fmt.Println("6. 获取AI聊天历史...")
 	historyReq := &conversation.GetConversationMessagesRequest{
 		ConversationID: conversationID,
+		UserID:         1,
 		Limit:          50,
 		Offset:         0,
 	}
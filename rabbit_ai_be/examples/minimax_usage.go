@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -18,12 +19,13 @@ func main() {
 	// 创建MiniMax服务
 	config := minimax.DefaultConfig(apiKey)
 	service := minimax.NewMiniMaxService(config)
+	ctx := context.Background()
 
 	fmt.Println("=== MiniMax AI 使用示例 ===\n")
 
 	// 示例1: 简单聊天
 	fmt.Println("1. 简单聊天:")
-	response, err := service.SimpleChat("你好，请介绍一下自己")
+	response, err := service.SimpleChat(ctx, "你好，请介绍一下自己")
 	if err != nil {
 		log.Printf("简单聊天失败: %v", err)
 	} else {
@@ -32,7 +34,7 @@ func main() {
 
 	// 示例2: 带参数的聊天
 	fmt.Println("2. 带参数的聊天 (低温度，短回复):")
-	response, err = service.SimpleChatWithParams("写一首关于春天的短诗", 0.3, 100)
+	response, err = service.SimpleChatWithParams(ctx, "写一首关于春天的短诗", 0.3, 100)
 	if err != nil {
 		log.Printf("带参数聊天失败: %v", err)
 	} else {
@@ -58,7 +60,7 @@ func main() {
 		WithTopP(0.8).
 		WithUser("go-developer")
 
-	responseObj, err := service.ChatCompletion(*request)
+	responseObj, err := service.ChatCompletion(ctx, *request)
 	if err != nil {
 		log.Printf("完整聊天失败: %v", err)
 	} else {
@@ -93,7 +95,7 @@ func main() {
 	}).WithStream(true).WithTemperature(0.7).WithMaxTokens(300)
 
 	fmt.Print("AI回复 (流式): ")
-	responseChan, err := service.ChatCompletionStream(*streamRequest)
+	responseChan, err := service.ChatCompletionStream(ctx, *streamRequest)
 	if err != nil {
 		log.Printf("流式聊天失败: %v", err)
 	} else {
@@ -133,7 +135,7 @@ func main() {
 		},
 	}).WithTemperature(0.3)
 
-	responseObj, err = service.ChatCompletion(*toolRequest)
+	responseObj, err = service.ChatCompletion(ctx, *toolRequest)
 	if err != nil {
 		log.Printf("工具选择聊天失败: %v", err)
 	} else {
@@ -161,7 +163,7 @@ func main() {
 		WithTemperature(0.6).
 		WithMaxTokens(200)
 
-	responseObj, err = service.ChatCompletion(*stopRequest)
+	responseObj, err = service.ChatCompletion(ctx, *stopRequest)
 	if err != nil {
 		log.Printf("停止词聊天失败: %v", err)
 	} else {
@@ -0,0 +1,137 @@
+package attachment
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead 上传请求体中图片以外的部分（边界、字段头等）允许的最大字节数
+const multipartOverhead = 64 << 10
+
+// Handler 图片上传处理器
+type Handler struct {
+	service *Service
+}
+
+// NewHandler 创建图片上传处理器实例
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// RegisterRoutes 注册路由
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	group := r.Group("/images")
+	{
+		group.POST("", h.Upload)
+		group.GET("/:id", h.Get)
+	}
+}
+
+// Upload 上传图片（multipart 表单的 file 字段）
+func (h *Handler) Upload(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	// 解析表单前限制请求体大小，避免超大请求被完整读取并写入临时文件
+	maxBytes := int64(h.service.MaxImageBytes())
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+multipartOverhead)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondError(c, fmt.Errorf("%w: exceeds %d bytes", ErrImageTooLarge, maxBytes), "Failed to upload image")
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid image upload",
+			"details": err.Error(),
+		})
+		return
+	}
+	if fileHeader.Size > maxBytes {
+		respondError(c, fmt.Errorf("%w: exceeds %d bytes", ErrImageTooLarge, maxBytes), "Failed to upload image")
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid image upload",
+			"details": err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid image upload",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	image, err := h.service.Upload(c.Request.Context(), userID.(int64), data)
+	if err != nil {
+		respondError(c, err, "Failed to upload image")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    image,
+	})
+}
+
+// Get 获取图片内容，只能获取自己上传的图片
+func (h *Handler) Get(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	data, mimeType, err := h.service.Get(c.Request.Context(), userID.(int64), c.Param("id"))
+	if err != nil {
+		respondError(c, err, "Failed to get image")
+		return
+	}
+
+	// 图片ID随机生成，内容上传后不再修改，可长期缓存
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Data(http.StatusOK, mimeType, data)
+}
+
+// respondError 按错误类型响应
+func respondError(c *gin.Context, err error, message string) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrEmptyImage):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrImageTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrUnsupportedImageType):
+		status = http.StatusUnsupportedMediaType
+	case errors.Is(err, ErrImageNotFound):
+		status = http.StatusNotFound
+	}
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
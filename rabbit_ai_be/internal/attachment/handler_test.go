@@ -0,0 +1,50 @@
+package attachment
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uploadImage 以 multipart 表单上传图片
+func uploadImage(t *testing.T, service *Service, data []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "image.png")
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write(data)
+	writer.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", int64(1))
+	})
+	NewHandler(service).RegisterRoutes(router.Group("/api/v1"))
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/images", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestHandler_Upload(t *testing.T) {
+	service := newTestService(t, 1024)
+
+	if recorder := uploadImage(t, service, pngBytes(t)); recorder.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	// 请求体超过限制时在解析表单阶段即拒绝
+	recorder := uploadImage(t, service, bytes.Repeat([]byte{0}, 1<<20))
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for oversized upload, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,148 @@
+package attachment
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"rabbit_ai/internal/storage"
+)
+
+// DefaultMaxImageBytes 单张图片默认最大字节数
+const DefaultMaxImageBytes = 5 << 20
+
+// 图片上传错误
+var (
+	ErrImageNotFound        = errors.New("image not found")
+	ErrEmptyImage           = errors.New("empty image")
+	ErrImageTooLarge        = errors.New("image too large")
+	ErrUnsupportedImageType = errors.New("unsupported image type")
+)
+
+// imageTypes 允许上传的图片类型及保存时使用的扩展名
+var imageTypes = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpg",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// imageIDPattern 图片ID：32位十六进制随机串加扩展名
+var imageIDPattern = regexp.MustCompile(`^[0-9a-f]{32}\.(png|jpg|gif|webp)$`)
+
+// Image 已上传的图片
+type Image struct {
+	ID       string `json:"id"`        // 发送图文消息时在 image 片段中引用
+	MimeType string `json:"mime_type"` // 按文件内容识别的类型
+	Size     int    `json:"size"`      // 字节数
+}
+
+// Config 图片上传配置
+type Config struct {
+	MaxImageBytes int // 单张图片最大字节数
+}
+
+// Service 图片上传服务，图片按用户分目录保存在存储后端中
+type Service struct {
+	storage storage.Storage
+	config  Config
+}
+
+// NewService 创建图片上传服务实例
+func NewService(store storage.Storage, config Config) *Service {
+	if config.MaxImageBytes <= 0 {
+		config.MaxImageBytes = DefaultMaxImageBytes
+	}
+	return &Service{
+		storage: store,
+		config:  config,
+	}
+}
+
+// MaxImageBytes 单张图片最大字节数
+func (s *Service) MaxImageBytes() int {
+	return s.config.MaxImageBytes
+}
+
+// Upload 校验并保存图片
+//
+// 类型按文件内容识别而不信任客户端声明，只接受PNG、JPEG、GIF和WebP。
+func (s *Service) Upload(ctx context.Context, userID int64, data []byte) (*Image, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyImage
+	}
+	if len(data) > s.config.MaxImageBytes {
+		return nil, fmt.Errorf("%w: exceeds %d bytes", ErrImageTooLarge, s.config.MaxImageBytes)
+	}
+	mimeType := http.DetectContentType(data)
+	ext, ok := imageTypes[mimeType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedImageType, mimeType)
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate image id: %w", err)
+	}
+	image := &Image{
+		ID:       hex.EncodeToString(random) + "." + ext,
+		MimeType: mimeType,
+		Size:     len(data),
+	}
+	if err := s.storage.Put(ctx, imageKey(userID, image.ID), data); err != nil {
+		return nil, fmt.Errorf("failed to save image: %w", err)
+	}
+	return image, nil
+}
+
+// Get 读取用户的图片内容及类型
+func (s *Service) Get(ctx context.Context, userID int64, imageID string) ([]byte, string, error) {
+	if !imageIDPattern.MatchString(imageID) {
+		return nil, "", fmt.Errorf("%w: %s", ErrImageNotFound, imageID)
+	}
+	data, err := s.storage.Get(ctx, imageKey(userID, imageID))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, "", fmt.Errorf("%w: %s", ErrImageNotFound, imageID)
+		}
+		return nil, "", err
+	}
+	return data, mimeTypeOf(imageID), nil
+}
+
+// Check 校验图片存在且属于该用户
+func (s *Service) Check(ctx context.Context, userID int64, imageID string) error {
+	_, _, err := s.Get(ctx, userID, imageID)
+	return err
+}
+
+// DataURL 以 data URL 形式返回图片，用于发送给视觉模型
+func (s *Service) DataURL(ctx context.Context, userID int64, imageID string) (string, error) {
+	data, mimeType, err := s.Get(ctx, userID, imageID)
+	if err != nil {
+		return "", err
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// imageKey 图片在存储后端中的key
+func imageKey(userID int64, imageID string) string {
+	return fmt.Sprintf("images/%d/%s", userID, imageID)
+}
+
+// mimeTypeOf 根据图片ID的扩展名得到类型
+func mimeTypeOf(imageID string) string {
+	ext := imageID[strings.LastIndexByte(imageID, '.')+1:]
+	for mimeType, e := range imageTypes {
+		if e == ext {
+			return mimeType
+		}
+	}
+	return "application/octet-stream"
+}
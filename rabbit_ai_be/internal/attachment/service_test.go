@@ -0,0 +1,77 @@
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"rabbit_ai/internal/storage"
+)
+
+// pngBytes 生成一张1x1的PNG图片
+func pngBytes(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatalf("Failed to encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func newTestService(t *testing.T, maxBytes int) *Service {
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	return NewService(store, Config{MaxImageBytes: maxBytes})
+}
+
+func TestUpload(t *testing.T) {
+	service := newTestService(t, 0)
+	ctx := context.Background()
+	data := pngBytes(t)
+
+	img, err := service.Upload(ctx, 1, data)
+	if err != nil {
+		t.Fatalf("Failed to upload image: %v", err)
+	}
+	if img.MimeType != "image/png" || img.Size != len(data) || !imageIDPattern.MatchString(img.ID) || !strings.HasSuffix(img.ID, ".png") {
+		t.Errorf("Unexpected image: %+v", img)
+	}
+
+	stored, mimeType, err := service.Get(ctx, 1, img.ID)
+	if err != nil || !bytes.Equal(stored, data) || mimeType != "image/png" {
+		t.Errorf("Expected stored image, got %d bytes, %q, %v", len(stored), mimeType, err)
+	}
+
+	url, err := service.DataURL(ctx, 1, img.ID)
+	if err != nil || !strings.HasPrefix(url, "data:image/png;base64,iVBORw0KGgo") {
+		t.Errorf("Expected png data URL, got %q, %v", url, err)
+	}
+
+	// 其他用户无法访问
+	if err := service.Check(ctx, 2, img.ID); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("Expected ErrImageNotFound for other user, got %v", err)
+	}
+	if err := service.Check(ctx, 1, "../2/"+img.ID); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("Expected ErrImageNotFound for invalid id, got %v", err)
+	}
+}
+
+func TestUpload_Validation(t *testing.T) {
+	service := newTestService(t, 64)
+	ctx := context.Background()
+
+	if _, err := service.Upload(ctx, 1, nil); !errors.Is(err, ErrEmptyImage) {
+		t.Errorf("Expected ErrEmptyImage, got %v", err)
+	}
+	if _, err := service.Upload(ctx, 1, []byte("%PDF-1.7 not an image")); !errors.Is(err, ErrUnsupportedImageType) {
+		t.Errorf("Expected ErrUnsupportedImageType, got %v", err)
+	}
+	if _, err := service.Upload(ctx, 1, append(pngBytes(t), make([]byte, 64)...)); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Expected ErrImageTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package batch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"rabbit_ai/internal/model"
+)
+
+// 任务列表分页参数
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// Handler 批量任务处理器
+type Handler struct {
+	service *Service
+}
+
+// NewHandler 创建批量任务处理器实例
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// RegisterRoutes 注册路由
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	group := r.Group("/batches")
+	{
+		group.POST("", h.Submit)
+		group.GET("", h.ListJobs)
+		group.GET("/:id", h.GetJob)
+		group.GET("/:id/results", h.DownloadResults)
+		group.POST("/:id/cancel", h.CancelJob)
+	}
+}
+
+// Submit 提交批量任务
+func (h *Handler) Submit(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req SubmitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+	req.UserID = userID
+
+	job, err := h.service.Submit(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err, "Failed to submit batch job")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"data":    job,
+	})
+}
+
+// ListJobs 获取批量任务列表
+func (h *Handler) ListJobs(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultListLimit)))
+	if err != nil || limit <= 0 {
+		limit = defaultListLimit
+	}
+	limit = min(limit, maxListLimit)
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	response, err := h.service.ListJobs(c.Request.Context(), userID, limit, offset)
+	if err != nil {
+		respondError(c, err, "Failed to list batch jobs")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// GetJob 获取批量任务状态
+func (h *Handler) GetJob(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	jobID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.service.GetJob(c.Request.Context(), userID, jobID)
+	if err != nil {
+		respondError(c, err, "Failed to get batch job")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    job,
+	})
+}
+
+// DownloadResults 下载批量任务结果，每行一个条目的JSON（JSON Lines）
+func (h *Handler) DownloadResults(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	jobID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	job, lines, err := h.service.Results(c.Request.Context(), userID, jobID)
+	if err != nil {
+		respondError(c, err, "Failed to get batch results")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="batch_%d_results.jsonl"`, job.ID))
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("X-Batch-Status", job.Status) // 任务状态，未完成时结果不完整
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	encoder.SetEscapeHTML(false)
+	for _, line := range lines {
+		if err := encoder.Encode(line); err != nil {
+			return
+		}
+	}
+}
+
+// CancelJob 取消批量任务
+func (h *Handler) CancelJob(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	jobID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.service.CancelJob(c.Request.Context(), userID, jobID)
+	if err != nil {
+		respondError(c, err, "Failed to cancel batch job")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    job,
+	})
+}
+
+// currentUser 获取当前用户ID，未认证时响应401
+func currentUser(c *gin.Context) (int64, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return 0, false
+	}
+	return userID.(int64), true
+}
+
+// pathID 解析路径中的任务ID，无效时响应400
+func pathID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid batch job ID",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// respondError 按错误类型响应
+func respondError(c *gin.Context, err error, message string) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		status = http.StatusBadRequest
+	case errors.Is(err, model.ErrBatchJobNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, model.ErrBatchJobFinished):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
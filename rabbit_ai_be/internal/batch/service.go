@@ -0,0 +1,313 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"rabbit_ai/internal/guardrail"
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+	"rabbit_ai/internal/structured"
+)
+
+// 批量任务限制与默认值
+const (
+	DefaultMaxItems       = 500
+	DefaultMaxTokens      = 2048
+	DefaultTemperature    = 0.7
+	maxNameLength         = 100   // 任务名称最大字符数
+	maxCustomIDLength     = 100   // 条目自定义标识最大字符数
+	maxContentLength      = 20000 // 单条消息最大字符数
+	maxSystemPromptLength = 4000  // 系统提示最大字符数
+)
+
+// ErrInvalidRequest 批量任务请求参数无效
+var ErrInvalidRequest = errors.New("invalid batch request")
+
+// Queue 批量任务队列，由 cache.ConversationCache 实现
+type Queue interface {
+	EnqueueBatchItems(ctx context.Context, availableAt time.Time, itemIDs ...int64) error
+	DequeueBatchItem(ctx context.Context) (int64, bool, error)
+	AcquireBatchRate(ctx context.Context, limit int) (time.Duration, error)
+}
+
+// Config 批量任务配置
+type Config struct {
+	MaxItems        int           // 单个任务最多条目数
+	Workers         int           // 每个实例的工作协程数
+	RateLimit       int           // 所有实例合计每分钟最多调用模型的次数，0表示不限制
+	MaxAttempts     int           // 单个条目最多调用模型的次数（含首次）
+	PollInterval    time.Duration // 队列为空时的轮询间隔
+	StaleAfter      time.Duration // 处理中超过该时间的条目视为中断，重新处理
+	RecoverInterval time.Duration // 将待处理和中断的条目重新入队的间隔
+	ItemTimeout     time.Duration // 单次调用模型的超时时间
+}
+
+// SubmitItem 批量任务中的一条请求
+type SubmitItem struct {
+	CustomID string `json:"custom_id"`
+	Content  string `json:"content"`
+}
+
+// SubmitRequest 提交批量任务请求，生成参数由所有条目共用
+type SubmitRequest struct {
+	UserID         int64                      `json:"user_id"`
+	Name           string                     `json:"name"`
+	Model          string                     `json:"model"`
+	SystemPrompt   string                     `json:"system_prompt"`
+	Temperature    *float64                   `json:"temperature"`
+	MaxTokens      int                        `json:"max_tokens"`
+	ResponseFormat *structured.ResponseFormat `json:"response_format"`
+	Items          []SubmitItem               `json:"items" binding:"required"`
+}
+
+// ListJobsResponse 任务列表
+type ListJobsResponse struct {
+	Jobs   []*model.BatchJob `json:"jobs"`
+	Total  int               `json:"total"`
+	Limit  int               `json:"limit"`
+	Offset int               `json:"offset"`
+}
+
+// ResultLine 结果文件（JSON Lines）中的一行，对应一个条目
+type ResultLine struct {
+	Index    int             `json:"index"`
+	CustomID string          `json:"custom_id"`
+	Status   string          `json:"status"`
+	Response string          `json:"response,omitempty"`
+	Output   json.RawMessage `json:"output,omitempty"` // 指定 response_format 时解析出的JSON
+	Error    string          `json:"error,omitempty"`
+	Attempts int             `json:"attempts"`
+	Model    string          `json:"model,omitempty"`
+	Usage    llm.Usage       `json:"usage"`
+}
+
+// Service 批量聊天任务服务：提交的条目保存在数据库并加入Redis队列，由后台工作池调用模型
+type Service struct {
+	repo       model.BatchRepository
+	queue      Queue
+	provider   llm.Provider
+	catalog    *llm.Catalog
+	guardrails *guardrail.Pipeline
+	config     Config
+}
+
+// NewService 创建批量任务服务实例
+func NewService(repo model.BatchRepository, queue Queue, provider llm.Provider, catalog *llm.Catalog, config Config) *Service {
+	if config.MaxItems <= 0 {
+		config.MaxItems = DefaultMaxItems
+	}
+	if config.Workers <= 0 {
+		config.Workers = DefaultWorkers
+	}
+	if config.RateLimit < 0 {
+		config.RateLimit = 0
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultMaxAttempts
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultPollInterval
+	}
+	if config.StaleAfter <= 0 {
+		config.StaleAfter = DefaultStaleAfter
+	}
+	if config.RecoverInterval <= 0 {
+		config.RecoverInterval = DefaultRecoverInterval
+	}
+	if config.ItemTimeout <= 0 {
+		config.ItemTimeout = DefaultItemTimeout
+	}
+	return &Service{
+		repo:     repo,
+		queue:    queue,
+		provider: provider,
+		catalog:  catalog,
+		config:   config,
+	}
+}
+
+// SetGuardrails 设置内容安全护栏，处理条目时检查消息与回复，命中屏蔽的条目记为失败
+func (s *Service) SetGuardrails(guardrails *guardrail.Pipeline) {
+	s.guardrails = guardrails
+}
+
+// Submit 校验并保存批量任务，条目加入队列后立即返回，由后台工作池处理
+func (s *Service) Submit(ctx context.Context, req *SubmitRequest) (*model.BatchJob, error) {
+	job, items, err := s.newJob(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateJob(job, items); err != nil {
+		return nil, fmt.Errorf("failed to create batch job: %w", err)
+	}
+
+	// 入队失败时条目仍为待处理，由后台任务定期重新入队
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	if err := s.queue.EnqueueBatchItems(ctx, time.Now(), ids...); err != nil {
+		log.Printf("failed to enqueue batch job %d: %v", job.ID, err)
+	}
+
+	return job, nil
+}
+
+// newJob 校验请求并构建任务及条目
+func (s *Service) newJob(req *SubmitRequest) (*model.BatchJob, []*model.BatchItem, error) {
+	name := strings.TrimSpace(req.Name)
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return nil, nil, fmt.Errorf("%w: name exceeds %d characters", ErrInvalidRequest, maxNameLength)
+	}
+	if len(req.Items) == 0 || len(req.Items) > s.config.MaxItems {
+		return nil, nil, fmt.Errorf("%w: items must contain 1-%d requests", ErrInvalidRequest, s.config.MaxItems)
+	}
+
+	modelName := req.Model
+	if modelName == "" {
+		modelName = s.catalog.DefaultModel()
+	}
+	spec, err := s.catalog.Lookup(modelName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	if utf8.RuneCountInString(req.SystemPrompt) > maxSystemPromptLength {
+		return nil, nil, fmt.Errorf("%w: system_prompt exceeds %d characters", ErrInvalidRequest, maxSystemPromptLength)
+	}
+	if req.Temperature != nil && (*req.Temperature <= 0 || *req.Temperature > 2) {
+		return nil, nil, fmt.Errorf("%w: temperature must be in (0, 2]", ErrInvalidRequest)
+	}
+	if req.MaxTokens < 0 || req.MaxTokens > spec.MaxOutputTokens {
+		return nil, nil, fmt.Errorf("%w: max_tokens must be in [0, %d] for model %s", ErrInvalidRequest, spec.MaxOutputTokens, spec.ID)
+	}
+
+	params := model.BatchParams{
+		SystemPrompt: req.SystemPrompt,
+		Temperature:  req.Temperature,
+		MaxTokens:    req.MaxTokens,
+	}
+	if req.ResponseFormat != nil {
+		if _, err := structured.NewOutput(req.ResponseFormat); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+		params.ResponseFormat, err = json.Marshal(req.ResponseFormat)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal response format: %w", err)
+		}
+	}
+
+	items := make([]*model.BatchItem, len(req.Items))
+	for i, item := range req.Items {
+		content := strings.TrimSpace(item.Content)
+		if content == "" || utf8.RuneCountInString(content) > maxContentLength {
+			return nil, nil, fmt.Errorf("%w: item %d content must be 1-%d characters", ErrInvalidRequest, i, maxContentLength)
+		}
+		if utf8.RuneCountInString(item.CustomID) > maxCustomIDLength {
+			return nil, nil, fmt.Errorf("%w: item %d custom_id exceeds %d characters", ErrInvalidRequest, i, maxCustomIDLength)
+		}
+		items[i] = &model.BatchItem{CustomID: item.CustomID, Content: content}
+	}
+
+	job := &model.BatchJob{
+		UserID: req.UserID,
+		Name:   name,
+		Model:  spec.ID,
+		Params: params,
+	}
+	return job, items, nil
+}
+
+// ListJobs 获取用户的任务列表
+func (s *Service) ListJobs(ctx context.Context, userID int64, limit, offset int) (*ListJobsResponse, error) {
+	jobs, err := s.repo.ListJobs(userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list batch jobs: %w", err)
+	}
+	if jobs == nil {
+		jobs = []*model.BatchJob{}
+	}
+
+	total, err := s.repo.CountJobs(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count batch jobs: %w", err)
+	}
+
+	return &ListJobsResponse{
+		Jobs:   jobs,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	}, nil
+}
+
+// GetJob 获取任务状态、进度与用量
+func (s *Service) GetJob(ctx context.Context, userID, jobID int64) (*model.BatchJob, error) {
+	return s.getOwnedJob(userID, jobID)
+}
+
+// CancelJob 取消任务，尚未开始的条目不再处理
+func (s *Service) CancelJob(ctx context.Context, userID, jobID int64) (*model.BatchJob, error) {
+	if _, err := s.getOwnedJob(userID, jobID); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CancelJob(jobID); err != nil {
+		return nil, fmt.Errorf("failed to cancel batch job: %w", err)
+	}
+	return s.getOwnedJob(userID, jobID)
+}
+
+// Results 获取任务及每个条目的结果；任务未完成时未结束的条目也会返回，状态为 pending 或 running
+func (s *Service) Results(ctx context.Context, userID, jobID int64) (*model.BatchJob, []ResultLine, error) {
+	job, err := s.getOwnedJob(userID, jobID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	items, err := s.repo.ListItems(jobID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list batch items: %w", err)
+	}
+
+	lines := make([]ResultLine, len(items))
+	for i, item := range items {
+		lines[i] = ResultLine{
+			Index:    item.Index,
+			CustomID: item.CustomID,
+			Status:   item.Status,
+			Response: item.Response,
+			Error:    item.Error,
+			Attempts: item.Attempts,
+			Model:    item.Model,
+			Usage: llm.Usage{
+				PromptTokens:     item.PromptTokens,
+				CompletionTokens: item.CompletionTokens,
+				TotalTokens:      item.TotalTokens,
+			},
+		}
+		if len(job.Params.ResponseFormat) > 0 && item.Status == model.BatchItemSucceeded {
+			lines[i].Output = json.RawMessage(item.Response)
+		}
+	}
+	return job, lines, nil
+}
+
+// getOwnedJob 获取属于用户的任务，不属于用户时同样返回未找到
+func (s *Service) getOwnedJob(userID, jobID int64) (*model.BatchJob, error) {
+	job, err := s.repo.GetJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.UserID != userID {
+		return nil, model.ErrBatchJobNotFound
+	}
+	return job, nil
+}
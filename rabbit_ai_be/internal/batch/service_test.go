@@ -0,0 +1,440 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+	"rabbit_ai/internal/structured"
+)
+
+// MockBatchRepository 模拟批量任务仓库
+type MockBatchRepository struct {
+	jobs  map[int64]*model.BatchJob
+	items map[int64]*model.BatchItem
+	next  int64
+}
+
+func newMockBatchRepository() *MockBatchRepository {
+	return &MockBatchRepository{jobs: map[int64]*model.BatchJob{}, items: map[int64]*model.BatchItem{}}
+}
+
+func (m *MockBatchRepository) CreateJob(job *model.BatchJob, items []*model.BatchItem) error {
+	m.next++
+	job.ID = m.next
+	job.Status = model.BatchQueued
+	job.TotalItems = len(items)
+	m.jobs[job.ID] = job
+	for i, item := range items {
+		m.next++
+		item.ID = m.next
+		item.JobID = job.ID
+		item.Index = i
+		item.Status = model.BatchItemPending
+		item.AvailableAt = time.Now()
+		m.items[item.ID] = item
+	}
+	return nil
+}
+
+func (m *MockBatchRepository) GetJob(id int64) (*model.BatchJob, error) {
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, model.ErrBatchJobNotFound
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (m *MockBatchRepository) ListJobs(userID int64, limit, offset int) ([]*model.BatchJob, error) {
+	var jobs []*model.BatchJob
+	for _, job := range m.jobs {
+		if job.UserID == userID {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func (m *MockBatchRepository) CountJobs(userID int64) (int, error) {
+	jobs, _ := m.ListJobs(userID, 0, 0)
+	return len(jobs), nil
+}
+
+func (m *MockBatchRepository) CancelJob(id int64) error {
+	job, ok := m.jobs[id]
+	if !ok {
+		return model.ErrBatchJobNotFound
+	}
+	if job.Status != model.BatchQueued && job.Status != model.BatchRunning {
+		return model.ErrBatchJobFinished
+	}
+	job.Status = model.BatchCancelled
+	for _, item := range m.items {
+		if item.JobID == id && item.Status == model.BatchItemPending {
+			item.Status = model.BatchItemCancelled
+		}
+	}
+	return nil
+}
+
+func (m *MockBatchRepository) ListItems(jobID int64) ([]*model.BatchItem, error) {
+	items := make([]*model.BatchItem, m.jobs[jobID].TotalItems)
+	for _, item := range m.items {
+		if item.JobID == jobID {
+			items[item.Index] = item
+		}
+	}
+	return items, nil
+}
+
+func (m *MockBatchRepository) ClaimItem(id int64, staleAfter time.Duration) (*model.BatchItem, error) {
+	item, ok := m.items[id]
+	if !ok || item.Status != model.BatchItemPending {
+		return nil, nil
+	}
+	item.Status = model.BatchItemRunning
+	item.Attempts++
+	if job := m.jobs[item.JobID]; job.Status == model.BatchQueued {
+		job.Status = model.BatchRunning
+	}
+	copied := *item
+	return &copied, nil
+}
+
+func (m *MockBatchRepository) RetryItem(id int64, errMsg string, availableAt time.Time) error {
+	item := m.items[id]
+	item.Status = model.BatchItemPending
+	item.Error = errMsg
+	item.AvailableAt = availableAt
+	return nil
+}
+
+func (m *MockBatchRepository) FinishItem(item *model.BatchItem) error {
+	stored, ok := m.items[item.ID]
+	if !ok || stored.Status != model.BatchItemRunning {
+		return model.ErrBatchItemNotFound
+	}
+	*stored = *item
+
+	job := m.jobs[item.JobID]
+	if item.Status == model.BatchItemSucceeded {
+		job.SucceededItems++
+	} else {
+		job.FailedItems++
+	}
+	job.PromptTokens += item.PromptTokens
+	job.CompletionTokens += item.CompletionTokens
+	job.TotalTokens += item.TotalTokens
+	if job.Status != model.BatchCancelled && job.SucceededItems+job.FailedItems >= job.TotalItems {
+		job.Status = model.BatchCompleted
+	}
+	return nil
+}
+
+func (m *MockBatchRepository) ListRecoverable(staleAfter time.Duration, limit int) ([]*model.BatchItem, error) {
+	var items []*model.BatchItem
+	for _, item := range m.items {
+		if item.Status == model.BatchItemPending {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// memoryQueue 内存队列，按加入顺序取出已到处理时间的条目
+type memoryQueue struct {
+	ids       []int64
+	ready     map[int64]time.Time
+	rateCalls int
+	rateWaits []time.Duration // 依次返回的限流等待时间
+}
+
+func newMemoryQueue() *memoryQueue {
+	return &memoryQueue{ready: map[int64]time.Time{}}
+}
+
+func (q *memoryQueue) EnqueueBatchItems(ctx context.Context, availableAt time.Time, itemIDs ...int64) error {
+	for _, id := range itemIDs {
+		if _, ok := q.ready[id]; ok {
+			continue
+		}
+		q.ids = append(q.ids, id)
+		q.ready[id] = availableAt
+	}
+	return nil
+}
+
+func (q *memoryQueue) DequeueBatchItem(ctx context.Context) (int64, bool, error) {
+	for i, id := range q.ids {
+		if !q.ready[id].After(time.Now()) {
+			q.ids = append(q.ids[:i], q.ids[i+1:]...)
+			delete(q.ready, id)
+			return id, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func (q *memoryQueue) AcquireBatchRate(ctx context.Context, limit int) (time.Duration, error) {
+	q.rateCalls++
+	if len(q.rateWaits) > 0 {
+		wait := q.rateWaits[0]
+		q.rateWaits = q.rateWaits[1:]
+		return wait, nil
+	}
+	return 0, nil
+}
+
+// transientError 可重试的上游错误
+type transientError struct{}
+
+func (transientError) Error() string  { return "upstream rate limited" }
+func (transientError) Failover() bool { return true }
+
+// stubProvider 依次返回预设回复或错误的Provider
+type stubProvider struct {
+	replies  []string
+	errs     []error
+	requests []llm.ChatRequest
+}
+
+func (p *stubProvider) Name() string { return "stub" }
+
+func (p *stubProvider) ChatCompletion(ctx context.Context, request llm.ChatRequest) (*llm.ChatResponse, error) {
+	i := len(p.requests)
+	p.requests = append(p.requests, request)
+	if i < len(p.errs) && p.errs[i] != nil {
+		return nil, p.errs[i]
+	}
+	return &llm.ChatResponse{
+		Model:   request.Model,
+		Message: llm.Message{Role: llm.RoleAssistant, Content: p.replies[i]},
+		Usage:   llm.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}, nil
+}
+
+func (p *stubProvider) ChatCompletionStream(ctx context.Context, request llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p *stubProvider) ListModels(ctx context.Context) ([]llm.ModelInfo, error) {
+	return nil, nil
+}
+
+func newTestService(provider *stubProvider) (*Service, *MockBatchRepository, *memoryQueue) {
+	repo := newMockBatchRepository()
+	queue := newMemoryQueue()
+	service := NewService(repo, queue, provider, llm.DefaultCatalog(), Config{MaxItems: 3, RateLimit: 10})
+	return service, repo, queue
+}
+
+// drain 处理队列中所有已到处理时间的条目
+func drain(t *testing.T, service *Service) {
+	t.Helper()
+	for {
+		processed, err := service.ProcessNext(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to process item: %v", err)
+		}
+		if !processed {
+			return
+		}
+	}
+}
+
+func TestSubmit_Validation(t *testing.T) {
+	service, _, _ := newTestService(&stubProvider{})
+	temperature := 3.0
+
+	invalid := map[string]*SubmitRequest{
+		"no items":        {},
+		"too many items":  {Items: []SubmitItem{{Content: "1"}, {Content: "2"}, {Content: "3"}, {Content: "4"}}},
+		"empty content":   {Items: []SubmitItem{{Content: "  "}}},
+		"unknown model":   {Model: "gpt-unknown", Items: []SubmitItem{{Content: "你好"}}},
+		"temperature":     {Temperature: &temperature, Items: []SubmitItem{{Content: "你好"}}},
+		"max tokens":      {MaxTokens: 1 << 30, Items: []SubmitItem{{Content: "你好"}}},
+		"response format": {ResponseFormat: &structured.ResponseFormat{Type: "text"}, Items: []SubmitItem{{Content: "你好"}}},
+	}
+	for name, req := range invalid {
+		if _, err := service.Submit(context.Background(), req); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("%s: expected ErrInvalidRequest, got %v", name, err)
+		}
+	}
+}
+
+func TestProcessNext(t *testing.T) {
+	provider := &stubProvider{replies: []string{"商品A的描述", "商品B的描述"}}
+	service, _, queue := newTestService(provider)
+	ctx := context.Background()
+
+	job, err := service.Submit(ctx, &SubmitRequest{
+		UserID:       1,
+		Name:         "商品描述",
+		SystemPrompt: "你是文案助手",
+		Items:        []SubmitItem{{CustomID: "sku-a", Content: "商品A"}, {CustomID: "sku-b", Content: " 商品B "}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to submit: %v", err)
+	}
+	if job.Status != model.BatchQueued || job.Model != llm.DefaultCatalog().DefaultModel() || len(queue.ids) != 2 {
+		t.Fatalf("Expected queued job with 2 enqueued items, got %+v, queue %v", job, queue.ids)
+	}
+
+	drain(t, service)
+
+	job, _ = service.GetJob(ctx, 1, job.ID)
+	if job.Status != model.BatchCompleted || job.SucceededItems != 2 || job.TotalTokens != 30 {
+		t.Errorf("Expected completed job with summed usage, got %+v", job)
+	}
+	if queue.rateCalls != 2 {
+		t.Errorf("Expected rate limit checked per call, got %d", queue.rateCalls)
+	}
+	request := provider.requests[1]
+	if len(request.Messages) != 2 || request.Messages[0].Content != "你是文案助手" || request.Messages[1].Content != "商品B" {
+		t.Errorf("Expected system prompt and trimmed content, got %+v", request.Messages)
+	}
+
+	_, lines, err := service.Results(ctx, 1, job.ID)
+	if err != nil {
+		t.Fatalf("Failed to get results: %v", err)
+	}
+	if len(lines) != 2 || lines[1].CustomID != "sku-b" || lines[1].Response != "商品B的描述" || lines[1].Usage.TotalTokens != 15 || lines[1].Attempts != 1 {
+		t.Errorf("Unexpected results: %+v", lines)
+	}
+
+	// 其他用户不可见
+	if _, err := service.GetJob(ctx, 2, job.ID); !errors.Is(err, model.ErrBatchJobNotFound) {
+		t.Errorf("Expected not found for other user, got %v", err)
+	}
+	if _, err := service.CancelJob(ctx, 1, job.ID); !errors.Is(err, model.ErrBatchJobFinished) {
+		t.Errorf("Expected finished job not to be cancellable, got %v", err)
+	}
+}
+
+func TestProcessNext_Retry(t *testing.T) {
+	provider := &stubProvider{
+		replies: []string{"", "", "", "成功"},
+		errs:    []error{transientError{}, transientError{}, transientError{}, nil},
+	}
+	service, repo, queue := newTestService(provider)
+	ctx := context.Background()
+
+	job, _ := service.Submit(ctx, &SubmitRequest{UserID: 1, Items: []SubmitItem{{Content: "你好"}}})
+	itemID := queue.ids[0]
+
+	// 每次失败后推迟重新入队，提前到期以继续处理
+	for attempt := 1; attempt < DefaultMaxAttempts; attempt++ {
+		drain(t, service)
+		item := repo.items[itemID]
+		if item.Status != model.BatchItemPending || item.Attempts != attempt || !item.AvailableAt.After(time.Now()) {
+			t.Fatalf("Attempt %d: expected delayed retry, got %+v", attempt, item)
+		}
+		queue.ready[itemID] = time.Now()
+	}
+	drain(t, service)
+
+	item := repo.items[itemID]
+	if item.Status != model.BatchItemFailed || item.Attempts != DefaultMaxAttempts || item.Error != "upstream rate limited" {
+		t.Errorf("Expected item failed after max attempts, got %+v", item)
+	}
+	if job, _ = service.GetJob(ctx, 1, job.ID); job.Status != model.BatchCompleted || job.FailedItems != 1 {
+		t.Errorf("Expected completed job with failed item, got %+v", job)
+	}
+
+	// 不可重试的错误直接失败
+	provider = &stubProvider{errs: []error{errors.New("invalid api key")}}
+	service, repo, queue = newTestService(provider)
+	service.Submit(ctx, &SubmitRequest{UserID: 1, Items: []SubmitItem{{Content: "你好"}}})
+	itemID = queue.ids[0]
+	drain(t, service)
+	if item := repo.items[itemID]; item.Status != model.BatchItemFailed || item.Attempts != 1 {
+		t.Errorf("Expected permanent error to fail immediately, got %+v", item)
+	}
+}
+
+func TestProcessNext_RateLimit(t *testing.T) {
+	service, _, queue := newTestService(&stubProvider{replies: []string{"好的"}})
+	queue.rateWaits = []time.Duration{10 * time.Millisecond}
+	ctx := context.Background()
+
+	job, _ := service.Submit(ctx, &SubmitRequest{UserID: 1, Items: []SubmitItem{{Content: "你好"}}})
+	drain(t, service)
+
+	if queue.rateCalls != 2 {
+		t.Errorf("Expected to wait for the next rate window, got %d rate checks", queue.rateCalls)
+	}
+	if job, _ = service.GetJob(ctx, 1, job.ID); job.SucceededItems != 1 {
+		t.Errorf("Expected item processed after waiting, got %+v", job)
+	}
+}
+
+func TestProcessNext_StructuredOutput(t *testing.T) {
+	provider := &stubProvider{replies: []string{`{"title": 1}`, "```json\n{\"title\": \"无线耳机\"}\n```"}}
+	service, _, _ := newTestService(provider)
+	ctx := context.Background()
+
+	job, err := service.Submit(ctx, &SubmitRequest{
+		UserID: 1,
+		ResponseFormat: &structured.ResponseFormat{
+			Type:   structured.FormatJSONSchema,
+			Schema: json.RawMessage(`{"type":"object","properties":{"title":{"type":"string"}},"required":["title"]}`),
+		},
+		Items: []SubmitItem{{Content: "耳机"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to submit: %v", err)
+	}
+	drain(t, service)
+
+	_, lines, _ := service.Results(ctx, 1, job.ID)
+	if len(lines) != 1 || string(lines[0].Output) != `{"title": "无线耳机"}` || lines[0].Usage.TotalTokens != 30 {
+		t.Errorf("Expected validated output with usage of both attempts, got %+v", lines)
+	}
+	if !strings.Contains(provider.requests[0].Messages[0].Content, `"title"`) {
+		t.Errorf("Expected schema instruction in system prompt, got %+v", provider.requests[0].Messages)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	service, repo, _ := newTestService(&stubProvider{replies: []string{"好的"}})
+	ctx := context.Background()
+
+	job, _ := service.Submit(ctx, &SubmitRequest{UserID: 1, Items: []SubmitItem{{Content: "一"}, {Content: "二"}}})
+	job, err := service.CancelJob(ctx, 1, job.ID)
+	if err != nil || job.Status != model.BatchCancelled {
+		t.Fatalf("Expected cancelled job, got %+v, %v", job, err)
+	}
+
+	drain(t, service)
+	items, _ := repo.ListItems(job.ID)
+	for _, item := range items {
+		if item.Status != model.BatchItemCancelled || item.Attempts != 0 {
+			t.Errorf("Expected cancelled item not processed, got %+v", item)
+		}
+	}
+}
+
+func TestRecover(t *testing.T) {
+	service, _, queue := newTestService(&stubProvider{replies: []string{"好的"}})
+	ctx := context.Background()
+
+	service.Submit(ctx, &SubmitRequest{UserID: 1, Items: []SubmitItem{{Content: "你好"}}})
+	queue.ids = nil
+	queue.ready = map[int64]time.Time{}
+
+	count, err := service.Recover(ctx)
+	if err != nil || count != 1 || len(queue.ids) != 1 {
+		t.Fatalf("Expected pending item re-enqueued, got %d, %v, queue %v", count, err, queue.ids)
+	}
+	// 已在队列中的条目不重复加入
+	service.Recover(ctx)
+	if len(queue.ids) != 1 {
+		t.Errorf("Expected no duplicate queue entries, got %v", queue.ids)
+	}
+}
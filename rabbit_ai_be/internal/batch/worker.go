@@ -0,0 +1,300 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"rabbit_ai/internal/guardrail"
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+	"rabbit_ai/internal/structured"
+)
+
+// 工作池默认参数
+const (
+	DefaultWorkers         = 4
+	DefaultMaxAttempts     = 3
+	DefaultRateLimit       = 60
+	DefaultPollInterval    = time.Second
+	DefaultStaleAfter      = 10 * time.Minute
+	DefaultRecoverInterval = time.Minute
+	DefaultItemTimeout     = 2 * time.Minute
+	retryInitialBackoff    = 5 * time.Second
+	retryMaxBackoff        = 2 * time.Minute
+	recoverBatchSize       = 1000
+)
+
+// Run 启动工作池处理队列中的条目，并定期将待处理和中断的条目重新入队，直到ctx结束
+//
+// 多实例部署时各实例从同一Redis队列取条目，领取时以数据库状态为准，同一条目不会被重复处理。
+// 实例退出时正在处理的条目在 StaleAfter 后被重新处理。
+func (s *Service) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx)
+		}()
+	}
+
+	s.recoverLoop(ctx)
+	wg.Wait()
+}
+
+// work 循环处理队列中的条目
+func (s *Service) work(ctx context.Context) {
+	for {
+		processed, err := s.ProcessNext(ctx)
+		if err != nil {
+			log.Printf("failed to process batch item: %v", err)
+		}
+		if processed {
+			continue
+		}
+
+		timer := time.NewTimer(s.config.PollInterval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// recoverLoop 定期将待处理和中断的条目重新入队，弥补入队失败、Redis数据丢失或实例异常退出
+func (s *Service) recoverLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.config.RecoverInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Recover(ctx); err != nil {
+			log.Printf("failed to recover batch items: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Recover 将待处理和中断的条目重新入队，已在队列中的条目不受影响，返回处理的条目数
+func (s *Service) Recover(ctx context.Context) (int, error) {
+	items, err := s.repo.ListRecoverable(s.config.StaleAfter, recoverBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list recoverable items: %w", err)
+	}
+	for _, item := range items {
+		if err := s.queue.EnqueueBatchItems(ctx, item.AvailableAt, item.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(items), nil
+}
+
+// ProcessNext 从队列取出并处理一个条目，队列为空时返回 false
+func (s *Service) ProcessNext(ctx context.Context) (bool, error) {
+	id, ok, err := s.queue.DequeueBatchItem(ctx)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	item, err := s.repo.ClaimItem(id, s.config.StaleAfter)
+	if err != nil {
+		return true, fmt.Errorf("failed to claim batch item %d: %w", id, err)
+	}
+	if item == nil {
+		// 已被其他实例领取、已结束或任务已取消
+		return true, nil
+	}
+
+	if err := s.process(ctx, item); err != nil {
+		return true, fmt.Errorf("batch item %d: %w", item.ID, err)
+	}
+	return true, nil
+}
+
+// process 调用模型处理条目并保存结果；可重试的错误在退避后重新入队
+func (s *Service) process(ctx context.Context, item *model.BatchItem) error {
+	job, err := s.repo.GetJob(item.JobID)
+	if err != nil {
+		return fmt.Errorf("failed to get batch job: %w", err)
+	}
+
+	// 中断后重新领取的条目也受尝试次数限制
+	if item.Attempts > s.config.MaxAttempts {
+		item.Status = model.BatchItemFailed
+		if item.Error == "" {
+			item.Error = "processing interrupted"
+		}
+		return s.finish(item)
+	}
+
+	if s.checkContent(ctx, guardrail.StageInput, item.Content, nil).Blocked() {
+		item.Status = model.BatchItemFailed
+		item.Error = "content blocked by guardrail: input"
+		return s.finish(item)
+	}
+
+	if err := s.waitRate(ctx); err != nil {
+		return err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, s.config.ItemTimeout)
+	defer cancel()
+	response, err := s.generate(callCtx, job, item)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var outputErr *structured.OutputError
+		if errors.As(err, &outputErr) {
+			item.Response = outputErr.Raw
+			setUsage(item, outputErr.Usage)
+		} else if llm.ShouldFailover(err) && item.Attempts < s.config.MaxAttempts {
+			return s.retry(ctx, item, err)
+		}
+		item.Status = model.BatchItemFailed
+		item.Error = model.TruncateError(err.Error())
+		return s.finish(item)
+	}
+
+	item.Model = response.Model
+	setUsage(item, response.Usage)
+	content := response.GetContent()
+	if s.checkContent(ctx, guardrail.StageOutput, content, response.Sensitive).Blocked() {
+		item.Status = model.BatchItemFailed
+		item.Error = "content blocked by guardrail: output"
+		return s.finish(item)
+	}
+
+	item.Status = model.BatchItemSucceeded
+	item.Response = content
+	item.Error = ""
+	return s.finish(item)
+}
+
+// generate 按任务参数调用模型；指定结构化输出时回复为校验通过的JSON，用量为所有尝试之和
+func (s *Service) generate(ctx context.Context, job *model.BatchJob, item *model.BatchItem) (*llm.ChatResponse, error) {
+	request := llm.ChatRequest{
+		Model:       job.Model,
+		Temperature: DefaultTemperature,
+		MaxTokens:   DefaultMaxTokens,
+		User:        fmt.Sprintf("user_%d", job.UserID),
+	}
+	if job.Params.Temperature != nil {
+		request.Temperature = *job.Params.Temperature
+	}
+	if job.Params.MaxTokens > 0 {
+		request.MaxTokens = job.Params.MaxTokens
+	}
+	if job.Params.SystemPrompt != "" {
+		request.Messages = append(request.Messages, llm.Message{Role: llm.RoleSystem, Content: job.Params.SystemPrompt})
+	}
+	request.Messages = append(request.Messages, llm.Message{Role: llm.RoleUser, Content: item.Content})
+
+	if len(job.Params.ResponseFormat) == 0 {
+		return s.provider.ChatCompletion(ctx, request)
+	}
+
+	var format structured.ResponseFormat
+	if err := json.Unmarshal(job.Params.ResponseFormat, &format); err != nil {
+		return nil, fmt.Errorf("invalid response format: %w", err)
+	}
+	output, err := structured.NewOutput(&format)
+	if err != nil {
+		return nil, err
+	}
+	result, err := output.Generate(ctx, s.provider, request)
+	if err != nil {
+		return nil, err
+	}
+	result.Response.Message.Content = string(result.Value)
+	return result.Response, nil
+}
+
+// waitRate 等待全局限流额度；限流状态读取失败时不阻塞处理
+func (s *Service) waitRate(ctx context.Context) error {
+	if s.config.RateLimit == 0 {
+		return nil
+	}
+	for {
+		wait, err := s.queue.AcquireBatchRate(ctx, s.config.RateLimit)
+		if err != nil {
+			log.Printf("failed to acquire batch rate: %v", err)
+			return nil
+		}
+		if wait <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// retry 按指数退避推迟条目并重新入队
+func (s *Service) retry(ctx context.Context, item *model.BatchItem, cause error) error {
+	availableAt := time.Now().Add(retryBackoff(item.Attempts))
+	if err := s.repo.RetryItem(item.ID, model.TruncateError(cause.Error()), availableAt); err != nil {
+		return fmt.Errorf("failed to schedule retry: %w", err)
+	}
+	if err := s.queue.EnqueueBatchItems(ctx, availableAt, item.ID); err != nil {
+		// 条目已是待处理状态，由后台任务重新入队
+		log.Printf("failed to enqueue retry of batch item %d: %v", item.ID, err)
+	}
+	return nil
+}
+
+// finish 保存条目结果
+func (s *Service) finish(item *model.BatchItem) error {
+	if err := s.repo.FinishItem(item); err != nil {
+		return fmt.Errorf("failed to save result: %w", err)
+	}
+	return nil
+}
+
+// checkContent 执行护栏检查，护栏出错时放行
+func (s *Service) checkContent(ctx context.Context, stage guardrail.Stage, content string, sensitive *llm.SensitiveFlags) guardrail.Verdict {
+	verdict, err := s.guardrails.Check(ctx, guardrail.Input{
+		Stage:     stage,
+		Content:   content,
+		Sensitive: sensitive,
+	})
+	if err != nil {
+		log.Printf("guardrail check failed: %v", err)
+		return guardrail.Verdict{}
+	}
+	return verdict
+}
+
+// retryBackoff 第attempt次失败后的等待时间：指数增长并受上限限制，在 [d/2, d) 之间随机
+func retryBackoff(attempt int) time.Duration {
+	d := retryInitialBackoff << (attempt - 1)
+	if attempt > 8 || d > retryMaxBackoff {
+		d = retryMaxBackoff
+	}
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int64N(half+1))
+}
+
+// setUsage 记录条目的用量
+func setUsage(item *model.BatchItem, usage llm.Usage) {
+	item.PromptTokens = usage.PromptTokens
+	item.CompletionTokens = usage.CompletionTokens
+	item.TotalTokens = usage.TotalTokens
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 批量任务队列与限流键
+const (
+	BatchQueueKey      = "batch_queue" // 有序集合，成员为条目ID，分值为最早可处理时间（毫秒）
+	BatchRateKeyPrefix = "batch_rate:"
+
+	batchRateWindow = time.Minute
+)
+
+// dequeueBatchScript 原子地取出一个已到处理时间的条目，多个实例不会取到同一条目
+var dequeueBatchScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 1)
+if #ids == 0 then
+	return false
+end
+redis.call('ZREM', KEYS[1], ids[1])
+return ids[1]
+`)
+
+// EnqueueBatchItems 将条目加入批量任务队列，availableAt 之前不会被取出；已在队列中的条目保持原有时间
+func (c *ConversationCache) EnqueueBatchItems(ctx context.Context, availableAt time.Time, itemIDs ...int64) error {
+	if len(itemIDs) == 0 {
+		return nil
+	}
+
+	members := make([]redis.Z, len(itemIDs))
+	for i, id := range itemIDs {
+		members[i] = redis.Z{Score: float64(availableAt.UnixMilli()), Member: id}
+	}
+
+	err := c.client.ZAddNX(ctx, BatchQueueKey, members...).Err()
+	if err != nil {
+		return fmt.Errorf("failed to enqueue batch items: %w", err)
+	}
+
+	return nil
+}
+
+// DequeueBatchItem 取出一个已到处理时间的条目，队列为空时返回 false
+func (c *ConversationCache) DequeueBatchItem(ctx context.Context) (int64, bool, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	result, err := dequeueBatchScript.Run(ctx, c.client, []string{BatchQueueKey}, now).Text()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to dequeue batch item: %w", err)
+	}
+
+	id, err := strconv.ParseInt(result, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid batch item id %q: %w", result, err)
+	}
+
+	return id, true, nil
+}
+
+// AcquireBatchRate 在所有实例共享的每分钟窗口内占用一次调用额度；
+// 额度已用完时返回距下一个窗口的等待时间
+func (c *ConversationCache) AcquireBatchRate(ctx context.Context, limit int) (time.Duration, error) {
+	now := time.Now()
+	window := now.Truncate(batchRateWindow)
+	key := fmt.Sprintf("%s%d", BatchRateKeyPrefix, window.Unix())
+
+	count, err := c.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to acquire batch rate: %w", err)
+	}
+	if count == 1 {
+		c.client.Expire(ctx, key, 2*batchRateWindow)
+	}
+
+	if count > int64(limit) {
+		return window.Add(batchRateWindow).Sub(now), nil
+	}
+	return 0, nil
+}
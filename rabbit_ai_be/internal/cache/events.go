@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+)
+
+// UserEventChannelPrefix 用户事件频道前缀，服务端异步产生的变更（如对话标题）经此推送给客户端
+const UserEventChannelPrefix = "user_events:"
+
+// getUserEventChannel 生成用户事件频道名
+func getUserEventChannel(userID int64) string {
+	return fmt.Sprintf("%s%d", UserEventChannelPrefix, userID)
+}
+
+// PublishUserEvent 向用户事件频道发布事件，payload 通常为JSON
+func (c *ConversationCache) PublishUserEvent(ctx context.Context, userID int64, payload []byte) error {
+	err := c.client.Publish(ctx, getUserEventChannel(userID), payload).Err()
+	if err != nil {
+		return fmt.Errorf("failed to publish user event: %w", err)
+	}
+
+	return nil
+}
+
+// SubscribeUserEvents 订阅用户事件并逐个回调，直到ctx结束
+//
+// 订阅确认后调用 ready（可为nil），调用方可据此在确认后再向客户端输出响应头。
+func (c *ConversationCache) SubscribeUserEvents(ctx context.Context, userID int64, ready func(), handler func(payload string)) error {
+	pubsub := c.client.Subscribe(ctx, getUserEventChannel(userID))
+	defer pubsub.Close()
+
+	// 等待订阅确认，连接失败时直接返回
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe user events: %w", err)
+	}
+	if ready != nil {
+		ready()
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			handler(msg.Payload)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 生成任务登记键与取消信号频道
+const (
+	GenerationKeyPrefix     = "generation:"
+	GenerationCancelChannel = "generation_cancel"
+
+	GenerationTTL = 30 * time.Minute // 生成任务登记的最长有效期，防止实例异常退出后残留
+)
+
+// getGenerationKey 生成生成任务登记键
+func getGenerationKey(conversationID int64, generationID string) string {
+	return fmt.Sprintf("%s%d:%s", GenerationKeyPrefix, conversationID, generationID)
+}
+
+// ClaimGeneration 登记正在进行的生成任务，供其他实例判断任务是否存在
+//
+// 使用 SET NX 占用登记键，任务已在某个实例上登记时返回false。
+func (c *ConversationCache) ClaimGeneration(ctx context.Context, conversationID int64, generationID string) (bool, error) {
+	key := getGenerationKey(conversationID, generationID)
+
+	claimed, err := c.client.SetNX(ctx, key, 1, GenerationTTL).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim generation: %w", err)
+	}
+
+	return claimed, nil
+}
+
+// GenerationExists 检查生成任务是否在某个实例上进行中
+func (c *ConversationCache) GenerationExists(ctx context.Context, conversationID int64, generationID string) (bool, error) {
+	key := getGenerationKey(conversationID, generationID)
+
+	count, err := c.client.Exists(ctx, key).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check generation: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// DeleteGeneration 删除生成任务登记
+func (c *ConversationCache) DeleteGeneration(ctx context.Context, conversationID int64, generationID string) error {
+	key := getGenerationKey(conversationID, generationID)
+	return c.client.Del(ctx, key).Err()
+}
+
+// PublishGenerationCancel 广播取消生成任务的信号
+func (c *ConversationCache) PublishGenerationCancel(ctx context.Context, conversationID int64, generationID string) error {
+	payload := fmt.Sprintf("%d:%s", conversationID, generationID)
+
+	err := c.client.Publish(ctx, GenerationCancelChannel, payload).Err()
+	if err != nil {
+		return fmt.Errorf("failed to publish generation cancel: %w", err)
+	}
+
+	return nil
+}
+
+// SubscribeGenerationCancels 订阅取消信号并逐个回调，直到ctx结束
+func (c *ConversationCache) SubscribeGenerationCancels(ctx context.Context, handler func(conversationID int64, generationID string)) error {
+	pubsub := c.client.Subscribe(ctx, GenerationCancelChannel)
+	defer pubsub.Close()
+
+	// 等待订阅确认，连接失败时直接返回
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe generation cancels: %w", err)
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			idStr, generationID, found := strings.Cut(msg.Payload, ":")
+			conversationID, err := strconv.ParseInt(idStr, 10, 64)
+			if !found || err != nil {
+				continue
+			}
+			handler(conversationID, generationID)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ResponseKeyPrefix AI响应缓存键前缀
+const ResponseKeyPrefix = "ai_response:"
+
+// getResponseKey 生成AI响应缓存键
+func getResponseKey(key string) string {
+	return ResponseKeyPrefix + key
+}
+
+// SetResponse 缓存AI响应
+func (c *ConversationCache) SetResponse(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	err := c.client.Set(ctx, getResponseKey(key), data, ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to set response cache: %w", err)
+	}
+
+	return nil
+}
+
+// GetResponse 获取缓存的AI响应，未命中时返回nil
+func (c *ConversationCache) GetResponse(ctx context.Context, key string) ([]byte, error) {
+	data, err := c.client.Get(ctx, getResponseKey(key)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil // 缓存未命中
+		}
+		return nil, fmt.Errorf("failed to get response from cache: %w", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,234 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+)
+
+// 消息树相关错误
+var (
+	ErrNotUserMessage = errors.New("message is not a user message")
+	ErrNoReply        = errors.New("branch has no assistant reply")
+)
+
+// Branch 对话分支，以叶子消息（最终的AI回复）标识
+type Branch struct {
+	LeafID        int64          `json:"leaf_id"`
+	ForkMessageID int64          `json:"fork_message_id"` // 与当前分支分叉后的第一条消息ID，当前分支为0
+	Length        int            `json:"length"`          // 分支路径上的消息数量
+	Leaf          *model.Message `json:"leaf"`
+	Active        bool           `json:"active"`
+}
+
+// ListBranchesRequest 获取对话分支请求
+type ListBranchesRequest struct {
+	ConversationID int64 `json:"conversation_id"`
+	UserID         int64 `json:"user_id"`
+}
+
+// ListBranchesResponse 获取对话分支响应
+type ListBranchesResponse struct {
+	ActiveLeafID int64     `json:"active_leaf_id"`
+	Branches     []*Branch `json:"branches"` // 按叶子消息创建顺序
+}
+
+// SwitchBranchRequest 切换分支请求
+type SwitchBranchRequest struct {
+	ConversationID int64 `json:"conversation_id"`
+	UserID         int64 `json:"user_id"`
+	MessageID      int64 `json:"message_id"` // 切换到经过该消息的分支
+}
+
+// SwitchBranchResponse 切换分支响应
+type SwitchBranchResponse struct {
+	Conversation *model.Conversation `json:"conversation"`
+	Messages     []*model.Message    `json:"messages"` // 切换后的当前分支路径
+}
+
+// ListBranches 获取对话的全部分支
+func (s *Service) ListBranches(ctx context.Context, req *ListBranchesRequest) (*ListBranchesResponse, error) {
+	conversation, err := s.getOwnedConversation(req.UserID, req.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.messageRepo.GetConversationMessages(req.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation messages: %w", err)
+	}
+
+	tree := newMessageTree(messages)
+	onActivePath := make(map[int64]bool)
+	for _, msg := range tree.path(conversation.ActiveLeafID) {
+		onActivePath[msg.ID] = true
+	}
+
+	branches := make([]*Branch, 0)
+	for _, leaf := range tree.replyLeaves(0) {
+		path := tree.path(leaf.ID)
+		branch := &Branch{
+			LeafID: leaf.ID,
+			Length: len(path),
+			Leaf:   leaf,
+			Active: leaf.ID == conversation.ActiveLeafID,
+		}
+		for _, msg := range path {
+			if !onActivePath[msg.ID] {
+				branch.ForkMessageID = msg.ID
+				break
+			}
+		}
+		branches = append(branches, branch)
+	}
+
+	return &ListBranchesResponse{
+		ActiveLeafID: conversation.ActiveLeafID,
+		Branches:     branches,
+	}, nil
+}
+
+// SwitchBranch 切换当前分支
+//
+// MessageID 可以是任意消息，当前分支切换为经过该消息的分支中最新的一条（叶子为最新的AI回复）。
+func (s *Service) SwitchBranch(ctx context.Context, req *SwitchBranchRequest) (*SwitchBranchResponse, error) {
+	conversation, err := s.getOwnedConversation(req.UserID, req.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.messageRepo.GetConversationMessages(req.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation messages: %w", err)
+	}
+
+	tree := newMessageTree(messages)
+	if _, ok := tree.byID[req.MessageID]; !ok {
+		return nil, fmt.Errorf("%w: %d", model.ErrMessageNotFound, req.MessageID)
+	}
+	leaves := tree.replyLeaves(req.MessageID)
+	if len(leaves) == 0 {
+		return nil, ErrNoReply
+	}
+	leaf := leaves[len(leaves)-1]
+
+	if conversation.ActiveLeafID != leaf.ID {
+		conversation.ActiveLeafID = leaf.ID
+		if err := s.conversationRepo.Update(conversation); err != nil {
+			return nil, fmt.Errorf("failed to update conversation: %w", err)
+		}
+
+		// 使相关缓存失效
+		err = s.conversationCache.InvalidateConversationCache(ctx, req.ConversationID)
+		if err != nil {
+			fmt.Printf("failed to invalidate conversation cache: %v\n", err)
+		}
+		err = s.conversationCache.InvalidateUserCache(ctx, req.UserID)
+		if err != nil {
+			fmt.Printf("failed to invalidate user cache: %v\n", err)
+		}
+	}
+
+	return &SwitchBranchResponse{
+		Conversation: conversation,
+		Messages:     tree.path(leaf.ID),
+	}, nil
+}
+
+// messageTree 按 parent_id 组织的对话消息树
+type messageTree struct {
+	byID     map[int64]*model.Message
+	children map[int64][]*model.Message // 父消息ID -> 子消息（按创建顺序），根消息的父ID为0
+}
+
+// newMessageTree 由按创建顺序排列的消息构建消息树
+func newMessageTree(messages []*model.Message) *messageTree {
+	tree := &messageTree{
+		byID:     make(map[int64]*model.Message, len(messages)),
+		children: make(map[int64][]*model.Message),
+	}
+	for _, msg := range messages {
+		tree.byID[msg.ID] = msg
+		tree.children[msg.ParentID] = append(tree.children[msg.ParentID], msg)
+	}
+	return tree
+}
+
+// path 返回从根到 leafID 的消息路径，leafID 不存在时返回空
+func (t *messageTree) path(leafID int64) []*model.Message {
+	var path []*model.Message
+	for id := leafID; id != 0 && len(path) <= len(t.byID); {
+		msg, ok := t.byID[id]
+		if !ok {
+			break
+		}
+		path = append(path, msg)
+		id = msg.ParentID
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// replyLeaves 返回以 rootID 为根的子树（0表示整棵树）中以最终AI回复结尾的叶子，按创建顺序
+//
+// 未得到回复的用户消息、工具调用中断留下的中间消息、被屏蔽的回复不构成分支。
+func (t *messageTree) replyLeaves(rootID int64) []*model.Message {
+	var leaves []*model.Message
+	var visit func(msg *model.Message)
+	visit = func(msg *model.Message) {
+		children := t.children[msg.ID]
+		if len(children) == 0 && msg.Role == "assistant" && len(msg.ToolCalls) == 0 && msg.Moderation != model.ModerationBlocked {
+			leaves = append(leaves, msg)
+		}
+		for _, child := range children {
+			visit(child)
+		}
+	}
+	if rootID == 0 {
+		for _, msg := range t.children[0] {
+			visit(msg)
+		}
+	} else if msg, ok := t.byID[rootID]; ok {
+		visit(msg)
+	}
+
+	// 按ID排序即按创建顺序
+	sort.Slice(leaves, func(i, j int) bool { return leaves[i].ID < leaves[j].ID })
+	return leaves
+}
+
+// turnOf 返回消息所在轮次的用户消息：用户消息为其本身，回复消息沿 parent_id 向上查找
+func (t *messageTree) turnOf(messageID int64) (*model.Message, error) {
+	msg, ok := t.byID[messageID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", model.ErrMessageNotFound, messageID)
+	}
+	for steps := 0; steps <= len(t.byID); steps++ {
+		if msg.Role == llm.RoleUser {
+			return msg, nil
+		}
+		if msg, ok = t.byID[msg.ParentID]; !ok {
+			break
+		}
+	}
+	return nil, fmt.Errorf("%w: message %d has no user turn", model.ErrMessageNotFound, messageID)
+}
+
+// pathSummary 摘要覆盖的最后一条消息在当前路径上时返回摘要，否则（摘要属于其他分支）返回nil
+func pathSummary(summary *model.ConversationSummary, path []*model.Message) *model.ConversationSummary {
+	if summary == nil {
+		return nil
+	}
+	for _, msg := range path {
+		if msg.ID == summary.CoveredMessageID {
+			return summary
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/model"
+)
+
+func TestEditMessage_CreatesBranch(t *testing.T) {
+	service, _, fake := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: "北京"}, {Content: "约2154万"}, {Content: "上海"}},
+	})
+	ctx := context.Background()
+
+	first, err := service.SendMessage(ctx, &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "中国的首都是哪里"})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	second, err := service.SendMessage(ctx, &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "人口多少"})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if second.UserMessage.ParentID != first.AssistantMessage.ID {
+		t.Errorf("Expected second turn to follow first reply, got parent %d", second.UserMessage.ParentID)
+	}
+
+	edited, err := service.SendMessage(ctx, &SendMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		Content:        "中国最大的城市是哪里",
+		EditMessageID:  first.UserMessage.ID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to edit message: %v", err)
+	}
+	if edited.UserMessage.ParentID != first.UserMessage.ParentID {
+		t.Errorf("Expected edited message to be a sibling, got parent %d", edited.UserMessage.ParentID)
+	}
+	if messages := fake.LastMessages(); len(messages) != 1 || messages[0] != "user: 中国最大的城市是哪里" {
+		t.Errorf("Expected history to follow the new branch, got %v", messages)
+	}
+
+	branches, err := service.ListBranches(ctx, &ListBranchesRequest{ConversationID: 1, UserID: 1})
+	if err != nil {
+		t.Fatalf("Failed to list branches: %v", err)
+	}
+	if len(branches.Branches) != 2 {
+		t.Fatalf("Expected 2 branches, got %d", len(branches.Branches))
+	}
+	old, current := branches.Branches[0], branches.Branches[1]
+	if old.LeafID != second.AssistantMessage.ID || old.Active || old.ForkMessageID != first.UserMessage.ID || old.Length != 4 {
+		t.Errorf("Unexpected original branch: %+v", old)
+	}
+	if current.LeafID != edited.AssistantMessage.ID || !current.Active || current.ForkMessageID != 0 {
+		t.Errorf("Unexpected edited branch: %+v", current)
+	}
+
+	// 切换回原分支：指定分叉点的用户消息即可，叶子取该子树中最新的回复
+	switched, err := service.SwitchBranch(ctx, &SwitchBranchRequest{ConversationID: 1, UserID: 1, MessageID: first.UserMessage.ID})
+	if err != nil {
+		t.Fatalf("Failed to switch branch: %v", err)
+	}
+	if switched.Conversation.ActiveLeafID != second.AssistantMessage.ID || len(switched.Messages) != 4 {
+		t.Errorf("Expected original branch active, got leaf %d with %d messages", switched.Conversation.ActiveLeafID, len(switched.Messages))
+	}
+
+	_, err = service.SendMessage(ctx, &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "不存在", EditMessageID: first.AssistantMessage.ID})
+	if !errors.Is(err, ErrNotUserMessage) {
+		t.Errorf("Expected ErrNotUserMessage when editing a reply, got %v", err)
+	}
+}
+
+func TestSwitchBranch_RequiresReply(t *testing.T) {
+	service, messageRepo, _ := newFakeMiniMaxService(t, fakellm.Config{})
+	ctx := context.Background()
+
+	userMessage := &model.Message{ConversationID: 1, Role: "user", Content: "没有回复"}
+	messageRepo.Create(userMessage)
+
+	_, err := service.SwitchBranch(ctx, &SwitchBranchRequest{ConversationID: 1, UserID: 1, MessageID: userMessage.ID})
+	if !errors.Is(err, ErrNoReply) {
+		t.Errorf("Expected ErrNoReply, got %v", err)
+	}
+}
+
+func TestGetConversationMessages_RequiresOwnership(t *testing.T) {
+	service, messageRepo, _ := newFakeMiniMaxService(t, fakellm.Config{})
+	service.userRepo.Create(&model.User{ID: 2, Phone: "13800138001", Status: 1})
+	messageRepo.Create(&model.Message{ConversationID: 1, Role: "user", Content: "私密问题"})
+	ctx := context.Background()
+
+	// 其他用户不能读取对话的任何分支
+	_, err := service.GetConversationMessages(ctx, &GetConversationMessagesRequest{ConversationID: 1, UserID: 2, All: true})
+	if !errors.Is(err, ErrConversationForbidden) {
+		t.Errorf("Expected ErrConversationForbidden, got %v", err)
+	}
+
+	_, err = service.GetConversationMessages(ctx, &GetConversationMessagesRequest{ConversationID: 99, UserID: 1})
+	if !errors.Is(err, model.ErrConversationNotFound) {
+		t.Errorf("Expected ErrConversationNotFound, got %v", err)
+	}
+
+	response, err := service.GetConversationMessages(ctx, &GetConversationMessagesRequest{ConversationID: 1, UserID: 1, All: true})
+	if err != nil || response.Total != 1 {
+		t.Errorf("Expected owner to read messages, got %+v, %v", response, err)
+	}
+}
+
+func TestPathSummary(t *testing.T) {
+	path := []*model.Message{{ID: 1}, {ID: 2}, {ID: 5}}
+	if pathSummary(&model.ConversationSummary{CoveredMessageID: 2}, path) == nil {
+		t.Error("Expected summary covering a message on the path to apply")
+	}
+	if pathSummary(&model.ConversationSummary{CoveredMessageID: 4}, path) != nil {
+		t.Error("Expected summary of another branch to be ignored")
+	}
+}
@@ -0,0 +1,118 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+)
+
+// MaxImagesPerMessage 单条消息最多包含的图片数
+const MaxImagesPerMessage = 4
+
+// imagePlaceholder 向不支持图片的模型回放历史时代替图片的文字
+const imagePlaceholder = "[图片]"
+
+// ErrInvalidContent 消息内容无效
+var ErrInvalidContent = errors.New("invalid message content")
+
+// ImageStore 图片存储，用于校验消息引用的图片并在回放历史时读取
+type ImageStore interface {
+	Check(ctx context.Context, userID int64, imageID string) error
+	DataURL(ctx context.Context, userID int64, imageID string) (string, error)
+}
+
+// SetImageStore 设置图片存储，未设置时不接受图文消息
+func (s *Service) SetImageStore(store ImageStore) {
+	s.images = store
+}
+
+// normalizeContent 校验发送的消息内容
+//
+// content 与 parts 二选一；parts 中的文字片段以换行连接后作为消息的 content，
+// 至少需要一段非空文字。只有文字片段时返回nil，消息按纯文本保存。
+func (s *Service) normalizeContent(ctx context.Context, req *SendMessageRequest) (model.ContentParts, error) {
+	if len(req.Parts) == 0 {
+		if strings.TrimSpace(req.Content) == "" {
+			return nil, fmt.Errorf("%w: content is required", ErrInvalidContent)
+		}
+		return nil, nil
+	}
+	if req.Content != "" {
+		return nil, fmt.Errorf("%w: content and parts cannot be used together", ErrInvalidContent)
+	}
+
+	var texts []string
+	images := 0
+	for i, part := range req.Parts {
+		switch part.Type {
+		case model.PartText:
+			if strings.TrimSpace(part.Text) == "" {
+				return nil, fmt.Errorf("%w: part %d has empty text", ErrInvalidContent, i)
+			}
+			texts = append(texts, part.Text)
+		case model.PartImage:
+			if s.images == nil {
+				return nil, fmt.Errorf("%w: image attachments are disabled", ErrInvalidContent)
+			}
+			if images++; images > MaxImagesPerMessage {
+				return nil, fmt.Errorf("%w: at most %d images per message", ErrInvalidContent, MaxImagesPerMessage)
+			}
+			if err := s.images.Check(ctx, req.UserID, part.ImageID); err != nil {
+				return nil, fmt.Errorf("%w: part %d: %v", ErrInvalidContent, i, err)
+			}
+		default:
+			return nil, fmt.Errorf("%w: part %d has unknown type %q", ErrInvalidContent, i, part.Type)
+		}
+	}
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("%w: a text part is required", ErrInvalidContent)
+	}
+
+	req.Content = strings.Join(texts, "\n")
+	if images == 0 {
+		return nil, nil
+	}
+	return model.ContentParts(req.Parts), nil
+}
+
+// replayMessage 将历史消息转换为大模型请求消息
+//
+// 图文消息只向支持图片的模型发送图片片段；不支持图片的模型以及读取失败的图片
+// 以占位文字代替，让模型知道用户曾发送过图片。
+func (s *Service) replayMessage(ctx context.Context, userID int64, msg *model.Message, vision bool) llm.Message {
+	message := toLLMMessage(msg)
+	if !msg.Parts.HasImages() {
+		return message
+	}
+
+	texts := make([]string, 0, len(msg.Parts))
+	parts := make([]llm.Part, 0, len(msg.Parts))
+	for _, part := range msg.Parts {
+		if part.Type == model.PartImage && vision && s.images != nil {
+			url, err := s.images.DataURL(ctx, userID, part.ImageID)
+			if err == nil {
+				parts = append(parts, llm.Part{Type: llm.PartImageURL, ImageURL: url})
+				continue
+			}
+			log.Printf("failed to load image %s of message %d: %v", part.ImageID, msg.ID, err)
+		}
+
+		text := part.Text
+		if part.Type == model.PartImage {
+			text = imagePlaceholder
+		}
+		texts = append(texts, text)
+		parts = append(parts, llm.Part{Type: llm.PartText, Text: text})
+	}
+
+	message.Content = strings.Join(texts, "\n")
+	if vision {
+		message.Parts = parts
+	}
+	return message
+}
@@ -0,0 +1,144 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+)
+
+// stubImageStore 用户1上传了 cat.png
+type stubImageStore struct{}
+
+func (stubImageStore) Check(ctx context.Context, userID int64, imageID string) error {
+	if userID != 1 || imageID != "cat.png" {
+		return errors.New("image not found")
+	}
+	return nil
+}
+
+func (stubImageStore) DataURL(ctx context.Context, userID int64, imageID string) (string, error) {
+	if err := (stubImageStore{}).Check(ctx, userID, imageID); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64,iVBORw0KGgo=", nil
+}
+
+// withVisionCatalog 为服务设置包含视觉模型 MiniMax-VL-01 的模型目录和图片存储
+func withVisionCatalog(t *testing.T, service *Service) {
+	catalog, err := llm.NewCatalog(llm.CatalogConfig{
+		Models: []llm.ModelSpec{
+			{ID: "MiniMax-M1", Provider: "minimax", ContextWindow: 1000000, MaxOutputTokens: 40000,
+				Capabilities: llm.ModelCapabilities{Streaming: true}, Enabled: true},
+			{ID: "MiniMax-VL-01", Provider: "minimax", ContextWindow: 1000000, MaxOutputTokens: 8192,
+				Capabilities: llm.ModelCapabilities{Streaming: true, Vision: true}, Enabled: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create catalog: %v", err)
+	}
+	service.SetModelCatalog(catalog)
+	service.SetImageStore(stubImageStore{})
+}
+
+func TestNormalizeContent(t *testing.T) {
+	service, _, _ := newFakeMiniMaxService(t, fakellm.Config{})
+	ctx := context.Background()
+	text := func(s string) model.ContentPart { return model.ContentPart{Type: model.PartText, Text: s} }
+	image := model.ContentPart{Type: model.PartImage, ImageID: "cat.png"}
+
+	// 未设置图片存储时拒绝图片
+	if _, err := service.normalizeContent(ctx, &SendMessageRequest{UserID: 1, Parts: []model.ContentPart{text("看"), image}}); !errors.Is(err, ErrInvalidContent) {
+		t.Errorf("Expected images rejected without image store, got %v", err)
+	}
+	service.SetImageStore(stubImageStore{})
+
+	invalid := map[string]*SendMessageRequest{
+		"empty":          {UserID: 1, Content: "  "},
+		"content+parts":  {UserID: 1, Content: "你好", Parts: []model.ContentPart{text("你好")}},
+		"image only":     {UserID: 1, Parts: []model.ContentPart{image}},
+		"empty text":     {UserID: 1, Parts: []model.ContentPart{text(""), image}},
+		"unknown type":   {UserID: 1, Parts: []model.ContentPart{text("看"), {Type: "audio"}}},
+		"other's image":  {UserID: 2, Parts: []model.ContentPart{text("看"), image}},
+		"too many image": {UserID: 1, Parts: []model.ContentPart{text("看"), image, image, image, image, image}},
+	}
+	for name, req := range invalid {
+		if _, err := service.normalizeContent(ctx, req); !errors.Is(err, ErrInvalidContent) {
+			t.Errorf("%s: expected ErrInvalidContent, got %v", name, err)
+		}
+	}
+
+	req := &SendMessageRequest{UserID: 1, Parts: []model.ContentPart{text("第一段"), text("第二段")}}
+	if parts, err := service.normalizeContent(ctx, req); err != nil || parts != nil || req.Content != "第一段\n第二段" {
+		t.Errorf("Expected text-only parts saved as plain content, got %v, %v, %q", parts, err, req.Content)
+	}
+
+	req = &SendMessageRequest{UserID: 1, Parts: []model.ContentPart{text("这是什么"), image}}
+	if parts, err := service.normalizeContent(ctx, req); err != nil || len(parts) != 2 || req.Content != "这是什么" {
+		t.Errorf("Expected image parts kept, got %v, %v, %q", parts, err, req.Content)
+	}
+}
+
+func TestSendMessage_Image(t *testing.T) {
+	service, messageRepo, fake := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: "一只猫"}, {Content: "橘色的"}},
+	})
+	withVisionCatalog(t, service)
+	ctx := context.Background()
+
+	response, err := service.SendMessage(ctx, &SendMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		Model:          "MiniMax-VL-01",
+		Parts: []model.ContentPart{
+			{Type: model.PartText, Text: "这是什么"},
+			{Type: model.PartImage, ImageID: "cat.png"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if images := fake.LastImages(); len(images) != 1 || !strings.HasPrefix(images[0], "data:image/png;base64,") {
+		t.Errorf("Expected image sent to vision model, got %v", images)
+	}
+	stored, _ := messageRepo.GetByID(response.UserMessage.ID)
+	if stored.Content != "这是什么" || !stored.Parts.HasImages() {
+		t.Errorf("Expected user message stored with parts, got %+v", stored)
+	}
+
+	// 换用不支持图片的模型继续对话：历史中的图片以占位文字代替
+	if _, err := service.SendMessage(ctx, &SendMessageRequest{ConversationID: 1, UserID: 1, Model: "MiniMax-M1", Content: "什么颜色"}); err != nil {
+		t.Fatalf("Failed to send follow-up: %v", err)
+	}
+	if images := fake.LastImages(); len(images) != 0 {
+		t.Errorf("Expected no images sent to text model, got %v", images)
+	}
+	if messages := fake.LastMessages(); len(messages) != 3 || messages[0] != "user: 这是什么\n"+imagePlaceholder {
+		t.Errorf("Expected image placeholder in history, got %v", messages)
+	}
+}
+
+func TestSendMessage_ImageRequiresVision(t *testing.T) {
+	service, _, fake := newFakeMiniMaxService(t, fakellm.Config{})
+	withVisionCatalog(t, service)
+
+	_, err := service.SendMessage(context.Background(), &SendMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		Model:          "MiniMax-M1",
+		Parts: []model.ContentPart{
+			{Type: model.PartText, Text: "这是什么"},
+			{Type: model.PartImage, ImageID: "cat.png"},
+		},
+	})
+	if !errors.Is(err, llm.ErrUnsupportedCapability) {
+		t.Fatalf("Expected ErrUnsupportedCapability, got %v", err)
+	}
+	if fake.RequestCount() != 0 {
+		t.Errorf("Expected no upstream request, got %d", fake.RequestCount())
+	}
+}
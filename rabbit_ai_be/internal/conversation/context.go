@@ -0,0 +1,94 @@
+package conversation
+
+import (
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+)
+
+// contextEntry 参与上下文裁剪的消息，messageID 为0表示未持久化的消息（如系统提示）
+type contextEntry struct {
+	message   llm.Message
+	messageID int64
+}
+
+// fitContext 按模型上下文窗口裁剪历史消息
+//
+// 预算为 llm.PromptBudget：模型上下文窗口减去 maxTokens（为回复预留），再扣除估算误差余量。
+// 开头的系统消息和最新一轮（最后一条用户消息及其之后的消息）始终保留，其余历史按轮次从最早开始丢弃，
+// 一轮包含一条用户消息及其后的助手/工具消息，避免留下孤立的工具结果。
+// 未发生裁剪时返回的报告为nil。
+func fitContext(entries []contextEntry, spec llm.ModelSpec, maxTokens int) ([]llm.Message, *model.ContextReport) {
+	window := spec.ContextWindow
+	budget := llm.PromptBudget(window, maxTokens)
+
+	// 开头的系统消息
+	systemEnd := 0
+	for systemEnd < len(entries) && entries[systemEnd].message.Role == llm.RoleSystem {
+		systemEnd++
+	}
+
+	// 最新一轮从最后一条用户消息开始
+	latestStart := len(entries)
+	for i := len(entries) - 1; i >= systemEnd; i-- {
+		if entries[i].message.Role == llm.RoleUser {
+			latestStart = i
+			break
+		}
+	}
+
+	// 将中间历史按轮次分组
+	var turns [][]contextEntry
+	for i := systemEnd; i < latestStart; i++ {
+		if entries[i].message.Role == llm.RoleUser || len(turns) == 0 {
+			turns = append(turns, nil)
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], entries[i])
+	}
+
+	total := llm.EstimatePromptTokens(toMessages(entries))
+	originalTokens := total
+
+	dropped := 0
+	var droppedIDs []int64
+	for total > budget && dropped < len(turns) {
+		for _, entry := range turns[dropped] {
+			total -= llm.EstimateMessageTokens(entry.message)
+			if entry.messageID != 0 {
+				droppedIDs = append(droppedIDs, entry.messageID)
+			}
+		}
+		dropped++
+	}
+
+	if dropped == 0 && total <= budget {
+		return toMessages(entries), nil
+	}
+
+	kept := make([]contextEntry, 0, len(entries))
+	kept = append(kept, entries[:systemEnd]...)
+	for _, turn := range turns[dropped:] {
+		kept = append(kept, turn...)
+	}
+	kept = append(kept, entries[latestStart:]...)
+
+	return toMessages(kept), &model.ContextReport{
+		Model:             spec.ID,
+		ContextWindow:     window,
+		ReservedTokens:    maxTokens,
+		BudgetTokens:      budget,
+		OriginalTokens:    originalTokens,
+		FinalTokens:       total,
+		DroppedTurns:      dropped,
+		DroppedMessageIDs: droppedIDs,
+		OverBudget:        total > budget,
+	}
+}
+
+// toMessages 提取请求消息
+func toMessages(entries []contextEntry) []llm.Message {
+	messages := make([]llm.Message, 0, len(entries))
+	for _, entry := range entries {
+		messages = append(messages, entry.message)
+	}
+	return messages
+}
@@ -0,0 +1,79 @@
+package conversation
+
+import (
+	"strings"
+	"testing"
+
+	"rabbit_ai/internal/llm"
+)
+
+// testSpec 上下文窗口较小的测试模型
+var testSpec = llm.ModelSpec{ID: "test-model", ContextWindow: 8192, MaxOutputTokens: 8000}
+
+func TestFitContext_NoTrimming(t *testing.T) {
+	entries := []contextEntry{
+		{message: llm.Message{Role: llm.RoleUser, Content: "你好"}, messageID: 1},
+		{message: llm.Message{Role: llm.RoleAssistant, Content: "你好！"}, messageID: 2},
+		{message: llm.Message{Role: llm.RoleUser, Content: "再见"}, messageID: 3},
+	}
+
+	messages, report := fitContext(entries, llm.DefaultCatalog().Models()[0], 2048)
+	if report != nil {
+		t.Errorf("Expected no report when history fits, got %+v", report)
+	}
+	if len(messages) != 3 {
+		t.Errorf("Expected all 3 messages kept, got %d", len(messages))
+	}
+}
+
+func TestFitContext_DropsOldestTurns(t *testing.T) {
+	// 窗口8192，预留8000并扣除10%估算余量后预算为172
+	long := strings.Repeat("长", 60)
+	entries := []contextEntry{
+		{message: llm.Message{Role: llm.RoleSystem, Content: "你是一个助手"}},
+		{message: llm.Message{Role: llm.RoleUser, Content: long}, messageID: 1},
+		{message: llm.Message{Role: llm.RoleAssistant, Content: long}, messageID: 2},
+		{message: llm.Message{Role: llm.RoleUser, Content: "调用工具"}, messageID: 3},
+		{message: llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "c1", Name: "add"}}}, messageID: 4},
+		{message: llm.Message{Role: llm.RoleTool, Content: strings.Repeat(long, 3), ToolCallID: "c1"}, messageID: 5},
+		{message: llm.Message{Role: llm.RoleAssistant, Content: "结果"}, messageID: 6},
+		{message: llm.Message{Role: llm.RoleUser, Content: "最新问题"}, messageID: 7},
+	}
+
+	messages, report := fitContext(entries, testSpec, 8000)
+	if report == nil {
+		t.Fatal("Expected trimming report")
+	}
+
+	// 两轮历史都需要丢弃，工具调用与工具结果随所在轮次一起丢弃
+	if report.DroppedTurns != 2 {
+		t.Errorf("Expected 2 dropped turns, got %d", report.DroppedTurns)
+	}
+	if len(report.DroppedMessageIDs) != 6 || report.DroppedMessageIDs[0] != 1 || report.DroppedMessageIDs[5] != 6 {
+		t.Errorf("Unexpected dropped IDs: %v", report.DroppedMessageIDs)
+	}
+	if len(messages) != 2 || messages[0].Role != llm.RoleSystem || messages[1].Content != "最新问题" {
+		t.Errorf("Expected system prompt and latest turn kept, got %+v", messages)
+	}
+	if report.BudgetTokens != 172 || report.OverBudget || report.FinalTokens > report.BudgetTokens {
+		t.Errorf("Expected final tokens within budget, got %+v", report)
+	}
+	if report.OriginalTokens <= report.FinalTokens {
+		t.Errorf("Expected original tokens > final tokens, got %+v", report)
+	}
+}
+
+func TestFitContext_KeepsLatestTurnOverBudget(t *testing.T) {
+	entries := []contextEntry{
+		{message: llm.Message{Role: llm.RoleUser, Content: "旧问题"}, messageID: 1},
+		{message: llm.Message{Role: llm.RoleUser, Content: strings.Repeat("长", 300)}, messageID: 2},
+	}
+
+	messages, report := fitContext(entries, testSpec, 8000)
+	if report == nil || !report.OverBudget {
+		t.Fatalf("Expected over-budget report, got %+v", report)
+	}
+	if len(messages) != 1 || messages[0].Role != llm.RoleUser {
+		t.Errorf("Expected latest user turn kept, got %+v", messages)
+	}
+}
@@ -0,0 +1,190 @@
+package conversation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// FinishReasonCancelled 用户取消生成时记录的结束原因
+const FinishReasonCancelled = "cancelled"
+
+// 生成任务相关错误
+var (
+	ErrGenerationCancelled = errors.New("generation cancelled")
+	ErrGenerationNotFound  = errors.New("generation not found")
+	ErrGenerationExists    = errors.New("generation already running")
+	ErrInvalidGenerationID = errors.New("invalid generation id")
+)
+
+// generationIDPattern 客户端指定的生成ID格式
+var generationIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// cancelListenRetryInterval 订阅取消信号失败后的重试间隔
+const cancelListenRetryInterval = 5 * time.Second
+
+// CancelGenerationRequest 取消生成请求
+type CancelGenerationRequest struct {
+	ConversationID int64  `json:"conversation_id"`
+	UserID         int64  `json:"user_id"`
+	GenerationID   string `json:"generation_id"`
+}
+
+// generationRegistry 本实例上正在进行的生成任务，键为 对话ID:生成ID
+type generationRegistry struct {
+	mu      sync.Mutex
+	running map[string]context.CancelCauseFunc
+}
+
+// newGenerationRegistry 创建生成任务登记表
+func newGenerationRegistry() *generationRegistry {
+	return &generationRegistry{
+		running: make(map[string]context.CancelCauseFunc),
+	}
+}
+
+// generationKey 生成任务登记键
+func generationKey(conversationID int64, generationID string) string {
+	return fmt.Sprintf("%d:%s", conversationID, generationID)
+}
+
+// register 登记生成任务，同一对话下生成ID重复时返回错误
+func (r *generationRegistry) register(conversationID int64, generationID string, cancel context.CancelCauseFunc) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := generationKey(conversationID, generationID)
+	if _, exists := r.running[key]; exists {
+		return ErrGenerationExists
+	}
+	r.running[key] = cancel
+	return nil
+}
+
+// unregister 移除生成任务登记
+func (r *generationRegistry) unregister(conversationID int64, generationID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.running, generationKey(conversationID, generationID))
+}
+
+// cancel 取消本实例上的生成任务，任务不在本实例时返回false
+func (r *generationRegistry) cancel(conversationID int64, generationID string) bool {
+	r.mu.Lock()
+	cancel, ok := r.running[generationKey(conversationID, generationID)]
+	r.mu.Unlock()
+
+	if ok {
+		cancel(ErrGenerationCancelled)
+	}
+	return ok
+}
+
+// startGeneration 校验对话归属后登记生成任务并返回可取消的ctx，结束时须调用返回的清理函数
+//
+// generationID 为空时自动生成。任务同时以 SET NX 登记到Redis，使其他实例收到取消请求时
+// 能够判断任务存在并广播取消信号，同一对话下的生成ID已在其他实例登记时返回 ErrGenerationExists；
+// Redis不可用时仍可在本实例内取消。
+func (s *Service) startGeneration(ctx context.Context, userID, conversationID int64, generationID *string) (context.Context, func(), error) {
+	if _, err := s.getOwnedConversation(userID, conversationID); err != nil {
+		return nil, nil, err
+	}
+
+	if *generationID == "" {
+		*generationID = newGenerationID()
+	} else if !generationIDPattern.MatchString(*generationID) {
+		return nil, nil, fmt.Errorf("%w: %q", ErrInvalidGenerationID, *generationID)
+	}
+	id := *generationID
+
+	genCtx, cancel := context.WithCancelCause(ctx)
+	if err := s.generations.register(conversationID, id, cancel); err != nil {
+		cancel(err)
+		return nil, nil, err
+	}
+
+	claimed, err := s.conversationCache.ClaimGeneration(ctx, conversationID, id)
+	if err != nil {
+		fmt.Printf("failed to register generation: %v\n", err)
+	} else if !claimed {
+		s.generations.unregister(conversationID, id)
+		cancel(ErrGenerationExists)
+		return nil, nil, ErrGenerationExists
+	}
+
+	cleanup := func() {
+		s.generations.unregister(conversationID, id)
+		// 只删除本任务占用的登记，避免误删其他实例的同名任务
+		if claimed {
+			if err := s.conversationCache.DeleteGeneration(context.WithoutCancel(ctx), conversationID, id); err != nil {
+				fmt.Printf("failed to unregister generation: %v\n", err)
+			}
+		}
+		cancel(nil)
+	}
+	return genCtx, cleanup, nil
+}
+
+// generationCancelled ctx是否因用户取消生成而结束
+func generationCancelled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrGenerationCancelled)
+}
+
+// CancelGeneration 取消正在进行的生成任务
+//
+// 任务在本实例时直接取消；否则通过Redis广播取消信号，由持有任务的实例取消。
+func (s *Service) CancelGeneration(ctx context.Context, req *CancelGenerationRequest) error {
+	if _, err := s.getOwnedConversation(req.UserID, req.ConversationID); err != nil {
+		return err
+	}
+
+	if s.generations.cancel(req.ConversationID, req.GenerationID) {
+		return nil
+	}
+
+	exists, err := s.conversationCache.GenerationExists(ctx, req.ConversationID, req.GenerationID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrGenerationNotFound
+	}
+
+	return s.conversationCache.PublishGenerationCancel(ctx, req.ConversationID, req.GenerationID)
+}
+
+// ListenGenerationCancels 订阅其他实例广播的取消信号，直到ctx结束；连接断开时自动重试
+func (s *Service) ListenGenerationCancels(ctx context.Context) {
+	for {
+		err := s.conversationCache.SubscribeGenerationCancels(ctx, func(conversationID int64, generationID string) {
+			s.generations.cancel(conversationID, generationID)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("generation cancel listener: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cancelListenRetryInterval):
+		}
+	}
+}
+
+// newGenerationID 生成随机的生成ID
+func newGenerationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,123 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/model"
+)
+
+func TestSendMessageStream_Cancelled(t *testing.T) {
+	service, messageRepo, _ := newFakeMiniMaxService(t, fakellm.Config{
+		Replies:    []fakellm.Reply{{Content: "一段会被取消的很长的回复"}},
+		ChunkSize:  2,
+		ChunkDelay: 50 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	// 收到第一个片段后取消生成
+	response, err := service.SendMessageStream(ctx, &SendMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		Content:        "你好",
+		GenerationID:   "gen-1",
+	}, StreamCallbacks{
+		OnDelta: func(content string) {
+			if err := service.CancelGeneration(ctx, &CancelGenerationRequest{ConversationID: 1, UserID: 1, GenerationID: "gen-1"}); err != nil {
+				t.Errorf("Failed to cancel generation: %v", err)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected partial reply to be saved, got error: %v", err)
+	}
+
+	assistant := response.AssistantMessage
+	if assistant.FinishReason != FinishReasonCancelled {
+		t.Errorf("Expected finish reason %s, got %s", FinishReasonCancelled, assistant.FinishReason)
+	}
+	if assistant.Content == "" || assistant.Content == "一段会被取消的很长的回复" {
+		t.Errorf("Expected partial content, got '%s'", assistant.Content)
+	}
+	if stored, _ := messageRepo.GetByID(assistant.ID); stored == nil {
+		t.Error("Expected partial reply to be stored")
+	}
+	if response.GenerationID != "gen-1" {
+		t.Errorf("Expected generation ID gen-1, got %s", response.GenerationID)
+	}
+
+	// 生成结束后登记被移除
+	if service.generations.cancel(1, "gen-1") {
+		t.Error("Expected generation to be unregistered after completion")
+	}
+}
+
+func TestSendMessage_Cancelled(t *testing.T) {
+	service, _, _ := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: "不会返回", Delay: 5 * time.Second}},
+	})
+
+	go func() {
+		for !service.generations.cancel(1, "gen-2") {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	start := time.Now()
+	_, err := service.SendMessage(context.Background(), &SendMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		Content:        "你好",
+		GenerationID:   "gen-2",
+	})
+	if !errors.Is(err, ErrGenerationCancelled) {
+		t.Errorf("Expected ErrGenerationCancelled, got %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Error("Expected upstream call to be aborted")
+	}
+}
+
+func TestStartGeneration(t *testing.T) {
+	service, _, _ := newFakeMiniMaxService(t, fakellm.Config{})
+	ctx := context.Background()
+
+	id := ""
+	_, done, err := service.startGeneration(ctx, 1, 1, &id)
+	if err != nil || id == "" {
+		t.Fatalf("Expected generated ID, got %q, %v", id, err)
+	}
+	defer done()
+
+	if _, _, err := service.startGeneration(ctx, 1, 1, &id); !errors.Is(err, ErrGenerationExists) {
+		t.Errorf("Expected ErrGenerationExists, got %v", err)
+	}
+
+	invalid := "bad id!"
+	if _, _, err := service.startGeneration(ctx, 1, 1, &invalid); !errors.Is(err, ErrInvalidGenerationID) {
+		t.Errorf("Expected ErrInvalidGenerationID, got %v", err)
+	}
+}
+
+func TestStartGeneration_RequiresOwnership(t *testing.T) {
+	service, _, _ := newFakeMiniMaxService(t, fakellm.Config{})
+	service.userRepo.Create(&model.User{ID: 2, Phone: "13800138001", Status: 1})
+
+	// 其他用户不能在该对话下登记生成任务，也不能占用所有者的生成ID
+	id := "gen-owner"
+	if _, _, err := service.startGeneration(context.Background(), 2, 1, &id); err == nil {
+		t.Fatal("Expected ownership error for another user's conversation")
+	}
+	if service.generations.cancel(1, id) {
+		t.Error("Expected no generation registered for another user's conversation")
+	}
+
+	_, done, err := service.startGeneration(context.Background(), 1, 1, &id)
+	if err != nil {
+		t.Fatalf("Expected owner to start generation, got %v", err)
+	}
+	done()
+}
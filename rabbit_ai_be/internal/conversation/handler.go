@@ -1,12 +1,23 @@
 package conversation
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+	"rabbit_ai/internal/structured"
 )
 
+// ErrCodeAIUnavailable 大模型服务熔断时返回的错误码，客户端可据此提示稍后重试
+const ErrCodeAIUnavailable = "AI_SERVICE_UNAVAILABLE"
+
 // Handler 对话处理器
 type Handler struct {
 	service *Service
@@ -25,8 +36,17 @@ func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
 	{
 		conversationGroup.POST("", h.CreateConversation)
 		conversationGroup.GET("", h.GetConversations)
+		conversationGroup.GET("/events", h.StreamEvents)
+		conversationGroup.GET("/search", h.Search)
+		conversationGroup.GET("/:id", h.GetConversation)
+		conversationGroup.PUT("/:id/settings", h.UpdateConversationSettings)
 		conversationGroup.GET("/:id/messages", h.GetConversationMessages)
 		conversationGroup.POST("/:id/messages", h.SendMessage)
+		conversationGroup.POST("/:id/messages/:msgId/regenerate", h.RegenerateMessage)
+		conversationGroup.POST("/:id/messages/:msgId/edit", h.EditMessage)
+		conversationGroup.POST("/:id/messages/:msgId/activate", h.SwitchBranch)
+		conversationGroup.GET("/:id/branches", h.ListBranches)
+		conversationGroup.POST("/:id/generations/:genId/cancel", h.CancelGeneration)
 		conversationGroup.DELETE("/:id", h.DeleteConversation)
 	}
 }
@@ -56,6 +76,13 @@ func (h *Handler) CreateConversation(c *gin.Context) {
 
 	response, err := h.service.CreateConversation(c.Request.Context(), &req)
 	if err != nil {
+		if errors.Is(err, ErrInvalidSettings) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid conversation settings",
+				"details": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to create conversation",
 			"details": err.Error(),
@@ -115,6 +142,105 @@ func (h *Handler) GetConversations(c *gin.Context) {
 	})
 }
 
+// GetConversation 获取对话详情
+func (h *Handler) GetConversation(c *gin.Context) {
+	// 获取对话ID
+	conversationIDStr := c.Param("id")
+	conversationID, err := strconv.ParseInt(conversationIDStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid conversation ID",
+		})
+		return
+	}
+
+	// 从JWT中获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	req := &GetConversationRequest{
+		ConversationID: conversationID,
+		UserID:         userID.(int64),
+	}
+
+	response, err := h.service.GetConversation(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get conversation",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// UpdateConversationSettings 更新对话设置
+func (h *Handler) UpdateConversationSettings(c *gin.Context) {
+	// 获取对话ID
+	conversationIDStr := c.Param("id")
+	conversationID, err := strconv.ParseInt(conversationIDStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid conversation ID",
+		})
+		return
+	}
+
+	var settings model.ConversationSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// 从JWT中获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	req := &UpdateConversationSettingsRequest{
+		ConversationID: conversationID,
+		UserID:         userID.(int64),
+		Settings:       settings,
+	}
+
+	response, err := h.service.UpdateConversationSettings(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSettings) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid conversation settings",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to update conversation settings",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    response,
+	})
+}
+
 // GetConversationMessages 获取对话消息
 func (h *Handler) GetConversationMessages(c *gin.Context) {
 	// 获取对话ID
@@ -141,14 +267,28 @@ func (h *Handler) GetConversationMessages(c *gin.Context) {
 		offset = 0
 	}
 
+	// 从JWT中获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
 	req := &GetConversationMessagesRequest{
 		ConversationID: conversationID,
+		UserID:         userID.(int64),
 		Limit:          limit,
 		Offset:         offset,
+		All:            c.Query("all") == "true",
 	}
 
 	response, err := h.service.GetConversationMessages(c.Request.Context(), req)
 	if err != nil {
+		if respondOwnershipError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get conversation messages",
 			"details": err.Error(),
@@ -196,10 +336,172 @@ func (h *Handler) SendMessage(c *gin.Context) {
 	req.ConversationID = conversationID
 	req.UserID = userID.(int64)
 
+	if req.Stream {
+		h.sendMessageStream(c, &req)
+		return
+	}
+
+	response, err := h.service.SendMessage(c.Request.Context(), &req)
+	if err != nil {
+		respondSendError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// RegenerateMessage 重新生成最新一轮的AI回复
+func (h *Handler) RegenerateMessage(c *gin.Context) {
+	// 获取对话ID和消息ID
+	conversationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid conversation ID",
+		})
+		return
+	}
+	messageID, err := strconv.ParseInt(c.Param("msgId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid message ID",
+		})
+		return
+	}
+
+	// 请求体可选，用于覆盖模型和温度
+	var req RegenerateMessageRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request parameters",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	// 从JWT中获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	req.ConversationID = conversationID
+	req.UserID = userID.(int64)
+	req.MessageID = messageID
+
+	response, err := h.service.RegenerateMessage(c.Request.Context(), &req)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSettings) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request parameters",
+				"details": err.Error(),
+			})
+			return
+		}
+		respondSendError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// EditMessage 编辑历史用户消息：以新内容创建分支并获取AI回复
+func (h *Handler) EditMessage(c *gin.Context) {
+	// 获取对话ID和消息ID
+	conversationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid conversation ID",
+		})
+		return
+	}
+	messageID, err := strconv.ParseInt(c.Param("msgId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid message ID",
+		})
+		return
+	}
+
+	var req SendMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// 从JWT中获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	// 设置请求参数
+	req.ConversationID = conversationID
+	req.UserID = userID.(int64)
+	req.EditMessageID = messageID
+
+	if req.Stream {
+		h.sendMessageStream(c, &req)
+		return
+	}
+
 	response, err := h.service.SendMessage(c.Request.Context(), &req)
+	if err != nil {
+		respondSendError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// ListBranches 获取对话的全部分支
+func (h *Handler) ListBranches(c *gin.Context) {
+	// 获取对话ID
+	conversationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid conversation ID",
+		})
+		return
+	}
+
+	// 从JWT中获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	req := &ListBranchesRequest{
+		ConversationID: conversationID,
+		UserID:         userID.(int64),
+	}
+
+	response, err := h.service.ListBranches(c.Request.Context(), req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to send message",
+			"error":   "Failed to list branches",
 			"details": err.Error(),
 		})
 		return
@@ -211,6 +513,384 @@ func (h *Handler) SendMessage(c *gin.Context) {
 	})
 }
 
+// SwitchBranch 切换到经过指定消息的分支
+func (h *Handler) SwitchBranch(c *gin.Context) {
+	// 获取对话ID和消息ID
+	conversationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid conversation ID",
+		})
+		return
+	}
+	messageID, err := strconv.ParseInt(c.Param("msgId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid message ID",
+		})
+		return
+	}
+
+	// 从JWT中获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	req := &SwitchBranchRequest{
+		ConversationID: conversationID,
+		UserID:         userID.(int64),
+		MessageID:      messageID,
+	}
+
+	response, err := h.service.SwitchBranch(c.Request.Context(), req)
+	if err != nil {
+		if respondTurnError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to switch branch",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// CancelGeneration 取消正在进行的生成
+func (h *Handler) CancelGeneration(c *gin.Context) {
+	// 获取对话ID
+	conversationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid conversation ID",
+		})
+		return
+	}
+
+	// 从JWT中获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	req := &CancelGenerationRequest{
+		ConversationID: conversationID,
+		UserID:         userID.(int64),
+		GenerationID:   c.Param("genId"),
+	}
+
+	err = h.service.CancelGeneration(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, ErrGenerationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Generation not found",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to cancel generation",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Generation cancelled",
+	})
+}
+
+// StreamEvents 以SSE推送当前用户的对话事件（如后台生成的标题），直到客户端断开
+func (h *Handler) StreamEvents(c *gin.Context) {
+	// 从JWT中获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	ready := func() {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+	}
+	err := h.service.SubscribeEvents(c.Request.Context(), userID.(int64), ready, func(event *ConversationEvent) {
+		c.SSEvent(event.Type, event)
+		c.Writer.Flush()
+	})
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Failed to subscribe conversation events",
+			"details": err.Error(),
+		})
+	}
+}
+
+// Search 按关键词搜索对话标题和消息
+func (h *Handler) Search(c *gin.Context) {
+	// 从JWT中获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(DefaultSearchLimit)))
+	if err != nil {
+		limit = DefaultSearchLimit
+	}
+
+	req := &SearchRequest{
+		UserID: userID.(int64),
+		Query:  c.Query("q"),
+		Role:   c.Query("role"),
+		Model:  c.Query("model"),
+		Cursor: c.Query("cursor"),
+		Limit:  limit,
+	}
+	if req.From, err = parseSearchTime(c.Query("from"), false); err == nil {
+		req.To, err = parseSearchTime(c.Query("to"), true)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid search parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	response, err := h.service.Search(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSearch) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid search parameters",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to search conversations",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// parseSearchTime 解析搜索的时间参数，支持RFC3339和日期；日期作为上限时包含当天
+func parseSearchTime(value string, upper bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected RFC3339 or YYYY-MM-DD", value)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// respondOwnershipError 处理对话不存在或不属于当前用户的错误，已响应时返回true
+func respondOwnershipError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, model.ErrConversationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Conversation not found",
+			"details": err.Error(),
+		})
+	case errors.Is(err, ErrConversationForbidden):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Access denied",
+			"details": err.Error(),
+		})
+	default:
+		return false
+	}
+	return true
+}
+
+// respondTurnError 处理定位消息或分支时的错误，已响应时返回true
+func respondTurnError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, model.ErrMessageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Message not found",
+			"details": err.Error(),
+		})
+	case errors.Is(err, ErrNotUserMessage):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Only user messages can be edited",
+			"details": err.Error(),
+		})
+	case errors.Is(err, ErrNoReply):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Branch has no assistant reply",
+			"details": err.Error(),
+		})
+	default:
+		return false
+	}
+	return true
+}
+
+// sendMessageStream 以SSE流式返回AI回复
+//
+// 事件依次为：generation（生成ID，用于取消）、user_message（已保存的用户消息）、
+// message（增量内容）、done（与非流式接口data相同的完整结果）或 error。
+// 上游流建立之前的错误仍以JSON返回。
+func (h *Handler) sendMessageStream(c *gin.Context, req *SendMessageRequest) {
+	streaming := false
+	response, err := h.service.SendMessageStream(c.Request.Context(), req, StreamCallbacks{
+		OnUserMessage: func(message *model.Message) {
+			c.Header("Content-Type", "text/event-stream")
+			c.Header("Cache-Control", "no-cache")
+			c.Header("Connection", "keep-alive")
+			streaming = true
+
+			c.SSEvent("generation", gin.H{
+				"generation_id": req.GenerationID,
+			})
+			c.SSEvent("user_message", message)
+			c.Writer.Flush()
+		},
+		OnDelta: func(content string) {
+			c.SSEvent("message", gin.H{
+				"content": content,
+			})
+			c.Writer.Flush()
+		},
+	})
+
+	if !streaming {
+		respondSendError(c, err)
+		return
+	}
+
+	// 客户端已断开，部分回复已由服务保存
+	if c.Request.Context().Err() != nil {
+		return
+	}
+
+	if err != nil {
+		var blockedErr *ContentBlockedError
+		if errors.As(err, &blockedErr) {
+			c.SSEvent("error", contentBlockedBody(blockedErr))
+			return
+		}
+		c.SSEvent("error", gin.H{
+			"error":   "Failed to send message",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.SSEvent("done", response)
+}
+
+// respondSendError 返回发送消息失败的错误响应
+func respondSendError(c *gin.Context, err error) {
+	if respondTurnError(c, err) {
+		return
+	}
+	var blockedErr *ContentBlockedError
+	if errors.As(err, &blockedErr) {
+		c.JSON(http.StatusUnprocessableEntity, contentBlockedBody(blockedErr))
+		return
+	}
+	var outputErr *structured.OutputError
+	if errors.As(err, &outputErr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":             "Structured output failed validation",
+			"code":              ErrCodeInvalidOutput,
+			"attempts":          outputErr.Attempts,
+			"validation_errors": outputErr.Errors,
+			"raw":               outputErr.Raw,
+			"details":           err.Error(),
+		})
+		return
+	}
+	switch {
+	case errors.Is(err, ErrGenerationCancelled):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Generation cancelled",
+			"details": err.Error(),
+		})
+		return
+	case errors.Is(err, ErrGenerationExists):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Generation already running",
+			"details": err.Error(),
+		})
+		return
+	case errors.Is(err, ErrInvalidGenerationID):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid generation ID",
+			"details": err.Error(),
+		})
+		return
+	case errors.Is(err, ErrInvalidContent):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid message content",
+			"details": err.Error(),
+		})
+		return
+	case errors.Is(err, structured.ErrInvalidFormat):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid response format",
+			"details": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, llm.ErrCircuitOpen) {
+		respondAIUnavailable(c, err)
+		return
+	}
+	if isInvalidModel(err) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid model",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":   "Failed to send message",
+		"details": err.Error(),
+	})
+}
+
+// isInvalidModel 请求的模型未登记、已停用或不支持所需能力
+func isInvalidModel(err error) bool {
+	return errors.Is(err, llm.ErrUnknownModel) ||
+		errors.Is(err, llm.ErrModelDisabled) ||
+		errors.Is(err, llm.ErrUnsupportedCapability)
+}
+
 // DeleteConversation 删除对话
 func (h *Handler) DeleteConversation(c *gin.Context) {
 	// 获取对话ID
@@ -251,3 +931,27 @@ func (h *Handler) DeleteConversation(c *gin.Context) {
 		"message": "Conversation deleted successfully",
 	})
 }
+
+// respondAIUnavailable 大模型服务熔断时快速返回503
+func respondAIUnavailable(c *gin.Context, err error) {
+	var openErr *llm.CircuitOpenError
+	if errors.As(err, &openErr) && openErr.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(openErr.RetryAfter.Seconds()))))
+	}
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"error":   "AI service temporarily unavailable",
+		"code":    ErrCodeAIUnavailable,
+		"details": err.Error(),
+	})
+}
+
+// contentBlockedBody 内容被护栏屏蔽时的错误响应体
+func contentBlockedBody(err *ContentBlockedError) gin.H {
+	return gin.H{
+		"error":      "Content blocked by content safety check",
+		"code":       ErrCodeContentBlocked,
+		"stage":      err.Stage,
+		"message_id": err.MessageID,
+		"details":    err.Verdict.Reason,
+	}
+}
@@ -0,0 +1,121 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+)
+
+// DefaultKnowledgeTopK 每次发送消息从知识库检索的片段数
+const DefaultKnowledgeTopK = 4
+
+// knowledgePrompt 注入检索结果时的说明
+const knowledgePrompt = `以下是从用户知识库中检索到的资料。回答时请优先依据这些资料，引用时在句末以 [编号] 标注来源；资料与问题无关时忽略它们，不要编造资料中没有的内容。`
+
+// citationMarker 回复中的引用标记，如 [1]
+var citationMarker = regexp.MustCompile(`\[(\d+)\]`)
+
+// KnowledgeRetriever 知识库检索接口
+type KnowledgeRetriever interface {
+	// CheckAccess 校验知识库均存在且属于用户
+	CheckAccess(userID int64, knowledgeBaseIDs []int64) error
+	// Retrieve 检索与查询最相关的 topK 个片段，相似度低于 minScore 的片段不返回
+	Retrieve(ctx context.Context, userID int64, knowledgeBaseIDs []int64, query string, topK int, minScore float64) ([]model.Citation, error)
+}
+
+// KnowledgePolicy 知识库检索策略
+type KnowledgePolicy struct {
+	TopK     int     // 每次检索的片段数
+	MinScore float64 // 最低相似度
+}
+
+// SetKnowledge 设置知识库检索，未设置时对话不能关联知识库
+func (s *Service) SetKnowledge(retriever KnowledgeRetriever, policy KnowledgePolicy) {
+	if policy.TopK <= 0 {
+		policy.TopK = DefaultKnowledgeTopK
+	}
+	s.knowledge = retriever
+	s.knowledgePolicy = policy
+}
+
+// checkKnowledgeBases 校验对话设置关联的知识库，去除重复ID
+func (s *Service) checkKnowledgeBases(userID int64, settings *model.ConversationSettings) error {
+	if len(settings.KnowledgeBaseIDs) == 0 {
+		return nil
+	}
+	if s.knowledge == nil {
+		return fmt.Errorf("%w: knowledge bases are not enabled", ErrInvalidSettings)
+	}
+
+	seen := map[int64]bool{}
+	ids := settings.KnowledgeBaseIDs[:0]
+	for _, id := range settings.KnowledgeBaseIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	settings.KnowledgeBaseIDs = ids
+
+	if err := s.knowledge.CheckAccess(userID, ids); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSettings, err)
+	}
+	return nil
+}
+
+// retrieveKnowledge 从对话关联的知识库中检索与问题相关的片段；检索失败时记录日志并不使用知识库
+func (s *Service) retrieveKnowledge(ctx context.Context, userID int64, settings model.ConversationSettings, question string) []model.Citation {
+	if s.knowledge == nil || len(settings.KnowledgeBaseIDs) == 0 {
+		return nil
+	}
+	citations, err := s.knowledge.Retrieve(ctx, userID, settings.KnowledgeBaseIDs, question, s.knowledgePolicy.TopK, s.knowledgePolicy.MinScore)
+	if err != nil {
+		log.Printf("failed to retrieve knowledge for user %d: %v", userID, err)
+		return nil
+	}
+	return citations
+}
+
+// knowledgeEntry 将检索到的片段组成系统消息，位于系统提示之后，不参与上下文裁剪
+func knowledgeEntry(citations []model.Citation) contextEntry {
+	var b strings.Builder
+	b.WriteString(knowledgePrompt)
+	for _, citation := range citations {
+		fmt.Fprintf(&b, "\n\n[%d] 《%s》\n%s", citation.Index, citation.DocumentName, citation.Content)
+	}
+	return contextEntry{message: llm.Message{
+		Role:    llm.RoleSystem,
+		Content: b.String(),
+	}}
+}
+
+// usedCitations 回复引用的片段：回复中以 [编号] 标注的片段，没有标注时为提供给模型的全部片段
+func usedCitations(content string, citations []model.Citation) model.Citations {
+	if len(citations) == 0 {
+		return nil
+	}
+
+	referenced := map[int]bool{}
+	for _, match := range citationMarker.FindAllStringSubmatch(content, -1) {
+		if index, err := strconv.Atoi(match[1]); err == nil {
+			referenced[index] = true
+		}
+	}
+
+	var used model.Citations
+	for _, citation := range citations {
+		if referenced[citation.Index] {
+			used = append(used, citation)
+		}
+	}
+	if len(used) == 0 {
+		return citations
+	}
+	return used
+}
@@ -0,0 +1,132 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/model"
+)
+
+// stubRetriever 返回固定片段的知识库检索，只允许访问 allowed 中的知识库
+type stubRetriever struct {
+	allowed   map[int64]bool
+	citations []model.Citation
+	err       error
+	queries   []string
+}
+
+func (r *stubRetriever) CheckAccess(userID int64, knowledgeBaseIDs []int64) error {
+	for _, id := range knowledgeBaseIDs {
+		if !r.allowed[id] {
+			return model.ErrKnowledgeBaseNotFound
+		}
+	}
+	return nil
+}
+
+func (r *stubRetriever) Retrieve(ctx context.Context, userID int64, knowledgeBaseIDs []int64, query string, topK int, minScore float64) ([]model.Citation, error) {
+	r.queries = append(r.queries, query)
+	return r.citations, r.err
+}
+
+func newStubRetriever() *stubRetriever {
+	return &stubRetriever{
+		allowed: map[int64]bool{7: true},
+		citations: []model.Citation{
+			{Index: 1, KnowledgeBaseID: 7, DocumentID: 3, DocumentName: "菜谱.md", ChunkID: 11, Content: "红烧肉需要炖一小时", Score: 0.8},
+			{Index: 2, KnowledgeBaseID: 7, DocumentID: 3, DocumentName: "菜谱.md", ChunkID: 12, Content: "清蒸鱼蒸八分钟", Score: 0.4},
+		},
+	}
+}
+
+func TestSendMessage_KnowledgeContext(t *testing.T) {
+	service, messageRepo, fake := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: "红烧肉大约炖一小时 [1]。"}},
+	})
+	retriever := newStubRetriever()
+	service.SetKnowledge(retriever, KnowledgePolicy{})
+	conversation, _ := service.conversationRepo.GetByID(1)
+	conversation.Settings = model.ConversationSettings{SystemPrompt: "你是厨师", KnowledgeBaseIDs: []int64{7}}
+
+	response, err := service.SendMessage(context.Background(), &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "红烧肉炖多久"})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	messages := fake.LastMessages()
+	if len(messages) != 3 || messages[0] != "system: 你是厨师" {
+		t.Fatalf("Expected system prompt, knowledge and question, got %v", messages)
+	}
+	if !strings.HasPrefix(messages[1], "system: ") || !strings.Contains(messages[1], "[1] 《菜谱.md》\n红烧肉需要炖一小时") {
+		t.Errorf("Expected knowledge injected after system prompt, got %q", messages[1])
+	}
+	if len(retriever.queries) != 1 || retriever.queries[0] != "红烧肉炖多久" {
+		t.Errorf("Expected retrieval with the question, got %v", retriever.queries)
+	}
+
+	stored, _ := messageRepo.GetByID(response.AssistantMessage.ID)
+	if len(stored.Citations) != 1 || stored.Citations[0].ChunkID != 11 {
+		t.Errorf("Expected only the referenced citation stored, got %+v", stored.Citations)
+	}
+}
+
+func TestSendMessage_KnowledgeUnavailable(t *testing.T) {
+	service, _, fake := newFakeMiniMaxService(t, fakellm.Config{})
+	retriever := newStubRetriever()
+	retriever.err = errors.New("embedding service unavailable")
+	service.SetKnowledge(retriever, KnowledgePolicy{})
+	conversation, _ := service.conversationRepo.GetByID(1)
+	conversation.Settings = model.ConversationSettings{KnowledgeBaseIDs: []int64{7}}
+
+	response, err := service.SendMessage(context.Background(), &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "你好"})
+	if err != nil {
+		t.Fatalf("Expected message sent without knowledge, got %v", err)
+	}
+	if messages := fake.LastMessages(); len(messages) != 1 {
+		t.Errorf("Expected no knowledge context, got %v", messages)
+	}
+	if len(response.AssistantMessage.Citations) != 0 {
+		t.Errorf("Expected no citations, got %+v", response.AssistantMessage.Citations)
+	}
+}
+
+func TestCheckKnowledgeBases(t *testing.T) {
+	service, _, _ := newFakeMiniMaxService(t, fakellm.Config{})
+	ctx := context.Background()
+
+	_, err := service.CreateConversation(ctx, &CreateConversationRequest{UserID: 1, Title: "t", Settings: model.ConversationSettings{KnowledgeBaseIDs: []int64{7}}})
+	if !errors.Is(err, ErrInvalidSettings) {
+		t.Errorf("Expected ErrInvalidSettings when knowledge bases are disabled, got %v", err)
+	}
+
+	service.SetKnowledge(newStubRetriever(), KnowledgePolicy{})
+	response, err := service.CreateConversation(ctx, &CreateConversationRequest{UserID: 1, Title: "t", Settings: model.ConversationSettings{KnowledgeBaseIDs: []int64{7, 7}}})
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	if ids := response.Conversation.Settings.KnowledgeBaseIDs; len(ids) != 1 || ids[0] != 7 {
+		t.Errorf("Expected duplicate knowledge base removed, got %v", ids)
+	}
+
+	_, err = service.UpdateConversationSettings(ctx, &UpdateConversationSettingsRequest{ConversationID: 1, UserID: 1, Settings: model.ConversationSettings{KnowledgeBaseIDs: []int64{8}}})
+	if !errors.Is(err, ErrInvalidSettings) {
+		t.Errorf("Expected ErrInvalidSettings for inaccessible knowledge base, got %v", err)
+	}
+}
+
+func TestUsedCitations(t *testing.T) {
+	citations := newStubRetriever().citations
+
+	if used := usedCitations("见 [2] 和 [9]", citations); len(used) != 1 || used[0].Index != 2 {
+		t.Errorf("Expected referenced citation only, got %+v", used)
+	}
+	if used := usedCitations("没有标注", citations); len(used) != 2 {
+		t.Errorf("Expected all citations without markers, got %+v", used)
+	}
+	if used := usedCitations("[1]", nil); used != nil {
+		t.Errorf("Expected nil without citations, got %+v", used)
+	}
+}
@@ -0,0 +1,110 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"rabbit_ai/internal/guardrail"
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+)
+
+// ErrCodeContentBlocked 输入或回复未通过内容安全检查时返回的错误码
+const ErrCodeContentBlocked = guardrail.ErrCodeContentBlocked
+
+// ContentBlockedError 输入或回复被护栏屏蔽
+//
+// 被屏蔽的消息仍会保存（内容替换为 guardrail.BlockedPlaceholder），但不进入当前分支。
+type ContentBlockedError struct {
+	Stage     guardrail.Stage   // 被屏蔽的阶段：input/output
+	Verdict   guardrail.Verdict // 护栏结论
+	MessageID int64             // 被屏蔽的消息ID
+}
+
+// Error 实现error接口
+func (e *ContentBlockedError) Error() string {
+	return fmt.Sprintf("%s blocked by guardrail %s: %s", e.Stage, e.Verdict.Guard, e.Verdict.Reason)
+}
+
+// SetGuardrails 设置内容安全护栏链与审核事件仓库，未设置时不做检查
+func (s *Service) SetGuardrails(pipeline *guardrail.Pipeline, moderationRepo model.ModerationRepository) {
+	s.guardrails = pipeline
+	s.moderationRepo = moderationRepo
+}
+
+// checkContent 执行护栏检查；护栏出错时记录日志并放行，避免护栏故障导致对话不可用
+func (s *Service) checkContent(ctx context.Context, stage guardrail.Stage, content string, sensitive *llm.SensitiveFlags) guardrail.Verdict {
+	verdict, err := s.guardrails.Check(ctx, guardrail.Input{
+		Stage:     stage,
+		Content:   content,
+		Sensitive: sensitive,
+	})
+	if err != nil {
+		log.Printf("guardrail check failed: %v", err)
+		return guardrail.Verdict{}
+	}
+	return verdict
+}
+
+// applyVerdict 按护栏结论设置消息的审核状态，屏蔽时替换消息内容，返回原始内容
+func applyVerdict(message *model.Message, verdict guardrail.Verdict) string {
+	original := message.Content
+	switch {
+	case verdict.Blocked():
+		message.Content = guardrail.BlockedPlaceholder
+		message.Parts = nil
+		message.Moderation = model.ModerationBlocked
+	case verdict.Flagged():
+		message.Moderation = model.ModerationFlagged
+	}
+	return original
+}
+
+// recordModeration 记录命中护栏的审核事件，供管理员复核；放行的内容不记录
+func (s *Service) recordModeration(userID int64, message *model.Message, stage guardrail.Stage, verdict guardrail.Verdict, original string) {
+	if verdict.Action == guardrail.ActionAllow || s.moderationRepo == nil {
+		return
+	}
+
+	event := &model.ModerationEvent{
+		ConversationID: message.ConversationID,
+		MessageID:      message.ID,
+		UserID:         userID,
+		Stage:          string(stage),
+		Action:         string(verdict.Action),
+		Guard:          verdict.Guard,
+		Reason:         verdict.Reason,
+		Content:        original,
+	}
+	if err := s.moderationRepo.Create(event); err != nil {
+		log.Printf("failed to record moderation event: %v", err)
+	}
+}
+
+// blockInput 保存被屏蔽的用户消息并更新对话消息数，当前分支不变
+func (s *Service) blockInput(ctx context.Context, req *SendMessageRequest, conversation *model.Conversation, userMessage *model.Message, verdict guardrail.Verdict, original string) error {
+	if err := s.saveMessage(ctx, userMessage); err != nil {
+		return fmt.Errorf("failed to create user message: %w", err)
+	}
+	s.recordModeration(req.UserID, userMessage, guardrail.StageInput, verdict, original)
+
+	conversation.MessageCount++
+	if err := s.conversationRepo.Update(conversation); err != nil {
+		return fmt.Errorf("failed to update conversation: %w", err)
+	}
+
+	// 使相关缓存失效
+	if err := s.conversationCache.InvalidateConversationCache(ctx, req.ConversationID); err != nil {
+		fmt.Printf("failed to invalidate conversation cache: %v\n", err)
+	}
+	if err := s.conversationCache.InvalidateUserCache(ctx, req.UserID); err != nil {
+		fmt.Printf("failed to invalidate user cache: %v\n", err)
+	}
+
+	return &ContentBlockedError{
+		Stage:     guardrail.StageInput,
+		Verdict:   verdict,
+		MessageID: userMessage.ID,
+	}
+}
@@ -0,0 +1,173 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/guardrail"
+	"rabbit_ai/internal/model"
+)
+
+// MockModerationRepository 模拟审核事件仓库
+type MockModerationRepository struct {
+	events []*model.ModerationEvent
+}
+
+func (m *MockModerationRepository) Create(event *model.ModerationEvent) error {
+	event.ID = int64(len(m.events) + 1)
+	event.ReviewStatus = model.ReviewPending
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *MockModerationRepository) GetByID(id int64) (*model.ModerationEvent, error) {
+	if id < 1 || int(id) > len(m.events) {
+		return nil, model.ErrModerationEventNotFound
+	}
+	return m.events[id-1], nil
+}
+
+func (m *MockModerationRepository) List(reviewStatus string, limit, offset int) ([]*model.ModerationEvent, error) {
+	return m.events, nil
+}
+
+func (m *MockModerationRepository) Count(reviewStatus string) (int, error) {
+	return len(m.events), nil
+}
+
+func (m *MockModerationRepository) Review(id int64, reviewStatus string, reviewerID int64, note string) error {
+	event, err := m.GetByID(id)
+	if err != nil {
+		return err
+	}
+	event.ReviewStatus = reviewStatus
+	return nil
+}
+
+// withGuardrails 为服务设置关键词护栏（“违禁”屏蔽，“可疑”标记）与提供方敏感标记护栏
+func withGuardrails(t *testing.T, service *Service) *MockModerationRepository {
+	keywordGuard, err := guardrail.NewKeywordGuard([]guardrail.KeywordRule{
+		{Pattern: "违禁"},
+		{Pattern: "可疑", Action: guardrail.ActionFlag},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create keyword guard: %v", err)
+	}
+	events := &MockModerationRepository{}
+	service.SetGuardrails(guardrail.NewPipeline(keywordGuard, guardrail.NewSensitiveFlagGuard()), events)
+	return events
+}
+
+func TestSendMessage_InputBlocked(t *testing.T) {
+	service, messageRepo, fake := newFakeMiniMaxService(t, fakellm.Config{})
+	events := withGuardrails(t, service)
+
+	_, err := service.SendMessage(context.Background(), &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "这里有违禁内容"})
+	var blockedErr *ContentBlockedError
+	if !errors.As(err, &blockedErr) || blockedErr.Stage != guardrail.StageInput {
+		t.Fatalf("Expected input ContentBlockedError, got %v", err)
+	}
+	if fake.RequestCount() != 0 {
+		t.Errorf("Expected no upstream request for blocked input, got %d", fake.RequestCount())
+	}
+
+	stored, err := messageRepo.GetByID(blockedErr.MessageID)
+	if err != nil {
+		t.Fatalf("Expected blocked message stored: %v", err)
+	}
+	if stored.Content != guardrail.BlockedPlaceholder || stored.Moderation != model.ModerationBlocked {
+		t.Errorf("Expected placeholder with blocked status, got %+v", stored)
+	}
+	if len(events.events) != 1 || events.events[0].Content != "这里有违禁内容" || events.events[0].MessageID != stored.ID {
+		t.Errorf("Expected moderation event with original content, got %+v", events.events)
+	}
+
+	conversation, _ := service.conversationRepo.GetByID(1)
+	if conversation.ActiveLeafID != 0 {
+		t.Errorf("Expected blocked input to stay off the active branch, got leaf %d", conversation.ActiveLeafID)
+	}
+}
+
+func TestSendMessage_InputFlagged(t *testing.T) {
+	service, _, fake := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: "好的"}},
+	})
+	events := withGuardrails(t, service)
+
+	response, err := service.SendMessage(context.Background(), &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "一个可疑的问题"})
+	if err != nil {
+		t.Fatalf("Expected flagged input to be sent, got %v", err)
+	}
+	if response.UserMessage.Moderation != model.ModerationFlagged || response.UserMessage.Content != "一个可疑的问题" {
+		t.Errorf("Expected flagged user message with original content, got %+v", response.UserMessage)
+	}
+	if fake.RequestCount() != 1 {
+		t.Errorf("Expected 1 upstream request, got %d", fake.RequestCount())
+	}
+	if len(events.events) != 1 || events.events[0].Action != string(guardrail.ActionFlag) {
+		t.Errorf("Expected flag event recorded, got %+v", events.events)
+	}
+}
+
+func TestSendMessage_OutputBlockedByProviderFlag(t *testing.T) {
+	service, messageRepo, _ := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: "敏感回复", Sensitive: 2}},
+	})
+	events := withGuardrails(t, service)
+
+	_, err := service.SendMessage(context.Background(), &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "你好"})
+	var blockedErr *ContentBlockedError
+	if !errors.As(err, &blockedErr) || blockedErr.Stage != guardrail.StageOutput {
+		t.Fatalf("Expected output ContentBlockedError, got %v", err)
+	}
+	if blockedErr.Verdict.Reason != "output_sensitive_type=2" {
+		t.Errorf("Unexpected verdict: %+v", blockedErr.Verdict)
+	}
+
+	stored, _ := messageRepo.GetByID(blockedErr.MessageID)
+	if stored == nil || stored.Content != guardrail.BlockedPlaceholder || stored.Moderation != model.ModerationBlocked {
+		t.Errorf("Expected blocked reply stored as placeholder, got %+v", stored)
+	}
+	if len(events.events) != 1 || events.events[0].Content != "敏感回复" {
+		t.Errorf("Expected moderation event with original reply, got %+v", events.events)
+	}
+
+	branches, err := service.ListBranches(context.Background(), &ListBranchesRequest{ConversationID: 1, UserID: 1})
+	if err != nil {
+		t.Fatalf("Failed to list branches: %v", err)
+	}
+	if len(branches.Branches) != 0 || branches.ActiveLeafID != 0 {
+		t.Errorf("Expected blocked reply to form no branch, got %+v", branches)
+	}
+}
+
+func TestSendMessageStream_OutputBlockedMidStream(t *testing.T) {
+	service, _, fake := newFakeMiniMaxService(t, fakellm.Config{
+		Replies:   []fakellm.Reply{{Content: "前面正常后面出现违禁词以及更多内容"}},
+		ChunkSize: 2,
+	})
+	withGuardrails(t, service)
+
+	var deltas []string
+	_, err := service.SendMessageStream(context.Background(), &SendMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		Content:        "你好",
+		Model:          "MiniMax-M1",
+	}, StreamCallbacks{
+		OnDelta: func(content string) { deltas = append(deltas, content) },
+	})
+	var blockedErr *ContentBlockedError
+	if !errors.As(err, &blockedErr) || blockedErr.Stage != guardrail.StageOutput {
+		t.Fatalf("Expected output ContentBlockedError, got %v", err)
+	}
+	if streamed := strings.Join(deltas, ""); strings.Contains(streamed, "违禁") {
+		t.Errorf("Expected blocked content not to be streamed, got %q", streamed)
+	}
+	if fake.RequestCount() != 1 {
+		t.Errorf("Expected 1 upstream request, got %d", fake.RequestCount())
+	}
+}
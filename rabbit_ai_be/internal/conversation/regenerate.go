@@ -0,0 +1,109 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"rabbit_ai/internal/model"
+)
+
+// RegenerateMessageRequest 重新生成回复请求
+type RegenerateMessageRequest struct {
+	ConversationID int64    `json:"conversation_id"`
+	UserID         int64    `json:"user_id"`
+	MessageID      int64    `json:"message_id"`            // 要重新生成的轮次中的用户消息或回复消息
+	Model          string   `json:"model"`                 // 为空时沿用该轮的模型
+	Temperature    *float64 `json:"temperature,omitempty"` // 为空时使用对话设置
+	GenerationID   string   `json:"generation_id"`         // 生成ID，用于取消生成，为空时自动生成
+}
+
+// RegenerateMessage 为某一轮的用户消息重新生成回复
+//
+// 新回复作为该用户消息的另一个子分支保存并成为当前分支，原有回复及其后续消息
+// 保留在原分支中，可通过 SwitchBranch 切换回去。
+func (s *Service) RegenerateMessage(ctx context.Context, req *RegenerateMessageRequest) (*SendMessageResponse, error) {
+	ctx, done, err := s.startGeneration(ctx, req.UserID, req.ConversationID, &req.GenerationID)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	conversation, err := s.getOwnedConversation(req.UserID, req.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.messageRepo.GetConversationMessages(req.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation history: %w", err)
+	}
+
+	tree := newMessageTree(history)
+	userMessage, err := tree.turnOf(req.MessageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 模型依次取请求指定、该轮原模型、对话设置、目录默认模型
+	modelName := req.Model
+	if modelName == "" {
+		modelName = userMessage.Model
+	}
+	if modelName == "" {
+		modelName = conversation.Settings.Model
+	}
+	spec, err := s.lookupModel(modelName, false)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := conversation.Settings
+	if req.Temperature != nil {
+		if *req.Temperature <= 0 || *req.Temperature > 2 {
+			return nil, fmt.Errorf("%w: temperature must be in (0, 2]", ErrInvalidSettings)
+		}
+		settings.Temperature = req.Temperature
+	}
+
+	citations := s.retrieveKnowledge(ctx, req.UserID, settings, userMessage.Content)
+	pending := s.newPendingSend(ctx, conversation, userMessage, spec, settings, tree.path(userMessage.ID), citations)
+	pending.regenerate = true
+
+	chatReq := pending.chatReq
+	if s.tools != nil && s.tools.Len() > 0 && spec.Capabilities.Tools {
+		chatReq.Tools = s.tools.Definitions()
+	}
+
+	chatResp, toolMessages, err := s.runToolLoop(ctx, pending, chatReq)
+	if err != nil {
+		if generationCancelled(ctx) {
+			return nil, ErrGenerationCancelled
+		}
+		return nil, err
+	}
+
+	content := chatResp.GetContent()
+	if content == "" {
+		return nil, errors.New("empty response from AI")
+	}
+	pending.sensitive = chatResp.Sensitive
+
+	assistantMessage := &model.Message{
+		ConversationID: req.ConversationID,
+		Role:           "assistant",
+		Content:        content,
+		Model:          respondingModel(chatResp.Model, spec.ID),
+		FinishReason:   chatResp.FinishReason,
+		Tokens:         chatResp.Usage.TotalTokens,
+	}
+
+	sendReq := &SendMessageRequest{
+		ConversationID: req.ConversationID,
+		UserID:         req.UserID,
+		Content:        userMessage.Content,
+		Model:          spec.ID,
+		GenerationID:   req.GenerationID,
+	}
+	return s.completeSend(ctx, sendReq, pending, toolMessages, assistantMessage)
+}
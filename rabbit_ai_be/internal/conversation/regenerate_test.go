@@ -0,0 +1,86 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+
+	"rabbit_ai/internal/fakellm"
+)
+
+func TestRegenerateMessage_CreatesSiblingBranch(t *testing.T) {
+	service, messageRepo, fake := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: "第一版"}, {Content: "第二版"}},
+	})
+	ctx := context.Background()
+
+	first, err := service.SendMessage(ctx, &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "写一句诗"})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	temperature := 1.2
+	second, err := service.RegenerateMessage(ctx, &RegenerateMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		MessageID:      first.AssistantMessage.ID,
+		Model:          "MiniMax-Text-01",
+		Temperature:    &temperature,
+	})
+	if err != nil {
+		t.Fatalf("Failed to regenerate message: %v", err)
+	}
+
+	if second.AssistantMessage.Content != "第二版" || second.AssistantMessage.ParentID != first.UserMessage.ID {
+		t.Errorf("Expected new reply under the original user message, got %+v", second.AssistantMessage)
+	}
+	if second.UserMessage.ID != first.UserMessage.ID {
+		t.Errorf("Expected the original user message to be reused, got %d", second.UserMessage.ID)
+	}
+	if second.Conversation.ActiveLeafID != second.AssistantMessage.ID {
+		t.Errorf("Expected regenerated reply to become the active leaf, got %d", second.Conversation.ActiveLeafID)
+	}
+	if params := fake.LastParams(); params.Model != "MiniMax-Text-01" || params.Temperature != 1.2 {
+		t.Errorf("Expected overrides applied, got %+v", params)
+	}
+	messages := fake.LastMessages()
+	if len(messages) != 1 || messages[0] != "user: 写一句诗" {
+		t.Errorf("Expected previous reply excluded from history, got %v", messages)
+	}
+
+	if _, err := messageRepo.GetByID(first.AssistantMessage.ID); err != nil {
+		t.Errorf("Expected previous reply kept, got %v", err)
+	}
+	if second.Conversation.MessageCount != 3 {
+		t.Errorf("Expected message count 3, got %d", second.Conversation.MessageCount)
+	}
+}
+
+func TestRegenerateMessage_EarlierTurnDropsLaterTurnsFromPath(t *testing.T) {
+	service, _, fake := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: "一"}, {Content: "二"}, {Content: "一（新）"}},
+	})
+	ctx := context.Background()
+
+	first, err := service.SendMessage(ctx, &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "第一轮"})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if _, err := service.SendMessage(ctx, &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "第二轮"}); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	if _, err := service.RegenerateMessage(ctx, &RegenerateMessageRequest{ConversationID: 1, UserID: 1, MessageID: first.AssistantMessage.ID}); err != nil {
+		t.Fatalf("Failed to regenerate message: %v", err)
+	}
+	if messages := fake.LastMessages(); len(messages) != 1 || messages[0] != "user: 第一轮" {
+		t.Errorf("Expected only the first turn in history, got %v", messages)
+	}
+
+	response, err := service.GetConversationMessages(ctx, &GetConversationMessagesRequest{ConversationID: 1, UserID: 1})
+	if err != nil {
+		t.Fatalf("Failed to get messages: %v", err)
+	}
+	if response.Total != 2 || response.Messages[1].Content != "一（新）" {
+		t.Errorf("Expected active path [第一轮, 一（新）], got %d messages", response.Total)
+	}
+}
@@ -0,0 +1,169 @@
+package conversation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"rabbit_ai/internal/model"
+	"rabbit_ai/internal/textsearch"
+)
+
+// 关键词搜索参数
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+	searchBackfillSize = 500 // 每批补齐全文索引的行数
+)
+
+// ErrInvalidSearch 搜索参数无效
+var ErrInvalidSearch = errors.New("invalid search request")
+
+// SearchRequest 关键词搜索请求
+type SearchRequest struct {
+	UserID int64     `json:"user_id"`
+	Query  string    `json:"query"`
+	Role   string    `json:"role"`   // user/assistant，为空时不限
+	Model  string    `json:"model"`  // 为空时不限
+	From   time.Time `json:"from"`   // 时间下限（含）
+	To     time.Time `json:"to"`     // 时间上限（不含）
+	Cursor string    `json:"cursor"` // 上一页响应的 next_cursor
+	Limit  int       `json:"limit"`
+}
+
+// SearchResult 关键词搜索结果
+type SearchResult struct {
+	Type           string    `json:"type"` // message: 消息内容命中；conversation: 对话标题命中
+	ConversationID int64     `json:"conversation_id"`
+	Title          string    `json:"title"` // 对话标题
+	MessageID      int64     `json:"message_id,omitempty"`
+	Role           string    `json:"role,omitempty"`
+	Model          string    `json:"model,omitempty"`
+	Snippet        string    `json:"snippet"`    // 经过HTML转义、命中处以 <mark> 标记的片段
+	CreatedAt      time.Time `json:"created_at"` // 消息创建时间或对话最后消息时间
+}
+
+// SearchResponse 关键词搜索响应
+type SearchResponse struct {
+	Results    []*SearchResult `json:"results"`
+	NextCursor string          `json:"next_cursor,omitempty"` // 为空表示没有更多结果
+}
+
+// SetFullTextSearch 设置全文检索仓库
+func (s *Service) SetFullTextSearch(fullTextRepo model.FullTextRepository) {
+	s.fullTextRepo = fullTextRepo
+}
+
+// Search 在用户的对话标题和消息中按关键词搜索，结果按时间从新到旧排列
+//
+// 查询中的所有词都须出现；中文按相邻两字匹配，因此“红烧肉”可以命中“红烧肉做法”，但不会命中只含“红烧”的内容。
+func (s *Service) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	if s.fullTextRepo == nil {
+		return nil, errors.New("full-text search is not configured")
+	}
+
+	query := &model.FullTextQuery{
+		UserID:  req.UserID,
+		TSQuery: textsearch.Query(req.Query),
+		Role:    req.Role,
+		Model:   req.Model,
+		From:    req.From,
+		To:      req.To,
+		Limit:   req.Limit,
+	}
+	if query.TSQuery == "" {
+		return nil, fmt.Errorf("%w: query has no searchable words", ErrInvalidSearch)
+	}
+	if query.Role != "" && query.Role != "user" && query.Role != "assistant" {
+		return nil, fmt.Errorf("%w: role must be user or assistant", ErrInvalidSearch)
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidSearch)
+	}
+	if query.Limit <= 0 || query.Limit > MaxSearchLimit {
+		query.Limit = DefaultSearchLimit
+	}
+	if req.Cursor != "" {
+		cursor, err := decodeSearchCursor(req.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSearch, err)
+		}
+		query.After = cursor
+	}
+
+	// 多取一条判断是否还有下一页
+	limit := query.Limit
+	query.Limit++
+	hits, err := s.fullTextRepo.Search(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+
+	response := &SearchResponse{Results: []*SearchResult{}}
+	if len(hits) > limit {
+		hits = hits[:limit]
+		response.NextCursor = encodeSearchCursor(hits[limit-1].Cursor())
+	}
+	for _, hit := range hits {
+		result := &SearchResult{
+			Type:           hit.Kind,
+			ConversationID: hit.ConversationID,
+			Title:          hit.ConversationTitle,
+			Snippet:        textsearch.Snippet(hit.Content, req.Query, textsearch.DefaultSnippetRunes),
+			CreatedAt:      hit.CreatedAt,
+		}
+		if hit.Kind == model.FullTextKindMessage {
+			result.MessageID = hit.ID
+			result.Role = hit.Role
+			result.Model = hit.Model
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	return response, nil
+}
+
+// BackfillSearchIndex 为早期消息和对话补齐全文索引，直到全部完成或ctx结束
+func (s *Service) BackfillSearchIndex(ctx context.Context) {
+	total := 0
+	for ctx.Err() == nil {
+		count, err := s.fullTextRepo.Backfill(searchBackfillSize)
+		total += count
+		if err != nil {
+			log.Printf("failed to backfill search index: %v", err)
+			return
+		}
+		if count == 0 {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("Search index backfilled for %d rows", total)
+	}
+}
+
+// encodeSearchCursor 编码分页游标
+func encodeSearchCursor(cursor *model.FullTextCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeSearchCursor 解码分页游标
+func decodeSearchCursor(value string) (*model.FullTextCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+	var cursor model.FullTextCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return nil, errors.New("malformed cursor")
+	}
+	if cursor.Kind != model.FullTextKindMessage && cursor.Kind != model.FullTextKindConversation {
+		return nil, errors.New("malformed cursor")
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,115 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/model"
+)
+
+// MockFullTextRepository 模拟全文检索仓库，按游标从固定结果中分页
+type MockFullTextRepository struct {
+	hits    []*model.FullTextHit
+	queries []*model.FullTextQuery
+}
+
+func (m *MockFullTextRepository) Search(query *model.FullTextQuery) ([]*model.FullTextHit, error) {
+	m.queries = append(m.queries, query)
+	start := 0
+	if query.After != nil {
+		for i, hit := range m.hits {
+			if hit.Kind == query.After.Kind && hit.ID == query.After.ID {
+				start = i + 1
+			}
+		}
+	}
+	end := start + query.Limit
+	if end > len(m.hits) {
+		end = len(m.hits)
+	}
+	return m.hits[start:end], nil
+}
+
+func (m *MockFullTextRepository) Backfill(limit int) (int, error) {
+	return 0, nil
+}
+
+func TestSearch(t *testing.T) {
+	service, _, _ := newFakeMiniMaxService(t, fakellm.Config{})
+	now := time.Now()
+	repo := &MockFullTextRepository{hits: []*model.FullTextHit{
+		{Kind: model.FullTextKindMessage, ID: 3, ConversationID: 1, ConversationTitle: "家常菜", Role: "assistant", Model: "MiniMax-M1", Content: "红烧肉的做法如下", CreatedAt: now},
+		{Kind: model.FullTextKindConversation, ID: 1, ConversationID: 1, ConversationTitle: "红烧肉", Content: "红烧肉", CreatedAt: now.Add(-time.Minute)},
+		{Kind: model.FullTextKindMessage, ID: 1, ConversationID: 1, ConversationTitle: "家常菜", Role: "user", Content: "红烧肉怎么做", CreatedAt: now.Add(-time.Hour)},
+	}}
+	service.SetFullTextSearch(repo)
+	ctx := context.Background()
+
+	response, err := service.Search(ctx, &SearchRequest{UserID: 1, Query: "红烧肉", Limit: 2})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if query := repo.queries[0]; query.TSQuery != "'烧肉' & '红烧'" || query.UserID != 1 || query.Limit != 3 {
+		t.Errorf("Unexpected repository query: %+v", query)
+	}
+	if len(response.Results) != 2 || response.NextCursor == "" {
+		t.Fatalf("Expected first page of 2 with cursor, got %d results, cursor %q", len(response.Results), response.NextCursor)
+	}
+	first := response.Results[0]
+	if first.Type != model.FullTextKindMessage || first.MessageID != 3 || first.Snippet != "<mark>红烧肉</mark>的做法如下" {
+		t.Errorf("Unexpected message result: %+v", first)
+	}
+	if second := response.Results[1]; second.Type != model.FullTextKindConversation || second.MessageID != 0 || second.Snippet != "<mark>红烧肉</mark>" {
+		t.Errorf("Unexpected conversation result: %+v", second)
+	}
+
+	response, err = service.Search(ctx, &SearchRequest{UserID: 1, Query: "红烧肉", Limit: 2, Cursor: response.NextCursor})
+	if err != nil {
+		t.Fatalf("Search next page failed: %v", err)
+	}
+	if after := repo.queries[1].After; after == nil || after.Kind != model.FullTextKindConversation || after.ID != 1 {
+		t.Errorf("Expected cursor after conversation hit, got %+v", after)
+	}
+	if len(response.Results) != 1 || response.Results[0].MessageID != 1 || response.NextCursor != "" {
+		t.Errorf("Expected last page with 1 result, got %+v, cursor %q", response.Results, response.NextCursor)
+	}
+}
+
+func TestSearch_InvalidRequest(t *testing.T) {
+	service, _, _ := newFakeMiniMaxService(t, fakellm.Config{})
+	repo := &MockFullTextRepository{}
+	service.SetFullTextSearch(repo)
+	now := time.Now()
+
+	invalid := map[string]*SearchRequest{
+		"no words":     {UserID: 1, Query: "？！"},
+		"invalid role": {UserID: 1, Query: "你好", Role: "tool"},
+		"empty range":  {UserID: 1, Query: "你好", From: now, To: now},
+		"bad cursor":   {UserID: 1, Query: "你好", Cursor: "not-a-cursor"},
+	}
+	for name, req := range invalid {
+		if _, err := service.Search(context.Background(), req); !errors.Is(err, ErrInvalidSearch) {
+			t.Errorf("%s: expected ErrInvalidSearch, got %v", name, err)
+		}
+	}
+	if len(repo.queries) != 0 {
+		t.Errorf("Expected no repository query for invalid requests, got %d", len(repo.queries))
+	}
+}
+
+func TestParseSearchTime(t *testing.T) {
+	from, err := parseSearchTime("2024-01-02", false)
+	if err != nil || from.Day() != 2 {
+		t.Errorf("Expected start of day, got %v, %v", from, err)
+	}
+	to, err := parseSearchTime("2024-01-02", true)
+	if err != nil || to.Sub(from) != 24*time.Hour {
+		t.Errorf("Expected date upper bound to include the whole day, got %v, %v", to, err)
+	}
+	if _, err := parseSearchTime("yesterday", false); err == nil {
+		t.Error("Expected error for invalid time")
+	}
+}
@@ -2,29 +2,26 @@ package conversation
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"rabbit_ai/internal/cache"
-	"rabbit_ai/internal/minimax"
+	"rabbit_ai/internal/guardrail"
+	"rabbit_ai/internal/llm"
 	"rabbit_ai/internal/model"
+	"rabbit_ai/internal/structured"
+	"rabbit_ai/internal/tool"
 )
 
-// MiniMaxServiceInterface MiniMax服务接口
-type MiniMaxServiceInterface interface {
-	ChatCompletion(request minimax.ChatCompletionRequest) (*minimax.ChatCompletionResponse, error)
-	ChatCompletionStream(request minimax.ChatCompletionRequest) (<-chan minimax.ChatCompletionResponse, error)
-	SimpleChat(userMessage string) (string, error)
-	SimpleChatWithParams(userMessage string, temperature float64, maxTokens int) (string, error)
-	GetResponseContent(response *minimax.ChatCompletionResponse) (string, error)
-	GetUsage(response *minimax.ChatCompletionResponse) *minimax.Usage
-	IsRateLimited(response *minimax.ChatCompletionResponse) bool
-	IsAuthFailed(response *minimax.ChatCompletionResponse) bool
-	IsInsufficientBalance(response *minimax.ChatCompletionResponse) bool
-	IsTokenLimited(response *minimax.ChatCompletionResponse) bool
-}
+// DefaultMaxToolSteps 单次发送消息允许的最多工具调用轮数
+const DefaultMaxToolSteps = 5
+
+// ErrConversationForbidden 对话不属于该用户
+var ErrConversationForbidden = errors.New("conversation does not belong to user")
 
 // Service 对话服务
 type Service struct {
@@ -32,7 +29,21 @@ type Service struct {
 	messageRepo       model.MessageRepository
 	userRepo          model.UserRepository
 	conversationCache *cache.ConversationCache
-	minimaxService    MiniMaxServiceInterface
+	llmProvider       llm.Provider
+	tools             *tool.Registry
+	maxToolSteps      int
+	catalog           *llm.Catalog
+	summaryPolicy     SummaryPolicy
+	titlePolicy       TitlePolicy
+	summarizing       sync.Map // 正在生成摘要的对话ID
+	generations       *generationRegistry
+	guardrails        *guardrail.Pipeline
+	moderationRepo    model.ModerationRepository
+	fullTextRepo      model.FullTextRepository
+	knowledge         KnowledgeRetriever
+	knowledgePolicy   KnowledgePolicy
+	images            ImageStore
+	templates         TemplateRenderer
 }
 
 // NewService 创建对话服务实例
@@ -41,21 +52,41 @@ func NewService(
 	messageRepo model.MessageRepository,
 	userRepo model.UserRepository,
 	conversationCache *cache.ConversationCache,
-	minimaxService MiniMaxServiceInterface,
+	llmProvider llm.Provider,
 ) *Service {
 	return &Service{
 		conversationRepo:  conversationRepo,
 		messageRepo:       messageRepo,
 		userRepo:          userRepo,
 		conversationCache: conversationCache,
-		minimaxService:    minimaxService,
+		llmProvider:       llmProvider,
+		catalog:           llm.DefaultCatalog(),
+		maxToolSteps:      DefaultMaxToolSteps,
+		generations:       newGenerationRegistry(),
+	}
+}
+
+// SetModelCatalog 设置模型目录，请求的模型须在目录中启用
+func (s *Service) SetModelCatalog(catalog *llm.Catalog) {
+	s.catalog = catalog
+}
+
+// SetToolRegistry 设置工具注册表，未设置或为空时不向模型声明工具
+func (s *Service) SetToolRegistry(registry *tool.Registry, maxSteps int) {
+	s.tools = registry
+	if maxSteps > 0 {
+		s.maxToolSteps = maxSteps
 	}
 }
 
 // CreateConversationRequest 创建对话请求
 type CreateConversationRequest struct {
-	UserID int64  `json:"user_id" binding:"required"`
-	Title  string `json:"title" binding:"required"`
+	UserID          int64                      `json:"user_id" binding:"required"`
+	Title           string                     `json:"title"`            // 为空时使用默认标题，首轮对话后自动生成
+	Settings        model.ConversationSettings `json:"settings"`         // 系统提示与生成参数，可选
+	TemplateID      int64                      `json:"template_id"`      // 以模板渲染的系统提示创建对话，与 settings.system_prompt 二选一
+	TemplateVersion int                        `json:"template_version"` // 模板版本，为0时使用当前版本
+	Variables       map[string]string          `json:"variables"`        // 模板变量
 }
 
 // CreateConversationResponse 创建对话响应
@@ -63,6 +94,17 @@ type CreateConversationResponse struct {
 	Conversation *model.Conversation `json:"conversation"`
 }
 
+// GetConversationRequest 获取对话详情请求
+type GetConversationRequest struct {
+	ConversationID int64 `json:"conversation_id" binding:"required"`
+	UserID         int64 `json:"user_id" binding:"required"`
+}
+
+// GetConversationResponse 获取对话详情响应
+type GetConversationResponse struct {
+	Conversation *model.Conversation `json:"conversation"`
+}
+
 // GetConversationsRequest 获取对话列表请求
 type GetConversationsRequest struct {
 	UserID int64 `json:"user_id" binding:"required"`
@@ -79,8 +121,10 @@ type GetConversationsResponse struct {
 // GetConversationMessagesRequest 获取对话消息请求
 type GetConversationMessagesRequest struct {
 	ConversationID int64 `json:"conversation_id" binding:"required"`
+	UserID         int64 `json:"user_id" binding:"required"`
 	Limit          int   `json:"limit"`
 	Offset         int   `json:"offset"`
+	All            bool  `json:"all"` // 返回全部分支的消息，默认只返回当前分支
 }
 
 // GetConversationMessagesResponse 获取对话消息响应
@@ -91,17 +135,30 @@ type GetConversationMessagesResponse struct {
 
 // SendMessageRequest 发送消息请求
 type SendMessageRequest struct {
-	ConversationID int64  `json:"conversation_id" binding:"required"`
-	UserID         int64  `json:"user_id" binding:"required"`
-	Content        string `json:"content" binding:"required"`
-	Model          string `json:"model"`
+	ConversationID  int64                      `json:"conversation_id" binding:"required"`
+	UserID          int64                      `json:"user_id" binding:"required"`
+	Content         string                     `json:"content"`          // 文字内容，与 parts 二选一
+	Parts           []model.ContentPart        `json:"parts"`            // 图文内容片段（文字与已上传图片的引用）
+	TemplateID      int64                      `json:"template_id"`      // 以模板渲染的内容作为消息，与 content、parts 互斥
+	TemplateVersion int                        `json:"template_version"` // 模板版本，为0时使用当前版本
+	Variables       map[string]string          `json:"variables"`        // 模板变量
+	Model           string                     `json:"model"`
+	Stream          bool                       `json:"stream"`          // 是否以SSE流式返回AI回复
+	GenerationID    string                     `json:"generation_id"`   // 生成ID，用于取消生成，为空时自动生成
+	ResponseFormat  *structured.ResponseFormat `json:"response_format"` // 结构化回复格式，回复须为满足 Schema 的JSON，不支持流式
+	EditMessageID   int64                      `json:"-"`               // 编辑的历史用户消息ID，新消息作为其兄弟节点创建新分支
 }
 
 // SendMessageResponse 发送消息响应
 type SendMessageResponse struct {
 	UserMessage      *model.Message      `json:"user_message"`
+	ToolMessages     []*model.Message    `json:"tool_messages,omitempty"` // 工具调用过程中产生的中间消息（按顺序）
 	AssistantMessage *model.Message      `json:"assistant_message"`
 	Conversation     *model.Conversation `json:"conversation"`
+	GenerationID     string              `json:"generation_id"`
+	TitlePending     bool                `json:"title_pending,omitempty"` // 正在后台生成标题，完成后通过事件流推送
+	Output           json.RawMessage     `json:"output,omitempty"`        // 指定 response_format 时解析出的JSON结果
+	Attempts         int                 `json:"attempts,omitempty"`      // 指定 response_format 时调用模型的次数
 }
 
 // DeleteConversationRequest 删除对话请求
@@ -112,18 +169,34 @@ type DeleteConversationRequest struct {
 
 // CreateConversation 创建新对话
 func (s *Service) CreateConversation(ctx context.Context, req *CreateConversationRequest) (*CreateConversationResponse, error) {
+	if err := s.applyConversationTemplate(ctx, req); err != nil {
+		return nil, err
+	}
+	if err := s.validateSettings(&req.Settings); err != nil {
+		return nil, err
+	}
+	if err := s.checkKnowledgeBases(req.UserID, &req.Settings); err != nil {
+		return nil, err
+	}
+
 	// 验证用户是否存在
 	_, err := s.userRepo.GetByID(req.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("user not found: %w", err)
 	}
 
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		title = DefaultTitle
+	}
+
 	// 创建对话
 	conversation := &model.Conversation{
 		UserID:       req.UserID,
-		Title:        req.Title,
+		Title:        title,
 		Status:       1,
 		MessageCount: 0,
+		Settings:     req.Settings,
 	}
 
 	err = s.conversationRepo.Create(conversation)
@@ -149,6 +222,37 @@ func (s *Service) CreateConversation(ctx context.Context, req *CreateConversatio
 	}, nil
 }
 
+// GetConversation 获取对话详情（包含滚动摘要）
+func (s *Service) GetConversation(ctx context.Context, req *GetConversationRequest) (*GetConversationResponse, error) {
+	// 先从缓存获取
+	conversation, err := s.conversationCache.GetConversation(ctx, req.ConversationID)
+	if err != nil {
+		fmt.Printf("failed to get conversation from cache: %v\n", err)
+	}
+
+	// 缓存未命中，从数据库获取
+	if conversation == nil {
+		conversation, err = s.conversationRepo.GetByID(req.ConversationID)
+		if err != nil {
+			return nil, fmt.Errorf("conversation not found: %w", err)
+		}
+
+		err = s.conversationCache.SetConversation(ctx, conversation)
+		if err != nil {
+			fmt.Printf("failed to cache conversation: %v\n", err)
+		}
+	}
+
+	// 验证对话是否属于该用户
+	if conversation.UserID != req.UserID {
+		return nil, errors.New("conversation does not belong to user")
+	}
+
+	return &GetConversationResponse{
+		Conversation: conversation,
+	}, nil
+}
+
 // GetConversations 获取用户对话列表
 func (s *Service) GetConversations(ctx context.Context, req *GetConversationsRequest) (*GetConversationsResponse, error) {
 	// 验证用户是否存在
@@ -199,10 +303,9 @@ func (s *Service) GetConversations(ctx context.Context, req *GetConversationsReq
 
 // GetConversationMessages 获取对话消息
 func (s *Service) GetConversationMessages(ctx context.Context, req *GetConversationMessagesRequest) (*GetConversationMessagesResponse, error) {
-	// 验证对话是否存在
-	_, err := s.conversationRepo.GetByID(req.ConversationID)
+	conversation, err := s.getOwnedConversation(req.UserID, req.ConversationID)
 	if err != nil {
-		return nil, fmt.Errorf("conversation not found: %w", err)
+		return nil, err
 	}
 
 	// 设置默认分页参数
@@ -219,9 +322,9 @@ func (s *Service) GetConversationMessages(ctx context.Context, req *GetConversat
 		fmt.Printf("failed to get messages from cache: %v\n", err)
 	}
 
-	// 缓存未命中，从数据库获取
+	// 缓存未命中，从数据库获取（缓存对话的全部消息，分支与分页在内存中处理）
 	if messages == nil {
-		messages, err = s.messageRepo.GetByConversationID(req.ConversationID, req.Limit, req.Offset)
+		messages, err = s.messageRepo.GetConversationMessages(req.ConversationID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get messages: %w", err)
 		}
@@ -233,10 +336,19 @@ func (s *Service) GetConversationMessages(ctx context.Context, req *GetConversat
 		}
 	}
 
-	// 获取总数
-	total, err := s.messageRepo.GetConversationMessageCount(req.ConversationID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get message count: %w", err)
+	if !req.All {
+		messages = newMessageTree(messages).path(conversation.ActiveLeafID)
+	}
+	total := len(messages)
+
+	if req.Offset >= total {
+		messages = []*model.Message{}
+	} else {
+		end := req.Offset + req.Limit
+		if end > total {
+			end = total
+		}
+		messages = messages[req.Offset:end]
 	}
 
 	return &GetConversationMessagesResponse{
@@ -246,47 +358,157 @@ func (s *Service) GetConversationMessages(ctx context.Context, req *GetConversat
 }
 
 // SendMessage 发送消息并获取AI回复
+//
+// 指定 response_format 时不声明工具，回复未通过 Schema 校验时带着校验错误重新提示，
+// 保存的AI回复为解析出的JSON；重新提示达到上限仍失败时返回 *structured.OutputError。
 func (s *Service) SendMessage(ctx context.Context, req *SendMessageRequest) (*SendMessageResponse, error) {
-	// 验证用户是否存在
-	_, err := s.userRepo.GetByID(req.UserID)
+	output, err := newStructuredOutput(req, false)
 	if err != nil {
-		return nil, fmt.Errorf("user not found: %w", err)
+		return nil, err
 	}
 
-	// 验证对话是否存在
-	conversation, err := s.conversationRepo.GetByID(req.ConversationID)
+	ctx, done, err := s.startGeneration(ctx, req.UserID, req.ConversationID, &req.GenerationID)
 	if err != nil {
-		return nil, fmt.Errorf("conversation not found: %w", err)
+		return nil, err
 	}
+	defer done()
 
-	// 验证对话是否属于该用户
-	if conversation.UserID != req.UserID {
-		return nil, errors.New("conversation does not belong to user")
+	pending, err := s.prepareSend(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		chatResp     *llm.ChatResponse
+		toolMessages []*model.Message
+		result       *structured.Result
+	)
+	if output != nil {
+		result, err = s.generateStructured(ctx, output, pending)
+		if err == nil {
+			chatResp = result.Response
+		}
+	} else {
+		chatReq := pending.chatReq
+		if s.tools != nil && s.tools.Len() > 0 && pending.spec.Capabilities.Tools {
+			chatReq.Tools = s.tools.Definitions()
+		}
+		chatResp, toolMessages, err = s.runToolLoop(ctx, pending, chatReq)
+	}
+	if err != nil {
+		if generationCancelled(ctx) {
+			return nil, ErrGenerationCancelled
+		}
+		return nil, err
+	}
+
+	content := chatResp.GetContent()
+	if content == "" {
+		return nil, errors.New("empty response from AI")
+	}
+	pending.sensitive = chatResp.Sensitive
+
+	// 创建AI回复消息
+	assistantMessage := &model.Message{
+		ConversationID: req.ConversationID,
+		Role:           "assistant",
+		Content:        content,
+		Model:          respondingModel(chatResp.Model, req.Model),
+		FinishReason:   chatResp.FinishReason,
+		Tokens:         chatResp.Usage.TotalTokens,
+	}
+
+	response, err := s.completeSend(ctx, req, pending, toolMessages, assistantMessage)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		response.Output = result.Value
+		response.Attempts = result.Attempts
 	}
+	return response, nil
+}
 
-	// 设置默认模型
+// pendingSend 发送消息过程中调用模型前准备好的状态
+type pendingSend struct {
+	conversation  *model.Conversation
+	userMessage   *model.Message
+	spec          llm.ModelSpec
+	chatReq       llm.ChatRequest
+	contextReport *model.ContextReport // 上下文裁剪报告，未裁剪时为nil
+	regenerate    bool                 // 重新生成：用户消息已存在
+	sensitive     *llm.SensitiveFlags  // 提供方对本轮回复的敏感内容标记
+	citations     []model.Citation     // 注入上下文的知识库片段
+}
+
+// prepareSend 校验对话与模型、保存用户消息并根据历史构建大模型请求
+func (s *Service) prepareSend(ctx context.Context, req *SendMessageRequest, stream bool) (*pendingSend, error) {
+	conversation, err := s.getOwnedConversation(req.UserID, req.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 未指定模型时使用对话设置的模型，再退回目录默认模型
 	if req.Model == "" {
-		req.Model = "glm-4"
+		req.Model = conversation.Settings.Model
+	}
+	spec, err := s.lookupModel(req.Model, stream)
+	if err != nil {
+		return nil, err
+	}
+	req.Model = spec.ID
+
+	rendered, err := s.applyMessageTemplate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := s.normalizeContent(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if parts.HasImages() && !spec.Capabilities.Vision {
+		return nil, fmt.Errorf("%w: %s does not support images", llm.ErrUnsupportedCapability, spec.ID)
+	}
+
+	// 新消息接在当前分支之后；编辑历史用户消息时与其共享父消息，形成新分支
+	parentID := conversation.ActiveLeafID
+	if req.EditMessageID != 0 {
+		edited, err := s.messageRepo.GetByID(req.EditMessageID)
+		if err != nil || edited.ConversationID != req.ConversationID {
+			return nil, fmt.Errorf("%w: %d", model.ErrMessageNotFound, req.EditMessageID)
+		}
+		if edited.Role != llm.RoleUser {
+			return nil, ErrNotUserMessage
+		}
+		parentID = edited.ParentID
 	}
 
 	// 创建用户消息
 	userMessage := &model.Message{
 		ConversationID: req.ConversationID,
+		ParentID:       parentID,
 		Role:           "user",
 		Content:        req.Content,
+		Parts:          parts,
 		Model:          req.Model,
 	}
+	if rendered != nil {
+		userMessage.TemplateID = rendered.TemplateID
+		userMessage.TemplateVersion = rendered.Version
+	}
 
-	err = s.messageRepo.Create(userMessage)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create user message: %w", err)
+	// 调用模型前检查用户输入：屏蔽时保存占位消息并返回错误，不调用模型
+	verdict := s.checkContent(ctx, guardrail.StageInput, req.Content, nil)
+	original := applyVerdict(userMessage, verdict)
+	if verdict.Blocked() {
+		return nil, s.blockInput(ctx, req, conversation, userMessage, verdict, original)
 	}
 
-	// 缓存用户消息
-	err = s.conversationCache.SetMessage(ctx, userMessage)
+	err = s.saveMessage(ctx, userMessage)
 	if err != nil {
-		fmt.Printf("failed to cache user message: %v\n", err)
+		return nil, fmt.Errorf("failed to create user message: %w", err)
 	}
+	s.recordModeration(req.UserID, userMessage, guardrail.StageInput, verdict, original)
 
 	// 获取对话历史消息
 	historyMessages, err := s.messageRepo.GetConversationMessages(req.ConversationID)
@@ -294,69 +516,111 @@ func (s *Service) SendMessage(ctx context.Context, req *SendMessageRequest) (*Se
 		return nil, fmt.Errorf("failed to get conversation history: %w", err)
 	}
 
-	// 构建MiniMax请求消息
-	var minimaxMessages []minimax.ChatMessage
-	for _, msg := range historyMessages {
-		minimaxMessages = append(minimaxMessages, minimax.ChatMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		})
-	}
+	history := newMessageTree(historyMessages).path(userMessage.ID)
+	citations := s.retrieveKnowledge(ctx, req.UserID, conversation.Settings, req.Content)
+	return s.newPendingSend(ctx, conversation, userMessage, spec, conversation.Settings, history, citations), nil
+}
 
-	// 调用MiniMax API
-	minimaxReq := minimax.NewChatCompletionRequest(req.Model, minimaxMessages).
-		WithMaxTokens(2048).
-		WithTemperature(0.7).
-		WithUser(fmt.Sprintf("user_%d", req.UserID))
+// getOwnedConversation 校验用户存在且对话属于该用户
+func (s *Service) getOwnedConversation(userID, conversationID int64) (*model.Conversation, error) {
+	// 验证用户是否存在
+	_, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
 
-	minimaxResp, err := s.minimaxService.ChatCompletion(*minimaxReq)
+	// 验证对话是否存在
+	conversation, err := s.conversationRepo.GetByID(conversationID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get AI response: %w", err)
+		return nil, fmt.Errorf("conversation not found: %w", err)
 	}
 
-	// 检查MiniMax响应
-	if !minimaxResp.IsSuccess() {
-		err := minimaxResp.GetError()
-		return nil, fmt.Errorf("minimax API error: %d - %s", err.Code, err.Message)
+	// 验证对话是否属于该用户
+	if conversation.UserID != userID {
+		return nil, ErrConversationForbidden
 	}
 
-	content := minimaxResp.GetContent()
-	if content == "" {
-		return nil, errors.New("empty response from AI")
+	return conversation, nil
+}
+
+// lookupModel 在模型目录中查询模型，为空时使用目录默认模型
+func (s *Service) lookupModel(modelName string, stream bool) (llm.ModelSpec, error) {
+	if modelName == "" {
+		modelName = s.catalog.DefaultModel()
+	}
+	spec, err := s.catalog.Lookup(modelName)
+	if err != nil {
+		return spec, err
 	}
+	if stream && !spec.Capabilities.Streaming {
+		return spec, fmt.Errorf("%w: %s does not support streaming", llm.ErrUnsupportedCapability, spec.ID)
+	}
+	return spec, nil
+}
 
-	// 创建AI回复消息
-	assistantMessage := &model.Message{
-		ConversationID: req.ConversationID,
-		Role:           "assistant",
-		Content:        content,
-		Model:          req.Model,
-		FinishReason:   minimaxResp.GetFinishReason(),
-		Tokens:         minimaxResp.Usage.TotalTokens,
+// newPendingSend 根据历史（当前分支从根到 userMessage 的路径）和检索到的知识库片段构建大模型请求
+func (s *Service) newPendingSend(ctx context.Context, conversation *model.Conversation, userMessage *model.Message, spec llm.ModelSpec, settings model.ConversationSettings, history []*model.Message, citations []model.Citation) *pendingSend {
+	// 构建大模型请求消息：系统提示和知识库资料在前，已摘要的轮次以摘要代替，再按上下文窗口裁剪最早的轮次
+	entries := make([]contextEntry, 0, len(history)+3)
+	if settings.SystemPrompt != "" {
+		entries = append(entries, contextEntry{message: llm.Message{
+			Role:    llm.RoleSystem,
+			Content: settings.SystemPrompt,
+		}})
+	}
+	if len(citations) > 0 {
+		entries = append(entries, knowledgeEntry(citations))
+	}
+	summary := pathSummary(conversation.Summary, history)
+	if summary != nil {
+		entries = append(entries, summaryEntry(summary))
+	}
+	for _, msg := range unsummarized(history, summary) {
+		entries = append(entries, contextEntry{message: s.replayMessage(ctx, conversation.UserID, msg, spec.Capabilities.Vision), messageID: msg.ID})
 	}
 
-	err = s.messageRepo.Create(assistantMessage)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create assistant message: %w", err)
+	chatReq := newChatRequest(spec, settings)
+	chatReq.User = fmt.Sprintf("user_%d", conversation.UserID)
+	var report *model.ContextReport
+	chatReq.Messages, report = fitContext(entries, spec, chatReq.MaxTokens)
+
+	return &pendingSend{
+		conversation:  conversation,
+		userMessage:   userMessage,
+		spec:          spec,
+		chatReq:       chatReq,
+		contextReport: report,
+		citations:     citations,
 	}
+}
+
+// completeSend 检查并保存AI回复、更新对话信息并刷新缓存
+//
+// 回复被护栏屏蔽时仍保存（内容替换为占位提示）并更新消息数，但不进入当前分支，
+// 返回 ContentBlockedError。
+func (s *Service) completeSend(ctx context.Context, req *SendMessageRequest, pending *pendingSend, toolMessages []*model.Message, assistantMessage *model.Message) (*SendMessageResponse, error) {
+	conversation := pending.conversation
+	assistantMessage.ContextReport = pending.contextReport
+	assistantMessage.Citations = usedCitations(assistantMessage.Content, pending.citations)
+	assistantMessage.ParentID = pending.lastMessageID(toolMessages)
 
-	// 缓存AI消息
-	err = s.conversationCache.SetMessage(ctx, assistantMessage)
+	verdict := s.checkContent(ctx, guardrail.StageOutput, assistantMessage.Content, pending.sensitive)
+	original := applyVerdict(assistantMessage, verdict)
+
+	err := s.saveMessage(ctx, assistantMessage)
 	if err != nil {
-		fmt.Printf("failed to cache assistant message: %v\n", err)
+		return nil, fmt.Errorf("failed to create assistant message: %w", err)
 	}
+	s.recordModeration(req.UserID, assistantMessage, guardrail.StageOutput, verdict, original)
 
-	// 更新对话信息
-	conversation.MessageCount += 2 // 用户消息 + AI回复
+	// 更新对话信息：工具调用中间消息 + AI回复，非重新生成时还有用户消息
+	conversation.MessageCount += 1 + len(toolMessages)
+	if !pending.regenerate {
+		conversation.MessageCount++
+	}
 	conversation.LastMessageAt = time.Now()
-
-	// 如果对话标题为空或为默认标题，使用用户消息的前20个字符作为标题
-	if conversation.Title == "" || conversation.Title == "新对话" {
-		title := strings.TrimSpace(req.Content)
-		if len(title) > 20 {
-			title = title[:20] + "..."
-		}
-		conversation.Title = title
+	if !verdict.Blocked() {
+		conversation.ActiveLeafID = assistantMessage.ID
 	}
 
 	err = s.conversationRepo.Update(conversation)
@@ -364,6 +628,21 @@ func (s *Service) SendMessage(ctx context.Context, req *SendMessageRequest) (*Se
 		return nil, fmt.Errorf("failed to update conversation: %w", err)
 	}
 
+	// 对话标题为空或为默认标题时，先以截断的用户消息作为临时标题，再在后台由模型生成标题
+	var placeholderTitle string
+	if needsTitle(conversation.Title) {
+		placeholderTitle = fallbackTitle(req.Content)
+		updated, err := s.conversationRepo.UpdateTitle(conversation.ID, conversation.Title, placeholderTitle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update conversation title: %w", err)
+		}
+		if updated {
+			conversation.Title = placeholderTitle
+		} else {
+			placeholderTitle = ""
+		}
+	}
+
 	// 缓存更新后的对话信息
 	err = s.conversationCache.SetConversation(ctx, conversation)
 	if err != nil {
@@ -381,13 +660,148 @@ func (s *Service) SendMessage(ctx context.Context, req *SendMessageRequest) (*Se
 		fmt.Printf("failed to invalidate user cache: %v\n", err)
 	}
 
+	if verdict.Blocked() {
+		return nil, &ContentBlockedError{
+			Stage:     guardrail.StageOutput,
+			Verdict:   verdict,
+			MessageID: assistantMessage.ID,
+		}
+	}
+
+	// 历史较长时在后台压缩较早的轮次
+	s.summarizeInBackground(req.ConversationID, req.Model)
+
+	titlePending := placeholderTitle != "" && s.titlePolicy.Enabled
+	if titlePending {
+		s.titleInBackground(req.ConversationID, req.UserID, req.Model, placeholderTitle, req.Content, assistantMessage.Content)
+	}
+
 	return &SendMessageResponse{
-		UserMessage:      userMessage,
+		UserMessage:      pending.userMessage,
+		ToolMessages:     toolMessages,
 		AssistantMessage: assistantMessage,
 		Conversation:     conversation,
+		GenerationID:     req.GenerationID,
+		TitlePending:     titlePending,
 	}, nil
 }
 
+// runToolLoop 调用大模型并执行其请求的工具，直到得到最终回复
+//
+// 每一轮中模型请求的工具调用（assistant消息）和工具结果（tool消息）都会保存，
+// 以便后续加载历史时能够完整重放。达到 maxToolSteps 后最后一次调用不再声明工具，
+// 迫使模型给出最终回复。
+func (s *Service) runToolLoop(ctx context.Context, pending *pendingSend, chatReq llm.ChatRequest) (*llm.ChatResponse, []*model.Message, error) {
+	var toolMessages []*model.Message
+
+	for step := 1; ; step++ {
+		if step > s.maxToolSteps {
+			chatReq.Tools = nil
+		}
+
+		chatResp, err := s.llmProvider.ChatCompletion(ctx, chatReq)
+		if err != nil {
+			return nil, toolMessages, fmt.Errorf("failed to get AI response: %w", err)
+		}
+		if len(chatResp.Message.ToolCalls) == 0 || len(chatReq.Tools) == 0 {
+			return chatResp, toolMessages, nil
+		}
+
+		// 保存请求工具调用的assistant消息
+		callMessage := &model.Message{
+			ConversationID: pending.conversation.ID,
+			Role:           "assistant",
+			Content:        chatResp.GetContent(),
+			Model:          respondingModel(chatResp.Model, chatReq.Model),
+			FinishReason:   chatResp.FinishReason,
+			Tokens:         chatResp.Usage.TotalTokens,
+			ParentID:       pending.lastMessageID(toolMessages),
+		}
+		for _, call := range chatResp.Message.ToolCalls {
+			callMessage.ToolCalls = append(callMessage.ToolCalls, model.ToolCall{
+				ID:        call.ID,
+				Name:      call.Name,
+				Arguments: call.Arguments,
+			})
+		}
+		if err := s.saveMessage(ctx, callMessage); err != nil {
+			return nil, toolMessages, fmt.Errorf("failed to create tool call message: %w", err)
+		}
+		toolMessages = append(toolMessages, callMessage)
+		chatReq.Messages = append(chatReq.Messages, toLLMMessage(callMessage))
+
+		// 依次执行工具，错误信息作为工具结果回传给模型
+		for _, call := range chatResp.Message.ToolCalls {
+			result, err := s.tools.Call(ctx, call.Name, call.Arguments)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, toolMessages, ctx.Err()
+				}
+				errJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+				result = string(errJSON)
+			}
+
+			resultMessage := &model.Message{
+				ConversationID: pending.conversation.ID,
+				Role:           llm.RoleTool,
+				Content:        result,
+				Model:          chatReq.Model,
+				ToolCallID:     call.ID,
+				ParentID:       pending.lastMessageID(toolMessages),
+			}
+			if err := s.saveMessage(ctx, resultMessage); err != nil {
+				return nil, toolMessages, fmt.Errorf("failed to create tool result message: %w", err)
+			}
+			toolMessages = append(toolMessages, resultMessage)
+			chatReq.Messages = append(chatReq.Messages, toLLMMessage(resultMessage))
+		}
+	}
+}
+
+// lastMessageID 本轮最后保存的消息ID，作为下一条消息的父消息
+func (p *pendingSend) lastMessageID(toolMessages []*model.Message) int64 {
+	if len(toolMessages) > 0 {
+		return toolMessages[len(toolMessages)-1].ID
+	}
+	return p.userMessage.ID
+}
+
+// saveMessage 保存消息并写入缓存
+func (s *Service) saveMessage(ctx context.Context, message *model.Message) error {
+	if err := s.messageRepo.Create(message); err != nil {
+		return err
+	}
+	if err := s.conversationCache.SetMessage(ctx, message); err != nil {
+		fmt.Printf("failed to cache message: %v\n", err)
+	}
+	return nil
+}
+
+// respondingModel 实际回答的模型（故障转移时为备用模型），提供方未返回时为请求的模型
+func respondingModel(actual, requested string) string {
+	if actual != "" {
+		return actual
+	}
+	return requested
+}
+
+// toLLMMessage 将存储的消息转换为大模型请求消息
+func toLLMMessage(msg *model.Message) llm.Message {
+	message := llm.Message{
+		Role:       msg.Role,
+		Content:    msg.Content,
+		ToolCallID: msg.ToolCallID,
+	}
+	for _, call := range msg.ToolCalls {
+		message.ToolCalls = append(message.ToolCalls, llm.ToolCall{
+			ID:        call.ID,
+			Name:      call.Name,
+			Arguments: call.Arguments,
+		})
+	}
+	return message
+}
+
 // DeleteConversation 删除对话
 func (s *Service) DeleteConversation(ctx context.Context, req *DeleteConversationRequest) error {
 	// 验证用户是否存在
@@ -2,11 +2,19 @@ package conversation
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
 	"testing"
+	"time"
 
 	"rabbit_ai/internal/cache"
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/llm"
 	"rabbit_ai/internal/minimax"
 	"rabbit_ai/internal/model"
+	"rabbit_ai/internal/tool"
 )
 
 // MockConversationRepository 模拟对话仓库
@@ -72,6 +80,33 @@ func (m *MockConversationRepository) GetUserConversationCount(userID int64) (int
 	return count, nil
 }
 
+func (m *MockConversationRepository) UpdateSummary(id int64, summary *model.ConversationSummary) error {
+	conv, exists := m.conversations[id]
+	if !exists {
+		return model.ErrConversationNotFound
+	}
+	conv.Summary = summary
+	return nil
+}
+
+func (m *MockConversationRepository) UpdateSettings(id int64, settings model.ConversationSettings) error {
+	conv, exists := m.conversations[id]
+	if !exists {
+		return model.ErrConversationNotFound
+	}
+	conv.Settings = settings
+	return nil
+}
+
+func (m *MockConversationRepository) UpdateTitle(id int64, from, to string) (bool, error) {
+	conv, exists := m.conversations[id]
+	if !exists || conv.Title != from {
+		return false, nil
+	}
+	conv.Title = to
+	return true, nil
+}
+
 // MockMessageRepository 模拟消息仓库
 type MockMessageRepository struct {
 	messages map[int64]*model.Message
@@ -116,6 +151,8 @@ func (m *MockMessageRepository) GetConversationMessages(conversationID int64) ([
 			messages = append(messages, msg)
 		}
 	}
+	// 与数据库实现一致，按创建顺序返回
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
 	return messages, nil
 }
 
@@ -232,81 +269,46 @@ func (m *MockUserRepository) UpdatePassword(userID int64, newPassword string) er
 	return nil
 }
 
-// MockMiniMaxService 模拟MiniMax服务
-type MockMiniMaxService struct{}
+// MockLLMProvider 模拟大模型提供方
+type MockLLMProvider struct{}
 
-func NewMockMiniMaxService() *MockMiniMaxService {
-	return &MockMiniMaxService{}
+func NewMockLLMProvider() *MockLLMProvider {
+	return &MockLLMProvider{}
 }
 
-func (m *MockMiniMaxService) ChatCompletion(request minimax.ChatCompletionRequest) (*minimax.ChatCompletionResponse, error) {
-	return &minimax.ChatCompletionResponse{
-		ID: "test_id",
-		Choices: []minimax.Choice{
-			{
-				FinishReason: "stop",
-				Index:        0,
-				Message: minimax.ChatMessage{
-					Role:    "assistant",
-					Content: "这是一个模拟的AI回复",
-				},
-			},
-		},
+func (m *MockLLMProvider) Name() string {
+	return "mock"
+}
+
+func (m *MockLLMProvider) ChatCompletion(ctx context.Context, request llm.ChatRequest) (*llm.ChatResponse, error) {
+	return &llm.ChatResponse{
+		ID:    "test_id",
 		Model: "glm-4",
-		Usage: minimax.Usage{
-			TotalTokens: 100,
+		Message: llm.Message{
+			Role:    "assistant",
+			Content: "这是一个模拟的AI回复",
 		},
-		BaseResp: minimax.BaseResponse{
-			StatusCode: 0,
-			StatusMsg:  "success",
+		FinishReason: "stop",
+		Usage: llm.Usage{
+			TotalTokens: 100,
 		},
 	}, nil
 }
 
-func (m *MockMiniMaxService) ChatCompletionStream(request minimax.ChatCompletionRequest) (<-chan minimax.ChatCompletionResponse, error) {
-	ch := make(chan minimax.ChatCompletionResponse, 1)
+func (m *MockLLMProvider) ChatCompletionStream(ctx context.Context, request llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	ch := make(chan llm.StreamChunk, 1)
 	go func() {
 		defer close(ch)
-		ch <- minimax.ChatCompletionResponse{
-			BaseResp: minimax.BaseResponse{
-				StatusCode: 0,
-				StatusMsg:  "success",
-			},
+		ch <- llm.StreamChunk{
+			Content:      "这是一个模拟的AI回复",
+			FinishReason: "stop",
 		}
 	}()
 	return ch, nil
 }
 
-func (m *MockMiniMaxService) SimpleChat(userMessage string) (string, error) {
-	return "模拟回复", nil
-}
-
-func (m *MockMiniMaxService) SimpleChatWithParams(userMessage string, temperature float64, maxTokens int) (string, error) {
-	return "模拟回复", nil
-}
-
-func (m *MockMiniMaxService) GetResponseContent(response *minimax.ChatCompletionResponse) (string, error) {
-	return response.GetContent(), nil
-}
-
-func (m *MockMiniMaxService) GetUsage(response *minimax.ChatCompletionResponse) *minimax.Usage {
-	return &response.Usage
-}
-
-func (m *MockMiniMaxService) IsRateLimited(response *minimax.ChatCompletionResponse) bool {
-	return false
-}
-
-func (m *MockMiniMaxService) IsAuthFailed(response *minimax.ChatCompletionResponse) bool {
-	return false
-}
-
-func (m *MockMiniMaxService) IsInsufficientBalance(response *minimax.ChatCompletionResponse) bool {
-	return false
-}
-
-func (m *MockMiniMaxService) IsTokenLimited(response *minimax.ChatCompletionResponse) bool {
-	return false
+func (m *MockLLMProvider) ListModels(ctx context.Context) ([]llm.ModelInfo, error) {
+	return []llm.ModelInfo{{ID: "glm-4", Provider: "mock"}}, nil
 }
 
 // TestCreateConversation 测试创建对话
@@ -315,7 +317,7 @@ func TestCreateConversation(t *testing.T) {
 	conversationRepo := NewMockConversationRepository()
 	messageRepo := NewMockMessageRepository()
 	userRepo := NewMockUserRepository()
-	minimaxService := NewMockMiniMaxService()
+	llmProvider := NewMockLLMProvider()
 
 	// 创建用户
 	user := &model.User{
@@ -329,7 +331,7 @@ func TestCreateConversation(t *testing.T) {
 	conversationCache := cache.NewConversationCache("localhost:6379", "", 0)
 
 	// 创建服务
-	service := NewService(conversationRepo, messageRepo, userRepo, conversationCache, minimaxService)
+	service := NewService(conversationRepo, messageRepo, userRepo, conversationCache, llmProvider)
 
 	// 测试创建对话
 	req := &CreateConversationRequest{
@@ -363,7 +365,7 @@ func TestSendMessage(t *testing.T) {
 	conversationRepo := NewMockConversationRepository()
 	messageRepo := NewMockMessageRepository()
 	userRepo := NewMockUserRepository()
-	minimaxService := NewMockMiniMaxService()
+	llmProvider := NewMockLLMProvider()
 
 	// 创建用户
 	user := &model.User{
@@ -386,7 +388,7 @@ func TestSendMessage(t *testing.T) {
 	conversationCache := cache.NewConversationCache("localhost:6379", "", 0)
 
 	// 创建服务
-	service := NewService(conversationRepo, messageRepo, userRepo, conversationCache, minimaxService)
+	service := NewService(conversationRepo, messageRepo, userRepo, conversationCache, llmProvider)
 
 	// 测试发送消息
 	req := &SendMessageRequest{
@@ -419,3 +421,327 @@ func TestSendMessage(t *testing.T) {
 		t.Errorf("Expected assistant message content '这是一个模拟的AI回复', got '%s'", response.AssistantMessage.Content)
 	}
 }
+
+// TestSendMessage_FakeMiniMax 使用离线MiniMax模拟服务测试完整调用链路
+func TestSendMessage_FakeMiniMax(t *testing.T) {
+	service, _, fake := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: "来自模拟服务的回复"}},
+	})
+
+	response, err := service.SendMessage(context.Background(), &SendMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		Content:        "你好",
+		Model:          "MiniMax-M1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	if response.AssistantMessage.Content != "来自模拟服务的回复" {
+		t.Errorf("Expected scripted reply, got '%s'", response.AssistantMessage.Content)
+	}
+	if response.AssistantMessage.Tokens == 0 {
+		t.Error("Expected non-zero token usage from fake server")
+	}
+	if fake.RequestCount() != 1 {
+		t.Errorf("Expected 1 upstream request, got %d", fake.RequestCount())
+	}
+}
+
+// newFakeMiniMaxService 创建连接离线模拟服务的对话服务
+func newFakeMiniMaxService(t *testing.T, config fakellm.Config) (*Service, *MockMessageRepository, *fakellm.Server) {
+	server, fake := fakellm.StartTestServer(config)
+	t.Cleanup(server.Close)
+
+	minimaxService := minimax.NewMiniMaxService(minimax.MiniMaxConfig{
+		APIKey:  "test-api-key",
+		BaseURL: server.URL + "/v1",
+	})
+
+	conversationRepo := NewMockConversationRepository()
+	messageRepo := NewMockMessageRepository()
+	userRepo := NewMockUserRepository()
+	userRepo.Create(&model.User{ID: 1, Phone: "13800138000", Status: 1})
+	conversationRepo.Create(&model.Conversation{ID: 1, UserID: 1, Title: "测试对话", Status: 1})
+
+	conversationCache := cache.NewConversationCache("localhost:6379", "", 0)
+	service := NewService(conversationRepo, messageRepo, userRepo, conversationCache, minimax.NewProvider(minimaxService))
+	return service, messageRepo, fake
+}
+
+// newAddToolRegistry 创建包含加法工具的注册表
+func newAddToolRegistry(t *testing.T) *tool.Registry {
+	registry := tool.NewRegistry()
+	err := registry.Register(tool.Tool{
+		Name:        "add",
+		Description: "计算两个数的和",
+		Parameters:  json.RawMessage(`{"type":"object","properties":{"a":{"type":"number"},"b":{"type":"number"}},"required":["a","b"]}`),
+		Func: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			var args struct{ A, B float64 }
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%g", args.A+args.B), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	return registry
+}
+
+func TestSendMessage_ToolLoop(t *testing.T) {
+	service, messageRepo, fake := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{
+			{ToolCalls: []fakellm.ToolCall{{Name: "add", Arguments: `{"a":1,"b":2}`}}},
+			{Content: "1加2等于3"},
+		},
+	})
+	service.SetToolRegistry(newAddToolRegistry(t), 0)
+
+	response, err := service.SendMessage(context.Background(), &SendMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		Content:        "1加2等于几？",
+		Model:          "MiniMax-M1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	if response.AssistantMessage.Content != "1加2等于3" {
+		t.Errorf("Expected final answer, got '%s'", response.AssistantMessage.Content)
+	}
+	if len(response.ToolMessages) != 2 {
+		t.Fatalf("Expected 2 tool messages, got %d", len(response.ToolMessages))
+	}
+	call, result := response.ToolMessages[0], response.ToolMessages[1]
+	if len(call.ToolCalls) != 1 || call.ToolCalls[0].Name != "add" {
+		t.Errorf("Expected assistant tool call to add, got %+v", call.ToolCalls)
+	}
+	if result.Role != "tool" || result.Content != "3" || result.ToolCallID != call.ToolCalls[0].ID {
+		t.Errorf("Unexpected tool result message: %+v", result)
+	}
+
+	// 第二次请求携带工具定义和工具结果
+	if fake.RequestCount() != 2 {
+		t.Errorf("Expected 2 upstream requests, got %d", fake.RequestCount())
+	}
+	if tools := fake.LastTools(); len(tools) != 1 || tools[0] != "add" {
+		t.Errorf("Expected tool add declared, got %v", tools)
+	}
+	lastMessages := fake.LastMessages()
+	if lastMessages[len(lastMessages)-1] != "tool: 3" {
+		t.Errorf("Expected tool result as last message, got %v", lastMessages)
+	}
+
+	// 用户消息、工具调用、工具结果、最终回复均已保存，历史可重放
+	history, _ := messageRepo.GetConversationMessages(1)
+	if len(history) != 4 {
+		t.Fatalf("Expected 4 stored messages, got %d", len(history))
+	}
+	if response.Conversation.MessageCount != 4 {
+		t.Errorf("Expected message count 4, got %d", response.Conversation.MessageCount)
+	}
+}
+
+func TestSendMessage_ToolStepLimit(t *testing.T) {
+	toolCall := fakellm.Reply{ToolCalls: []fakellm.ToolCall{{Name: "add", Arguments: `{"a":1}`}}}
+	service, _, fake := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{toolCall, toolCall, {Content: "最终回复"}},
+	})
+	service.SetToolRegistry(newAddToolRegistry(t), 2)
+
+	response, err := service.SendMessage(context.Background(), &SendMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		Content:        "算一下",
+		Model:          "MiniMax-M1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	if response.AssistantMessage.Content != "最终回复" {
+		t.Errorf("Expected final answer, got '%s'", response.AssistantMessage.Content)
+	}
+	if fake.RequestCount() != 3 {
+		t.Errorf("Expected 3 upstream requests, got %d", fake.RequestCount())
+	}
+	// 达到步数限制后不再声明工具
+	if tools := fake.LastTools(); len(tools) != 0 {
+		t.Errorf("Expected no tools on final request, got %v", tools)
+	}
+	// 缺少必填参数时错误信息作为工具结果回传
+	if result := response.ToolMessages[1].Content; result == "" || result[0] != '{' {
+		t.Errorf("Expected JSON error result, got '%s'", result)
+	}
+}
+
+func TestSendMessageStream(t *testing.T) {
+	service, messageRepo, _ := newFakeMiniMaxService(t, fakellm.Config{
+		Replies:   []fakellm.Reply{{Content: "这是流式回复"}},
+		ChunkSize: 2,
+	})
+
+	var userMessage *model.Message
+	var deltas []string
+	response, err := service.SendMessageStream(context.Background(), &SendMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		Content:        "你好",
+		Model:          "MiniMax-M1",
+	}, StreamCallbacks{
+		OnUserMessage: func(message *model.Message) { userMessage = message },
+		OnDelta:       func(content string) { deltas = append(deltas, content) },
+	})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	if userMessage == nil || userMessage.ID == 0 {
+		t.Fatal("Expected persisted user message before streaming")
+	}
+	if len(deltas) != 3 {
+		t.Errorf("Expected 3 deltas, got %v", deltas)
+	}
+	assistant := response.AssistantMessage
+	if assistant.Content != "这是流式回复" || assistant.FinishReason != "stop" || assistant.Tokens == 0 {
+		t.Errorf("Unexpected assistant message: %+v", assistant)
+	}
+	if stored, _ := messageRepo.GetByID(assistant.ID); stored == nil {
+		t.Error("Expected assistant message to be stored")
+	}
+}
+
+func TestSendMessageStream_Interrupted(t *testing.T) {
+	service, messageRepo, _ := newFakeMiniMaxService(t, fakellm.Config{
+		Replies:    []fakellm.Reply{{Content: "一段会被打断的很长的回复"}},
+		ChunkSize:  2,
+		ChunkDelay: 50 * time.Millisecond,
+	})
+
+	// 收到第一个片段后模拟客户端断开
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	response, err := service.SendMessageStream(ctx, &SendMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		Content:        "你好",
+		Model:          "MiniMax-M1",
+	}, StreamCallbacks{
+		OnDelta: func(content string) { cancel() },
+	})
+	if err != nil {
+		t.Fatalf("Expected partial reply to be saved, got error: %v", err)
+	}
+
+	assistant := response.AssistantMessage
+	if assistant.FinishReason != FinishReasonInterrupted {
+		t.Errorf("Expected finish reason %s, got %s", FinishReasonInterrupted, assistant.FinishReason)
+	}
+	if assistant.Content == "" || assistant.Content == "一段会被打断的很长的回复" {
+		t.Errorf("Expected partial content, got '%s'", assistant.Content)
+	}
+	history, _ := messageRepo.GetConversationMessages(1)
+	if len(history) != 2 {
+		t.Errorf("Expected user and partial assistant messages stored, got %d", len(history))
+	}
+}
+
+// brokenStreamProvider 输出部分内容后流式响应出错的提供方
+type brokenStreamProvider struct {
+	llm.Provider
+}
+
+func (p *brokenStreamProvider) ChatCompletionStream(ctx context.Context, request llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	ch := make(chan llm.StreamChunk, 2)
+	ch <- llm.StreamChunk{Content: "已经生成的"}
+	ch <- llm.StreamChunk{Err: errors.New("stream read error: connection reset")}
+	close(ch)
+	return ch, nil
+}
+
+func TestSendMessageStream_UpstreamErrorAfterContent(t *testing.T) {
+	service, messageRepo, _ := newFakeMiniMaxService(t, fakellm.Config{})
+	service.llmProvider = &brokenStreamProvider{Provider: service.llmProvider}
+
+	var deltas []string
+	response, err := service.SendMessageStream(context.Background(), &SendMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		Content:        "你好",
+		Model:          "MiniMax-M1",
+	}, StreamCallbacks{
+		OnDelta: func(content string) { deltas = append(deltas, content) },
+	})
+	if err != nil {
+		t.Fatalf("Expected partial reply to be saved, got error: %v", err)
+	}
+
+	// 客户端已收到的内容以 error 结束原因保存到历史
+	assistant := response.AssistantMessage
+	if assistant.Content != "已经生成的" || assistant.FinishReason != FinishReasonError || len(deltas) != 1 {
+		t.Errorf("Unexpected partial reply: %+v (deltas %v)", assistant, deltas)
+	}
+	history, _ := messageRepo.GetConversationMessages(1)
+	if len(history) != 2 {
+		t.Errorf("Expected user and partial assistant messages stored, got %d", len(history))
+	}
+}
+
+// renamedProvider 以指定名称暴露的提供方，用于模拟其他提供方的备用模型
+type renamedProvider struct {
+	llm.Provider
+	name string
+}
+
+func (p *renamedProvider) Name() string { return p.name }
+
+func TestSendMessage_Failover(t *testing.T) {
+	service, messageRepo, primary := newFakeMiniMaxService(t, fakellm.Config{ErrorCode: fakellm.ErrorInsufficient})
+
+	server, fallback := fakellm.StartTestServer(fakellm.Config{Replies: []fakellm.Reply{{Content: "来自备用模型的回复"}}})
+	t.Cleanup(server.Close)
+	fallbackProvider := &renamedProvider{
+		Provider: minimax.NewProvider(minimax.NewMiniMaxService(minimax.MiniMaxConfig{APIKey: "test-api-key", BaseURL: server.URL + "/v1"})),
+		name:     "openai",
+	}
+
+	catalog, err := llm.NewCatalog(llm.CatalogConfig{
+		Models: []llm.ModelSpec{
+			{ID: "MiniMax-M1", Provider: "minimax", ContextWindow: 1000000, MaxOutputTokens: 40000, Enabled: true},
+			{ID: "deepseek-chat", Provider: "openai", ContextWindow: 64000, MaxOutputTokens: 8192, Enabled: true},
+		},
+		Failover: map[string][]string{"MiniMax-M1": {"deepseek-chat"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create catalog: %v", err)
+	}
+	service.llmProvider, err = llm.NewFailoverProvider(catalog, service.llmProvider, fallbackProvider)
+	if err != nil {
+		t.Fatalf("Failed to create failover provider: %v", err)
+	}
+
+	response, err := service.SendMessage(context.Background(), &SendMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		Content:        "你好",
+		Model:          "MiniMax-M1",
+	})
+	if err != nil {
+		t.Fatalf("Expected fallback to answer, got %v", err)
+	}
+	if primary.RequestCount() != 1 || fallback.RequestCount() != 1 {
+		t.Errorf("Expected one request to each backend, got %d/%d", primary.RequestCount(), fallback.RequestCount())
+	}
+
+	stored, _ := messageRepo.GetByID(response.AssistantMessage.ID)
+	if stored.Model != "deepseek-chat" || stored.Content != "来自备用模型的回复" {
+		t.Errorf("Expected reply recorded with fallback model, got %+v", stored)
+	}
+	if response.UserMessage.Model != "MiniMax-M1" {
+		t.Errorf("Expected user message to keep requested model, got %s", response.UserMessage.Model)
+	}
+}
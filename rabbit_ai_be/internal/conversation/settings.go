@@ -0,0 +1,150 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+)
+
+// 对话未设置时使用的默认生成参数（默认模型由模型目录决定）
+const (
+	DefaultMaxTokens   = 2048
+	DefaultTemperature = 0.7
+)
+
+// 对话设置的限制
+const (
+	maxSystemPromptLength = 10000 // 系统提示最大字符数
+	maxStopWords          = 4     // 最多停止词数量
+)
+
+// ErrInvalidSettings 对话设置无效
+var ErrInvalidSettings = errors.New("invalid conversation settings")
+
+// UpdateConversationSettingsRequest 更新对话设置请求
+type UpdateConversationSettingsRequest struct {
+	ConversationID int64                      `json:"conversation_id"`
+	UserID         int64                      `json:"user_id"`
+	Settings       model.ConversationSettings `json:"settings"`
+}
+
+// UpdateConversationSettingsResponse 更新对话设置响应
+type UpdateConversationSettingsResponse struct {
+	Conversation *model.Conversation `json:"conversation"`
+}
+
+// UpdateConversationSettings 更新对话设置（整体替换），之后的每次模型调用都会应用新设置
+func (s *Service) UpdateConversationSettings(ctx context.Context, req *UpdateConversationSettingsRequest) (*UpdateConversationSettingsResponse, error) {
+	if err := s.validateSettings(&req.Settings); err != nil {
+		return nil, err
+	}
+	if err := s.checkKnowledgeBases(req.UserID, &req.Settings); err != nil {
+		return nil, err
+	}
+
+	// 验证对话是否存在
+	conversation, err := s.conversationRepo.GetByID(req.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("conversation not found: %w", err)
+	}
+
+	// 验证对话是否属于该用户
+	if conversation.UserID != req.UserID {
+		return nil, errors.New("conversation does not belong to user")
+	}
+
+	// 模板字段只能由服务端写入：系统提示未修改时保留生成它的模板，修改后清空
+	req.Settings.TemplateID = 0
+	req.Settings.TemplateVersion = 0
+	if req.Settings.SystemPrompt == conversation.Settings.SystemPrompt {
+		req.Settings.TemplateID = conversation.Settings.TemplateID
+		req.Settings.TemplateVersion = conversation.Settings.TemplateVersion
+	}
+
+	err = s.conversationRepo.UpdateSettings(req.ConversationID, req.Settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update conversation settings: %w", err)
+	}
+	conversation.Settings = req.Settings
+
+	// 缓存更新后的对话信息
+	err = s.conversationCache.SetConversation(ctx, conversation)
+	if err != nil {
+		fmt.Printf("failed to cache updated conversation: %v\n", err)
+	}
+
+	err = s.conversationCache.InvalidateUserCache(ctx, req.UserID)
+	if err != nil {
+		fmt.Printf("failed to invalidate user cache: %v\n", err)
+	}
+
+	return &UpdateConversationSettingsResponse{
+		Conversation: conversation,
+	}, nil
+}
+
+// validateSettings 校验对话设置（模型须在目录中启用），并去除系统提示和模型名称的首尾空白
+func (s *Service) validateSettings(settings *model.ConversationSettings) error {
+	settings.SystemPrompt = strings.TrimSpace(settings.SystemPrompt)
+	settings.Model = strings.TrimSpace(settings.Model)
+
+	if utf8.RuneCountInString(settings.SystemPrompt) > maxSystemPromptLength {
+		return fmt.Errorf("%w: system_prompt exceeds %d characters", ErrInvalidSettings, maxSystemPromptLength)
+	}
+	if settings.Temperature != nil && (*settings.Temperature <= 0 || *settings.Temperature > 2) {
+		return fmt.Errorf("%w: temperature must be in (0, 2]", ErrInvalidSettings)
+	}
+	if settings.TopP != nil && (*settings.TopP <= 0 || *settings.TopP > 1) {
+		return fmt.Errorf("%w: top_p must be in (0, 1]", ErrInvalidSettings)
+	}
+	if settings.MaxTokens < 0 {
+		return fmt.Errorf("%w: max_tokens must not be negative", ErrInvalidSettings)
+	}
+	if settings.Model != "" {
+		spec, err := s.catalog.Lookup(settings.Model)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSettings, err)
+		}
+		if settings.MaxTokens > spec.MaxOutputTokens {
+			return fmt.Errorf("%w: max_tokens exceeds %d for model %s", ErrInvalidSettings, spec.MaxOutputTokens, spec.ID)
+		}
+	}
+	if len(settings.Stop) > maxStopWords {
+		return fmt.Errorf("%w: at most %d stop words", ErrInvalidSettings, maxStopWords)
+	}
+	for _, word := range settings.Stop {
+		if word == "" {
+			return fmt.Errorf("%w: stop words must not be empty", ErrInvalidSettings)
+		}
+	}
+	return nil
+}
+
+// newChatRequest 按对话设置构建大模型请求的生成参数，未设置的参数使用服务默认值，
+// 回复最大token数不超过模型上限
+func newChatRequest(spec llm.ModelSpec, settings model.ConversationSettings) llm.ChatRequest {
+	chatReq := llm.ChatRequest{
+		Model:       spec.ID,
+		MaxTokens:   DefaultMaxTokens,
+		Temperature: DefaultTemperature,
+		Stop:        settings.Stop,
+	}
+	if settings.MaxTokens > 0 {
+		chatReq.MaxTokens = settings.MaxTokens
+	}
+	if settings.Temperature != nil {
+		chatReq.Temperature = *settings.Temperature
+	}
+	if settings.TopP != nil {
+		chatReq.TopP = *settings.TopP
+	}
+	if chatReq.MaxTokens > spec.MaxOutputTokens {
+		chatReq.MaxTokens = spec.MaxOutputTokens
+	}
+	return chatReq
+}
@@ -0,0 +1,135 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+)
+
+func TestValidateSettings(t *testing.T) {
+	zero, high := 0.0, 1.5
+	tests := []struct {
+		name     string
+		settings model.ConversationSettings
+		valid    bool
+	}{
+		{"empty", model.ConversationSettings{}, true},
+		{"zero temperature", model.ConversationSettings{Temperature: &zero}, false},
+		{"top_p above 1", model.ConversationSettings{TopP: &high}, false},
+		{"negative max_tokens", model.ConversationSettings{MaxTokens: -1}, false},
+		{"too many stop words", model.ConversationSettings{Stop: []string{"a", "b", "c", "d", "e"}}, false},
+		{"empty stop word", model.ConversationSettings{Stop: []string{""}}, false},
+		{"unknown model", model.ConversationSettings{Model: "no-such-model"}, false},
+		{"max_tokens above model limit", model.ConversationSettings{Model: "MiniMax-Text-01", MaxTokens: 10000}, false},
+		{"valid", model.ConversationSettings{Model: "MiniMax-M1", Temperature: &high, Stop: []string{"END"}, MaxTokens: 512}, true},
+	}
+	service, _, _ := newFakeMiniMaxService(t, fakellm.Config{})
+	for _, tt := range tests {
+		err := service.validateSettings(&tt.settings)
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidSettings) {
+			t.Errorf("%s: expected ErrInvalidSettings, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestSendMessage_AppliesSettings(t *testing.T) {
+	service, _, fake := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: "好的"}, {Content: "收到"}},
+	})
+	ctx := context.Background()
+
+	temperature, topP := 0.2, 0.5
+	_, err := service.UpdateConversationSettings(ctx, &UpdateConversationSettingsRequest{
+		ConversationID: 1,
+		UserID:         1,
+		Settings: model.ConversationSettings{
+			SystemPrompt: "  你是一名翻译  ",
+			Model:        "MiniMax-Text-01",
+			Temperature:  &temperature,
+			TopP:         &topP,
+			MaxTokens:    256,
+			Stop:         []string{"END"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to update settings: %v", err)
+	}
+
+	_, err = service.SendMessage(ctx, &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "你好"})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	params := fake.LastParams()
+	if params.Model != "MiniMax-Text-01" || params.Temperature != 0.2 || params.TopP != 0.5 || params.MaxTokens != 256 {
+		t.Errorf("Expected conversation settings applied, got %+v", params)
+	}
+	if len(params.Stop) != 1 || params.Stop[0] != "END" {
+		t.Errorf("Expected stop words applied, got %v", params.Stop)
+	}
+	if messages := fake.LastMessages(); messages[0] != "system: 你是一名翻译" {
+		t.Errorf("Expected system prompt first, got %v", messages)
+	}
+
+	// 发送时指定的模型优先于对话设置
+	_, err = service.SendMessage(ctx, &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "再见", Model: "MiniMax-M1"})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if params := fake.LastParams(); params.Model != "MiniMax-M1" || params.MaxTokens != 256 {
+		t.Errorf("Expected request model override, got %+v", params)
+	}
+}
+
+func TestUpdateConversationSettings_NotOwner(t *testing.T) {
+	service, _, _ := newFakeMiniMaxService(t, fakellm.Config{})
+
+	_, err := service.UpdateConversationSettings(context.Background(), &UpdateConversationSettingsRequest{
+		ConversationID: 1,
+		UserID:         2,
+		Settings:       model.ConversationSettings{SystemPrompt: "test"},
+	})
+	if err == nil {
+		t.Fatal("Expected error when updating another user's conversation")
+	}
+}
+
+func TestSendMessage_InvalidModel(t *testing.T) {
+	service, messageRepo, fake := newFakeMiniMaxService(t, fakellm.Config{})
+	catalog, err := llm.DefaultCatalog().ForProvider("minimax")
+	if err != nil {
+		t.Fatalf("Failed to create catalog: %v", err)
+	}
+	service.SetModelCatalog(catalog)
+	ctx := context.Background()
+
+	_, err = service.SendMessage(ctx, &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "你好", Model: "gpt-4o"})
+	if !errors.Is(err, llm.ErrModelDisabled) {
+		t.Errorf("Expected ErrModelDisabled, got %v", err)
+	}
+	_, err = service.SendMessage(ctx, &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "你好", Model: "no-such-model"})
+	if !errors.Is(err, llm.ErrUnknownModel) {
+		t.Errorf("Expected ErrUnknownModel, got %v", err)
+	}
+
+	// 校验失败时不保存用户消息，也不调用上游
+	if count, _ := messageRepo.GetConversationMessageCount(1); count != 0 || fake.RequestCount() != 0 {
+		t.Errorf("Expected no messages saved and no upstream request, got %d messages, %d requests", count, fake.RequestCount())
+	}
+
+	// 未指定模型时使用目录默认模型
+	_, err = service.SendMessage(ctx, &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "你好"})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if params := fake.LastParams(); params.Model != "MiniMax-M1" {
+		t.Errorf("Expected catalog default model, got %s", params.Model)
+	}
+}
@@ -0,0 +1,141 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"rabbit_ai/internal/guardrail"
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+)
+
+// FinishReasonInterrupted 客户端断开导致流式回复中断时记录的结束原因
+const FinishReasonInterrupted = "interrupted"
+
+// FinishReasonError 上游在输出中途出错时，已生成的部分回复记录的结束原因
+const FinishReasonError = "error"
+
+// StreamCallbacks 流式发送消息的回调
+type StreamCallbacks struct {
+	OnUserMessage func(message *model.Message) // 用户消息保存且上游流建立后调用
+	OnDelta       func(content string)         // 收到增量内容时调用
+}
+
+// SendMessageStream 发送消息并以流式方式获取AI回复
+//
+// 用户消息在调用模型前保存；流结束后保存拼接完整的AI回复及结束原因和使用统计。
+// ctx结束（客户端断开）时，已生成的部分内容以 interrupted 结束原因保存；
+// 通过 CancelGeneration 取消时以 cancelled 结束原因保存；上游在输出中途出错时以 error 结束原因保存，
+// 客户端已显示的内容不会丢失。流式模式下不声明工具。
+// 每收到增量内容都对已生成的内容执行输出检查，命中屏蔽时立即停止上游生成，
+// 已生成的内容按屏蔽处理并返回 ContentBlockedError，客户端应丢弃已收到的增量。
+func (s *Service) SendMessageStream(ctx context.Context, req *SendMessageRequest, callbacks StreamCallbacks) (*SendMessageResponse, error) {
+	if _, err := newStructuredOutput(req, true); err != nil {
+		return nil, err
+	}
+
+	ctx, done, err := s.startGeneration(ctx, req.UserID, req.ConversationID, &req.GenerationID)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	pending, err := s.prepareSend(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// 命中护栏时单独停止上游流，与客户端断开、用户取消区分开
+	streamCtx, stopStream := context.WithCancelCause(ctx)
+	defer stopStream(nil)
+
+	chunkChan, err := s.llmProvider.ChatCompletionStream(streamCtx, pending.chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AI response: %w", err)
+	}
+
+	if callbacks.OnUserMessage != nil {
+		callbacks.OnUserMessage(pending.userMessage)
+	}
+
+	var content strings.Builder
+	var finishReason, answeredBy string
+	var usage llm.Usage
+	var streamErr error
+	var blocked bool
+	for chunk := range chunkChan {
+		if blocked {
+			// 等待上游退出并关闭通道
+			continue
+		}
+		if chunk.Err != nil {
+			streamErr = chunk.Err
+			continue
+		}
+		if chunk.Sensitive != nil {
+			pending.sensitive = chunk.Sensitive
+		}
+		if chunk.Model != "" {
+			answeredBy = chunk.Model
+		}
+		if chunk.Content != "" {
+			content.WriteString(chunk.Content)
+			if s.checkContent(ctx, guardrail.StageOutput, content.String(), pending.sensitive).Blocked() {
+				blocked = true
+				stopStream(errors.New("content blocked by guardrail"))
+				continue
+			}
+			if callbacks.OnDelta != nil {
+				callbacks.OnDelta(chunk.Content)
+			}
+		}
+		if chunk.FinishReason != "" {
+			finishReason = chunk.FinishReason
+		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+	}
+
+	// 客户端断开或用户取消：后续保存不再受原请求ctx取消的影响
+	cancelled := generationCancelled(ctx)
+	interrupted := ctx.Err() != nil
+	if interrupted {
+		finishReason = FinishReasonInterrupted
+		if cancelled {
+			finishReason = FinishReasonCancelled
+		}
+		ctx = context.WithoutCancel(ctx)
+	} else if streamErr != nil && !blocked {
+		if content.Len() == 0 {
+			return nil, fmt.Errorf("failed to get AI response: %w", streamErr)
+		}
+		log.Printf("stream of conversation %d failed after partial reply: %v", req.ConversationID, streamErr)
+		finishReason = FinishReasonError
+	}
+
+	if content.Len() == 0 {
+		if cancelled {
+			return nil, fmt.Errorf("%w before any content", ErrGenerationCancelled)
+		}
+		if interrupted {
+			return nil, fmt.Errorf("stream interrupted before any content: %w", context.Canceled)
+		}
+		return nil, errors.New("empty response from AI")
+	}
+
+	// 创建AI回复消息
+	assistantMessage := &model.Message{
+		ConversationID: req.ConversationID,
+		Role:           "assistant",
+		Content:        content.String(),
+		Model:          respondingModel(answeredBy, req.Model),
+		FinishReason:   finishReason,
+		Tokens:         usage.TotalTokens,
+	}
+
+	return s.completeSend(ctx, req, pending, nil, assistantMessage)
+}
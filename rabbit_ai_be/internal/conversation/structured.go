@@ -0,0 +1,39 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"rabbit_ai/internal/structured"
+)
+
+// ErrCodeInvalidOutput 结构化回复重新提示达到上限后仍未通过 Schema 校验时返回的错误码
+const ErrCodeInvalidOutput = structured.ErrCodeInvalidOutput
+
+// newStructuredOutput 校验发送消息请求的回复格式，未指定时返回nil
+//
+// 结构化回复需要完整回复后校验，不支持流式；调用模型时不声明工具。
+func newStructuredOutput(req *SendMessageRequest, stream bool) (*structured.Output, error) {
+	if req.ResponseFormat == nil {
+		return nil, nil
+	}
+	if stream {
+		return nil, fmt.Errorf("%w: streaming is not supported", structured.ErrInvalidFormat)
+	}
+	return structured.NewOutput(req.ResponseFormat)
+}
+
+// generateStructured 调用模型生成满足 Schema 的回复，回复内容替换为解析出的JSON（去掉代码块标记等）
+func (s *Service) generateStructured(ctx context.Context, output *structured.Output, pending *pendingSend) (*structured.Result, error) {
+	result, err := output.Generate(ctx, s.llmProvider, pending.chatReq)
+	if err != nil {
+		var outputErr *structured.OutputError
+		if errors.As(err, &outputErr) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get AI response: %w", err)
+	}
+	result.Response.Message.Content = string(result.Value)
+	return result, nil
+}
@@ -0,0 +1,83 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/structured"
+)
+
+// answerFormat 要求回复为 {"answer": 整数}
+func answerFormat(maxRetries int) *structured.ResponseFormat {
+	return &structured.ResponseFormat{
+		Type:       structured.FormatJSONSchema,
+		Schema:     json.RawMessage(`{"type":"object","properties":{"answer":{"type":"integer"}},"required":["answer"]}`),
+		MaxRetries: &maxRetries,
+	}
+}
+
+func TestSendMessage_StructuredOutput(t *testing.T) {
+	service, messageRepo, fake := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: "答案是42"}, {Content: "```json\n{\"answer\": 42}\n```"}},
+	})
+	service.SetToolRegistry(newAddToolRegistry(t), 0)
+
+	response, err := service.SendMessage(context.Background(), &SendMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		Content:        "6乘7等于多少",
+		ResponseFormat: answerFormat(2),
+	})
+	if err != nil {
+		t.Fatalf("Failed to send structured message: %v", err)
+	}
+	if string(response.Output) != `{"answer": 42}` || response.Attempts != 2 {
+		t.Errorf("Expected parsed output after one retry, got %s / %d", response.Output, response.Attempts)
+	}
+	stored, _ := messageRepo.GetByID(response.AssistantMessage.ID)
+	if stored.Content != `{"answer": 42}` {
+		t.Errorf("Expected JSON saved as reply, got %q", stored.Content)
+	}
+	if fake.RequestCount() != 2 || len(fake.LastTools()) != 0 {
+		t.Errorf("Expected 2 requests without tools, got %d / %v", fake.RequestCount(), fake.LastTools())
+	}
+	messages := fake.LastMessages()
+	if len(messages) != 4 || !strings.HasPrefix(messages[0], "system: ") || !strings.Contains(messages[3], "reply is not valid JSON") {
+		t.Errorf("Expected schema instruction and retry prompt, got %v", messages)
+	}
+}
+
+func TestSendMessage_StructuredOutputFailure(t *testing.T) {
+	service, messageRepo, _ := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: `{"answer":"42"}`}},
+	})
+	ctx := context.Background()
+
+	_, err := service.SendMessage(ctx, &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "6乘7", ResponseFormat: answerFormat(0)})
+	var outputErr *structured.OutputError
+	if !errors.As(err, &outputErr) {
+		t.Fatalf("Expected OutputError, got %v", err)
+	}
+	if outputErr.Attempts != 1 || outputErr.Errors[0].Path != "$.answer" {
+		t.Errorf("Unexpected failure: %+v", outputErr)
+	}
+	for _, message := range messageRepo.messages {
+		if message.Role == "assistant" {
+			t.Errorf("Expected no reply saved, got %+v", message)
+		}
+	}
+
+	invalid := answerFormat(0)
+	invalid.Type = "json_object"
+	if _, err := service.SendMessage(ctx, &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "x", ResponseFormat: invalid}); !errors.Is(err, structured.ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat, got %v", err)
+	}
+	_, err = service.SendMessageStream(ctx, &SendMessageRequest{ConversationID: 1, UserID: 1, Content: "x", ResponseFormat: answerFormat(0)}, StreamCallbacks{})
+	if !errors.Is(err, structured.ErrInvalidFormat) {
+		t.Errorf("Expected streaming to be rejected, got %v", err)
+	}
+}
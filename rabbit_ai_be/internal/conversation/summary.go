@@ -0,0 +1,219 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+)
+
+// 滚动摘要默认参数
+const (
+	DefaultSummaryThresholdTokens = 6000
+	DefaultSummaryKeepTurns       = 4
+	DefaultSummaryMaxTokens       = 1024
+	summaryTimeout                = 2 * time.Minute
+)
+
+// summarySystemPrompt 生成摘要时的系统提示
+const summarySystemPrompt = `你是对话摘要助手。请将给出的对话内容压缩为简洁的摘要，保留用户的身份信息、偏好与目标、已确认的事实与结论、尚未解决的问题，省略寒暄和重复内容。
+如果提供了已有摘要，请将新的对话内容合并进去，输出完整的更新后摘要。只输出摘要正文。`
+
+// summaryPrefix 摘要作为系统消息发送时的前缀
+const summaryPrefix = "以下是此前对话的摘要，请结合摘要继续对话：\n"
+
+// SummaryPolicy 滚动摘要策略
+type SummaryPolicy struct {
+	ThresholdTokens int    // 未摘要的较早轮次估算token数达到该值时生成摘要，<=0 表示关闭
+	KeepTurns       int    // 始终以原文发送的最近轮数
+	MaxTokens       int    // 摘要最大token数
+	Model           string // 生成摘要使用的模型，为空时使用对话当前的模型
+}
+
+// SetSummaryPolicy 设置滚动摘要策略
+func (s *Service) SetSummaryPolicy(policy SummaryPolicy) {
+	if policy.KeepTurns <= 0 {
+		policy.KeepTurns = DefaultSummaryKeepTurns
+	}
+	if policy.MaxTokens <= 0 {
+		policy.MaxTokens = DefaultSummaryMaxTokens
+	}
+	s.summaryPolicy = policy
+}
+
+// summaryEntry 将对话摘要转换为系统消息
+func summaryEntry(summary *model.ConversationSummary) contextEntry {
+	return contextEntry{message: llm.Message{
+		Role:    llm.RoleSystem,
+		Content: summaryPrefix + summary.Content,
+	}}
+}
+
+// unsummarized 返回摘要未覆盖的历史消息
+func unsummarized(history []*model.Message, summary *model.ConversationSummary) []*model.Message {
+	if summary == nil {
+		return history
+	}
+	for i, msg := range history {
+		if msg.ID > summary.CoveredMessageID {
+			return history[i:]
+		}
+	}
+	return nil
+}
+
+// splitTurns 将消息按轮次分组，一轮以用户消息开始，包含其后的助手/工具消息
+func splitTurns(messages []*model.Message) [][]*model.Message {
+	var turns [][]*model.Message
+	for _, msg := range messages {
+		if msg.Role == llm.RoleUser || len(turns) == 0 {
+			turns = append(turns, nil)
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], msg)
+	}
+	return turns
+}
+
+// summarizeInBackground 在后台为对话生成或更新摘要，同一对话同时只运行一个摘要任务
+func (s *Service) summarizeInBackground(conversationID int64, modelName string) {
+	if s.summaryPolicy.ThresholdTokens <= 0 {
+		return
+	}
+	if _, running := s.summarizing.LoadOrStore(conversationID, struct{}{}); running {
+		return
+	}
+
+	go func() {
+		defer s.summarizing.Delete(conversationID)
+
+		ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+		defer cancel()
+
+		if _, err := s.summarize(ctx, conversationID, modelName); err != nil {
+			log.Printf("failed to summarize conversation %d: %v", conversationID, err)
+		}
+	}()
+}
+
+// summarize 在未摘要的较早轮次达到阈值时生成摘要
+//
+// 最近 KeepTurns 轮始终保留原文；更早的轮次与已有摘要一起交给模型合并为新摘要，
+// 摘要只在轮次边界推进，避免工具调用与工具结果被拆开。未达到阈值时返回nil。
+func (s *Service) summarize(ctx context.Context, conversationID int64, modelName string) (*model.ConversationSummary, error) {
+	policy := s.summaryPolicy
+	if policy.ThresholdTokens <= 0 {
+		return nil, nil
+	}
+	if policy.Model != "" {
+		modelName = policy.Model
+	}
+
+	conversation, err := s.conversationRepo.GetByID(conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("conversation not found: %w", err)
+	}
+
+	history, err := s.messageRepo.GetConversationMessages(conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation history: %w", err)
+	}
+
+	// 摘要沿当前分支推进；已有摘要属于其他分支时重新生成
+	path := newMessageTree(history).path(conversation.ActiveLeafID)
+	previous := pathSummary(conversation.Summary, path)
+	turns := splitTurns(unsummarized(path, previous))
+	if len(turns) <= policy.KeepTurns {
+		return nil, nil
+	}
+
+	var covered []*model.Message
+	tokens := 0
+	for _, turn := range turns[:len(turns)-policy.KeepTurns] {
+		for _, msg := range turn {
+			covered = append(covered, msg)
+			tokens += llm.EstimateMessageTokens(toLLMMessage(msg))
+		}
+	}
+	if tokens < policy.ThresholdTokens {
+		return nil, nil
+	}
+
+	chatResp, err := s.llmProvider.ChatCompletion(ctx, llm.ChatRequest{
+		Model: modelName,
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: summarySystemPrompt},
+			{Role: llm.RoleUser, Content: summaryInput(previous, covered)},
+		},
+		MaxTokens:   policy.MaxTokens,
+		Temperature: 0.3,
+		User:        fmt.Sprintf("user_%d", conversation.UserID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate summary: %w", err)
+	}
+
+	content := strings.TrimSpace(chatResp.GetContent())
+	if content == "" {
+		return nil, errors.New("empty summary from AI")
+	}
+
+	summary := &model.ConversationSummary{
+		Content:          content,
+		CoveredMessageID: covered[len(covered)-1].ID,
+		CoveredMessages:  len(covered),
+		Model:            modelName,
+		Tokens:           chatResp.Usage.TotalTokens,
+		UpdatedAt:        time.Now(),
+	}
+	if previous != nil {
+		summary.CoveredMessages += previous.CoveredMessages
+	}
+
+	if err := s.conversationRepo.UpdateSummary(conversationID, summary); err != nil {
+		return nil, fmt.Errorf("failed to update conversation summary: %w", err)
+	}
+
+	// 使相关缓存失效
+	if err := s.conversationCache.InvalidateConversationCache(ctx, conversationID); err != nil {
+		fmt.Printf("failed to invalidate conversation cache: %v\n", err)
+	}
+	if err := s.conversationCache.InvalidateUserCache(ctx, conversation.UserID); err != nil {
+		fmt.Printf("failed to invalidate user cache: %v\n", err)
+	}
+
+	return summary, nil
+}
+
+// summaryInput 构建生成摘要的输入：已有摘要 + 新增的对话记录
+func summaryInput(previous *model.ConversationSummary, messages []*model.Message) string {
+	var b strings.Builder
+	if previous != nil {
+		b.WriteString("已有摘要：\n")
+		b.WriteString(previous.Content)
+		b.WriteString("\n\n新的对话内容：\n")
+	} else {
+		b.WriteString("对话内容：\n")
+	}
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case llm.RoleUser:
+			b.WriteString("用户：")
+		case llm.RoleTool:
+			b.WriteString("工具结果：")
+		default:
+			b.WriteString("助手：")
+		}
+		b.WriteString(msg.Content)
+		for _, call := range msg.ToolCalls {
+			fmt.Fprintf(&b, "[调用工具 %s %s]", call.Name, call.Arguments)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,110 @@
+package conversation
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/model"
+)
+
+// seedTurns 在当前分支末尾写入若干轮用户/助手消息
+func seedTurns(service *Service, messageRepo *MockMessageRepository, turns int) {
+	conversation, _ := service.conversationRepo.GetByID(1)
+	for i := 0; i < turns; i++ {
+		for _, msg := range []*model.Message{
+			{Role: "user", Content: strings.Repeat("问", 50)},
+			{Role: "assistant", Content: strings.Repeat("答", 50)},
+		} {
+			msg.ConversationID = 1
+			msg.ParentID = conversation.ActiveLeafID
+			messageRepo.Create(msg)
+			conversation.ActiveLeafID = msg.ID
+		}
+	}
+}
+
+func TestSummarize_Incremental(t *testing.T) {
+	service, messageRepo, fake := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{
+			{Content: "第一版摘要"},
+			{Content: "第二版摘要"},
+		},
+	})
+	service.SetSummaryPolicy(SummaryPolicy{ThresholdTokens: 200, KeepTurns: 2})
+	ctx := context.Background()
+
+	// 只有一轮超出保留范围，未达到阈值
+	seedTurns(service, messageRepo, 3)
+	summary, err := service.summarize(ctx, 1, "MiniMax-M1")
+	if err != nil || summary != nil {
+		t.Fatalf("Expected no summary below threshold, got %+v, %v", summary, err)
+	}
+
+	// 三轮超出保留范围（6条消息）
+	seedTurns(service, messageRepo, 2)
+	summary, err = service.summarize(ctx, 1, "MiniMax-M1")
+	if err != nil {
+		t.Fatalf("Failed to summarize: %v", err)
+	}
+	if summary == nil || summary.Content != "第一版摘要" || summary.CoveredMessageID != 6 || summary.CoveredMessages != 6 {
+		t.Fatalf("Unexpected summary: %+v", summary)
+	}
+	conversation, _ := service.conversationRepo.GetByID(1)
+	if conversation.Summary != summary {
+		t.Error("Expected summary stored on conversation")
+	}
+
+	// 新增的轮次与已有摘要合并，只发送未覆盖的消息
+	seedTurns(service, messageRepo, 3)
+	summary, err = service.summarize(ctx, 1, "MiniMax-M1")
+	if err != nil {
+		t.Fatalf("Failed to summarize: %v", err)
+	}
+	if summary.Content != "第二版摘要" || summary.CoveredMessageID != 12 || summary.CoveredMessages != 12 {
+		t.Fatalf("Unexpected incremental summary: %+v", summary)
+	}
+	lastMessages := fake.LastMessages()
+	input := lastMessages[len(lastMessages)-1]
+	if !strings.Contains(input, "已有摘要：\n第一版摘要") {
+		t.Errorf("Expected previous summary in prompt, got %q", input)
+	}
+	if strings.Count(input, "用户：") != 3 {
+		t.Errorf("Expected only 3 new turns in prompt, got %q", input)
+	}
+}
+
+func TestSendMessage_UsesSummary(t *testing.T) {
+	service, messageRepo, fake := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: "好的"}},
+	})
+	seedTurns(service, messageRepo, 3)
+	service.conversationRepo.UpdateSummary(1, &model.ConversationSummary{
+		Content:          "用户喜欢简洁的回答",
+		CoveredMessageID: 4,
+		CoveredMessages:  4,
+	})
+
+	_, err := service.SendMessage(context.Background(), &SendMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		Content:        "继续",
+		Model:          "MiniMax-M1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	// 摘要系统消息 + 未覆盖的一轮 + 新的用户消息
+	lastMessages := fake.LastMessages()
+	if len(lastMessages) != 4 {
+		t.Fatalf("Expected 4 upstream messages, got %v", lastMessages)
+	}
+	if lastMessages[0] != "system: "+summaryPrefix+"用户喜欢简洁的回答" {
+		t.Errorf("Expected summary as system message, got %q", lastMessages[0])
+	}
+	if lastMessages[3] != "user: 继续" {
+		t.Errorf("Expected new user message last, got %q", lastMessages[3])
+	}
+}
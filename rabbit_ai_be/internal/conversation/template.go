@@ -0,0 +1,93 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"rabbit_ai/internal/model"
+)
+
+// TemplateRenderer 提示词模板渲染接口
+type TemplateRenderer interface {
+	// Render 按变量渲染用户可用的模板，version 为0时使用当前版本
+	Render(ctx context.Context, userID, templateID int64, version int, variables map[string]string) (*model.RenderedPrompt, error)
+}
+
+// SetTemplates 设置提示词模板，未设置时不能以模板创建对话或发送消息
+func (s *Service) SetTemplates(templates TemplateRenderer) {
+	s.templates = templates
+}
+
+// renderTemplate 渲染请求引用的模板，模板或版本不存在、变量无效时返回以 invalid 包装的错误
+func (s *Service) renderTemplate(ctx context.Context, userID, templateID int64, version int, variables map[string]string, invalid error) (*model.RenderedPrompt, error) {
+	if s.templates == nil {
+		return nil, fmt.Errorf("%w: prompt templates are not enabled", invalid)
+	}
+	rendered, err := s.templates.Render(ctx, userID, templateID, version, variables)
+	if errors.Is(err, model.ErrPromptTemplateNotFound) || errors.Is(err, model.ErrPromptTemplateVersionNotFound) ||
+		errors.Is(err, model.ErrInvalidTemplateVariables) {
+		return nil, fmt.Errorf("%w: %v", invalid, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to render prompt template: %w", err)
+	}
+	return rendered, nil
+}
+
+// applyConversationTemplate 以模板渲染出的系统提示作为对话的系统提示，并记录模板及版本
+func (s *Service) applyConversationTemplate(ctx context.Context, req *CreateConversationRequest) error {
+	// 模板字段只能由服务端写入
+	req.Settings.TemplateID = 0
+	req.Settings.TemplateVersion = 0
+
+	if req.TemplateID == 0 {
+		if req.TemplateVersion != 0 || len(req.Variables) > 0 {
+			return fmt.Errorf("%w: template_version and variables require template_id", ErrInvalidSettings)
+		}
+		return nil
+	}
+	if strings.TrimSpace(req.Settings.SystemPrompt) != "" {
+		return fmt.Errorf("%w: system_prompt cannot be used with template_id", ErrInvalidSettings)
+	}
+
+	rendered, err := s.renderTemplate(ctx, req.UserID, req.TemplateID, req.TemplateVersion, req.Variables, ErrInvalidSettings)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(rendered.SystemPrompt) == "" {
+		return fmt.Errorf("%w: template %d has no system prompt", ErrInvalidSettings, req.TemplateID)
+	}
+
+	req.Settings.SystemPrompt = rendered.SystemPrompt
+	req.Settings.TemplateID = rendered.TemplateID
+	req.Settings.TemplateVersion = rendered.Version
+	return nil
+}
+
+// applyMessageTemplate 以模板渲染出的内容作为用户消息内容，返回渲染结果；未引用模板时返回nil
+//
+// 发送消息只使用模板的 content，模板的系统提示在以模板创建对话时生效。
+func (s *Service) applyMessageTemplate(ctx context.Context, req *SendMessageRequest) (*model.RenderedPrompt, error) {
+	if req.TemplateID == 0 {
+		if req.TemplateVersion != 0 || len(req.Variables) > 0 {
+			return nil, fmt.Errorf("%w: template_version and variables require template_id", ErrInvalidContent)
+		}
+		return nil, nil
+	}
+	if req.Content != "" || len(req.Parts) > 0 {
+		return nil, fmt.Errorf("%w: content and parts cannot be used with template_id", ErrInvalidContent)
+	}
+
+	rendered, err := s.renderTemplate(ctx, req.UserID, req.TemplateID, req.TemplateVersion, req.Variables, ErrInvalidContent)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(rendered.Content) == "" {
+		return nil, fmt.Errorf("%w: template %d has no message content", ErrInvalidContent, req.TemplateID)
+	}
+
+	req.Content = rendered.Content
+	return rendered, nil
+}
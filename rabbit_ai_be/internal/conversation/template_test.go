@@ -0,0 +1,142 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/model"
+)
+
+// stubTemplates 渲染固定模板的模板服务：模板5有系统提示与内容，模板6只有内容
+type stubTemplates struct{}
+
+func (stubTemplates) Render(ctx context.Context, userID, templateID int64, version int, variables map[string]string) (*model.RenderedPrompt, error) {
+	if templateID != 5 && templateID != 6 {
+		return nil, model.ErrPromptTemplateNotFound
+	}
+	if variables["text"] == "" {
+		return nil, model.ErrInvalidTemplateVariables
+	}
+	if version == 0 {
+		version = 2
+	}
+	rendered := &model.RenderedPrompt{TemplateID: templateID, Version: version, Name: "翻译", Content: "翻译成英文：" + variables["text"]}
+	if templateID == 5 {
+		rendered.SystemPrompt = "你是译者，风格：" + variables["text"]
+	}
+	return rendered, nil
+}
+
+func TestSendMessage_Template(t *testing.T) {
+	service, messageRepo, fake := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: "Hello"}},
+	})
+	service.SetTemplates(stubTemplates{})
+
+	response, err := service.SendMessage(context.Background(), &SendMessageRequest{
+		ConversationID: 1,
+		UserID:         1,
+		TemplateID:     5,
+		Variables:      map[string]string{"text": "你好"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to send templated message: %v", err)
+	}
+
+	stored, _ := messageRepo.GetByID(response.UserMessage.ID)
+	if stored.Content != "翻译成英文：你好" || stored.TemplateID != 5 || stored.TemplateVersion != 2 {
+		t.Errorf("Expected rendered content with template version recorded, got %+v", stored)
+	}
+	if messages := fake.LastMessages(); len(messages) != 1 || messages[0] != "user: 翻译成英文：你好" {
+		t.Errorf("Expected only rendered content sent, got %v", messages)
+	}
+}
+
+func TestSendMessage_TemplateInvalid(t *testing.T) {
+	service, _, fake := newFakeMiniMaxService(t, fakellm.Config{})
+	ctx := context.Background()
+
+	requests := map[string]*SendMessageRequest{
+		"templates disabled":   {TemplateID: 5, Variables: map[string]string{"text": "x"}},
+		"variables without id": {Content: "x", Variables: map[string]string{"text": "x"}},
+	}
+	for name, req := range requests {
+		req.ConversationID, req.UserID = 1, 1
+		if _, err := service.SendMessage(ctx, req); !errors.Is(err, ErrInvalidContent) {
+			t.Errorf("%s: expected ErrInvalidContent, got %v", name, err)
+		}
+	}
+
+	service.SetTemplates(stubTemplates{})
+	requests = map[string]*SendMessageRequest{
+		"content with template": {TemplateID: 5, Content: "x", Variables: map[string]string{"text": "x"}},
+		"unknown template":      {TemplateID: 9, Variables: map[string]string{"text": "x"}},
+		"missing variable":      {TemplateID: 5},
+	}
+	for name, req := range requests {
+		req.ConversationID, req.UserID = 1, 1
+		if _, err := service.SendMessage(ctx, req); !errors.Is(err, ErrInvalidContent) {
+			t.Errorf("%s: expected ErrInvalidContent, got %v", name, err)
+		}
+	}
+	if fake.RequestCount() != 0 {
+		t.Errorf("Expected no upstream requests, got %d", fake.RequestCount())
+	}
+}
+
+func TestCreateConversation_Template(t *testing.T) {
+	service, _, _ := newFakeMiniMaxService(t, fakellm.Config{})
+	service.SetTemplates(stubTemplates{})
+	ctx := context.Background()
+
+	response, err := service.CreateConversation(ctx, &CreateConversationRequest{
+		UserID:          1,
+		TemplateID:      5,
+		TemplateVersion: 1,
+		Variables:       map[string]string{"text": "正式"},
+		Settings:        model.ConversationSettings{TemplateID: 99},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create templated conversation: %v", err)
+	}
+	settings := response.Conversation.Settings
+	if settings.SystemPrompt != "你是译者，风格：正式" || settings.TemplateID != 5 || settings.TemplateVersion != 1 {
+		t.Errorf("Expected rendered system prompt with template recorded, got %+v", settings)
+	}
+	if response.Conversation.Title != DefaultTitle {
+		t.Errorf("Expected default title, got %q", response.Conversation.Title)
+	}
+
+	invalid := map[string]*CreateConversationRequest{
+		"system prompt with template": {TemplateID: 5, Variables: map[string]string{"text": "x"}, Settings: model.ConversationSettings{SystemPrompt: "x"}},
+		"template without system":     {TemplateID: 6, Variables: map[string]string{"text": "x"}},
+		"unknown template":            {TemplateID: 9, Variables: map[string]string{"text": "x"}},
+	}
+	for name, req := range invalid {
+		req.UserID = 1
+		if _, err := service.CreateConversation(ctx, req); !errors.Is(err, ErrInvalidSettings) {
+			t.Errorf("%s: expected ErrInvalidSettings, got %v", name, err)
+		}
+	}
+
+	// 修改系统提示后不再记录模板
+	conversationID := response.Conversation.ID
+	updated, err := service.UpdateConversationSettings(ctx, &UpdateConversationSettingsRequest{
+		ConversationID: conversationID,
+		UserID:         1,
+		Settings:       model.ConversationSettings{SystemPrompt: settings.SystemPrompt, Model: "MiniMax-M1"},
+	})
+	if err != nil || updated.Conversation.Settings.TemplateID != 5 {
+		t.Fatalf("Expected template kept with unchanged system prompt, got %+v, %v", updated, err)
+	}
+	updated, err = service.UpdateConversationSettings(ctx, &UpdateConversationSettingsRequest{
+		ConversationID: conversationID,
+		UserID:         1,
+		Settings:       model.ConversationSettings{SystemPrompt: "自定义"},
+	})
+	if err != nil || updated.Conversation.Settings.TemplateID != 0 || updated.Conversation.Settings.TemplateVersion != 0 {
+		t.Errorf("Expected template cleared after system prompt change, got %+v, %v", updated, err)
+	}
+}
@@ -0,0 +1,179 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"rabbit_ai/internal/guardrail"
+	"rabbit_ai/internal/llm"
+)
+
+// 对话标题参数
+const (
+	DefaultTitle          = "新对话" // 创建对话未指定标题时的默认标题
+	DefaultTitleMaxTokens = 32
+	fallbackTitleRunes    = 20 // 截断用户消息作为标题时保留的字符数
+	maxTitleRunes         = 30 // 模型生成标题的最大字符数
+	titleTimeout          = 30 * time.Second
+)
+
+// EventTitle 对话标题更新事件类型
+const EventTitle = "title"
+
+// titleSystemPrompt 生成标题时的系统提示
+const titleSystemPrompt = `你是对话标题助手。请根据用户的第一条消息和AI的回复，为这段对话生成一个简短的标题（不超过15个字），概括对话主题。
+只输出标题本身，不要加引号、句末标点或任何解释。`
+
+// TitlePolicy 自动标题策略
+type TitlePolicy struct {
+	Enabled   bool   // 是否在首轮对话后调用模型生成标题，关闭时只截断用户消息作为标题
+	Model     string // 生成标题使用的模型，为空时使用对话当前的模型
+	MaxTokens int    // 标题最大token数
+}
+
+// ConversationEvent 推送给客户端的对话事件
+type ConversationEvent struct {
+	Type           string `json:"type"` // 事件类型，如 title
+	ConversationID int64  `json:"conversation_id"`
+	Title          string `json:"title,omitempty"`
+}
+
+// SetTitlePolicy 设置自动标题策略
+func (s *Service) SetTitlePolicy(policy TitlePolicy) {
+	if policy.MaxTokens <= 0 {
+		policy.MaxTokens = DefaultTitleMaxTokens
+	}
+	s.titlePolicy = policy
+}
+
+// needsTitle 对话标题为空或为默认标题
+func needsTitle(title string) bool {
+	return title == "" || title == DefaultTitle
+}
+
+// fallbackTitle 截断用户消息作为标题，按字符而非字节截断，避免截断多字节字符
+func fallbackTitle(content string) string {
+	title := strings.Join(strings.Fields(content), " ")
+	runes := []rune(title)
+	if len(runes) > fallbackTitleRunes {
+		return string(runes[:fallbackTitleRunes]) + "..."
+	}
+	return title
+}
+
+// cleanTitle 规范化模型生成的标题：取第一行，去掉“标题：”前缀、引号与句末标点并限制长度
+func cleanTitle(raw string) string {
+	title := strings.TrimSpace(raw)
+	if line, _, found := strings.Cut(title, "\n"); found {
+		title = line
+	}
+	for _, prefix := range []string{"标题：", "标题:", "Title:"} {
+		title = strings.TrimPrefix(title, prefix)
+	}
+	quote := func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(`"'“”‘’《》「」*#`, r)
+	}
+	title = strings.TrimLeftFunc(title, quote)
+	title = strings.TrimRightFunc(title, func(r rune) bool {
+		return quote(r) || strings.ContainsRune("。．.！!？?，,；;：:", r)
+	})
+
+	runes := []rune(title)
+	if len(runes) > maxTitleRunes {
+		title = string(runes[:maxTitleRunes])
+	}
+	return title
+}
+
+// titleInBackground 在后台为对话生成标题，替换截断用户消息得到的临时标题
+func (s *Service) titleInBackground(conversationID, userID int64, modelName, placeholder, question, answer string) {
+	if !s.titlePolicy.Enabled {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+		defer cancel()
+
+		if _, err := s.generateTitle(ctx, conversationID, userID, modelName, placeholder, question, answer); err != nil {
+			log.Printf("failed to generate title for conversation %d: %v", conversationID, err)
+		}
+	}()
+}
+
+// generateTitle 根据首轮对话生成标题，仅当标题仍为 placeholder 时更新，并通知客户端
+//
+// 模型不可用或生成的标题为空、未通过内容安全检查时返回错误，保留临时标题。
+// 标题已被其他请求修改时不更新，返回空标题。
+func (s *Service) generateTitle(ctx context.Context, conversationID, userID int64, modelName, placeholder, question, answer string) (string, error) {
+	if s.titlePolicy.Model != "" {
+		modelName = s.titlePolicy.Model
+	}
+
+	chatResp, err := s.llmProvider.ChatCompletion(ctx, llm.ChatRequest{
+		Model: modelName,
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: titleSystemPrompt},
+			{Role: llm.RoleUser, Content: fmt.Sprintf("用户：%s\n助手：%s", question, answer)},
+		},
+		MaxTokens:   s.titlePolicy.MaxTokens,
+		Temperature: 0.3,
+		User:        fmt.Sprintf("user_%d", userID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate title: %w", err)
+	}
+
+	title := cleanTitle(chatResp.GetContent())
+	if title == "" {
+		return "", errors.New("empty title from AI")
+	}
+	if s.checkContent(ctx, guardrail.StageOutput, title, chatResp.Sensitive).Blocked() {
+		return "", errors.New("title blocked by guardrail")
+	}
+
+	updated, err := s.conversationRepo.UpdateTitle(conversationID, placeholder, title)
+	if err != nil {
+		return "", fmt.Errorf("failed to update conversation title: %w", err)
+	}
+	if !updated {
+		return "", nil
+	}
+
+	// 使相关缓存失效
+	if err := s.conversationCache.InvalidateConversationCache(ctx, conversationID); err != nil {
+		fmt.Printf("failed to invalidate conversation cache: %v\n", err)
+	}
+	if err := s.conversationCache.InvalidateUserCache(ctx, userID); err != nil {
+		fmt.Printf("failed to invalidate user cache: %v\n", err)
+	}
+
+	// 通知客户端标题已更新
+	payload, _ := json.Marshal(ConversationEvent{
+		Type:           EventTitle,
+		ConversationID: conversationID,
+		Title:          title,
+	})
+	if err := s.conversationCache.PublishUserEvent(ctx, userID, payload); err != nil {
+		fmt.Printf("failed to publish title event: %v\n", err)
+	}
+
+	return title, nil
+}
+
+// SubscribeEvents 订阅用户的对话事件，直到ctx结束；订阅确认后调用 ready
+func (s *Service) SubscribeEvents(ctx context.Context, userID int64, ready func(), handler func(event *ConversationEvent)) error {
+	return s.conversationCache.SubscribeUserEvents(ctx, userID, ready, func(payload string) {
+		var event ConversationEvent
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			return
+		}
+		handler(&event)
+	})
+}
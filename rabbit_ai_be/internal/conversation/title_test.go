@@ -0,0 +1,104 @@
+package conversation
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"rabbit_ai/internal/fakellm"
+)
+
+func TestFallbackTitle(t *testing.T) {
+	long := strings.Repeat("并发编程", 8)
+	title := fallbackTitle(long)
+	if !utf8.ValidString(title) {
+		t.Fatalf("Expected valid UTF-8 title, got %q", title)
+	}
+	if title != strings.Repeat("并发编程", 5)+"..." {
+		t.Errorf("Expected first 20 runes with ellipsis, got %q", title)
+	}
+
+	if title := fallbackTitle("  你好\n 世界 "); title != "你好 世界" {
+		t.Errorf("Expected whitespace collapsed, got %q", title)
+	}
+}
+
+func TestCleanTitle(t *testing.T) {
+	tests := map[string]string{
+		"“Go 并发入门”。":            "Go 并发入门",
+		"标题：《红烧肉做法》":            "红烧肉做法",
+		"旅行计划\n以上是标题":           "旅行计划",
+		"**Weekly Report**":     "Weekly Report",
+		strings.Repeat("长", 40): strings.Repeat("长", maxTitleRunes),
+		"  。":                   "",
+	}
+	for raw, expected := range tests {
+		if title := cleanTitle(raw); title != expected {
+			t.Errorf("cleanTitle(%q): expected %q, got %q", raw, expected, title)
+		}
+	}
+}
+
+func TestSendMessage_FallbackTitle(t *testing.T) {
+	service, _, _ := newFakeMiniMaxService(t, fakellm.Config{})
+	conversation, _ := service.conversationRepo.GetByID(1)
+	conversation.Title = DefaultTitle
+
+	content := strings.Repeat("请介绍一下", 6)
+	response, err := service.SendMessage(context.Background(), &SendMessageRequest{ConversationID: 1, UserID: 1, Content: content})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	if response.Conversation.Title != fallbackTitle(content) || !utf8.ValidString(response.Conversation.Title) {
+		t.Errorf("Expected rune-safe fallback title, got %q", response.Conversation.Title)
+	}
+	if response.TitlePending {
+		t.Error("Expected no title generation when title policy is disabled")
+	}
+}
+
+func TestGenerateTitle(t *testing.T) {
+	service, _, fake := newFakeMiniMaxService(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: "“Go 并发入门”。"}, {Content: "不会被使用"}},
+	})
+	service.SetTitlePolicy(TitlePolicy{Enabled: true, Model: "MiniMax-Text-01"})
+	conversation, _ := service.conversationRepo.GetByID(1)
+	conversation.Title = "Go的goroutine怎么用..."
+	ctx := context.Background()
+
+	title, err := service.generateTitle(ctx, 1, 1, "MiniMax-M1", "Go的goroutine怎么用...", "Go的goroutine怎么用？", "goroutine是轻量级线程……")
+	if err != nil {
+		t.Fatalf("Failed to generate title: %v", err)
+	}
+	if title != "Go 并发入门" || conversation.Title != "Go 并发入门" {
+		t.Errorf("Expected cleaned title saved, got %q / %q", title, conversation.Title)
+	}
+	if params := fake.LastParams(); params.Model != "MiniMax-Text-01" || params.MaxTokens != DefaultTitleMaxTokens {
+		t.Errorf("Expected title policy applied, got %+v", params)
+	}
+	if messages := fake.LastMessages(); len(messages) != 2 || !strings.Contains(messages[1], "Go的goroutine怎么用？") {
+		t.Errorf("Expected first exchange sent to model, got %v", messages)
+	}
+
+	// 标题已被修改时不覆盖
+	title, err = service.generateTitle(ctx, 1, 1, "MiniMax-M1", "Go的goroutine怎么用...", "问题", "回答")
+	if err != nil || title != "" || conversation.Title != "Go 并发入门" {
+		t.Errorf("Expected changed title to be kept, got %q, %v, %q", title, err, conversation.Title)
+	}
+}
+
+func TestGenerateTitle_ModelUnavailable(t *testing.T) {
+	service, _, _ := newFakeMiniMaxService(t, fakellm.Config{ErrorCode: fakellm.ErrorInsufficient})
+	service.SetTitlePolicy(TitlePolicy{Enabled: true})
+	conversation, _ := service.conversationRepo.GetByID(1)
+	conversation.Title = "临时标题"
+
+	if _, err := service.generateTitle(context.Background(), 1, 1, "MiniMax-M1", "临时标题", "问题", "回答"); err == nil {
+		t.Fatal("Expected error when model is unavailable")
+	}
+	if conversation.Title != "临时标题" {
+		t.Errorf("Expected fallback title kept, got %q", conversation.Title)
+	}
+}
@@ -0,0 +1,104 @@
+package fakellm
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// EmbeddingDimension 模拟向量的维度
+const EmbeddingDimension = 64
+
+// embeddingRequest embeddings 请求
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Texts []string `json:"texts"`
+	Type  string   `json:"type"` // db/query
+}
+
+// embeddingResponse embeddings 响应
+type embeddingResponse struct {
+	Vectors     [][]float32  `json:"vectors"`
+	TotalTokens int          `json:"total_tokens"`
+	BaseResp    baseResponse `json:"base_resp"`
+}
+
+// EmbeddingCount 已收到的向量化请求数量
+func (s *Server) EmbeddingCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.embeddings
+}
+
+// serveEmbeddings 处理向量化请求，相同文本得到相同向量，字符重叠越多的文本余弦相似度越高
+func (s *Server) serveEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req embeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Texts) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"base_resp":{"status_code":2013,"status_msg":"invalid params"}}`)
+		return
+	}
+
+	s.mu.Lock()
+	s.embeddings++
+	errorCode := s.config.ErrorCode
+	if s.config.APIKey != "" && r.Header.Get("Authorization") != "Bearer "+s.config.APIKey {
+		errorCode = ErrorAuthFailed
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if errorCode != 0 {
+		json.NewEncoder(w).Encode(embeddingResponse{
+			BaseResp: baseResponse{StatusCode: errorCode, StatusMsg: errorMessages[errorCode]},
+		})
+		return
+	}
+
+	resp := embeddingResponse{Vectors: make([][]float32, 0, len(req.Texts))}
+	for _, text := range req.Texts {
+		resp.Vectors = append(resp.Vectors, Embed(text))
+		resp.TotalTokens += EstimateTokens(text)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Embed 计算文本的模拟向量：将小写化后的单字与相邻字对散列到固定维度并归一化
+func Embed(text string) []float32 {
+	vector := make([]float64, EmbeddingDimension)
+	var runes []rune
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			runes = append(runes, r)
+		}
+	}
+	add := func(feature string, weight float64) {
+		h := fnv.New32a()
+		h.Write([]byte(feature))
+		vector[h.Sum32()%EmbeddingDimension] += weight
+	}
+	for i, r := range runes {
+		add(string(r), 1)
+		if i+1 < len(runes) {
+			add(string(runes[i:i+2]), 2)
+		}
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	result := make([]float32, EmbeddingDimension)
+	if norm == 0 {
+		return result
+	}
+	norm = math.Sqrt(norm)
+	for i, v := range vector {
+		result[i] = float32(v / norm)
+	}
+	return result
+}
@@ -0,0 +1,160 @@
+package fakellm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 该文件独立定义 chatcompletion_v2 的线上格式，而不是复用 minimax 包的结构体，
+// 这样客户端结构体的改动如果破坏了协议兼容性，测试能够及时发现。
+
+// chatCompletionRequest chatcompletion_v2 请求
+type chatCompletionRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Stream      bool          `json:"stream"`
+	Temperature float64       `json:"temperature"`
+	MaxTokens   int           `json:"max_tokens"`
+	TopP        float64       `json:"top_p"`
+	Stop        []string      `json:"stop,omitempty"`
+	User        string        `json:"user"`
+	Tools       []tool        `json:"tools,omitempty"`
+}
+
+// tool 工具定义
+type tool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Parameters  json.RawMessage `json:"parameters"`
+	} `json:"function"`
+}
+
+// toolCall 工具调用
+type toolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// chatMessage 聊天消息
+type chatMessage struct {
+	Role       string     `json:"role"`
+	Name       string     `json:"name"`
+	Content    string     `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+
+	images []string // 请求中图文消息的图片地址
+}
+
+// contentPart 图文消息的内容片段
+type contentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url"`
+}
+
+// UnmarshalJSON content 可以是字符串，也可以是图文内容片段数组（文字片段以换行连接）
+func (m *chatMessage) UnmarshalJSON(data []byte) error {
+	type plain chatMessage
+	var wire struct {
+		plain
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	*m = chatMessage(wire.plain)
+	if len(wire.Content) == 0 {
+		return nil
+	}
+	if wire.Content[0] != '[' {
+		return json.Unmarshal(wire.Content, &m.Content)
+	}
+
+	var parts []contentPart
+	if err := json.Unmarshal(wire.Content, &parts); err != nil {
+		return err
+	}
+	var texts []string
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			texts = append(texts, part.Text)
+		case "image_url":
+			if part.ImageURL == nil || part.ImageURL.URL == "" {
+				return errors.New("image_url part without url")
+			}
+			m.images = append(m.images, part.ImageURL.URL)
+		default:
+			return fmt.Errorf("unknown content part type %q", part.Type)
+		}
+	}
+	m.Content = strings.Join(texts, "\n")
+	return nil
+}
+
+// baseResponse 基础响应
+type baseResponse struct {
+	StatusCode int    `json:"status_code"`
+	StatusMsg  string `json:"status_msg"`
+}
+
+// choice 选择项
+type choice struct {
+	FinishReason string       `json:"finish_reason,omitempty"`
+	Index        int          `json:"index"`
+	Message      *chatMessage `json:"message,omitempty"`
+	Delta        *chatMessage `json:"delta,omitempty"`
+}
+
+// usage 使用统计
+type usage struct {
+	TotalTokens      int `json:"total_tokens"`
+	TotalCharacters  int `json:"total_characters"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// chatCompletionResponse chatcompletion_v2 响应（流式片段复用该结构）
+type chatCompletionResponse struct {
+	ID       string       `json:"id"`
+	Choices  []choice     `json:"choices,omitempty"`
+	Created  int64        `json:"created"`
+	Model    string       `json:"model"`
+	Object   string       `json:"object"`
+	Usage    *usage       `json:"usage,omitempty"`
+	BaseResp baseResponse `json:"base_resp"`
+
+	OutputSensitive     bool `json:"output_sensitive,omitempty"`
+	OutputSensitiveType int  `json:"output_sensitive_type,omitempty"`
+}
+
+// 常用的 base_resp 错误码，与 MiniMax 官方保持一致
+const (
+	ErrorTimeout      = 1001 // 请求超时
+	ErrorRateLimit    = 1002 // 触发RPM限流
+	ErrorAuthFailed   = 1004 // 鉴权失败
+	ErrorInsufficient = 1008 // 余额不足
+	ErrorInternal     = 1013 // 服务内部错误
+	ErrorTokenLimit   = 1039 // Token限制
+)
+
+// errorMessages 错误码对应的 status_msg
+var errorMessages = map[int]string{
+	ErrorTimeout:      "request timeout",
+	ErrorRateLimit:    "rate limit exceeded(RPM)",
+	ErrorAuthFailed:   "login fail: Please carry the API secret key in the 'Authorization' field of the request header",
+	ErrorInsufficient: "insufficient balance",
+	ErrorInternal:     "internal error",
+	ErrorTokenLimit:   "context window exceeds limit",
+}
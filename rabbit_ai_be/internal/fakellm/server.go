@@ -0,0 +1,397 @@
+// Package fakellm 提供离线的 MiniMax chatcompletion_v2 与 embeddings 模拟服务，
+// 用于本地开发（cmd/fakellm）和测试（httptest）。
+package fakellm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Reply 脚本化回复
+type Reply struct {
+	Content    string        // 回复内容
+	ErrorCode  int           // 非0时返回对应的 base_resp 错误码
+	StatusCode int           // 非0时直接返回该HTTP状态码
+	Delay      time.Duration // 响应前的等待时间
+	RetryAfter time.Duration // 非0时返回Retry-After响应头（按秒取整）
+	ToolCalls  []ToolCall    // 非空时返回工具调用，finish_reason 为 tool_calls
+	Sensitive  int           // 非0时标记输出敏感（output_sensitive），值为敏感类型
+}
+
+// ToolCall 脚本化的工具调用
+type ToolCall struct {
+	Name      string // 工具名称
+	Arguments string // JSON编码的参数
+}
+
+// Params 请求携带的模型与采样参数
+type Params struct {
+	Model       string
+	Temperature float64
+	TopP        float64
+	MaxTokens   int
+	Stop        []string
+}
+
+// Config 模拟服务配置
+type Config struct {
+	APIKey     string        // 非空时校验 Authorization 头，不匹配返回1004
+	Replies    []Reply       // 脚本回复，按顺序消费，耗尽后回显最后一条用户消息
+	ErrorCode  int           // 非0时所有请求都返回该错误码
+	ChunkSize  int           // 流式响应每个片段的字符数，默认4
+	ChunkDelay time.Duration // 流式响应片段之间的间隔
+}
+
+// Server MiniMax模拟服务
+type Server struct {
+	mu         sync.Mutex
+	config     Config
+	script     []Reply
+	requests   []chatCompletionRequest
+	embeddings int
+	seq        int64
+}
+
+// NewServer 创建模拟服务实例
+func NewServer(config Config) *Server {
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = 4
+	}
+	return &Server{
+		config: config,
+		script: append([]Reply(nil), config.Replies...),
+	}
+}
+
+// StartTestServer 启动基于httptest的模拟服务，返回的URL可直接作为MiniMax BaseURL使用
+func StartTestServer(config Config) (*httptest.Server, *Server) {
+	fake := NewServer(config)
+	return httptest.NewServer(fake), fake
+}
+
+// Enqueue 追加脚本回复
+func (s *Server) Enqueue(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = append(s.script, replies...)
+}
+
+// SetErrorCode 设置所有请求返回的错误码，0表示恢复正常
+func (s *Server) SetErrorCode(code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config.ErrorCode = code
+}
+
+// RequestCount 已收到的请求数量
+func (s *Server) RequestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+// LastMessages 最近一次请求携带的消息内容（role: content）
+func (s *Server) LastMessages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return nil
+	}
+	var result []string
+	for _, msg := range s.requests[len(s.requests)-1].Messages {
+		result = append(result, msg.Role+": "+msg.Content)
+	}
+	return result
+}
+
+// LastImages 最近一次请求中图文消息携带的图片地址
+func (s *Server) LastImages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return nil
+	}
+	var result []string
+	for _, msg := range s.requests[len(s.requests)-1].Messages {
+		result = append(result, msg.images...)
+	}
+	return result
+}
+
+// LastTools 最近一次请求声明的工具名称
+func (s *Server) LastTools() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return nil
+	}
+	var result []string
+	for _, t := range s.requests[len(s.requests)-1].Tools {
+		result = append(result, t.Function.Name)
+	}
+	return result
+}
+
+// LastParams 最近一次请求的模型与采样参数
+func (s *Server) LastParams() Params {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return Params{}
+	}
+	req := s.requests[len(s.requests)-1]
+	return Params{
+		Model:       req.Model,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxTokens,
+		Stop:        req.Stop,
+	}
+}
+
+// ServeHTTP 处理请求，兼容带或不带 /v1 前缀的 /text/chatcompletion_v2 与 /embeddings
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/embeddings") {
+		s.serveEmbeddings(w, r)
+		return
+	}
+	if !strings.HasSuffix(r.URL.Path, "/text/chatcompletion_v2") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req chatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"base_resp":{"status_code":2013,"status_msg":"invalid params: %s"}}`, err.Error())
+		return
+	}
+
+	reply, id := s.next(r, req)
+
+	// 模拟上游耗时，客户端断开时提前返回
+	if reply.Delay > 0 {
+		select {
+		case <-time.After(reply.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if reply.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(reply.RetryAfter/time.Second)))
+	}
+
+	if reply.StatusCode != 0 && reply.StatusCode != http.StatusOK {
+		w.WriteHeader(reply.StatusCode)
+		fmt.Fprintf(w, `{"error":"fake upstream status %d"}`, reply.StatusCode)
+		return
+	}
+
+	if reply.ErrorCode != 0 {
+		s.writeError(w, req, reply.ErrorCode)
+		return
+	}
+
+	if req.Stream {
+		s.writeStream(w, r, req, id, reply)
+		return
+	}
+	s.writeJSON(w, req, id, reply)
+}
+
+// next 记录请求并取出下一条回复
+func (s *Server) next(r *http.Request, req chatCompletionRequest) (Reply, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, req)
+	s.seq++
+	id := fmt.Sprintf("fake-%d-%d", time.Now().Unix(), s.seq)
+
+	if s.config.APIKey != "" && r.Header.Get("Authorization") != "Bearer "+s.config.APIKey {
+		return Reply{ErrorCode: ErrorAuthFailed}, id
+	}
+	if s.config.ErrorCode != 0 {
+		return Reply{ErrorCode: s.config.ErrorCode}, id
+	}
+	if len(s.script) > 0 {
+		reply := s.script[0]
+		s.script = s.script[1:]
+		if reply.Content == "" && reply.ErrorCode == 0 && reply.StatusCode == 0 && len(reply.ToolCalls) == 0 {
+			reply.Content = echo(req)
+		}
+		return reply, id
+	}
+	return Reply{Content: echo(req)}, id
+}
+
+// writeJSON 写入非流式响应
+func (s *Server) writeJSON(w http.ResponseWriter, req chatCompletionRequest, id string, reply Reply) {
+	u := buildUsage(req, reply.Content)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chatCompletionResponse{
+		ID:       id,
+		Created:  time.Now().Unix(),
+		Model:    req.Model,
+		Object:   "chat.completion",
+		Choices:  []choice{finalChoice(id, reply)},
+		Usage:    &u,
+		BaseResp: baseResponse{StatusCode: 0, StatusMsg: ""},
+
+		OutputSensitive:     reply.Sensitive != 0,
+		OutputSensitiveType: reply.Sensitive,
+	})
+}
+
+// finalChoice 构建携带完整消息的选择项，有工具调用时结束原因为 tool_calls
+func finalChoice(id string, reply Reply) choice {
+	message := &chatMessage{Role: "assistant", Name: "MiniMax AI", Content: reply.Content}
+	finishReason := "stop"
+	for i, call := range reply.ToolCalls {
+		var tc toolCall
+		tc.ID = fmt.Sprintf("call_%s_%d", id, i)
+		tc.Type = "function"
+		tc.Function.Name = call.Name
+		tc.Function.Arguments = call.Arguments
+		message.ToolCalls = append(message.ToolCalls, tc)
+		finishReason = "tool_calls"
+	}
+	return choice{
+		FinishReason: finishReason,
+		Index:        0,
+		Message:      message,
+	}
+}
+
+// writeStream 写入SSE流式响应，最后一个片段携带完整消息、结束原因和使用统计
+func (s *Server) writeStream(w http.ResponseWriter, r *http.Request, req chatCompletionRequest, id string, reply Reply) {
+	content := reply.Content
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+
+	send := func(resp chatCompletionResponse) {
+		data, _ := json.Marshal(resp)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	runes := []rune(content)
+	for start := 0; start < len(runes); start += s.config.ChunkSize {
+		end := start + s.config.ChunkSize
+		if end > len(runes) {
+			end = len(runes)
+		}
+		send(chatCompletionResponse{
+			ID:      id,
+			Created: time.Now().Unix(),
+			Model:   req.Model,
+			Object:  "chat.completion.chunk",
+			Choices: []choice{{
+				Index: 0,
+				Delta: &chatMessage{Role: "assistant", Content: string(runes[start:end])},
+			}},
+		})
+
+		if s.config.ChunkDelay > 0 {
+			select {
+			case <-time.After(s.config.ChunkDelay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+
+	u := buildUsage(req, content)
+	send(chatCompletionResponse{
+		ID:      id,
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Object:  "chat.completion",
+		Choices: []choice{finalChoice(id, reply)},
+		Usage:   &u,
+
+		OutputSensitive:     reply.Sensitive != 0,
+		OutputSensitiveType: reply.Sensitive,
+	})
+}
+
+// writeError 写入 base_resp 错误，流式请求以单个SSE片段返回
+func (s *Server) writeError(w http.ResponseWriter, req chatCompletionRequest, code int) {
+	msg, ok := errorMessages[code]
+	if !ok {
+		msg = "unknown error"
+	}
+	resp := chatCompletionResponse{
+		BaseResp: baseResponse{StatusCode: code, StatusMsg: msg},
+	}
+
+	if req.Stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		data, _ := json.Marshal(resp)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// echo 回显最后一条用户消息
+func echo(req chatCompletionRequest) string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			return req.Messages[i].Content
+		}
+	}
+	return "你好，我是模拟的MiniMax助手。"
+}
+
+// buildUsage 根据请求和回复估算使用统计
+func buildUsage(req chatCompletionRequest, content string) usage {
+	promptTokens := 0
+	promptChars := 0
+	for _, msg := range req.Messages {
+		// 每条消息额外计入角色等格式开销
+		promptTokens += EstimateTokens(msg.Content) + 4
+		promptChars += utf8.RuneCountInString(msg.Content)
+	}
+	completionTokens := EstimateTokens(content)
+
+	return usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+		TotalCharacters:  promptChars + utf8.RuneCountInString(content),
+	}
+}
+
+// EstimateTokens 粗略估算文本的token数：中日韩字符按1个token计，其余字符按4个字符1个token计
+func EstimateTokens(text string) int {
+	tokens := 0
+	others := 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			tokens++
+			continue
+		}
+		others++
+	}
+	return tokens + (others+3)/4
+}
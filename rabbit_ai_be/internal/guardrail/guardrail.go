@@ -0,0 +1,106 @@
+// Package guardrail 提供调用大模型前后的内容安全检查链（护栏），检查输入与输出并给出放行、标记或屏蔽的结论。
+package guardrail
+
+import (
+	"context"
+	"fmt"
+
+	"rabbit_ai/internal/llm"
+)
+
+// Stage 检查阶段
+type Stage string
+
+// 检查阶段
+const (
+	StageInput  Stage = "input"  // 调用模型前检查用户输入
+	StageOutput Stage = "output" // 模型返回后检查回复
+)
+
+// Action 检查结论
+type Action string
+
+// 检查结论，严重程度依次递增
+const (
+	ActionAllow Action = ""      // 放行
+	ActionFlag  Action = "flag"  // 放行但标记，等待人工复核
+	ActionBlock Action = "block" // 屏蔽
+)
+
+// Input 待检查的内容
+type Input struct {
+	Stage     Stage
+	Content   string
+	Sensitive *llm.SensitiveFlags // 提供方返回的敏感内容标记，仅输出阶段可能非空
+}
+
+// Verdict 检查结果
+type Verdict struct {
+	Action Action `json:"action"`
+	Guard  string `json:"guard"`  // 给出结论的护栏名称
+	Reason string `json:"reason"` // 命中原因，如命中的规则
+}
+
+// Blocked 内容是否被屏蔽
+func (v Verdict) Blocked() bool {
+	return v.Action == ActionBlock
+}
+
+// Flagged 内容是否被标记（未屏蔽）
+func (v Verdict) Flagged() bool {
+	return v.Action == ActionFlag
+}
+
+// Guard 护栏，对单个阶段的内容给出结论
+type Guard interface {
+	Name() string
+	Check(ctx context.Context, input Input) (Verdict, error)
+}
+
+// Pipeline 护栏链，按顺序执行各护栏
+type Pipeline struct {
+	guards []Guard
+}
+
+// NewPipeline 创建护栏链
+func NewPipeline(guards ...Guard) *Pipeline {
+	return &Pipeline{guards: guards}
+}
+
+// Len 护栏数量
+func (p *Pipeline) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.guards)
+}
+
+// Check 依次执行护栏：任一护栏屏蔽时立即返回屏蔽结论，否则返回第一个标记结论，均放行时返回放行
+//
+// 护栏为nil时直接放行。护栏执行出错时返回错误，由调用方决定是否放行。
+func (p *Pipeline) Check(ctx context.Context, input Input) (Verdict, error) {
+	var result Verdict
+	if p == nil {
+		return result, nil
+	}
+
+	for _, guard := range p.guards {
+		verdict, err := guard.Check(ctx, input)
+		if err != nil {
+			return Verdict{}, fmt.Errorf("guard %s: %w", guard.Name(), err)
+		}
+		if verdict.Action == ActionAllow {
+			continue
+		}
+		if verdict.Guard == "" {
+			verdict.Guard = guard.Name()
+		}
+		if verdict.Blocked() {
+			return verdict, nil
+		}
+		if result.Action == ActionAllow {
+			result = verdict
+		}
+	}
+	return result, nil
+}
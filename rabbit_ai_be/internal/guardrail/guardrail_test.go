@@ -0,0 +1,149 @@
+package guardrail
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"rabbit_ai/internal/llm"
+)
+
+// stubGuard 返回固定结论的护栏
+type stubGuard struct {
+	name    string
+	verdict Verdict
+	err     error
+	calls   int
+}
+
+func (g *stubGuard) Name() string { return g.name }
+
+func (g *stubGuard) Check(ctx context.Context, input Input) (Verdict, error) {
+	g.calls++
+	return g.verdict, g.err
+}
+
+func TestPipeline_BlockShortCircuits(t *testing.T) {
+	flag := &stubGuard{name: "flag", verdict: Verdict{Action: ActionFlag, Reason: "可疑"}}
+	block := &stubGuard{name: "block", verdict: Verdict{Action: ActionBlock, Reason: "违规"}}
+	after := &stubGuard{name: "after"}
+
+	verdict, err := NewPipeline(flag, block, after).Check(context.Background(), Input{Stage: StageInput, Content: "x"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !verdict.Blocked() || verdict.Guard != "block" {
+		t.Errorf("Expected block verdict from block guard, got %+v", verdict)
+	}
+	if after.calls != 0 {
+		t.Errorf("Expected guards after a block to be skipped, got %d calls", after.calls)
+	}
+}
+
+func TestPipeline_FirstFlagWins(t *testing.T) {
+	first := &stubGuard{name: "first", verdict: Verdict{Action: ActionFlag}}
+	second := &stubGuard{name: "second", verdict: Verdict{Action: ActionFlag}}
+
+	verdict, _ := NewPipeline(&stubGuard{name: "allow"}, first, second).Check(context.Background(), Input{})
+	if !verdict.Flagged() || verdict.Guard != "first" {
+		t.Errorf("Expected flag from first guard, got %+v", verdict)
+	}
+
+	var nilPipeline *Pipeline
+	if verdict, err := nilPipeline.Check(context.Background(), Input{}); err != nil || verdict.Action != ActionAllow {
+		t.Errorf("Expected nil pipeline to allow, got %+v, %v", verdict, err)
+	}
+
+	failing := &stubGuard{name: "failing", err: errors.New("boom")}
+	if _, err := NewPipeline(failing).Check(context.Background(), Input{}); err == nil {
+		t.Error("Expected guard error to be returned")
+	}
+}
+
+func TestKeywordGuard(t *testing.T) {
+	guard, err := NewKeywordGuard([]KeywordRule{
+		{Pattern: "Secret"},
+		{Pattern: `\d{3}-\d{4}`, Regex: true, Action: ActionFlag, Reason: "phone number"},
+		{Pattern: "draft", Stages: []Stage{StageOutput}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create keyword guard: %v", err)
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		input  Input
+		action Action
+		reason string
+	}{
+		{"case insensitive keyword", Input{Stage: StageInput, Content: "my SECRET plan"}, ActionBlock, "keyword: Secret"},
+		{"regex flag", Input{Stage: StageInput, Content: "call 555-1234"}, ActionFlag, "phone number"},
+		{"block beats earlier flag", Input{Stage: StageOutput, Content: "555-1234 secret"}, ActionBlock, "keyword: Secret"},
+		{"stage filter skips input", Input{Stage: StageInput, Content: "a draft"}, ActionAllow, ""},
+		{"stage filter applies to output", Input{Stage: StageOutput, Content: "a draft"}, ActionBlock, "keyword: draft"},
+		{"no match", Input{Stage: StageInput, Content: "hello"}, ActionAllow, ""},
+	}
+	for _, tt := range tests {
+		verdict, err := guard.Check(ctx, tt.input)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if verdict.Action != tt.action || verdict.Reason != tt.reason {
+			t.Errorf("%s: expected %q/%q, got %+v", tt.name, tt.action, tt.reason, verdict)
+		}
+	}
+}
+
+func TestNewKeywordGuard_Validation(t *testing.T) {
+	invalid := map[string]KeywordRule{
+		"empty pattern":  {},
+		"invalid action": {Pattern: "x", Action: "drop"},
+		"invalid stage":  {Pattern: "x", Stages: []Stage{"both"}},
+		"invalid regex":  {Pattern: "(", Regex: true},
+	}
+	for name, rule := range invalid {
+		if _, err := NewKeywordGuard([]KeywordRule{rule}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestLoadKeywordRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guardrail.yaml")
+	data := "rules:\n  - pattern: 违禁词\n  - pattern: '\\d{11}'\n    regex: true\n    action: flag\n    stages: [output]\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := LoadKeywordRules(path)
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Pattern != "违禁词" || !rules[1].Regex || rules[1].Action != ActionFlag || rules[1].Stages[0] != StageOutput {
+		t.Errorf("Unexpected rules: %+v", rules)
+	}
+}
+
+func TestSensitiveFlagGuard(t *testing.T) {
+	guard := NewSensitiveFlagGuard()
+	ctx := context.Background()
+
+	flags := &llm.SensitiveFlags{Output: true, OutputType: 3}
+	if verdict, _ := guard.Check(ctx, Input{Stage: StageInput, Sensitive: flags}); verdict.Action != ActionAllow {
+		t.Errorf("Expected input stage to be ignored, got %+v", verdict)
+	}
+	if verdict, _ := guard.Check(ctx, Input{Stage: StageOutput}); verdict.Action != ActionAllow {
+		t.Errorf("Expected missing flags to allow, got %+v", verdict)
+	}
+	verdict, _ := guard.Check(ctx, Input{Stage: StageOutput, Sensitive: flags})
+	if !verdict.Blocked() || verdict.Reason != "output_sensitive_type=3" {
+		t.Errorf("Expected output flag to block, got %+v", verdict)
+	}
+	verdict, _ = guard.Check(ctx, Input{Stage: StageOutput, Sensitive: &llm.SensitiveFlags{Input: true, InputType: 1}})
+	if !verdict.Blocked() || verdict.Reason != "input_sensitive_type=1" {
+		t.Errorf("Expected input flag to block, got %+v", verdict)
+	}
+}
@@ -0,0 +1,136 @@
+package guardrail
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"rabbit_ai/internal/model"
+)
+
+// Handler 内容安全复核处理器（管理员接口）
+type Handler struct {
+	service *ReviewService
+}
+
+// NewHandler 创建复核处理器实例
+func NewHandler(service *ReviewService) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// RegisterRoutes 注册路由，调用方负责在路由组上校验管理员身份
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	moderationGroup := r.Group("/moderation")
+	{
+		moderationGroup.GET("", h.ListEvents)
+		moderationGroup.POST("/:id/review", h.ReviewEvent)
+	}
+}
+
+// ListEvents 获取审核事件列表
+func (h *Handler) ListEvents(c *gin.Context) {
+	// 获取分页参数
+	limitStr := c.DefaultQuery("limit", "20")
+	offsetStr := c.DefaultQuery("offset", "0")
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	req := &ListEventsRequest{
+		ReviewStatus: c.DefaultQuery("status", model.ReviewPending),
+		Limit:        limit,
+		Offset:       offset,
+	}
+	if req.ReviewStatus == "all" {
+		req.ReviewStatus = ""
+	}
+
+	response, err := h.service.ListEvents(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list moderation events",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// ReviewEvent 复核审核事件
+func (h *Handler) ReviewEvent(c *gin.Context) {
+	eventID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid moderation event ID",
+		})
+		return
+	}
+
+	var req ReviewEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// 从JWT中获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	req.EventID = eventID
+	req.ReviewerID = userID.(int64)
+
+	event, err := h.service.ReviewEvent(c.Request.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidDecision):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid review decision",
+				"details": err.Error(),
+			})
+		case errors.Is(err, model.ErrModerationEventNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Moderation event not found",
+				"details": err.Error(),
+			})
+		case errors.Is(err, ErrAlreadyReviewed):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Moderation event already reviewed",
+				"details": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to review moderation event",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    event,
+	})
+}
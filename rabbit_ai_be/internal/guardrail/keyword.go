@@ -0,0 +1,136 @@
+package guardrail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KeywordRule 关键词/正则规则
+type KeywordRule struct {
+	Pattern string  `yaml:"pattern"` // 关键词（不区分大小写）或正则表达式
+	Regex   bool    `yaml:"regex"`   // Pattern 是否为正则表达式
+	Action  Action  `yaml:"action"`  // 命中后的结论：flag/block，默认 block
+	Stages  []Stage `yaml:"stages"`  // 生效阶段：input/output，默认两者
+	Reason  string  `yaml:"reason"`  // 命中原因，默认为规则本身
+}
+
+// KeywordConfig 关键词规则配置（config/guardrail.yaml 的文件格式）
+type KeywordConfig struct {
+	Rules []KeywordRule `yaml:"rules"`
+}
+
+// keywordMatcher 编译后的规则
+type keywordMatcher struct {
+	rule    KeywordRule
+	keyword string         // 小写关键词，正则规则为空
+	regex   *regexp.Regexp // 正则规则
+}
+
+// KeywordGuard 本地关键词/正则过滤护栏
+type KeywordGuard struct {
+	matchers []keywordMatcher
+}
+
+// NewKeywordGuard 校验并编译规则，创建关键词护栏
+func NewKeywordGuard(rules []KeywordRule) (*KeywordGuard, error) {
+	g := &KeywordGuard{}
+	for i, rule := range rules {
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("rule %d: empty pattern", i)
+		}
+		switch rule.Action {
+		case ActionAllow:
+			rule.Action = ActionBlock
+		case ActionFlag, ActionBlock:
+		default:
+			return nil, fmt.Errorf("rule %d: invalid action %q", i, rule.Action)
+		}
+		if len(rule.Stages) == 0 {
+			rule.Stages = []Stage{StageInput, StageOutput}
+		}
+		for _, stage := range rule.Stages {
+			if stage != StageInput && stage != StageOutput {
+				return nil, fmt.Errorf("rule %d: invalid stage %q", i, stage)
+			}
+		}
+		if rule.Reason == "" {
+			rule.Reason = "keyword: " + rule.Pattern
+		}
+
+		matcher := keywordMatcher{rule: rule}
+		if rule.Regex {
+			re, err := regexp.Compile("(?i)" + rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i, err)
+			}
+			matcher.regex = re
+		} else {
+			matcher.keyword = strings.ToLower(rule.Pattern)
+		}
+		g.matchers = append(g.matchers, matcher)
+	}
+	return g, nil
+}
+
+// LoadKeywordRules 从YAML文件加载关键词规则
+func LoadKeywordRules(path string) ([]KeywordRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guardrail rules: %w", err)
+	}
+
+	var config KeywordConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse guardrail rules: %w", err)
+	}
+	return config.Rules, nil
+}
+
+// Name 护栏名称
+func (g *KeywordGuard) Name() string {
+	return "keyword"
+}
+
+// Check 按规则顺序匹配内容：命中屏蔽规则立即返回，否则返回第一条命中的标记规则
+func (g *KeywordGuard) Check(ctx context.Context, input Input) (Verdict, error) {
+	var result Verdict
+	lower := strings.ToLower(input.Content)
+	for _, m := range g.matchers {
+		if !m.appliesTo(input.Stage) {
+			continue
+		}
+		var hit bool
+		if m.regex != nil {
+			hit = m.regex.MatchString(input.Content)
+		} else {
+			hit = strings.Contains(lower, m.keyword)
+		}
+		if !hit {
+			continue
+		}
+
+		verdict := Verdict{Action: m.rule.Action, Guard: g.Name(), Reason: m.rule.Reason}
+		if verdict.Blocked() {
+			return verdict, nil
+		}
+		if result.Action == ActionAllow {
+			result = verdict
+		}
+	}
+	return result, nil
+}
+
+// appliesTo 规则是否在该阶段生效
+func (m keywordMatcher) appliesTo(stage Stage) bool {
+	for _, s := range m.rule.Stages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
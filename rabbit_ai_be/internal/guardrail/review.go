@@ -0,0 +1,158 @@
+package guardrail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"rabbit_ai/internal/cache"
+	"rabbit_ai/internal/model"
+)
+
+// BlockedPlaceholder 屏蔽的消息保存和返回的内容，原始内容只保存在审核事件中
+const BlockedPlaceholder = "该内容未通过内容安全检查，已被屏蔽。"
+
+// ErrCodeContentBlocked 内容被屏蔽时返回给客户端的错误码
+const ErrCodeContentBlocked = "CONTENT_BLOCKED"
+
+// 复核相关错误
+var (
+	ErrInvalidDecision = errors.New("invalid review decision")
+	ErrAlreadyReviewed = errors.New("moderation event already reviewed")
+)
+
+// 复核决定
+const (
+	DecisionApprove = "approve" // 误判放行：屏蔽的消息恢复原始内容
+	DecisionReject  = "reject"  // 确认违规：标记的消息改为屏蔽
+)
+
+// ListEventsRequest 获取审核事件请求
+type ListEventsRequest struct {
+	ReviewStatus string `json:"review_status"` // pending/approved/rejected，为空时返回全部
+	Limit        int    `json:"limit"`
+	Offset       int    `json:"offset"`
+}
+
+// ListEventsResponse 获取审核事件响应
+type ListEventsResponse struct {
+	Events []*model.ModerationEvent `json:"events"`
+	Total  int                      `json:"total"`
+}
+
+// ReviewEventRequest 复核审核事件请求
+type ReviewEventRequest struct {
+	EventID    int64  `json:"event_id"`
+	ReviewerID int64  `json:"reviewer_id"`
+	Decision   string `json:"decision" binding:"required"` // approve/reject
+	Note       string `json:"note"`
+}
+
+// ReviewService 内容安全复核服务
+type ReviewService struct {
+	moderationRepo    model.ModerationRepository
+	messageRepo       model.MessageRepository
+	conversationCache *cache.ConversationCache
+}
+
+// NewReviewService 创建复核服务实例
+func NewReviewService(moderationRepo model.ModerationRepository, messageRepo model.MessageRepository, conversationCache *cache.ConversationCache) *ReviewService {
+	return &ReviewService{
+		moderationRepo:    moderationRepo,
+		messageRepo:       messageRepo,
+		conversationCache: conversationCache,
+	}
+}
+
+// ListEvents 按复核状态分页获取审核事件
+func (s *ReviewService) ListEvents(ctx context.Context, req *ListEventsRequest) (*ListEventsResponse, error) {
+	events, err := s.moderationRepo.List(req.ReviewStatus, req.Limit, req.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list moderation events: %w", err)
+	}
+
+	total, err := s.moderationRepo.Count(req.ReviewStatus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count moderation events: %w", err)
+	}
+
+	if events == nil {
+		events = []*model.ModerationEvent{}
+	}
+	return &ListEventsResponse{
+		Events: events,
+		Total:  total,
+	}, nil
+}
+
+// ReviewEvent 复核审核事件并同步更新对应消息
+//
+// 放行时屏蔽的消息恢复原始内容，标记的消息状态改为 approved；
+// 拒绝时标记的消息内容替换为屏蔽提示，已屏蔽的消息保持不变。
+func (s *ReviewService) ReviewEvent(ctx context.Context, req *ReviewEventRequest) (*model.ModerationEvent, error) {
+	var reviewStatus string
+	switch req.Decision {
+	case DecisionApprove:
+		reviewStatus = model.ReviewApproved
+	case DecisionReject:
+		reviewStatus = model.ReviewRejected
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidDecision, req.Decision)
+	}
+
+	event, err := s.moderationRepo.GetByID(req.EventID)
+	if err != nil {
+		return nil, err
+	}
+	if event.ReviewStatus != model.ReviewPending {
+		return nil, ErrAlreadyReviewed
+	}
+
+	if event.MessageID != 0 {
+		if err := s.applyDecision(ctx, event, reviewStatus); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.moderationRepo.Review(event.ID, reviewStatus, req.ReviewerID, req.Note); err != nil {
+		return nil, fmt.Errorf("failed to review moderation event: %w", err)
+	}
+	return s.moderationRepo.GetByID(event.ID)
+}
+
+// applyDecision 按复核结果更新消息内容与审核状态
+func (s *ReviewService) applyDecision(ctx context.Context, event *model.ModerationEvent, reviewStatus string) error {
+	message, err := s.messageRepo.GetByID(event.MessageID)
+	if err != nil {
+		if errors.Is(err, model.ErrMessageNotFound) {
+			// 消息已随对话删除，只记录复核结果
+			return nil
+		}
+		return fmt.Errorf("failed to get message: %w", err)
+	}
+
+	switch {
+	case reviewStatus == model.ReviewApproved:
+		if message.Moderation == model.ModerationBlocked {
+			message.Content = event.Content
+		}
+		message.Moderation = model.ModerationApproved
+	case message.Moderation == model.ModerationFlagged:
+		message.Content = BlockedPlaceholder
+		message.Moderation = model.ModerationBlocked
+	default:
+		return nil
+	}
+
+	if err := s.messageRepo.Update(message); err != nil {
+		return fmt.Errorf("failed to update message: %w", err)
+	}
+
+	if err := s.conversationCache.SetMessage(ctx, message); err != nil {
+		fmt.Printf("failed to cache message: %v\n", err)
+	}
+	if err := s.conversationCache.InvalidateConversationCache(ctx, message.ConversationID); err != nil {
+		fmt.Printf("failed to invalidate conversation cache: %v\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package guardrail
+
+import (
+	"context"
+	"fmt"
+)
+
+// SensitiveFlagGuard 依据提供方返回的敏感内容标记（如MiniMax的 input_sensitive/output_sensitive）屏蔽回复
+//
+// 标记随模型响应返回，因此只在输出阶段生效；输入被提供方标记时同样屏蔽本轮回复。
+type SensitiveFlagGuard struct{}
+
+// NewSensitiveFlagGuard 创建提供方敏感标记护栏
+func NewSensitiveFlagGuard() *SensitiveFlagGuard {
+	return &SensitiveFlagGuard{}
+}
+
+// Name 护栏名称
+func (g *SensitiveFlagGuard) Name() string {
+	return "provider_sensitive"
+}
+
+// Check 检查提供方敏感标记
+func (g *SensitiveFlagGuard) Check(ctx context.Context, input Input) (Verdict, error) {
+	flags := input.Sensitive
+	if input.Stage != StageOutput || flags == nil {
+		return Verdict{}, nil
+	}
+
+	switch {
+	case flags.Input:
+		return Verdict{
+			Action: ActionBlock,
+			Guard:  g.Name(),
+			Reason: fmt.Sprintf("input_sensitive_type=%d", flags.InputType),
+		}, nil
+	case flags.Output:
+		return Verdict{
+			Action: ActionBlock,
+			Guard:  g.Name(),
+			Reason: fmt.Sprintf("output_sensitive_type=%d", flags.OutputType),
+		}, nil
+	}
+	return Verdict{}, nil
+}
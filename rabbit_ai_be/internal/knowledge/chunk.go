@@ -0,0 +1,211 @@
+package knowledge
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 文档格式
+const (
+	FormatText     = "text"     // 纯文本
+	FormatMarkdown = "markdown" // Markdown，片段不跨越标题并带上所属标题
+	FormatPDFText  = "pdf_text" // 从PDF提取的文本，合并被硬换行拆开的段落
+)
+
+// 默认切分参数（字符数）
+const (
+	DefaultChunkSize    = 800
+	DefaultChunkOverlap = 100
+)
+
+var (
+	markdownHeading = regexp.MustCompile(`^#{1,6}\s+\S`)
+	hyphenBreak     = regexp.MustCompile(`(\p{L})-\n(\p{Ll})`)
+	blankLines      = regexp.MustCompile(`\n\s*\n`)
+)
+
+// ValidFormat 判断文档格式是否支持
+func ValidFormat(format string) bool {
+	return format == FormatText || format == FormatMarkdown || format == FormatPDFText
+}
+
+// section 文档中的一节，Markdown 按标题划分，其他格式整篇为一节
+type section struct {
+	heading string
+	body    string
+}
+
+// Split 按格式将文档切分为片段
+//
+// 先按段落（空行）切分，再将相邻段落合并为不超过 size 个字符的片段；超长段落按句子、
+// 必要时按字符切开。相邻片段重叠约 overlap 个字符，避免答案恰好落在切分处。
+func Split(content, format string, size, overlap int) []string {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	var sections []section
+	switch format {
+	case FormatMarkdown:
+		sections = markdownSections(content)
+	case FormatPDFText:
+		sections = []section{{body: normalizePDFText(content)}}
+	default:
+		sections = []section{{body: content}}
+	}
+
+	var chunks []string
+	for _, sec := range sections {
+		prefix := ""
+		if sec.heading != "" {
+			prefix = sec.heading + "\n"
+		}
+		for _, chunk := range pack(paragraphs(sec.body), size-utf8.RuneCountInString(prefix), overlap) {
+			chunks = append(chunks, prefix+chunk)
+		}
+	}
+	return chunks
+}
+
+// markdownSections 按标题切分 Markdown，代码块中的 # 不视为标题
+func markdownSections(content string) []section {
+	var sections []section
+	current := section{}
+	var body strings.Builder
+	inCode := false
+	flush := func() {
+		current.body = body.String()
+		if strings.TrimSpace(current.body) != "" {
+			sections = append(sections, current)
+		}
+		body.Reset()
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+		}
+		if !inCode && markdownHeading.MatchString(line) {
+			flush()
+			current = section{heading: strings.TrimSpace(line)}
+			continue
+		}
+		body.WriteString(line)
+		body.WriteString("\n")
+	}
+	flush()
+	return sections
+}
+
+// normalizePDFText 整理PDF提取的文本：分页符视为段落分隔，合并行尾连字符和段落内的硬换行
+func normalizePDFText(content string) string {
+	content = strings.ReplaceAll(content, "\f", "\n\n")
+	content = hyphenBreak.ReplaceAllString(content, "$1$2")
+
+	var b strings.Builder
+	for i, para := range blankLines.Split(content, -1) {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		lines := strings.Split(para, "\n")
+		for j, line := range lines {
+			line = strings.TrimSpace(line)
+			if j > 0 && line != "" && b.Len() > 0 {
+				// 中文行之间直接连接，其他文字以空格连接
+				last, _ := utf8.DecodeLastRuneInString(b.String())
+				first, _ := utf8.DecodeRuneInString(line)
+				if !unicode.Is(unicode.Han, last) || !unicode.Is(unicode.Han, first) {
+					b.WriteString(" ")
+				}
+			}
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
+
+// paragraphs 按空行切分段落并去除首尾空白
+func paragraphs(text string) []string {
+	var result []string
+	for _, para := range blankLines.Split(text, -1) {
+		if para = strings.TrimSpace(para); para != "" {
+			result = append(result, para)
+		}
+	}
+	return result
+}
+
+// sentences 按句末标点和换行切分，标点保留在句子末尾
+func sentences(text string) []string {
+	var result []string
+	start := 0
+	runes := []rune(text)
+	for i, r := range runes {
+		if strings.ContainsRune("。！？!?；;\n", r) || (r == '.' && (i+1 == len(runes) || unicode.IsSpace(runes[i+1]))) {
+			if s := strings.TrimSpace(string(runes[start : i+1])); s != "" {
+				result = append(result, s)
+			}
+			start = i + 1
+		}
+	}
+	if s := strings.TrimSpace(string(runes[start:])); s != "" {
+		result = append(result, s)
+	}
+	return result
+}
+
+// pack 将段落合并为片段
+func pack(paras []string, size, overlap int) []string {
+	if size < 1 {
+		size = 1
+	}
+
+	// 超长段落先按句子切开，超长句子按字符切开
+	var pieces []string
+	for _, para := range paras {
+		if utf8.RuneCountInString(para) <= size {
+			pieces = append(pieces, para)
+			continue
+		}
+		for _, sentence := range sentences(para) {
+			runes := []rune(sentence)
+			for len(runes) > size {
+				pieces = append(pieces, string(runes[:size]))
+				runes = runes[size-overlap:]
+			}
+			pieces = append(pieces, string(runes))
+		}
+	}
+
+	var chunks []string
+	var current []rune
+	for _, piece := range pieces {
+		runes := []rune(piece)
+		if len(current) > 0 && len(current)+1+len(runes) > size {
+			chunks = append(chunks, string(current))
+			// 新片段以上一片段的结尾开头
+			tail := current
+			if len(tail) > overlap {
+				tail = tail[len(tail)-overlap:]
+			}
+			current = nil
+			if overlap > 0 && len(tail)+1+len(runes) <= size {
+				current = append(current, tail...)
+			}
+		}
+		if len(current) > 0 {
+			current = append(current, '\n')
+		}
+		current = append(current, runes...)
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, string(current))
+	}
+	return chunks
+}
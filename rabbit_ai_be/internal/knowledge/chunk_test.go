@@ -0,0 +1,78 @@
+package knowledge
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplit_Text(t *testing.T) {
+	content := "第一段。\n\n第二段。\n\n\n第三段。"
+	chunks := Split(content, FormatText, 100, 0)
+	if len(chunks) != 1 || chunks[0] != "第一段。\n第二段。\n第三段。" {
+		t.Errorf("Expected short paragraphs merged into one chunk, got %q", chunks)
+	}
+
+	long := strings.Repeat("这是一个很长的句子。", 30)
+	chunks = Split(long, FormatText, 50, 10)
+	if len(chunks) < 6 {
+		t.Fatalf("Expected long paragraph split, got %d chunks", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if utf8.RuneCountInString(chunk) > 50 {
+			t.Errorf("Chunk exceeds size: %q", chunk)
+		}
+	}
+	if !strings.HasPrefix(chunks[1], "这是一个很长的句子。") {
+		t.Errorf("Expected chunks to start at sentence boundaries, got %q", chunks[1])
+	}
+}
+
+func TestSplit_Overlap(t *testing.T) {
+	paras := []string{strings.Repeat("甲", 30), strings.Repeat("乙", 30), strings.Repeat("丙", 30)}
+	chunks := Split(strings.Join(paras, "\n\n"), FormatText, 50, 10)
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %q", chunks)
+	}
+	if !strings.HasPrefix(chunks[1], strings.Repeat("甲", 10)+"\n"+strings.Repeat("乙", 30)) {
+		t.Errorf("Expected chunk to start with the tail of the previous one, got %q", chunks[1])
+	}
+}
+
+func TestSplit_Markdown(t *testing.T) {
+	content := "# 安装\n\n运行 go build。\n\n```sh\n# 不是标题\n```\n\n## 配置\n\n设置环境变量。"
+	chunks := Split(content, FormatMarkdown, 200, 0)
+	if len(chunks) != 2 {
+		t.Fatalf("Expected one chunk per heading, got %q", chunks)
+	}
+	if !strings.HasPrefix(chunks[0], "# 安装\n") || !strings.Contains(chunks[0], "# 不是标题") {
+		t.Errorf("Expected heading prefix with code block kept, got %q", chunks[0])
+	}
+	if chunks[1] != "## 配置\n设置环境变量。" {
+		t.Errorf("Unexpected second chunk: %q", chunks[1])
+	}
+}
+
+func TestSplit_PDFText(t *testing.T) {
+	content := "The quick brown fox jumps over the lazy\ndog. A well-known exam-\nple sentence.\f中文段落被\n硬换行拆开。"
+	chunks := Split(content, FormatPDFText, 500, 0)
+	expected := "The quick brown fox jumps over the lazy dog. A well-known example sentence.\n中文段落被硬换行拆开。"
+	if len(chunks) != 1 || chunks[0] != expected {
+		t.Errorf("Expected normalized PDF text %q, got %q", expected, chunks)
+	}
+}
+
+func TestFormatFromName(t *testing.T) {
+	tests := map[string]string{
+		"README.md":      FormatMarkdown,
+		"guide.Markdown": FormatMarkdown,
+		"paper.pdf.txt":  FormatPDFText,
+		"notes.txt":      FormatText,
+		"notes":          FormatText,
+	}
+	for name, expected := range tests {
+		if format := FormatFromName(name); format != expected {
+			t.Errorf("FormatFromName(%q): expected %s, got %s", name, expected, format)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package knowledge
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"rabbit_ai/internal/model"
+)
+
+// Handler 知识库处理器
+type Handler struct {
+	service *Service
+}
+
+// NewHandler 创建知识库处理器实例
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// RegisterRoutes 注册路由
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	group := r.Group("/knowledge-bases")
+	{
+		group.POST("", h.CreateBase)
+		group.GET("", h.ListBases)
+		group.GET("/:id", h.GetBase)
+		group.PUT("/:id", h.UpdateBase)
+		group.DELETE("/:id", h.DeleteBase)
+		group.POST("/:id/documents", h.AddDocument)
+		group.GET("/:id/documents/:docId", h.GetDocument)
+		group.DELETE("/:id/documents/:docId", h.DeleteDocument)
+	}
+}
+
+// CreateBase 创建知识库
+func (h *Handler) CreateBase(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req CreateBaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+	req.UserID = userID
+
+	base, err := h.service.CreateBase(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err, "Failed to create knowledge base")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    base,
+	})
+}
+
+// ListBases 获取知识库列表
+func (h *Handler) ListBases(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	bases, err := h.service.ListBases(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err, "Failed to list knowledge bases")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"knowledge_bases": bases,
+		},
+	})
+}
+
+// GetBase 获取知识库详情及文档列表
+func (h *Handler) GetBase(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	baseID, ok := pathID(c, "id", "knowledge base ID")
+	if !ok {
+		return
+	}
+
+	response, err := h.service.GetBase(c.Request.Context(), userID, baseID)
+	if err != nil {
+		respondError(c, err, "Failed to get knowledge base")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// UpdateBase 更新知识库名称和描述
+func (h *Handler) UpdateBase(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	baseID, ok := pathID(c, "id", "knowledge base ID")
+	if !ok {
+		return
+	}
+
+	var req UpdateBaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+	req.UserID = userID
+	req.KnowledgeBaseID = baseID
+
+	base, err := h.service.UpdateBase(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err, "Failed to update knowledge base")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    base,
+	})
+}
+
+// DeleteBase 删除知识库
+func (h *Handler) DeleteBase(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	baseID, ok := pathID(c, "id", "knowledge base ID")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteBase(c.Request.Context(), userID, baseID); err != nil {
+		respondError(c, err, "Failed to delete knowledge base")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Knowledge base deleted successfully",
+	})
+}
+
+// AddDocument 上传文档，支持JSON（name/format/content）或 multipart 表单（file 文件字段，可选 format）
+func (h *Handler) AddDocument(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	baseID, ok := pathID(c, "id", "knowledge base ID")
+	if !ok {
+		return
+	}
+
+	var req AddDocumentRequest
+	if c.ContentType() == "multipart/form-data" {
+		if err := h.readUpload(c, &req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid document upload",
+				"details": err.Error(),
+			})
+			return
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+	req.UserID = userID
+	req.KnowledgeBaseID = baseID
+
+	document, err := h.service.AddDocument(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err, "Failed to add document")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"data":    document,
+	})
+}
+
+// readUpload 读取 multipart 上传的文档，超过大小限制时返回错误
+func (h *Handler) readUpload(c *gin.Context, req *AddDocumentRequest) error {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return err
+	}
+	if fileHeader.Size > int64(h.service.config.MaxDocumentBytes) {
+		return fmt.Errorf("document exceeds %d bytes", h.service.config.MaxDocumentBytes)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, int64(h.service.config.MaxDocumentBytes)+1))
+	if err != nil {
+		return err
+	}
+
+	req.Name = c.DefaultPostForm("name", fileHeader.Filename)
+	req.Format = c.PostForm("format")
+	req.Content = string(data)
+	return nil
+}
+
+// GetDocument 获取文档处理状态
+func (h *Handler) GetDocument(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	baseID, ok := pathID(c, "id", "knowledge base ID")
+	if !ok {
+		return
+	}
+	documentID, ok := pathID(c, "docId", "document ID")
+	if !ok {
+		return
+	}
+
+	document, err := h.service.GetDocument(c.Request.Context(), userID, baseID, documentID)
+	if err != nil {
+		respondError(c, err, "Failed to get document")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    document,
+	})
+}
+
+// DeleteDocument 删除文档
+func (h *Handler) DeleteDocument(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	baseID, ok := pathID(c, "id", "knowledge base ID")
+	if !ok {
+		return
+	}
+	documentID, ok := pathID(c, "docId", "document ID")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteDocument(c.Request.Context(), userID, baseID, documentID); err != nil {
+		respondError(c, err, "Failed to delete document")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Document deleted successfully",
+	})
+}
+
+// currentUser 从JWT中获取用户ID，未认证时响应401
+func currentUser(c *gin.Context) (int64, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return 0, false
+	}
+	return userID.(int64), true
+}
+
+// pathID 解析路径中的ID参数，无效时响应400
+func pathID(c *gin.Context, name, label string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid " + label,
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// respondError 按错误类型响应
+func respondError(c *gin.Context, err error, message string) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		status = http.StatusBadRequest
+	case errors.Is(err, model.ErrKnowledgeBaseNotFound), errors.Is(err, model.ErrKnowledgeDocumentNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, model.ErrKnowledgeBaseExists):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,99 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+)
+
+// 后台处理参数
+const (
+	DefaultIngestInterval = 10 * time.Second
+	DefaultStaleAfter     = 10 * time.Minute
+	embedBatchSize        = 16 // 每次向量化请求的片段数
+)
+
+// RunIngestion 循环处理待处理的文档，直到ctx结束
+//
+// 上传文档时立即唤醒；多实例部署时各实例通过数据库行锁领取不同文档。
+func (s *Service) RunIngestion(ctx context.Context) {
+	for {
+		processed, err := s.IngestNext(ctx)
+		if err != nil {
+			log.Printf("failed to ingest document: %v", err)
+		}
+		if processed {
+			continue
+		}
+
+		timer := time.NewTimer(s.config.IngestInterval)
+		select {
+		case <-timer.C:
+		case <-s.wake:
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// IngestNext 领取并处理一个文档：切分、向量化并保存片段，更新文档状态。
+// 没有待处理文档时返回 false
+func (s *Service) IngestNext(ctx context.Context) (bool, error) {
+	document, err := s.repo.ClaimDocument(s.config.StaleAfter)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim document: %w", err)
+	}
+	if document == nil {
+		return false, nil
+	}
+
+	chunks, err := s.buildChunks(ctx, document)
+	if err == nil {
+		err = s.repo.ReplaceChunks(document.ID, chunks)
+	}
+	if err != nil {
+		if updateErr := s.repo.UpdateDocumentStatus(document.ID, model.DocumentFailed, model.TruncateError(err.Error()), 0); updateErr != nil {
+			log.Printf("failed to update status of document %d: %v", document.ID, updateErr)
+		}
+		return true, fmt.Errorf("document %d: %w", document.ID, err)
+	}
+
+	if err := s.repo.UpdateDocumentStatus(document.ID, model.DocumentReady, "", len(chunks)); err != nil {
+		return true, fmt.Errorf("failed to update status of document %d: %w", document.ID, err)
+	}
+	return true, nil
+}
+
+// buildChunks 切分文档并分批向量化
+func (s *Service) buildChunks(ctx context.Context, document *model.KnowledgeDocument) ([]*model.KnowledgeChunk, error) {
+	texts := Split(document.Content, document.Format, s.config.ChunkSize, s.config.ChunkOverlap)
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("document has no text")
+	}
+
+	chunks := make([]*model.KnowledgeChunk, 0, len(texts))
+	for start := 0; start < len(texts); start += embedBatchSize {
+		end := min(start+embedBatchSize, len(texts))
+		vectors, err := s.embedder.Embed(ctx, texts[start:end], llm.EmbeddingDocument)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed chunks: %w", err)
+		}
+		for i, vector := range vectors {
+			chunks = append(chunks, &model.KnowledgeChunk{
+				DocumentID:      document.ID,
+				KnowledgeBaseID: document.KnowledgeBaseID,
+				Index:           start + i,
+				Content:         texts[start+i],
+				Model:           s.embedder.Model(),
+				Vector:          vector,
+			})
+		}
+	}
+	return chunks, nil
+}
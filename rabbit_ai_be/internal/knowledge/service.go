@@ -0,0 +1,366 @@
+package knowledge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+)
+
+// 知识库限制
+const (
+	DefaultMaxDocumentBytes = 1 << 20 // 单个文档最大字节数
+	maxNameLength           = 100     // 知识库名称最大字符数
+	maxDescriptionLength    = 1000    // 知识库描述最大字符数
+	maxDocumentNameLength   = 255     // 文档名称最大字符数
+	MaxAttachedBases        = 5       // 一个对话最多关联的知识库数量
+)
+
+// ErrInvalidRequest 知识库请求参数无效
+var ErrInvalidRequest = errors.New("invalid knowledge base request")
+
+// Config 知识库配置
+type Config struct {
+	ChunkSize        int           // 片段最大字符数
+	ChunkOverlap     int           // 相邻片段重叠字符数
+	MaxDocumentBytes int           // 单个文档最大字节数
+	IngestInterval   time.Duration // 没有待处理文档时后台任务的轮询间隔
+	StaleAfter       time.Duration // 处理中超过该时间的文档视为中断，重新处理
+}
+
+// CreateBaseRequest 创建知识库请求
+type CreateBaseRequest struct {
+	UserID      int64  `json:"user_id"`
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// UpdateBaseRequest 更新知识库请求
+type UpdateBaseRequest struct {
+	UserID          int64  `json:"user_id"`
+	KnowledgeBaseID int64  `json:"knowledge_base_id"`
+	Name            string `json:"name" binding:"required"`
+	Description     string `json:"description"`
+}
+
+// GetBaseResponse 知识库详情
+type GetBaseResponse struct {
+	KnowledgeBase *model.KnowledgeBase       `json:"knowledge_base"`
+	Documents     []*model.KnowledgeDocument `json:"documents"`
+}
+
+// AddDocumentRequest 上传文档请求
+type AddDocumentRequest struct {
+	UserID          int64  `json:"user_id"`
+	KnowledgeBaseID int64  `json:"knowledge_base_id"`
+	Name            string `json:"name" binding:"required"`
+	Format          string `json:"format"` // text/markdown/pdf_text，为空时按文件扩展名判断
+	Content         string `json:"content" binding:"required"`
+}
+
+// Service 知识库服务：管理知识库与文档、后台切分向量化文档，并为对话检索相关片段
+type Service struct {
+	repo     model.KnowledgeRepository
+	embedder llm.Embedder
+	config   Config
+	wake     chan struct{} // 有新文档时唤醒后台任务
+}
+
+// NewService 创建知识库服务实例
+func NewService(repo model.KnowledgeRepository, embedder llm.Embedder, config Config) *Service {
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = DefaultChunkSize
+	}
+	if config.ChunkOverlap < 0 {
+		config.ChunkOverlap = 0
+	}
+	if config.MaxDocumentBytes <= 0 {
+		config.MaxDocumentBytes = DefaultMaxDocumentBytes
+	}
+	if config.IngestInterval <= 0 {
+		config.IngestInterval = DefaultIngestInterval
+	}
+	if config.StaleAfter <= 0 {
+		config.StaleAfter = DefaultStaleAfter
+	}
+	return &Service{
+		repo:     repo,
+		embedder: embedder,
+		config:   config,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// CreateBase 创建知识库，同一用户的知识库名称不能重复
+func (s *Service) CreateBase(ctx context.Context, req *CreateBaseRequest) (*model.KnowledgeBase, error) {
+	name, description, err := validateBase(req.Name, req.Description)
+	if err != nil {
+		return nil, err
+	}
+
+	base := &model.KnowledgeBase{
+		UserID:      req.UserID,
+		Name:        name,
+		Description: description,
+	}
+	if err := s.repo.CreateBase(base); err != nil {
+		return nil, fmt.Errorf("failed to create knowledge base: %w", err)
+	}
+	return base, nil
+}
+
+// ListBases 获取用户的知识库列表
+func (s *Service) ListBases(ctx context.Context, userID int64) ([]*model.KnowledgeBase, error) {
+	bases, err := s.repo.ListBases(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list knowledge bases: %w", err)
+	}
+	if bases == nil {
+		bases = []*model.KnowledgeBase{}
+	}
+	return bases, nil
+}
+
+// GetBase 获取知识库及其文档
+func (s *Service) GetBase(ctx context.Context, userID, knowledgeBaseID int64) (*GetBaseResponse, error) {
+	base, err := s.getOwnedBase(userID, knowledgeBaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	documents, err := s.repo.ListDocuments(knowledgeBaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	if documents == nil {
+		documents = []*model.KnowledgeDocument{}
+	}
+
+	return &GetBaseResponse{
+		KnowledgeBase: base,
+		Documents:     documents,
+	}, nil
+}
+
+// UpdateBase 更新知识库名称和描述
+func (s *Service) UpdateBase(ctx context.Context, req *UpdateBaseRequest) (*model.KnowledgeBase, error) {
+	name, description, err := validateBase(req.Name, req.Description)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := s.getOwnedBase(req.UserID, req.KnowledgeBaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	base.Name = name
+	base.Description = description
+	if err := s.repo.UpdateBase(base); err != nil {
+		return nil, fmt.Errorf("failed to update knowledge base: %w", err)
+	}
+	return base, nil
+}
+
+// DeleteBase 删除知识库及其全部文档；关联该知识库的对话之后检索时忽略它
+func (s *Service) DeleteBase(ctx context.Context, userID, knowledgeBaseID int64) error {
+	if _, err := s.getOwnedBase(userID, knowledgeBaseID); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteBase(knowledgeBaseID); err != nil {
+		return fmt.Errorf("failed to delete knowledge base: %w", err)
+	}
+	return nil
+}
+
+// AddDocument 上传文档，文档保存后由后台任务切分和向量化，可通过文档状态查询进度
+func (s *Service) AddDocument(ctx context.Context, req *AddDocumentRequest) (*model.KnowledgeDocument, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxDocumentNameLength {
+		return nil, fmt.Errorf("%w: document name must be 1-%d characters", ErrInvalidRequest, maxDocumentNameLength)
+	}
+	format := req.Format
+	if format == "" {
+		format = FormatFromName(name)
+	}
+	if !ValidFormat(format) {
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidRequest, format)
+	}
+	if len(req.Content) > s.config.MaxDocumentBytes {
+		return nil, fmt.Errorf("%w: document exceeds %d bytes", ErrInvalidRequest, s.config.MaxDocumentBytes)
+	}
+	if strings.HasPrefix(req.Content, "%PDF-") {
+		return nil, fmt.Errorf("%w: binary PDF is not supported, upload the extracted text with format pdf_text", ErrInvalidRequest)
+	}
+	if !utf8.ValidString(req.Content) {
+		return nil, fmt.Errorf("%w: document must be UTF-8 text", ErrInvalidRequest)
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		return nil, fmt.Errorf("%w: document is empty", ErrInvalidRequest)
+	}
+
+	if _, err := s.getOwnedBase(req.UserID, req.KnowledgeBaseID); err != nil {
+		return nil, err
+	}
+
+	document := &model.KnowledgeDocument{
+		KnowledgeBaseID: req.KnowledgeBaseID,
+		UserID:          req.UserID,
+		Name:            name,
+		Format:          format,
+		Content:         req.Content,
+		Size:            len(req.Content),
+		Status:          model.DocumentPending,
+	}
+	if err := s.repo.CreateDocument(document); err != nil {
+		return nil, fmt.Errorf("failed to create document: %w", err)
+	}
+
+	// 唤醒后台任务，已有待处理的唤醒信号时无需重复发送
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return document, nil
+}
+
+// GetDocument 获取文档及其处理状态
+func (s *Service) GetDocument(ctx context.Context, userID, knowledgeBaseID, documentID int64) (*model.KnowledgeDocument, error) {
+	return s.getOwnedDocument(userID, knowledgeBaseID, documentID)
+}
+
+// DeleteDocument 删除文档及其片段
+func (s *Service) DeleteDocument(ctx context.Context, userID, knowledgeBaseID, documentID int64) error {
+	if _, err := s.getOwnedDocument(userID, knowledgeBaseID, documentID); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteDocument(documentID); err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+	return nil
+}
+
+// CheckAccess 校验知识库均存在且属于用户
+func (s *Service) CheckAccess(userID int64, knowledgeBaseIDs []int64) error {
+	if len(knowledgeBaseIDs) > MaxAttachedBases {
+		return fmt.Errorf("%w: at most %d knowledge bases", ErrInvalidRequest, MaxAttachedBases)
+	}
+	for _, id := range knowledgeBaseIDs {
+		if _, err := s.getOwnedBase(userID, id); err != nil {
+			return fmt.Errorf("%w: %d", err, id)
+		}
+	}
+	return nil
+}
+
+// Retrieve 从用户的知识库中检索与查询最相关的 topK 个片段，相似度低于 minScore 的片段不返回
+//
+// 已删除或不属于用户的知识库被忽略。返回的引用按相似度从高到低排列，编号从1开始。
+func (s *Service) Retrieve(ctx context.Context, userID int64, knowledgeBaseIDs []int64, query string, topK int, minScore float64) ([]model.Citation, error) {
+	if len(knowledgeBaseIDs) == 0 || strings.TrimSpace(query) == "" || topK <= 0 {
+		return nil, nil
+	}
+
+	chunks, err := s.repo.ListChunks(userID, knowledgeBaseIDs, s.embedder.Model())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunks: %w", err)
+	}
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+
+	vectors, err := s.embedder.Embed(ctx, []string{query}, llm.EmbeddingQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	type scored struct {
+		chunk *model.KnowledgeChunk
+		score float64
+	}
+	candidates := make([]scored, 0, len(chunks))
+	for _, chunk := range chunks {
+		if score := llm.CosineSimilarity(vectors[0], chunk.Vector); score >= minScore {
+			candidates = append(candidates, scored{chunk: chunk, score: score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	if len(candidates) > topK {
+		candidates = candidates[:topK]
+	}
+
+	citations := make([]model.Citation, len(candidates))
+	for i, candidate := range candidates {
+		citations[i] = model.Citation{
+			Index:           i + 1,
+			KnowledgeBaseID: candidate.chunk.KnowledgeBaseID,
+			DocumentID:      candidate.chunk.DocumentID,
+			DocumentName:    candidate.chunk.DocumentName,
+			ChunkID:         candidate.chunk.ID,
+			Content:         candidate.chunk.Content,
+			Score:           candidate.score,
+		}
+	}
+	return citations, nil
+}
+
+// getOwnedBase 获取属于用户的知识库，不属于用户时同样返回未找到
+func (s *Service) getOwnedBase(userID, knowledgeBaseID int64) (*model.KnowledgeBase, error) {
+	base, err := s.repo.GetBase(knowledgeBaseID)
+	if err != nil {
+		return nil, err
+	}
+	if base.UserID != userID {
+		return nil, model.ErrKnowledgeBaseNotFound
+	}
+	return base, nil
+}
+
+// getOwnedDocument 获取属于用户指定知识库的文档
+func (s *Service) getOwnedDocument(userID, knowledgeBaseID, documentID int64) (*model.KnowledgeDocument, error) {
+	if _, err := s.getOwnedBase(userID, knowledgeBaseID); err != nil {
+		return nil, err
+	}
+	document, err := s.repo.GetDocument(documentID)
+	if err != nil {
+		return nil, err
+	}
+	if document.KnowledgeBaseID != knowledgeBaseID {
+		return nil, model.ErrKnowledgeDocumentNotFound
+	}
+	return document, nil
+}
+
+// validateBase 校验并规范化知识库名称和描述
+func validateBase(name, description string) (string, string, error) {
+	name = strings.TrimSpace(name)
+	description = strings.TrimSpace(description)
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return "", "", fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidRequest, maxNameLength)
+	}
+	if utf8.RuneCountInString(description) > maxDescriptionLength {
+		return "", "", fmt.Errorf("%w: description exceeds %d characters", ErrInvalidRequest, maxDescriptionLength)
+	}
+	return name, description, nil
+}
+
+// FormatFromName 根据文件扩展名判断文档格式，无法判断时为纯文本
+func FormatFromName(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".md"), strings.HasSuffix(lower, ".markdown"):
+		return FormatMarkdown
+	case strings.HasSuffix(lower, ".pdf"), strings.HasSuffix(lower, ".pdf.txt"):
+		return FormatPDFText
+	default:
+		return FormatText
+	}
+}
@@ -0,0 +1,282 @@
+package knowledge
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+)
+
+// hashEmbedder 使用模拟服务的确定性向量，字面重叠越多的文本越相似
+type hashEmbedder struct {
+	err   error
+	calls int
+}
+
+func (e *hashEmbedder) Model() string { return "embo-01" }
+
+func (e *hashEmbedder) Embed(ctx context.Context, texts []string, purpose llm.EmbeddingPurpose) ([][]float32, error) {
+	e.calls++
+	if e.err != nil {
+		return nil, e.err
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = fakellm.Embed(text)
+	}
+	return vectors, nil
+}
+
+// MockKnowledgeRepository 模拟知识库仓库
+type MockKnowledgeRepository struct {
+	bases     map[int64]*model.KnowledgeBase
+	documents map[int64]*model.KnowledgeDocument
+	chunks    map[int64][]*model.KnowledgeChunk
+	nextID    int64
+}
+
+func NewMockKnowledgeRepository() *MockKnowledgeRepository {
+	return &MockKnowledgeRepository{
+		bases:     map[int64]*model.KnowledgeBase{},
+		documents: map[int64]*model.KnowledgeDocument{},
+		chunks:    map[int64][]*model.KnowledgeChunk{},
+	}
+}
+
+func (m *MockKnowledgeRepository) CreateBase(base *model.KnowledgeBase) error {
+	for _, existing := range m.bases {
+		if existing.UserID == base.UserID && existing.Name == base.Name {
+			return model.ErrKnowledgeBaseExists
+		}
+	}
+	m.nextID++
+	base.ID = m.nextID
+	m.bases[base.ID] = base
+	return nil
+}
+
+func (m *MockKnowledgeRepository) GetBase(id int64) (*model.KnowledgeBase, error) {
+	if base, ok := m.bases[id]; ok {
+		return base, nil
+	}
+	return nil, model.ErrKnowledgeBaseNotFound
+}
+
+func (m *MockKnowledgeRepository) ListBases(userID int64) ([]*model.KnowledgeBase, error) {
+	var bases []*model.KnowledgeBase
+	for _, base := range m.bases {
+		if base.UserID == userID {
+			bases = append(bases, base)
+		}
+	}
+	return bases, nil
+}
+
+func (m *MockKnowledgeRepository) UpdateBase(base *model.KnowledgeBase) error {
+	m.bases[base.ID] = base
+	return nil
+}
+
+func (m *MockKnowledgeRepository) DeleteBase(id int64) error {
+	delete(m.bases, id)
+	return nil
+}
+
+func (m *MockKnowledgeRepository) CreateDocument(document *model.KnowledgeDocument) error {
+	m.nextID++
+	document.ID = m.nextID
+	m.documents[document.ID] = document
+	return nil
+}
+
+func (m *MockKnowledgeRepository) GetDocument(id int64) (*model.KnowledgeDocument, error) {
+	if document, ok := m.documents[id]; ok {
+		return document, nil
+	}
+	return nil, model.ErrKnowledgeDocumentNotFound
+}
+
+func (m *MockKnowledgeRepository) ListDocuments(knowledgeBaseID int64) ([]*model.KnowledgeDocument, error) {
+	var documents []*model.KnowledgeDocument
+	for _, document := range m.documents {
+		if document.KnowledgeBaseID == knowledgeBaseID {
+			documents = append(documents, document)
+		}
+	}
+	return documents, nil
+}
+
+func (m *MockKnowledgeRepository) DeleteDocument(id int64) error {
+	delete(m.documents, id)
+	delete(m.chunks, id)
+	return nil
+}
+
+func (m *MockKnowledgeRepository) ClaimDocument(staleAfter time.Duration) (*model.KnowledgeDocument, error) {
+	for id := int64(1); id <= m.nextID; id++ {
+		if document, ok := m.documents[id]; ok && document.Status == model.DocumentPending {
+			document.Status = model.DocumentProcessing
+			return document, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockKnowledgeRepository) UpdateDocumentStatus(id int64, status, errMsg string, chunkCount int) error {
+	document := m.documents[id]
+	document.Status = status
+	document.Error = errMsg
+	document.ChunkCount = chunkCount
+	return nil
+}
+
+func (m *MockKnowledgeRepository) ReplaceChunks(documentID int64, chunks []*model.KnowledgeChunk) error {
+	for _, chunk := range chunks {
+		m.nextID++
+		chunk.ID = m.nextID
+		chunk.DocumentName = m.documents[documentID].Name
+	}
+	m.chunks[documentID] = chunks
+	return nil
+}
+
+func (m *MockKnowledgeRepository) ListChunks(userID int64, knowledgeBaseIDs []int64, modelName string) ([]*model.KnowledgeChunk, error) {
+	var chunks []*model.KnowledgeChunk
+	for documentID, documentChunks := range m.chunks {
+		document := m.documents[documentID]
+		base := m.bases[document.KnowledgeBaseID]
+		if document.Status != model.DocumentReady || base == nil || base.UserID != userID {
+			continue
+		}
+		for _, id := range knowledgeBaseIDs {
+			if id == base.ID {
+				chunks = append(chunks, documentChunks...)
+			}
+		}
+	}
+	return chunks, nil
+}
+
+// newTestService 创建使用模拟仓库和确定性向量的知识库服务，并为用户1创建一个知识库
+func newTestService(t *testing.T) (*Service, *MockKnowledgeRepository, *hashEmbedder, *model.KnowledgeBase) {
+	repo := NewMockKnowledgeRepository()
+	embedder := &hashEmbedder{}
+	service := NewService(repo, embedder, Config{ChunkSize: 60})
+	base, err := service.CreateBase(context.Background(), &CreateBaseRequest{UserID: 1, Name: " 菜谱 "})
+	if err != nil {
+		t.Fatalf("Failed to create knowledge base: %v", err)
+	}
+	return service, repo, embedder, base
+}
+
+func TestService_CreateBase(t *testing.T) {
+	service, _, _, base := newTestService(t)
+	if base.Name != "菜谱" {
+		t.Errorf("Expected trimmed name, got %q", base.Name)
+	}
+
+	_, err := service.CreateBase(context.Background(), &CreateBaseRequest{UserID: 1, Name: "菜谱"})
+	if !errors.Is(err, model.ErrKnowledgeBaseExists) {
+		t.Errorf("Expected ErrKnowledgeBaseExists, got %v", err)
+	}
+	if _, err := service.CreateBase(context.Background(), &CreateBaseRequest{UserID: 1, Name: "  "}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("Expected ErrInvalidRequest for empty name, got %v", err)
+	}
+	if _, err := service.GetBase(context.Background(), 2, base.ID); !errors.Is(err, model.ErrKnowledgeBaseNotFound) {
+		t.Errorf("Expected other users to get not found, got %v", err)
+	}
+}
+
+func TestService_AddDocumentValidation(t *testing.T) {
+	service, _, _, base := newTestService(t)
+	service.config.MaxDocumentBytes = 100
+	ctx := context.Background()
+
+	invalid := map[string]*AddDocumentRequest{
+		"empty":          {Name: "a.txt", Content: "  \n"},
+		"too large":      {Name: "a.txt", Content: strings.Repeat("a", 101)},
+		"binary pdf":     {Name: "a.pdf", Content: "%PDF-1.7 ..."},
+		"unknown format": {Name: "a.txt", Format: "docx", Content: "内容"},
+		"invalid utf8":   {Name: "a.txt", Content: "\xff\xfe"},
+	}
+	for name, req := range invalid {
+		req.UserID = 1
+		req.KnowledgeBaseID = base.ID
+		if _, err := service.AddDocument(ctx, req); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("%s: expected ErrInvalidRequest, got %v", name, err)
+		}
+	}
+
+	_, err := service.AddDocument(ctx, &AddDocumentRequest{UserID: 2, KnowledgeBaseID: base.ID, Name: "a.txt", Content: "内容"})
+	if !errors.Is(err, model.ErrKnowledgeBaseNotFound) {
+		t.Errorf("Expected not found for another user's knowledge base, got %v", err)
+	}
+}
+
+func TestService_IngestAndRetrieve(t *testing.T) {
+	service, repo, _, base := newTestService(t)
+	ctx := context.Background()
+
+	recipe, err := service.AddDocument(ctx, &AddDocumentRequest{
+		UserID:          1,
+		KnowledgeBaseID: base.ID,
+		Name:            "红烧肉.md",
+		Content:         "# 红烧肉\n\n五花肉切块焯水，加冰糖炒出糖色。\n\n# 清蒸鱼\n\n鲈鱼洗净，上锅蒸八分钟，淋上蒸鱼豉油。",
+	})
+	if err != nil {
+		t.Fatalf("Failed to add document: %v", err)
+	}
+	if recipe.Status != model.DocumentPending || recipe.Format != FormatMarkdown {
+		t.Errorf("Expected pending markdown document, got %+v", recipe)
+	}
+
+	processed, err := service.IngestNext(ctx)
+	if err != nil || !processed {
+		t.Fatalf("Expected document ingested, got %v, %v", processed, err)
+	}
+	if recipe.Status != model.DocumentReady || recipe.ChunkCount != 2 || len(repo.chunks[recipe.ID]) != 2 {
+		t.Fatalf("Expected ready document with 2 chunks, got %+v", recipe)
+	}
+	if processed, _ := service.IngestNext(ctx); processed {
+		t.Error("Expected no more pending documents")
+	}
+
+	citations, err := service.Retrieve(ctx, 1, []int64{base.ID}, "清蒸鱼要蒸多久", 1, 0)
+	if err != nil {
+		t.Fatalf("Retrieve failed: %v", err)
+	}
+	if len(citations) != 1 || !strings.Contains(citations[0].Content, "鲈鱼") {
+		t.Fatalf("Expected fish chunk retrieved, got %+v", citations)
+	}
+	if citations[0].Index != 1 || citations[0].DocumentName != "红烧肉.md" || citations[0].DocumentID != recipe.ID {
+		t.Errorf("Unexpected citation: %+v", citations[0])
+	}
+
+	if citations, _ := service.Retrieve(ctx, 2, []int64{base.ID}, "清蒸鱼", 4, 0); len(citations) != 0 {
+		t.Errorf("Expected other users' knowledge bases to be ignored, got %+v", citations)
+	}
+}
+
+func TestService_IngestFailure(t *testing.T) {
+	service, _, embedder, base := newTestService(t)
+	embedder.err = errors.New("embedding service unavailable")
+	ctx := context.Background()
+
+	document, err := service.AddDocument(ctx, &AddDocumentRequest{UserID: 1, KnowledgeBaseID: base.ID, Name: "a.txt", Content: "内容"})
+	if err != nil {
+		t.Fatalf("Failed to add document: %v", err)
+	}
+
+	processed, err := service.IngestNext(ctx)
+	if !processed || err == nil {
+		t.Fatalf("Expected ingestion error, got %v, %v", processed, err)
+	}
+	if document.Status != model.DocumentFailed || !strings.Contains(document.Error, "embedding service unavailable") {
+		t.Errorf("Expected failed status with reason, got %+v", document)
+	}
+}
@@ -0,0 +1,371 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// BreakerState 熔断器状态
+type BreakerState int
+
+const (
+	StateClosed   BreakerState = iota // 关闭：正常放行请求
+	StateOpen                         // 打开：直接拒绝请求
+	StateHalfOpen                     // 半开：放行少量探测请求
+)
+
+// String 状态名称
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrCircuitOpen 熔断器打开时返回的错误，可通过 errors.Is 判断
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError 熔断器拒绝请求的错误
+type CircuitOpenError struct {
+	Provider   string
+	RetryAfter time.Duration // 距离进入半开状态的剩余时间
+}
+
+// Error 实现error接口
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s: provider %s is unavailable, retry after %v", ErrCircuitOpen, e.Provider, e.RetryAfter.Round(time.Second))
+}
+
+// Is 使 errors.Is(err, ErrCircuitOpen) 成立
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// BreakerConfig 熔断器配置
+type BreakerConfig struct {
+	WindowSize          int           // 统计最近多少次请求的结果
+	MinRequests         int           // 窗口内请求数达到该值后才计算错误率
+	FailureRate         float64       // 触发熔断的错误率阈值 (0.0-1.0)
+	OpenTimeout         time.Duration // 熔断持续时间，到期后进入半开状态
+	HalfOpenMaxRequests int           // 半开状态允许的并发探测请求数，全部成功后恢复关闭
+}
+
+// DefaultBreakerConfig 默认熔断器配置
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		WindowSize:          20,
+		MinRequests:         10,
+		FailureRate:         0.5,
+		OpenTimeout:         30 * time.Second,
+		HalfOpenMaxRequests: 1,
+	}
+}
+
+// BreakerStats 熔断器状态快照，用于健康检查和监控指标
+type BreakerStats struct {
+	Provider       string     `json:"provider"`
+	State          string     `json:"state"`
+	ErrorRate      float64    `json:"error_rate"`      // 当前窗口错误率
+	WindowRequests int        `json:"window_requests"` // 当前窗口请求数
+	WindowFailures int        `json:"window_failures"` // 当前窗口失败数
+	TotalRequests  int64      `json:"total_requests"`  // 累计放行请求数
+	TotalFailures  int64      `json:"total_failures"`  // 累计上游失败数
+	TotalRejected  int64      `json:"total_rejected"`  // 累计因熔断被拒绝的请求数
+	OpenedAt       *time.Time `json:"opened_at,omitempty"`
+}
+
+// IsUpstreamFailure 判断错误是否表明上游故障，只有这类错误计入熔断统计
+//
+// 提供方标记为可切换的错误（限流、超时、5xx、额度不足等）与网络层错误计入；
+// 鉴权失败、参数错误、超出上下文等由请求本身导致的错误不计入，
+// 避免个别用户的无效请求使所有用户的请求被熔断。
+func IsUpstreamFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var failoverErr FailoverError
+	if errors.As(err, &failoverErr) {
+		return failoverErr.Failover()
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// CircuitBreaker 为Provider增加熔断保护的装饰器
+//
+// 关闭状态下按滑动窗口统计错误率，超过阈值后打开并快速失败；
+// OpenTimeout 到期后进入半开状态放行探测请求，成功则恢复，失败则重新打开。
+// 只有 IsUpstreamFailure 判定的错误记为失败，其他错误说明上游可用，记为成功；
+// 调用方主动取消（ctx结束）的请求不计入统计。
+type CircuitBreaker struct {
+	provider Provider
+	config   BreakerConfig
+	now      func() time.Time
+
+	mu                sync.Mutex
+	state             BreakerState
+	window            []bool // 环形缓冲区，true表示失败
+	pos               int
+	count             int
+	failures          int
+	openedAt          time.Time
+	generation        uint64 // 状态切换次数，用于识别放行后状态已变化的请求
+	halfOpenInFlight  int
+	halfOpenSuccesses int
+	totalRequests     int64
+	totalFailures     int64
+	totalRejected     int64
+}
+
+// NewCircuitBreaker 创建熔断器，包装给定的Provider
+func NewCircuitBreaker(provider Provider, config BreakerConfig) *CircuitBreaker {
+	if config.WindowSize <= 0 {
+		config.WindowSize = DefaultBreakerConfig().WindowSize
+	}
+	if config.MinRequests <= 0 || config.MinRequests > config.WindowSize {
+		config.MinRequests = config.WindowSize
+	}
+	if config.HalfOpenMaxRequests <= 0 {
+		config.HalfOpenMaxRequests = 1
+	}
+	return &CircuitBreaker{
+		provider: provider,
+		config:   config,
+		now:      time.Now,
+		window:   make([]bool, config.WindowSize),
+	}
+}
+
+// Name 提供方名称
+func (b *CircuitBreaker) Name() string {
+	return b.provider.Name()
+}
+
+// admission 放行请求时的熔断状态，请求结果按放行时的状态统计
+type admission struct {
+	state      BreakerState
+	generation uint64
+}
+
+// Acquire 熔断检查，供不经过 Provider 接口的上游调用共享熔断状态与统计
+//
+// 熔断打开时返回 CircuitOpenError；放行时返回的 done 须在请求结束后以请求结果调用一次。
+func (b *CircuitBreaker) Acquire() (func(ctx context.Context, err error), error) {
+	adm, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, err error) {
+		b.record(ctx, adm, err)
+	}, nil
+}
+
+// ChatCompletion 聊天完成，熔断打开时直接返回 CircuitOpenError
+func (b *CircuitBreaker) ChatCompletion(ctx context.Context, request ChatRequest) (*ChatResponse, error) {
+	done, err := b.Acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := b.provider.ChatCompletion(ctx, request)
+	done(ctx, err)
+	return response, err
+}
+
+// ChatCompletionStream 流式聊天完成，流中出现错误片段时记为失败
+func (b *CircuitBreaker) ChatCompletionStream(ctx context.Context, request ChatRequest) (<-chan StreamChunk, error) {
+	done, err := b.Acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	chunkChan, err := b.provider.ChatCompletionStream(ctx, request)
+	if err != nil {
+		done(ctx, err)
+		return nil, err
+	}
+
+	out := make(chan StreamChunk, 10)
+	go func() {
+		defer close(out)
+
+		var streamErr error
+		delivered := true
+		for chunk := range chunkChan {
+			if chunk.Err != nil {
+				streamErr = chunk.Err
+			}
+			// 调用方已离开时继续读取直到上游通道关闭，避免上游goroutine阻塞
+			if !delivered {
+				continue
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				delivered = false
+			}
+		}
+		done(ctx, streamErr)
+	}()
+
+	return out, nil
+}
+
+// ListModels 获取可用模型列表（不经过熔断统计）
+func (b *CircuitBreaker) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	return b.provider.ListModels(ctx)
+}
+
+// State 当前状态
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	return b.state
+}
+
+// Stats 获取熔断器状态快照
+func (b *CircuitBreaker) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	stats := BreakerStats{
+		Provider:       b.provider.Name(),
+		State:          b.state.String(),
+		WindowRequests: b.count,
+		WindowFailures: b.failures,
+		TotalRequests:  b.totalRequests,
+		TotalFailures:  b.totalFailures,
+		TotalRejected:  b.totalRejected,
+	}
+	if b.count > 0 {
+		stats.ErrorRate = float64(b.failures) / float64(b.count)
+	}
+	if b.state != StateClosed {
+		openedAt := b.openedAt
+		stats.OpenedAt = &openedAt
+	}
+	return stats
+}
+
+// allow 判断是否放行请求，放行时返回当前状态
+func (b *CircuitBreaker) allow() (admission, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	switch b.state {
+	case StateOpen:
+		b.totalRejected++
+		return admission{}, &CircuitOpenError{
+			Provider:   b.provider.Name(),
+			RetryAfter: b.config.OpenTimeout - b.now().Sub(b.openedAt),
+		}
+	case StateHalfOpen:
+		if b.halfOpenInFlight >= b.config.HalfOpenMaxRequests {
+			b.totalRejected++
+			return admission{}, &CircuitOpenError{Provider: b.provider.Name()}
+		}
+		b.halfOpenInFlight++
+	}
+
+	b.totalRequests++
+	return admission{state: b.state, generation: b.generation}, nil
+}
+
+// record 记录请求结果
+func (b *CircuitBreaker) record(ctx context.Context, adm admission, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 调用方主动取消的请求既不算成功也不算失败
+	ignored := err != nil && ctx.Err() != nil
+	failed := !ignored && IsUpstreamFailure(err)
+	if failed {
+		b.totalFailures++
+	}
+
+	// 放行后状态已切换（如持续时间超过 OpenTimeout 的流式请求），结果不影响当前状态
+	if adm.generation != b.generation {
+		return
+	}
+
+	switch adm.state {
+	case StateHalfOpen:
+		b.halfOpenInFlight--
+		if ignored {
+			return
+		}
+		if failed {
+			b.trip()
+			return
+		}
+		b.halfOpenSuccesses++
+		if b.halfOpenSuccesses >= b.config.HalfOpenMaxRequests {
+			b.reset()
+		}
+	case StateClosed:
+		if ignored {
+			return
+		}
+		b.push(failed)
+		if b.count >= b.config.MinRequests && float64(b.failures)/float64(b.count) >= b.config.FailureRate {
+			b.trip()
+		}
+	}
+}
+
+// advance 熔断时间到期后由打开转为半开
+func (b *CircuitBreaker) advance() {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.config.OpenTimeout {
+		b.state = StateHalfOpen
+		b.generation++
+		b.halfOpenInFlight = 0
+		b.halfOpenSuccesses = 0
+	}
+}
+
+// trip 打开熔断器
+func (b *CircuitBreaker) trip() {
+	b.state = StateOpen
+	b.generation++
+	b.openedAt = b.now()
+}
+
+// reset 恢复关闭状态并清空统计窗口
+func (b *CircuitBreaker) reset() {
+	b.state = StateClosed
+	b.generation++
+	b.openedAt = time.Time{}
+	b.pos, b.count, b.failures = 0, 0, 0
+}
+
+// push 将结果写入滑动窗口
+func (b *CircuitBreaker) push(failed bool) {
+	if b.count == len(b.window) {
+		if b.window[b.pos] {
+			b.failures--
+		}
+	} else {
+		b.count++
+	}
+	b.window[b.pos] = failed
+	if failed {
+		b.failures++
+	}
+	b.pos = (b.pos + 1) % len(b.window)
+}
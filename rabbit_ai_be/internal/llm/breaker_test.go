@@ -0,0 +1,225 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubProvider 可控制成功/失败的测试提供方
+type stubProvider struct {
+	err   error
+	calls int
+}
+
+func (p *stubProvider) Name() string { return "stub" }
+
+func (p *stubProvider) ChatCompletion(ctx context.Context, request ChatRequest) (*ChatResponse, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &ChatResponse{Message: Message{Role: RoleAssistant, Content: "ok"}}, nil
+}
+
+func (p *stubProvider) ChatCompletionStream(ctx context.Context, request ChatRequest) (<-chan StreamChunk, error) {
+	p.calls++
+	chunkChan := make(chan StreamChunk, 2)
+	if p.err != nil {
+		chunkChan <- StreamChunk{Err: p.err}
+	} else {
+		chunkChan <- StreamChunk{Content: "ok", FinishReason: "stop"}
+	}
+	close(chunkChan)
+	return chunkChan, nil
+}
+
+func (p *stubProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	return []ModelInfo{{ID: "stub-model", Provider: "stub"}}, nil
+}
+
+func newTestBreaker(provider Provider) (*CircuitBreaker, *time.Time) {
+	now := time.Now()
+	breaker := NewCircuitBreaker(provider, BreakerConfig{
+		WindowSize:          4,
+		MinRequests:         4,
+		FailureRate:         0.5,
+		OpenTimeout:         10 * time.Second,
+		HalfOpenMaxRequests: 1,
+	})
+	breaker.now = func() time.Time { return now }
+	return breaker, &now
+}
+
+func TestCircuitBreaker_TripsAndRecovers(t *testing.T) {
+	provider := &stubProvider{}
+	breaker, now := newTestBreaker(provider)
+	ctx := context.Background()
+
+	// 2成功2失败，错误率达到50%后熔断
+	breaker.ChatCompletion(ctx, ChatRequest{})
+	breaker.ChatCompletion(ctx, ChatRequest{})
+	provider.err = &retryableError{failover: true}
+	breaker.ChatCompletion(ctx, ChatRequest{})
+	if breaker.State() != StateClosed {
+		t.Fatalf("Expected closed before reaching threshold, got %s", breaker.State())
+	}
+	breaker.ChatCompletion(ctx, ChatRequest{})
+	if breaker.State() != StateOpen {
+		t.Fatalf("Expected open after threshold, got %s", breaker.State())
+	}
+
+	// 熔断期间直接拒绝，不调用上游
+	calls := provider.calls
+	_, err := breaker.ChatCompletion(ctx, ChatRequest{})
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || openErr.RetryAfter != 10*time.Second {
+		t.Errorf("Expected CircuitOpenError with 10s retry-after, got %v", err)
+	}
+	if provider.calls != calls {
+		t.Errorf("Expected no upstream call while open")
+	}
+
+	// 到期后进入半开，探测失败重新打开
+	*now = now.Add(10 * time.Second)
+	if breaker.State() != StateHalfOpen {
+		t.Fatalf("Expected half_open after timeout, got %s", breaker.State())
+	}
+	breaker.ChatCompletion(ctx, ChatRequest{})
+	if breaker.State() != StateOpen {
+		t.Fatalf("Expected open after failed probe, got %s", breaker.State())
+	}
+
+	// 再次到期，探测成功后恢复关闭
+	*now = now.Add(10 * time.Second)
+	provider.err = nil
+	if _, err := breaker.ChatCompletion(ctx, ChatRequest{}); err != nil {
+		t.Fatalf("Expected probe to succeed, got %v", err)
+	}
+	if breaker.State() != StateClosed {
+		t.Fatalf("Expected closed after successful probe, got %s", breaker.State())
+	}
+
+	stats := breaker.Stats()
+	if stats.WindowRequests != 0 || stats.TotalRejected != 1 || stats.TotalFailures != 3 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestCircuitBreaker_IgnoresCanceledRequests(t *testing.T) {
+	provider := &stubProvider{err: context.Canceled}
+	breaker, _ := newTestBreaker(provider)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 4; i++ {
+		breaker.ChatCompletion(ctx, ChatRequest{})
+	}
+
+	if breaker.State() != StateClosed {
+		t.Errorf("Expected canceled requests not to trip the breaker, got %s", breaker.State())
+	}
+	if stats := breaker.Stats(); stats.WindowRequests != 0 {
+		t.Errorf("Expected canceled requests to be ignored, got %+v", stats)
+	}
+}
+
+func TestCircuitBreaker_IgnoresResultsFromEarlierState(t *testing.T) {
+	provider := &stubProvider{err: &retryableError{failover: true}}
+	breaker, now := newTestBreaker(provider)
+	ctx := context.Background()
+
+	// 关闭状态下放行的长请求，在熔断并进入半开之后才成功结束
+	done, err := breaker.Acquire()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		breaker.ChatCompletion(ctx, ChatRequest{})
+	}
+	*now = now.Add(10 * time.Second)
+	if breaker.State() != StateHalfOpen {
+		t.Fatalf("Expected half_open after timeout, got %s", breaker.State())
+	}
+	done(ctx, nil)
+	if breaker.State() != StateHalfOpen {
+		t.Fatalf("Expected stale success not to close the breaker, got %s", breaker.State())
+	}
+
+	// 半开状态的探测名额不受旧请求影响
+	probeDone, err := breaker.Acquire()
+	if err != nil {
+		t.Fatalf("Expected probe to be admitted, got %v", err)
+	}
+	if _, err := breaker.Acquire(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected only one probe in half_open, got %v", err)
+	}
+	probeDone(ctx, nil)
+	if breaker.State() != StateClosed {
+		t.Errorf("Expected closed after successful probe, got %s", breaker.State())
+	}
+}
+
+func TestCircuitBreaker_IgnoresClientErrors(t *testing.T) {
+	// 鉴权失败、参数错误等由请求本身导致的错误说明上游可用，不触发熔断
+	for _, err := range []error{&retryableError{failover: false}, errors.New("invalid model")} {
+		provider := &stubProvider{err: err}
+		breaker, _ := newTestBreaker(provider)
+		for i := 0; i < 4; i++ {
+			breaker.ChatCompletion(context.Background(), ChatRequest{})
+		}
+
+		if breaker.State() != StateClosed {
+			t.Errorf("Expected %v not to trip the breaker, got %s", err, breaker.State())
+		}
+		if stats := breaker.Stats(); stats.WindowRequests != 4 || stats.WindowFailures != 0 || stats.TotalFailures != 0 {
+			t.Errorf("Expected %v to count as upstream success, got %+v", err, stats)
+		}
+	}
+}
+
+func TestCircuitBreaker_StreamErrorsCount(t *testing.T) {
+	provider := &stubProvider{err: &retryableError{failover: true}}
+	breaker, _ := newTestBreaker(provider)
+
+	for i := 0; i < 4; i++ {
+		chunkChan, err := breaker.ChatCompletionStream(context.Background(), ChatRequest{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for range chunkChan {
+		}
+	}
+
+	if breaker.State() != StateOpen {
+		t.Fatalf("Expected stream errors to trip the breaker, got %s", breaker.State())
+	}
+	if _, err := breaker.ChatCompletionStream(context.Background(), ChatRequest{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen for stream, got %v", err)
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	breaker, _ := newTestBreaker(&stubProvider{})
+	breaker.ChatCompletion(context.Background(), ChatRequest{})
+
+	var buf bytes.Buffer
+	WriteMetrics(&buf, breaker)
+
+	output := buf.String()
+	for _, want := range []string{
+		`llm_circuit_breaker_state{provider="stub"} 0`,
+		`llm_upstream_requests_total{provider="stub"} 1`,
+		"# TYPE llm_upstream_failures_total counter",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", want, output)
+		}
+	}
+}
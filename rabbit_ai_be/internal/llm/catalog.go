@@ -0,0 +1,237 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 模型目录查询错误
+var (
+	ErrUnknownModel          = errors.New("unknown model")
+	ErrModelDisabled         = errors.New("model disabled")
+	ErrUnsupportedCapability = errors.New("model does not support requested capability")
+)
+
+// ModelCapabilities 模型能力标记
+type ModelCapabilities struct {
+	Streaming bool `json:"streaming" yaml:"streaming"` // 支持流式输出
+	Tools     bool `json:"tools" yaml:"tools"`         // 支持工具（函数）调用
+	Vision    bool `json:"vision" yaml:"vision"`       // 支持图片输入
+	Reasoning bool `json:"reasoning" yaml:"reasoning"` // 推理模型
+}
+
+// ModelSpec 模型目录条目
+type ModelSpec struct {
+	ID              string            `json:"id" yaml:"id"`                               // 请求上游使用的模型名称
+	DisplayName     string            `json:"display_name" yaml:"display_name"`           // 展示名称
+	Provider        string            `json:"provider" yaml:"provider"`                   // 提供方: minimax/openai
+	ContextWindow   int               `json:"context_window" yaml:"context_window"`       // 上下文窗口（token数）
+	MaxOutputTokens int               `json:"max_output_tokens" yaml:"max_output_tokens"` // 单次回复最大token数
+	InputPrice      float64           `json:"input_price" yaml:"input_price"`             // 每百万输入token价格（元）
+	OutputPrice     float64           `json:"output_price" yaml:"output_price"`           // 每百万输出token价格（元）
+	Capabilities    ModelCapabilities `json:"capabilities" yaml:"capabilities"`
+	Enabled         bool              `json:"enabled" yaml:"enabled"`
+}
+
+// CatalogConfig 模型目录配置（config/models.yaml 的文件格式）
+type CatalogConfig struct {
+	DefaultModel string              `yaml:"default_model"` // 未指定模型时使用，为空时取第一个启用的模型
+	Models       []ModelSpec         `yaml:"models"`
+	Failover     map[string][]string `yaml:"failover"` // 逻辑模型 -> 按顺序尝试的备用模型
+}
+
+// Catalog 模型目录，按配置顺序保存模型
+type Catalog struct {
+	defaultModel string
+	models       []ModelSpec
+	index        map[string]int
+	failover     map[string][]string
+}
+
+// NewCatalog 校验配置并创建模型目录
+func NewCatalog(config CatalogConfig) (*Catalog, error) {
+	c := &Catalog{
+		models: make([]ModelSpec, 0, len(config.Models)),
+		index:  make(map[string]int, len(config.Models)),
+	}
+	for _, spec := range config.Models {
+		if spec.ID == "" {
+			return nil, errors.New("model id is required")
+		}
+		if _, exists := c.index[spec.ID]; exists {
+			return nil, fmt.Errorf("duplicate model %s", spec.ID)
+		}
+		if spec.Provider == "" {
+			return nil, fmt.Errorf("provider of model %s is required", spec.ID)
+		}
+		if spec.ContextWindow <= 0 || spec.MaxOutputTokens <= 0 || spec.MaxOutputTokens >= spec.ContextWindow {
+			return nil, fmt.Errorf("model %s must have 0 < max_output_tokens < context_window", spec.ID)
+		}
+		if spec.InputPrice < 0 || spec.OutputPrice < 0 {
+			return nil, fmt.Errorf("prices of model %s must not be negative", spec.ID)
+		}
+		if spec.DisplayName == "" {
+			spec.DisplayName = spec.ID
+		}
+		c.index[spec.ID] = len(c.models)
+		c.models = append(c.models, spec)
+	}
+
+	for id, fallbacks := range config.Failover {
+		if _, ok := c.index[id]; !ok {
+			return nil, fmt.Errorf("failover of unknown model %s", id)
+		}
+		seen := map[string]bool{id: true}
+		for _, fallback := range fallbacks {
+			if _, ok := c.index[fallback]; !ok {
+				return nil, fmt.Errorf("unknown failover model %s for %s", fallback, id)
+			}
+			if seen[fallback] {
+				return nil, fmt.Errorf("duplicate failover model %s for %s", fallback, id)
+			}
+			seen[fallback] = true
+		}
+	}
+	c.failover = config.Failover
+
+	c.defaultModel = config.DefaultModel
+	if c.defaultModel == "" {
+		c.defaultModel = c.firstEnabled("")
+	}
+	if _, err := c.Lookup(c.defaultModel); err != nil {
+		return nil, fmt.Errorf("invalid default model: %w", err)
+	}
+	return c, nil
+}
+
+// LoadCatalog 从YAML文件加载模型目录
+func LoadCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model catalog: %w", err)
+	}
+
+	var config CatalogConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse model catalog: %w", err)
+	}
+	return NewCatalog(config)
+}
+
+// DefaultCatalog 内置模型目录，未配置目录文件时使用（价格未知，记为0）
+func DefaultCatalog() *Catalog {
+	catalog, err := NewCatalog(CatalogConfig{
+		DefaultModel: "glm-4",
+		Models: []ModelSpec{
+			{ID: "MiniMax-M1", Provider: "minimax", ContextWindow: 1000000, MaxOutputTokens: 40000,
+				Capabilities: ModelCapabilities{Streaming: true, Tools: true, Reasoning: true}, Enabled: true},
+			{ID: "MiniMax-Text-01", Provider: "minimax", ContextWindow: 1000000, MaxOutputTokens: 8192,
+				Capabilities: ModelCapabilities{Streaming: true, Tools: true}, Enabled: true},
+			{ID: "glm-4", DisplayName: "GLM-4", Provider: "openai", ContextWindow: 128000, MaxOutputTokens: 4096,
+				Capabilities: ModelCapabilities{Streaming: true, Tools: true}, Enabled: true},
+			{ID: "gpt-4o", DisplayName: "GPT-4o", Provider: "openai", ContextWindow: 128000, MaxOutputTokens: 16384,
+				Capabilities: ModelCapabilities{Streaming: true, Tools: true, Vision: true}, Enabled: true},
+			{ID: "gpt-4o-mini", DisplayName: "GPT-4o mini", Provider: "openai", ContextWindow: 128000, MaxOutputTokens: 16384,
+				Capabilities: ModelCapabilities{Streaming: true, Tools: true, Vision: true}, Enabled: true},
+			{ID: "deepseek-chat", DisplayName: "DeepSeek-V3", Provider: "openai", ContextWindow: 64000, MaxOutputTokens: 8192,
+				Capabilities: ModelCapabilities{Streaming: true, Tools: true}, Enabled: true},
+			{ID: "deepseek-reasoner", DisplayName: "DeepSeek-R1", Provider: "openai", ContextWindow: 64000, MaxOutputTokens: 32768,
+				Capabilities: ModelCapabilities{Streaming: true, Reasoning: true}, Enabled: true},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+}
+
+// ForProvider 返回只启用指定提供方模型的目录副本，默认模型不属于该提供方时改为其第一个启用的模型
+func (c *Catalog) ForProvider(provider string) (*Catalog, error) {
+	config := CatalogConfig{Models: make([]ModelSpec, len(c.models)), Failover: c.failover}
+	copy(config.Models, c.models)
+	for i := range config.Models {
+		if config.Models[i].Provider != provider {
+			config.Models[i].Enabled = false
+		}
+	}
+	config.DefaultModel = c.DefaultModelFor(provider)
+	if config.DefaultModel == "" {
+		return nil, fmt.Errorf("no enabled model for provider %s", provider)
+	}
+	return NewCatalog(config)
+}
+
+// DefaultModel 默认模型
+func (c *Catalog) DefaultModel() string {
+	return c.defaultModel
+}
+
+// DefaultModelFor 指定提供方的默认模型：目录默认模型属于该提供方时直接使用，否则取其第一个启用的模型，没有则返回空
+func (c *Catalog) DefaultModelFor(provider string) string {
+	if spec, err := c.Lookup(c.defaultModel); err == nil && spec.Provider == provider {
+		return spec.ID
+	}
+	return c.firstEnabled(provider)
+}
+
+// firstEnabled 第一个启用的模型，provider为空时不限提供方
+func (c *Catalog) firstEnabled(provider string) string {
+	for _, spec := range c.models {
+		if spec.Enabled && (provider == "" || spec.Provider == provider) {
+			return spec.ID
+		}
+	}
+	return ""
+}
+
+// Lookup 查询启用的模型，未登记返回 ErrUnknownModel，已停用返回 ErrModelDisabled
+func (c *Catalog) Lookup(id string) (ModelSpec, error) {
+	i, ok := c.index[id]
+	if !ok {
+		return ModelSpec{}, fmt.Errorf("%w: %q", ErrUnknownModel, id)
+	}
+	if !c.models[i].Enabled {
+		return ModelSpec{}, fmt.Errorf("%w: %s", ErrModelDisabled, id)
+	}
+	return c.models[i], nil
+}
+
+// Fallbacks 模型的备用模型（按配置顺序），只返回启用的模型
+func (c *Catalog) Fallbacks(id string) []ModelSpec {
+	var fallbacks []ModelSpec
+	for _, fallback := range c.failover[id] {
+		if spec, err := c.Lookup(fallback); err == nil {
+			fallbacks = append(fallbacks, spec)
+		}
+	}
+	return fallbacks
+}
+
+// FallbackProviders 备用模型涉及的提供方（按首次出现顺序）
+func (c *Catalog) FallbackProviders() []string {
+	var providers []string
+	seen := map[string]bool{}
+	for _, spec := range c.models {
+		for _, fallback := range c.Fallbacks(spec.ID) {
+			if !seen[fallback.Provider] {
+				seen[fallback.Provider] = true
+				providers = append(providers, fallback.Provider)
+			}
+		}
+	}
+	return providers
+}
+
+// Models 所有启用的模型（按配置顺序）
+func (c *Catalog) Models() []ModelSpec {
+	models := make([]ModelSpec, 0, len(c.models))
+	for _, spec := range c.models {
+		if spec.Enabled {
+			models = append(models, spec)
+		}
+	}
+	return models
+}
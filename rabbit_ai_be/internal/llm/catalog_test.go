@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewCatalog_Validation(t *testing.T) {
+	valid := ModelSpec{ID: "a", Provider: "minimax", ContextWindow: 1000, MaxOutputTokens: 100, Enabled: true}
+
+	tests := []struct {
+		name   string
+		config CatalogConfig
+	}{
+		{"missing id", CatalogConfig{Models: []ModelSpec{{Provider: "minimax", ContextWindow: 1000, MaxOutputTokens: 100}}}},
+		{"duplicate id", CatalogConfig{Models: []ModelSpec{valid, valid}}},
+		{"output exceeds window", CatalogConfig{Models: []ModelSpec{{ID: "b", Provider: "minimax", ContextWindow: 100, MaxOutputTokens: 100, Enabled: true}}}},
+		{"negative price", CatalogConfig{Models: []ModelSpec{{ID: "b", Provider: "minimax", ContextWindow: 1000, MaxOutputTokens: 100, InputPrice: -1, Enabled: true}}}},
+		{"unknown default", CatalogConfig{DefaultModel: "x", Models: []ModelSpec{valid}}},
+		{"no enabled model", CatalogConfig{Models: []ModelSpec{{ID: "b", Provider: "minimax", ContextWindow: 1000, MaxOutputTokens: 100}}}},
+		{"failover of unknown model", CatalogConfig{Models: []ModelSpec{valid}, Failover: map[string][]string{"x": {"a"}}}},
+		{"unknown failover model", CatalogConfig{Models: []ModelSpec{valid}, Failover: map[string][]string{"a": {"x"}}}},
+		{"failover to itself", CatalogConfig{Models: []ModelSpec{valid}, Failover: map[string][]string{"a": {"a"}}}},
+	}
+	for _, tt := range tests {
+		if _, err := NewCatalog(tt.config); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+
+	catalog, err := NewCatalog(CatalogConfig{Models: []ModelSpec{valid}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if catalog.DefaultModel() != "a" {
+		t.Errorf("Expected first enabled model as default, got %s", catalog.DefaultModel())
+	}
+	if spec, _ := catalog.Lookup("a"); spec.DisplayName != "a" {
+		t.Errorf("Expected display name to default to id, got %q", spec.DisplayName)
+	}
+}
+
+func TestCatalog_Lookup(t *testing.T) {
+	catalog, err := NewCatalog(CatalogConfig{Models: []ModelSpec{
+		{ID: "on", Provider: "minimax", ContextWindow: 1000, MaxOutputTokens: 100, Enabled: true},
+		{ID: "off", Provider: "minimax", ContextWindow: 1000, MaxOutputTokens: 100},
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := catalog.Lookup("missing"); !errors.Is(err, ErrUnknownModel) {
+		t.Errorf("Expected ErrUnknownModel, got %v", err)
+	}
+	if _, err := catalog.Lookup("off"); !errors.Is(err, ErrModelDisabled) {
+		t.Errorf("Expected ErrModelDisabled, got %v", err)
+	}
+	if models := catalog.Models(); len(models) != 1 || models[0].ID != "on" {
+		t.Errorf("Expected only enabled models listed, got %+v", models)
+	}
+}
+
+func TestCatalog_ForProvider(t *testing.T) {
+	catalog := DefaultCatalog()
+
+	minimaxCatalog, err := catalog.ForProvider("minimax")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if minimaxCatalog.DefaultModel() != "MiniMax-M1" {
+		t.Errorf("Expected MiniMax-M1 as default, got %s", minimaxCatalog.DefaultModel())
+	}
+	if _, err := minimaxCatalog.Lookup("glm-4"); !errors.Is(err, ErrModelDisabled) {
+		t.Errorf("Expected models of other providers disabled, got %v", err)
+	}
+
+	// 原目录不受影响
+	if catalog.DefaultModel() != "glm-4" {
+		t.Errorf("Expected original catalog unchanged, got default %s", catalog.DefaultModel())
+	}
+
+	if _, err := catalog.ForProvider("unknown"); err == nil {
+		t.Error("Expected error for provider without models")
+	}
+}
+
+func TestLoadCatalog(t *testing.T) {
+	// 仓库自带的示例目录必须有效
+	catalog, err := LoadCatalog(filepath.Join("..", "..", "config", "models.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to load config/models.yaml: %v", err)
+	}
+	spec, err := catalog.Lookup("MiniMax-M1")
+	if err != nil {
+		t.Fatalf("Expected MiniMax-M1 in catalog: %v", err)
+	}
+	if !spec.Capabilities.Streaming || spec.ContextWindow != 1000000 || spec.OutputPrice != 8 {
+		t.Errorf("Unexpected spec: %+v", spec)
+	}
+
+	path := filepath.Join(t.TempDir(), "models.yaml")
+	os.WriteFile(path, []byte("models: [{id: a}]"), 0o644)
+	if _, err := LoadCatalog(path); err == nil {
+		t.Error("Expected error for invalid catalog")
+	}
+}
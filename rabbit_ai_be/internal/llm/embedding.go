@@ -0,0 +1,39 @@
+package llm
+
+import (
+	"context"
+	"math"
+)
+
+// EmbeddingPurpose 向量用途，部分提供方对入库文本与查询文本使用不同的向量化方式
+type EmbeddingPurpose string
+
+const (
+	EmbeddingDocument EmbeddingPurpose = "db"    // 入库的文本
+	EmbeddingQuery    EmbeddingPurpose = "query" // 检索的查询
+)
+
+// Embedder 文本向量化接口
+type Embedder interface {
+	// Model 向量模型名称，不同模型的向量不能混用
+	Model() string
+	// Embed 按顺序返回每段文本的向量
+	Embed(ctx context.Context, texts []string, purpose EmbeddingPurpose) ([][]float32, error)
+}
+
+// CosineSimilarity 余弦相似度，维度不同或存在零向量时返回0
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,207 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+)
+
+// FailoverError 可由提供方错误实现，表明请求是否应切换到备用模型
+type FailoverError interface {
+	error
+	Failover() bool
+}
+
+// ShouldFailover 判断错误是否应切换到备用模型
+//
+// 熔断拒绝、网络层错误以及提供方标记的可重试或额度不足错误会切换；
+// 调用方取消的请求和参数错误等不切换。
+func ShouldFailover(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+
+	var failoverErr FailoverError
+	if errors.As(err, &failoverErr) {
+		return failoverErr.Failover()
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// failoverTarget 故障转移链中的一个后端
+type failoverTarget struct {
+	model     string
+	provider  Provider
+	maxTokens int // 不超过该模型单次回复上限的 MaxTokens
+}
+
+// FailoverProvider 按模型目录中的故障转移链依次尝试后端的Provider
+//
+// 请求的模型作为逻辑模型，首先使用主提供方，失败且错误满足 ShouldFailover 时
+// 依次改用目录中配置的备用模型。响应的 Model（流式为每个片段的 Model）
+// 为实际回答的模型。流式请求只在收到第一个片段前切换，已开始输出后不再切换。
+type FailoverProvider struct {
+	primary   Provider
+	providers map[string]Provider
+	catalog   *Catalog
+}
+
+// NewFailoverProvider 创建故障转移Provider，catalog 中备用模型涉及的提供方须在 providers 中
+func NewFailoverProvider(catalog *Catalog, primary Provider, fallbacks ...Provider) (*FailoverProvider, error) {
+	providers := map[string]Provider{primary.Name(): primary}
+	for _, provider := range fallbacks {
+		providers[provider.Name()] = provider
+	}
+	for _, name := range catalog.FallbackProviders() {
+		if _, ok := providers[name]; !ok {
+			return nil, fmt.Errorf("failover provider %s is not configured", name)
+		}
+	}
+	return &FailoverProvider{
+		primary:   primary,
+		providers: providers,
+		catalog:   catalog,
+	}, nil
+}
+
+// Name 主提供方名称
+func (f *FailoverProvider) Name() string {
+	return f.primary.Name()
+}
+
+// ChatCompletion 聊天完成，失败时按故障转移链切换后端
+func (f *FailoverProvider) ChatCompletion(ctx context.Context, request ChatRequest) (*ChatResponse, error) {
+	targets := f.targets(request, false)
+
+	var err error
+	for i, target := range targets {
+		if i > 0 {
+			log.Printf("LLM failover: %s -> %s: %v", targets[i-1].model, target.model, err)
+		}
+		request.Model = target.model
+		request.MaxTokens = target.maxTokens
+
+		var response *ChatResponse
+		response, err = target.provider.ChatCompletion(ctx, request)
+		if err == nil {
+			response.Model = target.model
+			return response, nil
+		}
+		if ctx.Err() != nil || !ShouldFailover(err) {
+			break
+		}
+	}
+	return nil, err
+}
+
+// ChatCompletionStream 流式聊天完成，建立流失败或第一个片段即为错误时切换后端
+func (f *FailoverProvider) ChatCompletionStream(ctx context.Context, request ChatRequest) (<-chan StreamChunk, error) {
+	targets := f.targets(request, true)
+
+	var err error
+	for i, target := range targets {
+		if i > 0 {
+			log.Printf("LLM failover: %s -> %s: %v", targets[i-1].model, target.model, err)
+		}
+		request.Model = target.model
+		request.MaxTokens = target.maxTokens
+
+		var chunkChan <-chan StreamChunk
+		chunkChan, err = target.provider.ChatCompletionStream(ctx, request)
+		if err == nil {
+			first, ok := <-chunkChan
+			if !ok || first.Err == nil || i == len(targets)-1 || !ShouldFailover(first.Err) {
+				return relayStream(ctx, target.model, first, ok, chunkChan), nil
+			}
+			err = first.Err
+			go drain(chunkChan)
+		}
+		if ctx.Err() != nil || !ShouldFailover(err) {
+			break
+		}
+	}
+	return nil, err
+}
+
+// ListModels 主提供方的可用模型列表
+func (f *FailoverProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	return f.primary.ListModels(ctx)
+}
+
+// targets 请求的故障转移链：主提供方的请求模型在前，其后为支持所需能力的备用模型
+//
+// 历史消息按主模型的上下文窗口裁剪，上下文窗口容纳不下请求的备用模型被跳过；
+// MaxTokens 超过备用模型单次回复上限时按上限发送。
+func (f *FailoverProvider) targets(request ChatRequest, stream bool) []failoverTarget {
+	vision := false
+	for _, message := range request.Messages {
+		if message.HasImages() {
+			vision = true
+			break
+		}
+	}
+
+	targets := []failoverTarget{{model: request.Model, provider: f.primary, maxTokens: request.MaxTokens}}
+	fallbacks := f.catalog.Fallbacks(request.Model)
+	if len(fallbacks) == 0 {
+		return targets
+	}
+
+	promptTokens := EstimatePromptTokens(request.Messages)
+	for _, spec := range fallbacks {
+		if (stream && !spec.Capabilities.Streaming) || (len(request.Tools) > 0 && !spec.Capabilities.Tools) ||
+			(vision && !spec.Capabilities.Vision) {
+			continue
+		}
+		maxTokens := min(request.MaxTokens, spec.MaxOutputTokens)
+		if promptTokens > PromptBudget(spec.ContextWindow, maxTokens) {
+			continue
+		}
+		targets = append(targets, failoverTarget{model: spec.ID, provider: f.providers[spec.Provider], maxTokens: maxTokens})
+	}
+	return targets
+}
+
+// relayStream 转发流式片段并标记实际回答的模型
+func relayStream(ctx context.Context, model string, first StreamChunk, ok bool, chunkChan <-chan StreamChunk) <-chan StreamChunk {
+	out := make(chan StreamChunk, 10)
+	go func() {
+		defer close(out)
+		if !ok {
+			return
+		}
+
+		delivered := true
+		forward := func(chunk StreamChunk) {
+			// 调用方已离开时继续读取直到上游通道关闭，避免上游goroutine阻塞
+			if !delivered {
+				return
+			}
+			chunk.Model = model
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				delivered = false
+			}
+		}
+
+		forward(first)
+		for chunk := range chunkChan {
+			forward(chunk)
+		}
+	}()
+	return out
+}
+
+// drain 读取并丢弃剩余片段，直到上游通道关闭
+func drain(chunkChan <-chan StreamChunk) {
+	for range chunkChan {
+	}
+}
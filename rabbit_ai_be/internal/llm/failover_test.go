@@ -0,0 +1,180 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// retryableError 可切换到备用模型的测试错误
+type retryableError struct{ failover bool }
+
+func (e *retryableError) Error() string  { return "upstream error" }
+func (e *retryableError) Failover() bool { return e.failover }
+
+// namedProvider 记录请求模型、可指定错误的测试提供方
+type namedProvider struct {
+	stubProvider
+	name   string
+	models []string
+}
+
+func (p *namedProvider) Name() string { return p.name }
+
+func (p *namedProvider) ChatCompletion(ctx context.Context, request ChatRequest) (*ChatResponse, error) {
+	p.models = append(p.models, request.Model)
+	return p.stubProvider.ChatCompletion(ctx, request)
+}
+
+func (p *namedProvider) ChatCompletionStream(ctx context.Context, request ChatRequest) (<-chan StreamChunk, error) {
+	p.models = append(p.models, request.Model)
+	return p.stubProvider.ChatCompletionStream(ctx, request)
+}
+
+func newFailoverCatalog(t *testing.T) *Catalog {
+	catalog, err := NewCatalog(CatalogConfig{
+		Models: []ModelSpec{
+			{ID: "primary", Provider: "minimax", ContextWindow: 1000, MaxOutputTokens: 100,
+				Capabilities: ModelCapabilities{Streaming: true, Tools: true}, Enabled: true},
+			{ID: "backup", Provider: "minimax", ContextWindow: 600, MaxOutputTokens: 100,
+				Capabilities: ModelCapabilities{Streaming: true}, Enabled: true},
+			{ID: "other", Provider: "openai", ContextWindow: 2000, MaxOutputTokens: 100,
+				Capabilities: ModelCapabilities{Streaming: true, Tools: true, Vision: true}, Enabled: true},
+			{ID: "disabled", Provider: "openai", ContextWindow: 1000, MaxOutputTokens: 100},
+		},
+		Failover: map[string][]string{"primary": {"disabled", "backup", "other"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create catalog: %v", err)
+	}
+	return catalog
+}
+
+func TestShouldFailover(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"nil", nil, false},
+		{"circuit open", &CircuitOpenError{Provider: "minimax"}, true},
+		{"provider retryable", &retryableError{failover: true}, true},
+		{"provider not retryable", &retryableError{failover: false}, false},
+		{"wrapped", errors.Join(errors.New("context"), &retryableError{failover: true}), true},
+		{"canceled", context.Canceled, false},
+		{"plain", errors.New("bad request"), false},
+	}
+	for _, tt := range tests {
+		if got := ShouldFailover(tt.err); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestFailoverProvider_ChatCompletion(t *testing.T) {
+	catalog := newFailoverCatalog(t)
+	primary := &namedProvider{name: "minimax", stubProvider: stubProvider{err: &retryableError{failover: true}}}
+	other := &namedProvider{name: "openai"}
+
+	failover, err := NewFailoverProvider(catalog, primary, other)
+	if err != nil {
+		t.Fatalf("Failed to create failover provider: %v", err)
+	}
+	if failover.Name() != "minimax" {
+		t.Errorf("Expected primary name, got %s", failover.Name())
+	}
+
+	// 停用的备用模型被跳过，主提供方上的 backup 同样失败后改用 other
+	response, err := failover.ChatCompletion(context.Background(), ChatRequest{Model: "primary"})
+	if err != nil {
+		t.Fatalf("Expected failover to succeed, got %v", err)
+	}
+	if response.Model != "other" {
+		t.Errorf("Expected answering model recorded, got %q", response.Model)
+	}
+	if len(primary.models) != 2 || primary.models[1] != "backup" || len(other.models) != 1 || other.models[0] != "other" {
+		t.Errorf("Unexpected attempts: primary %v, other %v", primary.models, other.models)
+	}
+
+	// 不可切换的错误直接返回
+	primary.err = &retryableError{failover: false}
+	primary.models, other.models = nil, nil
+	if _, err := failover.ChatCompletion(context.Background(), ChatRequest{Model: "primary"}); err == nil {
+		t.Fatal("Expected error to be returned")
+	}
+	if len(primary.models) != 1 || len(other.models) != 0 {
+		t.Errorf("Expected no failover, got primary %v, other %v", primary.models, other.models)
+	}
+
+	// 请求携带工具时跳过不支持工具的备用模型；未配置故障转移链的模型只尝试一次
+	primary.err = &retryableError{failover: true}
+	primary.models, other.models = nil, nil
+	failover.ChatCompletion(context.Background(), ChatRequest{Model: "primary", Tools: []Tool{{Name: "now"}}})
+	if len(primary.models) != 1 || len(other.models) != 1 {
+		t.Errorf("Expected backup skipped for tools, got primary %v, other %v", primary.models, other.models)
+	}
+	primary.models, other.models = nil, nil
+	failover.ChatCompletion(context.Background(), ChatRequest{Model: "primary", Messages: []Message{
+		{Role: RoleUser, Parts: []Part{{Type: PartImageURL, ImageURL: "data:image/png;base64,AA=="}}},
+	}})
+	if len(primary.models) != 1 || len(other.models) != 1 {
+		t.Errorf("Expected backup skipped for images, got primary %v, other %v", primary.models, other.models)
+	}
+
+	// 上下文窗口容纳不下请求的备用模型被跳过：backup 的窗口较小
+	primary.models, other.models = nil, nil
+	failover.ChatCompletion(context.Background(), ChatRequest{Model: "primary", MaxTokens: 100, Messages: []Message{
+		{Role: RoleUser, Content: strings.Repeat("长", 600)},
+	}})
+	if len(primary.models) != 1 || len(other.models) != 1 {
+		t.Errorf("Expected backup skipped for context window, got primary %v, other %v", primary.models, other.models)
+	}
+
+	primary.models = nil
+	if _, err := failover.ChatCompletion(context.Background(), ChatRequest{Model: "backup"}); err == nil || len(primary.models) != 1 {
+		t.Errorf("Expected single attempt without chain, got %v, %v", err, primary.models)
+	}
+}
+
+func TestFailoverProvider_Stream(t *testing.T) {
+	catalog := newFailoverCatalog(t)
+	primary := &namedProvider{name: "minimax", stubProvider: stubProvider{err: &retryableError{failover: true}}}
+	other := &namedProvider{name: "openai"}
+	failover, _ := NewFailoverProvider(catalog, primary, other)
+
+	chunkChan, err := failover.ChatCompletionStream(context.Background(), ChatRequest{Model: "primary"})
+	if err != nil {
+		t.Fatalf("Expected stream to be established, got %v", err)
+	}
+	var content string
+	for chunk := range chunkChan {
+		if chunk.Err != nil {
+			t.Fatalf("Unexpected stream error: %v", chunk.Err)
+		}
+		if chunk.Model != "other" {
+			t.Errorf("Expected chunks marked with answering model, got %q", chunk.Model)
+		}
+		content += chunk.Content
+	}
+	if content != "ok" || len(primary.models) != 2 {
+		t.Errorf("Expected content from fallback after 2 primary attempts, got %q, %v", content, primary.models)
+	}
+
+	// 最后一个后端的错误片段原样返回
+	other.err = &retryableError{failover: true}
+	chunkChan, err = failover.ChatCompletionStream(context.Background(), ChatRequest{Model: "primary"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	chunk := <-chunkChan
+	if chunk.Err == nil || chunk.Model != "other" {
+		t.Errorf("Expected error chunk from last backend, got %+v", chunk)
+	}
+}
+
+func TestNewFailoverProvider_MissingProvider(t *testing.T) {
+	if _, err := NewFailoverProvider(newFailoverCatalog(t), &namedProvider{name: "minimax"}); err == nil {
+		t.Error("Expected error when fallback provider is not configured")
+	}
+}
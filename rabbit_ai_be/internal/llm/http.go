@@ -0,0 +1,28 @@
+package llm
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// 上游HTTP请求的超时设置
+const (
+	RequestTimeout      = 30 * time.Second // 非流式请求的总超时，包含读取响应体
+	DialTimeout         = 10 * time.Second // 建立TCP连接的超时
+	StreamHeaderTimeout = 30 * time.Second // 流式请求等待响应头的超时
+)
+
+// NewStreamClient 创建流式请求使用的HTTP客户端
+//
+// http.Client.Timeout 包含读取响应体的时间，会截断输出较久的流式回复，
+// 因此流式客户端不设总超时，只限制建立连接和等待响应头的时间，响应体由请求ctx结束。
+func NewStreamClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.ResponseHeaderTimeout = StreamHeaderTimeout
+	return &http.Client{Transport: transport}
+}
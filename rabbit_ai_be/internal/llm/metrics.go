@@ -0,0 +1,52 @@
+package llm
+
+import (
+	"fmt"
+	"io"
+)
+
+// WriteMetrics 以Prometheus文本格式输出熔断器指标
+//
+// llm_circuit_breaker_state 取值：0=closed，1=open，2=half_open。
+func WriteMetrics(w io.Writer, breakers ...*CircuitBreaker) {
+	metrics := []struct {
+		name  string
+		help  string
+		kind  string
+		value func(s BreakerStats) float64
+	}{
+		{"llm_circuit_breaker_state", "Circuit breaker state (0=closed, 1=open, 2=half_open).", "gauge",
+			func(s BreakerStats) float64 { return stateValue(s.State) }},
+		{"llm_upstream_error_rate", "Upstream error rate in the current breaker window.", "gauge",
+			func(s BreakerStats) float64 { return s.ErrorRate }},
+		{"llm_upstream_requests_total", "Upstream requests allowed by the circuit breaker.", "counter",
+			func(s BreakerStats) float64 { return float64(s.TotalRequests) }},
+		{"llm_upstream_failures_total", "Upstream requests that failed.", "counter",
+			func(s BreakerStats) float64 { return float64(s.TotalFailures) }},
+		{"llm_circuit_breaker_rejected_total", "Requests rejected because the circuit breaker was open.", "counter",
+			func(s BreakerStats) float64 { return float64(s.TotalRejected) }},
+	}
+
+	stats := make([]BreakerStats, len(breakers))
+	for i, b := range breakers {
+		stats[i] = b.Stats()
+	}
+
+	for _, g := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n", g.name, g.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", g.name, g.kind)
+		for _, s := range stats {
+			fmt.Fprintf(w, "%s{provider=%q} %g\n", g.name, s.Provider, g.value(s))
+		}
+	}
+}
+
+// stateValue 将状态名称转换为指标数值
+func stateValue(state string) float64 {
+	for _, s := range []BreakerState{StateClosed, StateOpen, StateHalfOpen} {
+		if s.String() == state {
+			return float64(s)
+		}
+	}
+	return -1
+}
@@ -0,0 +1,114 @@
+package llm
+
+import "encoding/json"
+
+// 消息角色常量
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// FinishReasonToolCalls 模型请求调用工具时的结束原因
+const FinishReasonToolCalls = "tool_calls"
+
+// 内容片段类型
+const (
+	PartText     = "text"
+	PartImageURL = "image_url"
+)
+
+// Message 与提供方无关的聊天消息
+type Message struct {
+	Role       string     `json:"role"`                   // system, user, assistant, tool
+	Name       string     `json:"name,omitempty"`         // 可选字段，tool消息为工具名称
+	Content    string     `json:"content"`                // 消息内容
+	Parts      []Part     `json:"parts,omitempty"`        // 图文内容片段，非空时代替 Content 发送给模型
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // assistant消息请求的工具调用
+	ToolCallID string     `json:"tool_call_id,omitempty"` // tool消息对应的工具调用ID
+}
+
+// Part 图文消息的内容片段
+type Part struct {
+	Type     string `json:"type"`                // text/image_url
+	Text     string `json:"text,omitempty"`      // 文字内容
+	ImageURL string `json:"image_url,omitempty"` // 图片地址，可以是 data URL
+}
+
+// HasImages 消息是否包含图片片段
+func (m Message) HasImages() bool {
+	for _, part := range m.Parts {
+		if part.Type == PartImageURL {
+			return true
+		}
+	}
+	return false
+}
+
+// Tool 可供模型调用的工具（函数）定义
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"` // 参数的JSON Schema
+}
+
+// ToolCall 模型发起的工具调用
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON编码的参数
+}
+
+// ChatRequest 与提供方无关的聊天请求
+type ChatRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Temperature float64   `json:"temperature,omitempty"` // 温度参数，0表示使用提供方默认值
+	TopP        float64   `json:"top_p,omitempty"`       // 核采样参数，0表示使用提供方默认值
+	MaxTokens   int       `json:"max_tokens,omitempty"`  // 最大token数，0表示使用提供方默认值
+	Stop        []string  `json:"stop,omitempty"`        // 停止词
+	User        string    `json:"user,omitempty"`        // 用户标识
+	Tools       []Tool    `json:"tools,omitempty"`       // 可供模型调用的工具
+}
+
+// Usage 使用统计
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// SensitiveFlags 提供方内容安全检测结果（如MiniMax的 input_sensitive/output_sensitive）
+type SensitiveFlags struct {
+	Input      bool `json:"input"`       // 输入命中敏感内容
+	InputType  int  `json:"input_type"`  // 输入敏感类型，含义由提供方定义
+	Output     bool `json:"output"`      // 输出命中敏感内容
+	OutputType int  `json:"output_type"` // 输出敏感类型，含义由提供方定义
+}
+
+// ChatResponse 与提供方无关的聊天响应
+type ChatResponse struct {
+	ID           string          `json:"id"`
+	Model        string          `json:"model"`
+	Message      Message         `json:"message"`             // 模型回复
+	FinishReason string          `json:"finish_reason"`       // 结束原因
+	Usage        Usage           `json:"usage"`               // 使用统计
+	Sensitive    *SensitiveFlags `json:"sensitive,omitempty"` // 提供方标记的敏感内容，未标记时为nil
+}
+
+// StreamChunk 流式响应片段
+type StreamChunk struct {
+	Content      string          // 增量内容
+	FinishReason string          // 结束原因，仅最后一个片段非空
+	Usage        *Usage          // 使用统计，仅在提供方返回时非空
+	Sensitive    *SensitiveFlags // 提供方标记的敏感内容，未标记时为nil
+	Model        string          // 实际回答的模型，由 FailoverProvider 填写
+	Err          error           // 流读取错误，非空时流随即结束
+}
+
+// ModelInfo 模型信息
+type ModelInfo struct {
+	ID       string `json:"id"`
+	Provider string `json:"provider"`
+}
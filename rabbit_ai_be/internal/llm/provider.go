@@ -0,0 +1,23 @@
+package llm
+
+import "context"
+
+// Provider 大模型服务提供方接口
+//
+// 对话等业务代码只依赖该接口，具体实现（MiniMax、OpenAI兼容接口等）
+// 由 cmd/server/main.go 根据配置选择。
+type Provider interface {
+	// Name 提供方名称，例如 "minimax"、"openai"
+	Name() string
+	// ChatCompletion 聊天完成，ctx取消或超时时应立即中止上游请求
+	ChatCompletion(ctx context.Context, request ChatRequest) (*ChatResponse, error)
+	// ChatCompletionStream 流式聊天完成，通道在流结束或ctx结束后关闭
+	ChatCompletionStream(ctx context.Context, request ChatRequest) (<-chan StreamChunk, error)
+	// ListModels 获取可用模型列表
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+}
+
+// GetContent 获取回复内容
+func (r *ChatResponse) GetContent() string {
+	return r.Message.Content
+}
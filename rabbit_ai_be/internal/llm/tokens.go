@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"unicode"
+)
+
+// 估算时每条消息额外计入的格式开销（角色、分隔符等），以及回复引导开销
+const (
+	messageOverheadTokens = 4
+	replyPrimingTokens    = 3
+	imageTokens           = 765 // 单张图片，按视觉模型处理 1024x1024 图片的量级估算
+)
+
+// EstimateMarginPercent 估算误差余量（百分比），PromptBudget 按该比例缩小预算
+const EstimateMarginPercent = 10
+
+// HeuristicTokens 按字符类别经验估算文本的token数，并非真实分词结果
+//
+// 各提供方的分词器不同，这里不做逐模型的BPE分词，而是采用偏保守的规则：
+// 中日韩字符每个计1个token；其他非ASCII字符（emoji、带音标的字母等）在字节级BPE中
+// 常被拆成多个token，每个计2个；ASCII标点和符号（代码、JSON中常见）每个计1个；
+// 其余ASCII字符（字母、数字、空白）每4个计1个。
+// 结果可能与实际token数有偏差，用于上下文预算时应通过 PromptBudget 留出余量。
+func HeuristicTokens(text string) int {
+	tokens, plain := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			tokens++
+		case r > unicode.MaxASCII:
+			tokens += 2
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			tokens++
+		default:
+			plain++
+		}
+	}
+	return tokens + (plain+3)/4
+}
+
+// PromptBudget 请求消息可用的估算token预算：上下文窗口减去为回复预留的 maxTokens，
+// 再按 EstimateMarginPercent 扣除估算误差余量，避免估算偏低时超出上下文窗口
+func PromptBudget(contextWindow, maxTokens int) int {
+	return (contextWindow - maxTokens) * (100 - EstimateMarginPercent) / 100
+}
+
+// EstimateMessageTokens 按 HeuristicTokens 估算单条消息的token数（含格式开销、图片和工具调用参数）
+func EstimateMessageTokens(message Message) int {
+	tokens := messageOverheadTokens
+	if len(message.Parts) == 0 {
+		tokens += HeuristicTokens(message.Content)
+	}
+	for _, part := range message.Parts {
+		if part.Type == PartImageURL {
+			tokens += imageTokens
+		} else {
+			tokens += HeuristicTokens(part.Text)
+		}
+	}
+	for _, call := range message.ToolCalls {
+		tokens += HeuristicTokens(call.Name) + HeuristicTokens(call.Arguments)
+	}
+	return tokens
+}
+
+// EstimatePromptTokens 估算一组请求消息的token数
+func EstimatePromptTokens(messages []Message) int {
+	tokens := replyPrimingTokens
+	for _, message := range messages {
+		tokens += EstimateMessageTokens(message)
+	}
+	return tokens
+}
@@ -0,0 +1,55 @@
+package llm
+
+import "testing"
+
+func TestHeuristicTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"你好世界", 4},
+		{"hello world!", 4},
+		{"你好 world", 4},
+		{"👍🎉", 4},
+		{`f(x) == {"a": 1}`, 11},
+	}
+	for _, tt := range tests {
+		if got := HeuristicTokens(tt.text); got != tt.want {
+			t.Errorf("HeuristicTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPromptBudget(t *testing.T) {
+	// (8192 - 2048) 扣除10%余量
+	if got := PromptBudget(8192, 2048); got != 5529 {
+		t.Errorf("Expected budget 5529, got %d", got)
+	}
+}
+
+func TestEstimatePromptTokens(t *testing.T) {
+	messages := []Message{
+		{Role: RoleUser, Content: "你好"},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{Name: "add", Arguments: `{"a":1}`}}},
+	}
+	// 回复引导3 + (4+2) + (4+1+6)
+	if got := EstimatePromptTokens(messages); got != 20 {
+		t.Errorf("Expected 20 tokens, got %d", got)
+	}
+}
+
+func TestEstimateMessageTokens_Parts(t *testing.T) {
+	message := Message{
+		Role:    RoleUser,
+		Content: "这是什么",
+		Parts: []Part{
+			{Type: PartText, Text: "这是什么"},
+			{Type: PartImageURL, ImageURL: "data:image/png;base64,iVBORw0KGgo="},
+		},
+	}
+	// 格式开销4 + 文字4 + 图片765，Content 不重复计算
+	if got := EstimateMessageTokens(message); got != 773 {
+		t.Errorf("Expected 773 tokens, got %d", got)
+	}
+}
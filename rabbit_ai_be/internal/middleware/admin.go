@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware 管理员中间件，须在JWT中间件之后使用，只放行 adminIDs 中的用户
+func AdminMiddleware(adminIDs []int64) gin.HandlerFunc {
+	admins := make(map[int64]bool, len(adminIDs))
+	for _, id := range adminIDs {
+		admins[id] = true
+	}
+
+	return func(c *gin.Context) {
+		userID, ok := GetUserIDFromContext(c)
+		if !ok || !admins[userID] {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": "Admin permission required",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,126 @@
+package minimax
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"rabbit_ai/internal/llm"
+)
+
+// DefaultEmbeddingModel MiniMax默认向量模型
+const DefaultEmbeddingModel = "embo-01"
+
+// EmbeddingRequest MiniMax向量化请求
+type EmbeddingRequest struct {
+	Model string   `json:"model"`
+	Texts []string `json:"texts"`
+	Type  string   `json:"type"` // db: 入库文本，query: 检索查询
+}
+
+// EmbeddingResponse MiniMax向量化响应
+type EmbeddingResponse struct {
+	Vectors     [][]float32  `json:"vectors"`
+	TotalTokens int          `json:"total_tokens"`
+	BaseResp    BaseResponse `json:"base_resp"`
+}
+
+// CreateEmbeddings 文本向量化，瞬时故障按 config.Retry 策略自动重试
+func (s *MiniMaxService) CreateEmbeddings(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	var response *EmbeddingResponse
+	err := s.withRetry(ctx, func() error {
+		var err error
+		response, err = s.createEmbeddingsOnce(ctx, request)
+		return err
+	})
+	return response, err
+}
+
+// createEmbeddingsOnce 发送一次向量化请求
+func (s *MiniMaxService) createEmbeddingsOnce(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.config.BaseURL+"/embeddings", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.config.APIKey))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	var response EmbeddingResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if response.BaseResp.StatusCode != 0 {
+		return &response, &MiniMaxError{
+			Code:       response.BaseResp.StatusCode,
+			Message:    response.BaseResp.StatusMsg,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	if len(response.Vectors) != len(request.Texts) {
+		return &response, fmt.Errorf("expected %d vectors, got %d", len(request.Texts), len(response.Vectors))
+	}
+
+	return &response, nil
+}
+
+// Embedder 基于MiniMaxService的llm.Embedder实现
+type Embedder struct {
+	service *MiniMaxService
+	model   string
+}
+
+// NewEmbedder 创建MiniMax向量化实例，model为空时使用默认向量模型
+func NewEmbedder(service *MiniMaxService, model string) *Embedder {
+	if model == "" {
+		model = DefaultEmbeddingModel
+	}
+	return &Embedder{
+		service: service,
+		model:   model,
+	}
+}
+
+// Model 向量模型名称
+func (e *Embedder) Model() string {
+	return e.model
+}
+
+// Embed 按顺序返回每段文本的向量
+func (e *Embedder) Embed(ctx context.Context, texts []string, purpose llm.EmbeddingPurpose) ([][]float32, error) {
+	response, err := e.service.CreateEmbeddings(ctx, EmbeddingRequest{
+		Model: e.model,
+		Texts: texts,
+		Type:  string(purpose),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response.Vectors, nil
+}
@@ -0,0 +1,43 @@
+package minimax
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/llm"
+)
+
+func TestEmbedder_Embed(t *testing.T) {
+	service, fake := newFakeService(t, fakellm.Config{})
+	embedder := NewEmbedder(service, "")
+
+	if embedder.Model() != DefaultEmbeddingModel {
+		t.Errorf("Expected default model %s, got %s", DefaultEmbeddingModel, embedder.Model())
+	}
+
+	vectors, err := embedder.Embed(context.Background(), []string{"红烧肉做法", "Go并发"}, llm.EmbeddingDocument)
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(vectors) != 2 || len(vectors[0]) != fakellm.EmbeddingDimension {
+		t.Fatalf("Expected 2 vectors of dimension %d, got %d", fakellm.EmbeddingDimension, len(vectors))
+	}
+	if score := llm.CosineSimilarity(vectors[0], fakellm.Embed("红烧肉做法")); score < 0.999 {
+		t.Errorf("Expected vector of the first text, got similarity %f", score)
+	}
+	if fake.EmbeddingCount() != 1 {
+		t.Errorf("Expected 1 embedding request, got %d", fake.EmbeddingCount())
+	}
+}
+
+func TestEmbedder_EmbedError(t *testing.T) {
+	service, _ := newFakeService(t, fakellm.Config{ErrorCode: fakellm.ErrorAuthFailed})
+
+	_, err := NewEmbedder(service, "").Embed(context.Background(), []string{"你好"}, llm.EmbeddingQuery)
+	var apiErr *MiniMaxError
+	if !errors.As(err, &apiErr) || apiErr.Code != fakellm.ErrorAuthFailed {
+		t.Errorf("Expected MiniMaxError %d, got %v", fakellm.ErrorAuthFailed, err)
+	}
+}
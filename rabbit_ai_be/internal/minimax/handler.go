@@ -1,27 +1,57 @@
 package minimax
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+
+	"rabbit_ai/internal/guardrail"
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/structured"
 )
 
 // Handler MiniMax AI处理器
 type Handler struct {
-	service *MiniMaxService
+	service       *MiniMaxService
+	catalog       *llm.Catalog
+	guardrails    *guardrail.Pipeline
+	breaker       *llm.CircuitBreaker
+	responseStore ResponseStore
+	responseCache ResponseCachePolicy
 }
 
-// NewHandler 创建MiniMax处理器实例
-func NewHandler(service *MiniMaxService) *Handler {
+// NewHandler 创建MiniMax处理器实例，请求的模型须在目录中启用且由MiniMax提供
+func NewHandler(service *MiniMaxService, catalog *llm.Catalog) *Handler {
 	return &Handler{
 		service: service,
+		catalog: catalog,
 	}
 }
 
+// SetGuardrails 设置内容安全护栏链，未设置时不做检查
+func (h *Handler) SetGuardrails(pipeline *guardrail.Pipeline) {
+	h.guardrails = pipeline
+}
+
+// SetCircuitBreaker 设置熔断器，须为包装同一MiniMax服务的熔断器；设置后 /ai 接口与对话接口
+// 共享熔断状态和统计，熔断期间直接返回503。未设置时不做熔断
+func (h *Handler) SetCircuitBreaker(breaker *llm.CircuitBreaker) {
+	h.breaker = breaker
+}
+
 // ChatRequest 聊天请求
 type ChatRequest struct {
 	Message           string       `json:"message" binding:"required"`
-	Temperature       float64      `json:"temperature,omitempty"`
+	Model             string       `json:"model,omitempty"`       // 为空时使用目录中MiniMax的默认模型
+	Temperature       *float64     `json:"temperature,omitempty"` // 为空时使用默认值0.7，0表示确定性输出（可使用响应缓存）
 	MaxTokens         int          `json:"max_tokens,omitempty"`
 	TopP              float64      `json:"top_p,omitempty"`
 	Stream            bool         `json:"stream,omitempty"`
@@ -31,19 +61,24 @@ type ChatRequest struct {
 	RepetitionPenalty float64      `json:"repetition_penalty,omitempty"`
 	PresencePenalty   float64      `json:"presence_penalty,omitempty"`
 	FrequencyPenalty  float64      `json:"frequency_penalty,omitempty"`
+	// ResponseFormat 结构化回复格式，回复须为满足 Schema 的JSON；不支持流式和 tool_choices
+	ResponseFormat *structured.ResponseFormat `json:"response_format,omitempty"`
 }
 
 // ChatResponse 聊天响应
 type ChatResponse struct {
-	Content string `json:"content"`
-	Usage   *Usage `json:"usage,omitempty"`
+	Content  string          `json:"content"`
+	Usage    *Usage          `json:"usage,omitempty"`
+	Output   json.RawMessage `json:"output,omitempty"`   // 指定 response_format 时解析出的JSON结果
+	Attempts int             `json:"attempts,omitempty"` // 指定 response_format 时调用模型的次数
 }
 
 // ErrorResponse 错误响应
 type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Details string `json:"details,omitempty"`
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	Details   string `json:"details,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"` // 业务错误码，如 CONTENT_BLOCKED
 }
 
 // Chat 聊天接口
@@ -58,23 +93,28 @@ func (h *Handler) Chat(c *gin.Context) {
 		return
 	}
 
+	spec, err := h.resolveModel(&req)
+	if err != nil {
+		respondInvalidModel(c, err)
+		return
+	}
+
+	if verdict := h.check(c.Request.Context(), guardrail.StageInput, req.Message, nil); verdict.Blocked() {
+		c.JSON(http.StatusUnprocessableEntity, blockedResponse(guardrail.StageInput, verdict))
+		return
+	}
+
+	if req.ResponseFormat != nil {
+		h.structuredChat(c, spec, req)
+		return
+	}
+
 	// 构建请求
-	request := NewChatCompletionRequest("MiniMax-M1", []ChatMessage{
-		{
-			Role:    "system",
-			Name:    "MiniMax AI",
-			Content: "",
-		},
-		{
-			Role:    "user",
-			Name:    "用户",
-			Content: req.Message,
-		},
-	})
+	request := NewSimpleChatRequest(spec.ID, req.Message)
 
 	// 应用可选参数
-	if req.Temperature > 0 {
-		request.WithTemperature(req.Temperature)
+	if req.Temperature != nil {
+		request.WithTemperature(*req.Temperature)
 	}
 	if req.MaxTokens > 0 {
 		request.WithMaxTokens(req.MaxTokens)
@@ -111,8 +151,28 @@ func (h *Handler) Chat(c *gin.Context) {
 	}
 
 	// 调用MiniMax服务
-	response, err := h.service.ChatCompletion(*request)
+	response, err := h.complete(c, *request)
 	if err != nil {
+		// 客户端已断开，无需再写响应
+		if c.Request.Context().Err() != nil {
+			return
+		}
+
+		if errors.Is(err, llm.ErrCircuitOpen) {
+			respondUnavailable(c, err)
+			return
+		}
+
+		// 网络错误等未拿到响应体的情况
+		if response == nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Code:    500,
+				Message: "Failed to get AI response",
+				Details: err.Error(),
+			})
+			return
+		}
+
 		// 根据错误类型返回不同的状态码
 		statusCode := http.StatusInternalServerError
 		if h.service.IsRateLimited(response) {
@@ -133,6 +193,20 @@ func (h *Handler) Chat(c *gin.Context) {
 		return
 	}
 
+	var sensitive *llm.SensitiveFlags
+	if response.InputSensitive || response.OutputSensitive {
+		sensitive = &llm.SensitiveFlags{
+			Input:      response.InputSensitive,
+			InputType:  response.InputSensitiveType,
+			Output:     response.OutputSensitive,
+			OutputType: response.OutputSensitiveType,
+		}
+	}
+	if verdict := h.check(c.Request.Context(), guardrail.StageOutput, response.GetContent(), sensitive); verdict.Blocked() {
+		c.JSON(http.StatusUnprocessableEntity, blockedResponse(guardrail.StageOutput, verdict))
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "Success",
@@ -152,9 +226,21 @@ func (h *Handler) handleStreamChat(c *gin.Context, request ChatCompletionRequest
 	c.Header("Access-Control-Allow-Origin", "*")
 	c.Header("Access-Control-Allow-Headers", "Cache-Control")
 
+	ctx := c.Request.Context()
+	done, err := h.acquire()
+	if err != nil {
+		c.SSEvent("error", ErrorResponse{
+			Code:    http.StatusServiceUnavailable,
+			Message: "AI service temporarily unavailable",
+			Details: err.Error(),
+		})
+		return
+	}
+
 	// 获取流式响应
-	responseChan, err := h.service.ChatCompletionStream(request)
+	responseChan, err := h.service.ChatCompletionStream(ctx, request)
 	if err != nil {
+		done(ctx, err)
 		c.SSEvent("error", ErrorResponse{
 			Code:    500,
 			Message: "Failed to start stream",
@@ -162,12 +248,18 @@ func (h *Handler) handleStreamChat(c *gin.Context, request ChatCompletionRequest
 		})
 		return
 	}
+	var streamErr error
+	defer func() {
+		done(ctx, streamErr)
+	}()
 
-	// 发送流式响应
+	// 发送流式响应，每收到增量内容都对已生成的内容执行输出检查，命中屏蔽时结束流
+	var content strings.Builder
 	for response := range responseChan {
 		// 检查是否有错误
 		if !response.IsSuccess() {
 			err := response.GetError()
+			streamErr = err
 			c.SSEvent("error", ErrorResponse{
 				Code:    err.Code,
 				Message: err.Message,
@@ -179,6 +271,11 @@ func (h *Handler) handleStreamChat(c *gin.Context, request ChatCompletionRequest
 		if len(response.Choices) > 0 {
 			choice := response.Choices[0]
 			if choice.Delta != nil && choice.Delta.Content != "" {
+				content.WriteString(choice.Delta.Content)
+				if verdict := h.check(c.Request.Context(), guardrail.StageOutput, content.String(), nil); verdict.Blocked() {
+					c.SSEvent("error", blockedResponse(guardrail.StageOutput, verdict))
+					break
+				}
 				c.SSEvent("message", gin.H{
 					"content": choice.Delta.Content,
 					"index":   choice.Index,
@@ -196,6 +293,98 @@ func (h *Handler) handleStreamChat(c *gin.Context, request ChatCompletionRequest
 	}
 }
 
+// structuredChat 按 response_format 生成结构化结果：回复未通过 Schema 校验时带着校验错误
+// 重新提示，仍失败时返回422。不使用响应缓存
+func (h *Handler) structuredChat(c *gin.Context, spec llm.ModelSpec, req ChatRequest) {
+	if req.Stream || len(req.ToolChoices) > 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    400,
+			Message: "Invalid response format",
+			Details: "response_format cannot be used with stream or tool_choices",
+		})
+		return
+	}
+	output, err := structured.NewOutput(req.ResponseFormat)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    400,
+			Message: "Invalid response format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// 未指定温度时与普通聊天一样使用0.7
+	request := llm.ChatRequest{
+		Model:       spec.ID,
+		Messages:    []llm.Message{{Role: llm.RoleUser, Content: req.Message}},
+		Temperature: 0.7,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxTokens,
+		Stop:        req.Stop,
+		User:        req.User,
+	}
+	if req.Temperature != nil {
+		request.Temperature = *req.Temperature
+	}
+
+	var provider llm.Provider = NewProvider(h.service)
+	if h.breaker != nil {
+		provider = h.breaker
+	}
+	result, err := output.Generate(c.Request.Context(), provider, request)
+	if err != nil {
+		if c.Request.Context().Err() != nil {
+			return
+		}
+		if errors.Is(err, llm.ErrCircuitOpen) {
+			respondUnavailable(c, err)
+			return
+		}
+		var outputErr *structured.OutputError
+		if errors.As(err, &outputErr) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"code":              http.StatusUnprocessableEntity,
+				"message":           "Structured output failed validation",
+				"details":           err.Error(),
+				"error_code":        structured.ErrCodeInvalidOutput,
+				"attempts":          outputErr.Attempts,
+				"validation_errors": outputErr.Errors,
+				"raw":               outputErr.Raw,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Code:    500,
+			Message: "Failed to get AI response",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	content := string(result.Value)
+	if verdict := h.check(c.Request.Context(), guardrail.StageOutput, content, result.Response.Sensitive); verdict.Blocked() {
+		c.JSON(http.StatusUnprocessableEntity, blockedResponse(guardrail.StageOutput, verdict))
+		return
+	}
+
+	usage := result.Response.Usage
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Success",
+		"data": ChatResponse{
+			Content: content,
+			Usage: &Usage{
+				TotalTokens:      usage.TotalTokens,
+				PromptTokens:     usage.PromptTokens,
+				CompletionTokens: usage.CompletionTokens,
+			},
+			Output:   result.Value,
+			Attempts: result.Attempts,
+		},
+	})
+}
+
 // SimpleChat 简单聊天接口
 func (h *Handler) SimpleChat(c *gin.Context) {
 	var req ChatRequest
@@ -208,27 +397,32 @@ func (h *Handler) SimpleChat(c *gin.Context) {
 		return
 	}
 
-	// 调用简单聊天方法
-	var content string
-	var err error
+	spec, err := h.resolveModel(&req)
+	if err != nil {
+		respondInvalidModel(c, err)
+		return
+	}
+
+	if verdict := h.check(c.Request.Context(), guardrail.StageInput, req.Message, nil); verdict.Blocked() {
+		c.JSON(http.StatusUnprocessableEntity, blockedResponse(guardrail.StageInput, verdict))
+		return
+	}
 
-	if req.Temperature > 0 || req.MaxTokens > 0 {
-		// 使用带参数的聊天
-		temp := req.Temperature
-		if temp == 0 {
-			temp = 0.7 // 默认温度
-		}
-		maxTokens := req.MaxTokens
-		if maxTokens == 0 {
-			maxTokens = 2048 // 默认最大token数
-		}
-		content, err = h.service.SimpleChatWithParams(req.Message, temp, maxTokens)
-	} else {
-		// 使用简单聊天
-		content, err = h.service.SimpleChat(req.Message)
+	// 未指定的参数使用默认值（温度0.7，最大token数2048）
+	request := NewSimpleChatRequest(spec.ID, req.Message)
+	if req.Temperature != nil {
+		request.WithTemperature(*req.Temperature)
+	}
+	if req.MaxTokens > 0 {
+		request.WithMaxTokens(req.MaxTokens)
 	}
 
+	response, err := h.complete(c, *request)
 	if err != nil {
+		if errors.Is(err, llm.ErrCircuitOpen) {
+			respondUnavailable(c, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Code:    500,
 			Message: "Failed to get AI response",
@@ -237,6 +431,12 @@ func (h *Handler) SimpleChat(c *gin.Context) {
 		return
 	}
 
+	content := response.GetContent()
+	if verdict := h.check(c.Request.Context(), guardrail.StageOutput, content, nil); verdict.Blocked() {
+		c.JSON(http.StatusUnprocessableEntity, blockedResponse(guardrail.StageOutput, verdict))
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "Success",
@@ -246,6 +446,106 @@ func (h *Handler) SimpleChat(c *gin.Context) {
 	})
 }
 
+// complete 调用MiniMax非流式接口，可缓存的请求先查询响应缓存，成功后写入缓存
+func (h *Handler) complete(c *gin.Context, request ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	ctx := c.Request.Context()
+	key, cacheable := h.responseCacheKey(request)
+	if cacheable {
+		if response := h.cachedResponse(ctx, key); response != nil {
+			c.Header(ResponseCacheHeader, "HIT")
+			return response, nil
+		}
+		c.Header(ResponseCacheHeader, "MISS")
+	}
+
+	done, err := h.acquire()
+	if err != nil {
+		return nil, err
+	}
+	response, err := h.service.ChatCompletion(ctx, request)
+	done(ctx, err)
+	if err == nil && cacheable {
+		h.storeResponse(ctx, key, response)
+	}
+	return response, err
+}
+
+// resolveModel 按模型目录校验请求的模型、流式能力和最大token数
+func (h *Handler) resolveModel(req *ChatRequest) (llm.ModelSpec, error) {
+	id := req.Model
+	if id == "" {
+		id = h.catalog.DefaultModelFor(ProviderName)
+	}
+	spec, err := h.catalog.Lookup(id)
+	if err != nil {
+		return spec, err
+	}
+	if spec.Provider != ProviderName {
+		return spec, fmt.Errorf("%w: %s is not provided by %s", llm.ErrUnknownModel, spec.ID, ProviderName)
+	}
+	if req.Stream && !spec.Capabilities.Streaming {
+		return spec, fmt.Errorf("%w: %s does not support streaming", llm.ErrUnsupportedCapability, spec.ID)
+	}
+	if req.MaxTokens > spec.MaxOutputTokens {
+		return spec, fmt.Errorf("max_tokens exceeds %d for model %s", spec.MaxOutputTokens, spec.ID)
+	}
+	return spec, nil
+}
+
+// acquire 熔断检查，未设置熔断器时总是放行
+func (h *Handler) acquire() (func(ctx context.Context, err error), error) {
+	if h.breaker == nil {
+		return func(context.Context, error) {}, nil
+	}
+	return h.breaker.Acquire()
+}
+
+// check 执行护栏检查；护栏出错时记录日志并放行
+func (h *Handler) check(ctx context.Context, stage guardrail.Stage, content string, sensitive *llm.SensitiveFlags) guardrail.Verdict {
+	verdict, err := h.guardrails.Check(ctx, guardrail.Input{
+		Stage:     stage,
+		Content:   content,
+		Sensitive: sensitive,
+	})
+	if err != nil {
+		log.Printf("guardrail check failed: %v", err)
+		return guardrail.Verdict{}
+	}
+	return verdict
+}
+
+// blockedResponse 内容被护栏屏蔽时的错误响应
+func blockedResponse(stage guardrail.Stage, verdict guardrail.Verdict) ErrorResponse {
+	return ErrorResponse{
+		Code:      http.StatusUnprocessableEntity,
+		Message:   fmt.Sprintf("Content blocked by content safety check (%s)", stage),
+		Details:   verdict.Reason,
+		ErrorCode: guardrail.ErrCodeContentBlocked,
+	}
+}
+
+// respondInvalidModel 返回模型校验失败的错误响应
+func respondInvalidModel(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, ErrorResponse{
+		Code:    400,
+		Message: "Invalid model",
+		Details: err.Error(),
+	})
+}
+
+// respondUnavailable 熔断期间返回503，并按剩余熔断时间设置 Retry-After
+func respondUnavailable(c *gin.Context, err error) {
+	var openErr *llm.CircuitOpenError
+	if errors.As(err, &openErr) && openErr.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(openErr.RetryAfter.Seconds()))))
+	}
+	c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+		Code:    http.StatusServiceUnavailable,
+		Message: "AI service temporarily unavailable",
+		Details: err.Error(),
+	})
+}
+
 // RegisterRoutes 注册路由
 func (h *Handler) RegisterRoutes(rg *gin.RouterGroup) {
 	ai := rg.Group("/ai")
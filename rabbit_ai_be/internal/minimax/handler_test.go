@@ -0,0 +1,52 @@
+package minimax
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/llm"
+)
+
+func TestHandler_CircuitBreaker(t *testing.T) {
+	server, fake := fakellm.StartTestServer(fakellm.Config{ErrorCode: ErrorInternal})
+	t.Cleanup(server.Close)
+
+	service := NewMiniMaxService(MiniMaxConfig{APIKey: "test-api-key", BaseURL: server.URL + "/v1"})
+	breaker := llm.NewCircuitBreaker(NewProvider(service), llm.BreakerConfig{
+		WindowSize:  2,
+		MinRequests: 2,
+		FailureRate: 0.5,
+		OpenTimeout: time.Minute,
+	})
+	handler := NewHandler(service, llm.DefaultCatalog())
+	handler.SetCircuitBreaker(breaker)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.RegisterRoutes(router.Group("/api/v1"))
+
+	// 上游内部错误计入熔断统计，达到阈值后直接返回503，不再调用上游
+	for i := 0; i < 2; i++ {
+		if recorder := postJSON(router, "/api/v1/ai/chat/simple", `{"message":"你好"}`); recorder.Code != http.StatusInternalServerError {
+			t.Fatalf("Expected 500 from upstream error, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+	}
+	if breaker.State() != llm.StateOpen {
+		t.Fatalf("Expected breaker to open, got %s", breaker.State())
+	}
+
+	requests := fake.RequestCount()
+	for _, path := range []string{"/api/v1/ai/chat", "/api/v1/ai/chat/simple"} {
+		recorder := postJSON(router, path, `{"message":"你好"}`)
+		if recorder.Code != http.StatusServiceUnavailable || recorder.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: expected 503 with Retry-After, got %d", path, recorder.Code)
+		}
+	}
+	if fake.RequestCount() != requests {
+		t.Errorf("Expected no upstream requests while open, got %d", fake.RequestCount()-requests)
+	}
+}
@@ -1,12 +1,20 @@
 package minimax
 
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
 // ChatCompletionRequest MiniMax聊天完成请求
 type ChatCompletionRequest struct {
 	Model             string        `json:"model"`
 	Messages          []ChatMessage `json:"messages"`
 	Stream            bool          `json:"stream,omitempty"`             // 是否流式响应
-	Temperature       float64       `json:"temperature,omitempty"`        // 温度参数，控制随机性 (0.0-2.0)
+	Temperature       float64       `json:"temperature"`                  // 温度参数，控制随机性 (0.0-2.0)，0表示确定性输出
 	ToolChoices       []ToolChoice  `json:"tool_choices,omitempty"`       // 工具选择
+	Tools             []Tool        `json:"tools,omitempty"`              // 可供模型调用的工具
 	MaxTokens         int           `json:"max_tokens,omitempty"`         // 最大token数
 	TopP              float64       `json:"top_p,omitempty"`              // 核采样参数 (0.0-1.0)
 	TopK              int           `json:"top_k,omitempty"`              // Top-K采样
@@ -25,12 +33,51 @@ type ToolChoice struct {
 	} `json:"function,omitempty"`
 }
 
+// Tool 工具定义
+type Tool struct {
+	Type     string       `json:"type"` // 目前仅支持 "function"
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction 函数工具定义
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"` // 参数的JSON Schema
+}
+
 // ChatMessage 聊天消息
 type ChatMessage struct {
-	Role      string     `json:"role"`                 // system, user, assistant, tool
-	Name      string     `json:"name"`                 // 可选字段
-	Content   string     `json:"content"`              // 消息内容
-	ToolCalls []ToolCall `json:"tool_calls,omitempty"` // 工具调用
+	Role       string        `json:"role"`                   // system, user, assistant, tool
+	Name       string        `json:"name"`                   // 可选字段
+	Content    string        `json:"content"`                // 消息内容
+	Parts      []ContentPart `json:"-"`                      // 图文内容片段，非空时以数组形式代替 Content 发送
+	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`   // 工具调用
+	ToolCallID string        `json:"tool_call_id,omitempty"` // tool消息对应的工具调用ID
+}
+
+// MarshalJSON 有图文内容片段时将 content 编码为片段数组
+func (m ChatMessage) MarshalJSON() ([]byte, error) {
+	type plain ChatMessage
+	if len(m.Parts) == 0 {
+		return json.Marshal(plain(m))
+	}
+	return json.Marshal(struct {
+		plain
+		Content []ContentPart `json:"content"`
+	}{plain(m), m.Parts})
+}
+
+// ContentPart 图文消息的内容片段
+type ContentPart struct {
+	Type     string    `json:"type"` // text/image_url
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL 图片地址，支持 data URL（data:image/png;base64,...）
+type ImageURL struct {
+	URL string `json:"url"`
 }
 
 // ToolCall 工具调用
@@ -84,8 +131,36 @@ type Usage struct {
 
 // MiniMaxError MiniMax错误码
 type MiniMaxError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code       int           `json:"code"`
+	Message    string        `json:"message"`
+	RetryAfter time.Duration `json:"-"` // 上游Retry-After响应头指定的等待时间
+}
+
+// Error 实现error接口
+func (e *MiniMaxError) Error() string {
+	return fmt.Sprintf("MiniMax API error: %d - %s", e.Code, e.Message)
+}
+
+// Failover 可重试错误或余额不足时切换到备用模型
+func (e *MiniMaxError) Failover() bool {
+	return IsRetryableCode(e.Code) || e.Code == ErrorInsufficient
+}
+
+// HTTPError 上游返回非200 HTTP状态码
+type HTTPError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // 上游Retry-After响应头指定的等待时间
+}
+
+// Error 实现error接口
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// Failover 限流、需要付费（额度用尽）与5xx错误时切换到备用模型，402不重试但切换
+func (e *HTTPError) Failover() bool {
+	return IsRetryable(e) || e.StatusCode == http.StatusPaymentRequired
 }
 
 // 错误码常量
@@ -99,6 +174,8 @@ const (
 	ErrorOutput        = 1027 // 输出内容错误
 	ErrorTokenLimit    = 1039 // Token限制
 	ErrorInvalidParams = 2013 // 参数错误
+
+	ErrorStreamRead = -1 // 流式响应中途读取失败（本地错误码，非MiniMax返回）
 )
 
 // GetErrorMessage 根据错误码获取错误信息
@@ -122,6 +199,8 @@ func GetErrorMessage(code int) string {
 		return "Token限制"
 	case ErrorInvalidParams:
 		return "参数错误"
+	case ErrorStreamRead:
+		return "流式响应中断"
 	default:
 		return "未知错误"
 	}
@@ -163,6 +242,7 @@ func (r *ChatCompletionResponse) GetFinishReason() string {
 type MiniMaxConfig struct {
 	APIKey  string
 	BaseURL string
+	Retry   RetryPolicy // 瞬时故障重试策略，零值表示不重试
 }
 
 // DefaultConfig 默认配置
@@ -170,6 +250,7 @@ func DefaultConfig(apiKey string) MiniMaxConfig {
 	return MiniMaxConfig{
 		APIKey:  apiKey,
 		BaseURL: "https://api.minimaxi.com/v1",
+		Retry:   DefaultRetryPolicy(),
 	}
 }
 
@@ -184,6 +265,22 @@ func NewChatCompletionRequest(model string, messages []ChatMessage) *ChatComplet
 	}
 }
 
+// NewSimpleChatRequest 创建只包含一条用户消息的聊天请求
+func NewSimpleChatRequest(model, userMessage string) *ChatCompletionRequest {
+	return NewChatCompletionRequest(model, []ChatMessage{
+		{
+			Role:    "system",
+			Name:    "MiniMax AI",
+			Content: "",
+		},
+		{
+			Role:    "user",
+			Name:    "用户",
+			Content: userMessage,
+		},
+	})
+}
+
 // WithStream 设置流式响应
 func (r *ChatCompletionRequest) WithStream(stream bool) *ChatCompletionRequest {
 	r.Stream = stream
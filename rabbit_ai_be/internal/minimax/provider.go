@@ -0,0 +1,213 @@
+package minimax
+
+import (
+	"context"
+
+	"rabbit_ai/internal/llm"
+)
+
+// ProviderName MiniMax提供方名称
+const ProviderName = "minimax"
+
+// SupportedModels MiniMax支持的对话模型
+var SupportedModels = []string{
+	"MiniMax-M1",
+	"MiniMax-Text-01",
+	"MiniMax-VL-01",
+}
+
+// Provider 基于MiniMaxService的llm.Provider实现
+type Provider struct {
+	service *MiniMaxService
+}
+
+// NewProvider 创建MiniMax提供方实例
+func NewProvider(service *MiniMaxService) *Provider {
+	return &Provider{
+		service: service,
+	}
+}
+
+// Name 提供方名称
+func (p *Provider) Name() string {
+	return ProviderName
+}
+
+// ChatCompletion 聊天完成
+func (p *Provider) ChatCompletion(ctx context.Context, request llm.ChatRequest) (*llm.ChatResponse, error) {
+	response, err := p.service.ChatCompletion(ctx, *toChatCompletionRequest(request))
+	if err != nil {
+		return nil, err
+	}
+
+	var message llm.Message
+	if len(response.Choices) > 0 {
+		message = fromChatMessage(response.Choices[0].Message)
+	}
+
+	return &llm.ChatResponse{
+		ID:           response.ID,
+		Model:        response.Model,
+		Message:      message,
+		FinishReason: response.GetFinishReason(),
+		Usage:        fromUsage(response.Usage),
+		Sensitive:    fromSensitive(response),
+	}, nil
+}
+
+// ChatCompletionStream 流式聊天完成
+func (p *Provider) ChatCompletionStream(ctx context.Context, request llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	responseChan, err := p.service.ChatCompletionStream(ctx, *toChatCompletionRequest(request))
+	if err != nil {
+		return nil, err
+	}
+
+	chunkChan := make(chan llm.StreamChunk, 10)
+	go func() {
+		defer close(chunkChan)
+
+		// send 发送片段，ctx结束时放弃发送并返回false
+		send := func(chunk llm.StreamChunk) bool {
+			select {
+			case chunkChan <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for response := range responseChan {
+			if !response.IsSuccess() {
+				send(llm.StreamChunk{Err: response.GetError()})
+				return
+			}
+
+			var chunk llm.StreamChunk
+			if len(response.Choices) > 0 {
+				choice := response.Choices[0]
+				if choice.Delta != nil {
+					chunk.Content = choice.Delta.Content
+				}
+				chunk.FinishReason = choice.FinishReason
+			}
+			if response.Usage.TotalTokens > 0 {
+				usage := fromUsage(response.Usage)
+				chunk.Usage = &usage
+			}
+			chunk.Sensitive = fromSensitive(&response)
+			if !send(chunk) {
+				return
+			}
+		}
+	}()
+
+	return chunkChan, nil
+}
+
+// ListModels 获取可用模型列表
+func (p *Provider) ListModels(ctx context.Context) ([]llm.ModelInfo, error) {
+	models := make([]llm.ModelInfo, 0, len(SupportedModels))
+	for _, id := range SupportedModels {
+		models = append(models, llm.ModelInfo{
+			ID:       id,
+			Provider: ProviderName,
+		})
+	}
+	return models, nil
+}
+
+// toChatCompletionRequest 将通用请求转换为MiniMax请求
+func toChatCompletionRequest(request llm.ChatRequest) *ChatCompletionRequest {
+	messages := make([]ChatMessage, 0, len(request.Messages))
+	for _, msg := range request.Messages {
+		message := ChatMessage{
+			Role:       msg.Role,
+			Name:       msg.Name,
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, part := range msg.Parts {
+			if part.Type == llm.PartImageURL {
+				message.Parts = append(message.Parts, ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: part.ImageURL}})
+			} else {
+				message.Parts = append(message.Parts, ContentPart{Type: "text", Text: part.Text})
+			}
+		}
+		for _, call := range msg.ToolCalls {
+			toolCall := ToolCall{ID: call.ID, Type: "function"}
+			toolCall.Function.Name = call.Name
+			toolCall.Function.Arguments = call.Arguments
+			message.ToolCalls = append(message.ToolCalls, toolCall)
+		}
+		messages = append(messages, message)
+	}
+
+	// 未指定的参数沿用NewChatCompletionRequest的默认值
+	req := NewChatCompletionRequest(request.Model, messages)
+	if request.Temperature > 0 {
+		req.WithTemperature(request.Temperature)
+	}
+	if request.TopP > 0 {
+		req.WithTopP(request.TopP)
+	}
+	if request.MaxTokens > 0 {
+		req.WithMaxTokens(request.MaxTokens)
+	}
+	if len(request.Stop) > 0 {
+		req.WithStop(request.Stop)
+	}
+	if request.User != "" {
+		req.WithUser(request.User)
+	}
+	for _, tool := range request.Tools {
+		req.Tools = append(req.Tools, Tool{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return req
+}
+
+// fromChatMessage 将MiniMax消息转换为通用消息
+func fromChatMessage(msg ChatMessage) llm.Message {
+	message := llm.Message{
+		Role:       msg.Role,
+		Name:       msg.Name,
+		Content:    msg.Content,
+		ToolCallID: msg.ToolCallID,
+	}
+	for _, call := range msg.ToolCalls {
+		message.ToolCalls = append(message.ToolCalls, llm.ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return message
+}
+
+// fromUsage 将MiniMax使用统计转换为通用使用统计
+func fromUsage(usage Usage) llm.Usage {
+	return llm.Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+}
+
+// fromSensitive 提取MiniMax的敏感内容标记，均未命中时返回nil
+func fromSensitive(response *ChatCompletionResponse) *llm.SensitiveFlags {
+	if !response.InputSensitive && !response.OutputSensitive {
+		return nil
+	}
+	return &llm.SensitiveFlags{
+		Input:      response.InputSensitive,
+		InputType:  response.InputSensitiveType,
+		Output:     response.OutputSensitive,
+		OutputType: response.OutputSensitiveType,
+	}
+}
@@ -0,0 +1,102 @@
+package minimax
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 响应缓存参数
+const (
+	DefaultResponseCacheTTL = time.Hour
+	ResponseCacheHeader     = "X-Cache" // 可缓存请求的响应头：HIT 命中缓存，MISS 未命中
+)
+
+// ResponseStore 响应缓存存储，由 cache.ConversationCache 实现
+type ResponseStore interface {
+	GetResponse(ctx context.Context, key string) ([]byte, error)
+	SetResponse(ctx context.Context, key string, data []byte, ttl time.Duration) error
+}
+
+// ResponseCachePolicy 响应缓存策略
+//
+// 只缓存温度为0的非流式请求：相同的请求（忽略 user 字段与消息首尾空白）直接返回缓存的回复，
+// 命中时使用统计为0。
+type ResponseCachePolicy struct {
+	Enabled bool
+	TTL     time.Duration
+	Models  []string // 启用缓存的模型，为空时所有模型均启用
+}
+
+// SetResponseCache 设置响应缓存，未设置时不缓存
+func (h *Handler) SetResponseCache(store ResponseStore, policy ResponseCachePolicy) {
+	if policy.TTL <= 0 {
+		policy.TTL = DefaultResponseCacheTTL
+	}
+	h.responseStore = store
+	h.responseCache = policy
+}
+
+// responseCacheKey 可缓存请求的缓存键：模型名 + 规范化请求的SHA-256
+func (h *Handler) responseCacheKey(request ChatCompletionRequest) (string, bool) {
+	policy := h.responseCache
+	if !policy.Enabled || h.responseStore == nil || request.Stream || request.Temperature != 0 {
+		return "", false
+	}
+	if len(policy.Models) > 0 && !slices.Contains(policy.Models, request.Model) {
+		return "", false
+	}
+
+	// 规范化：user 字段不影响回复，消息内容去掉首尾空白
+	request.User = ""
+	request.Messages = slices.Clone(request.Messages)
+	for i := range request.Messages {
+		request.Messages[i].Content = strings.TrimSpace(request.Messages[i].Content)
+	}
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("%s:%s", request.Model, hex.EncodeToString(sum[:])), true
+}
+
+// cachedResponse 获取缓存的响应，未命中或缓存出错时返回nil；命中时使用统计清零
+func (h *Handler) cachedResponse(ctx context.Context, key string) *ChatCompletionResponse {
+	data, err := h.responseStore.GetResponse(ctx, key)
+	if err != nil {
+		fmt.Printf("failed to get cached response: %v\n", err)
+		return nil
+	}
+	if data == nil {
+		return nil
+	}
+
+	var response ChatCompletionResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		fmt.Printf("failed to unmarshal cached response: %v\n", err)
+		return nil
+	}
+	response.Usage = Usage{}
+	return &response
+}
+
+// storeResponse 缓存成功且未被标记为敏感的响应
+func (h *Handler) storeResponse(ctx context.Context, key string, response *ChatCompletionResponse) {
+	if !response.IsSuccess() || response.GetContent() == "" || response.InputSensitive || response.OutputSensitive {
+		return
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	if err := h.responseStore.SetResponse(ctx, key, data, h.responseCache.TTL); err != nil {
+		fmt.Printf("failed to cache response: %v\n", err)
+	}
+}
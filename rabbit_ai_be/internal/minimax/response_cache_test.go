@@ -0,0 +1,121 @@
+package minimax
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/llm"
+)
+
+// memoryResponseStore 内存响应缓存
+type memoryResponseStore struct {
+	data map[string][]byte
+	ttl  time.Duration
+}
+
+func (s *memoryResponseStore) GetResponse(ctx context.Context, key string) ([]byte, error) {
+	return s.data[key], nil
+}
+
+func (s *memoryResponseStore) SetResponse(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	s.data[key] = data
+	s.ttl = ttl
+	return nil
+}
+
+// newCachedHandler 创建带响应缓存的处理器与路由
+func newCachedHandler(t *testing.T, config fakellm.Config, policy ResponseCachePolicy) (*gin.Engine, *memoryResponseStore, *fakellm.Server) {
+	server, fake := fakellm.StartTestServer(config)
+	t.Cleanup(server.Close)
+
+	service := NewMiniMaxService(MiniMaxConfig{APIKey: "test-api-key", BaseURL: server.URL + "/v1"})
+	handler := NewHandler(service, llm.DefaultCatalog())
+	store := &memoryResponseStore{data: map[string][]byte{}}
+	handler.SetResponseCache(store, policy)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.RegisterRoutes(router.Group("/api/v1"))
+	return router, store, fake
+}
+
+// postJSON 发送JSON请求并返回响应
+func postJSON(router *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestHandler_ResponseCache(t *testing.T) {
+	router, store, fake := newCachedHandler(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: "缓存的回复"}, {Content: "第二次调用"}},
+	}, ResponseCachePolicy{Enabled: true})
+
+	first := postJSON(router, "/api/v1/ai/chat", `{"message":"你好","model":"MiniMax-M1","temperature":0,"user":"a"}`)
+	if first.Code != http.StatusOK || first.Header().Get(ResponseCacheHeader) != "MISS" {
+		t.Fatalf("Expected cache miss, got %d %q: %s", first.Code, first.Header().Get(ResponseCacheHeader), first.Body.String())
+	}
+	if store.ttl != DefaultResponseCacheTTL || len(store.data) != 1 {
+		t.Errorf("Expected response stored with default TTL, got %d entries, ttl %v", len(store.data), store.ttl)
+	}
+
+	// 规范化后相同的请求命中缓存：忽略 user 与首尾空白
+	second := postJSON(router, "/api/v1/ai/chat", `{"message":" 你好\n","model":"MiniMax-M1","temperature":0,"user":"b"}`)
+	if second.Header().Get(ResponseCacheHeader) != "HIT" {
+		t.Fatalf("Expected cache hit, got %q", second.Header().Get(ResponseCacheHeader))
+	}
+	var body struct {
+		Data ChatResponse `json:"data"`
+	}
+	if err := json.Unmarshal(second.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if body.Data.Content != "缓存的回复" || body.Data.Usage == nil || body.Data.Usage.TotalTokens != 0 {
+		t.Errorf("Expected cached content with zero usage, got %+v", body.Data)
+	}
+	if fake.RequestCount() != 1 {
+		t.Errorf("Expected 1 upstream request, got %d", fake.RequestCount())
+	}
+
+	// 简单聊天接口的相同请求同样命中
+	simple := postJSON(router, "/api/v1/ai/chat/simple", `{"message":"你好","model":"MiniMax-M1","temperature":0}`)
+	if simple.Header().Get(ResponseCacheHeader) != "HIT" || fake.RequestCount() != 1 {
+		t.Errorf("Expected simple chat cache hit, got %q with %d requests", simple.Header().Get(ResponseCacheHeader), fake.RequestCount())
+	}
+}
+
+func TestHandler_ResponseCacheSkipped(t *testing.T) {
+	router, store, fake := newCachedHandler(t, fakellm.Config{}, ResponseCachePolicy{Enabled: true, Models: []string{"MiniMax-Text-01"}})
+
+	requests := map[string]string{
+		"default temperature": `{"message":"你好","model":"MiniMax-Text-01"}`,
+		"non-zero":            `{"message":"你好","model":"MiniMax-Text-01","temperature":0.5}`,
+		"model not enabled":   `{"message":"你好","model":"MiniMax-M1","temperature":0}`,
+	}
+	for name, body := range requests {
+		recorder := postJSON(router, "/api/v1/ai/chat", body)
+		if recorder.Code != http.StatusOK || recorder.Header().Get(ResponseCacheHeader) != "" {
+			t.Errorf("%s: expected uncached request, got %d %q", name, recorder.Code, recorder.Header().Get(ResponseCacheHeader))
+		}
+	}
+	if len(store.data) != 0 || fake.RequestCount() != len(requests) {
+		t.Errorf("Expected nothing cached, got %d entries and %d requests", len(store.data), fake.RequestCount())
+	}
+
+	// 上游错误不缓存
+	router, store, _ = newCachedHandler(t, fakellm.Config{ErrorCode: fakellm.ErrorInsufficient}, ResponseCachePolicy{Enabled: true})
+	postJSON(router, "/api/v1/ai/chat/simple", `{"message":"你好","temperature":0}`)
+	if len(store.data) != 0 {
+		t.Errorf("Expected failed response not cached, got %d entries", len(store.data))
+	}
+}
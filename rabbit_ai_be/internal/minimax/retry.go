@@ -0,0 +1,160 @@
+package minimax
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy 瞬时故障重试策略
+type RetryPolicy struct {
+	MaxAttempts    int           // 最大尝试次数（含首次请求），小于等于1表示不重试
+	InitialBackoff time.Duration // 首次重试前的基础等待时间
+	MaxBackoff     time.Duration // 单次等待时间上限
+	Multiplier     float64       // 退避倍数
+	Budget         time.Duration // 单次调用（含所有重试与等待）的总时间预算，0表示不限制
+}
+
+// DefaultRetryPolicy 默认重试策略
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Budget:         20 * time.Second,
+	}
+}
+
+// backoff 计算第attempt次失败后的等待时间（指数退避 + 抖动）
+//
+// 等待时间在 [d/2, d) 之间随机，d 为按倍数增长并受 MaxBackoff 限制的基础时间，
+// 避免大量请求在同一时刻集中重试。
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int64N(half+1))
+}
+
+// IsRetryableCode 判断MiniMax错误码是否为可重试的瞬时错误
+//
+// 流式响应中途读取失败与非流式请求的网络层错误同属上游故障，计入熔断统计；
+// 已开始输出的流不会重放，因此该错误码实际不会触发重试。
+func IsRetryableCode(code int) bool {
+	switch code {
+	case ErrorTimeout, ErrorRateLimit, ErrorInternal, ErrorStreamRead:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsRetryable 判断错误是否可重试
+//
+// 可重试：限流/超时/内部错误码、HTTP 429 与 5xx、网络层错误；
+// 调用方取消或超时（ctx结束）的错误不重试。
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *MiniMaxError
+	if errors.As(err, &apiErr) {
+		return IsRetryableCode(apiErr.Code)
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		default:
+			return false
+		}
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryAfter 获取错误中携带的Retry-After等待时间
+func retryAfter(err error) time.Duration {
+	var apiErr *MiniMaxError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.RetryAfter
+	}
+	return 0
+}
+
+// parseRetryAfter 解析Retry-After响应头（秒数或HTTP日期）
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// withRetry 按重试策略执行fn，仅对可重试错误重试
+//
+// 存在Retry-After时以其为准；下一次等待会超出调用预算时直接返回最后一次的错误。
+func (s *MiniMaxService) withRetry(ctx context.Context, fn func() error) error {
+	policy := s.config.Retry
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= policy.MaxAttempts || !IsRetryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		wait := policy.backoff(attempt)
+		if d := retryAfter(err); d > 0 {
+			wait = d
+		}
+		if policy.Budget > 0 && time.Since(start)+wait > policy.Budget {
+			return err
+		}
+
+		log.Printf("MiniMax request failed (attempt %d/%d), retrying in %v: %v", attempt, policy.MaxAttempts, wait, err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package minimax
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/llm"
+)
+
+// newRetryService 创建带快速重试策略的服务实例
+func newRetryService(t *testing.T, config fakellm.Config, policy RetryPolicy) (*MiniMaxService, *fakellm.Server) {
+	server, fake := fakellm.StartTestServer(config)
+	t.Cleanup(server.Close)
+
+	return NewMiniMaxService(MiniMaxConfig{
+		APIKey:  "test-api-key",
+		BaseURL: server.URL + "/v1",
+		Retry:   policy,
+	}), fake
+}
+
+func fastRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Multiplier:     2,
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limit", &MiniMaxError{Code: ErrorRateLimit}, true},
+		{"timeout", &MiniMaxError{Code: ErrorTimeout}, true},
+		{"internal", fmt.Errorf("wrapped: %w", &MiniMaxError{Code: ErrorInternal}), true},
+		{"auth failed", &MiniMaxError{Code: ErrorAuthFailed}, false},
+		{"insufficient balance", &MiniMaxError{Code: ErrorInsufficient}, false},
+		{"token limit", &MiniMaxError{Code: ErrorTokenLimit}, false},
+		{"http 429", &HTTPError{StatusCode: http.StatusTooManyRequests}, true},
+		{"http 503", &HTTPError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"http 400", &HTTPError{StatusCode: http.StatusBadRequest}, false},
+		{"http 402", &HTTPError{StatusCode: http.StatusPaymentRequired}, false},
+		{"canceled", context.Canceled, false},
+		{"plain error", errors.New("boom"), false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: expected IsRetryable %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestShouldFailover(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limit", &MiniMaxError{Code: ErrorRateLimit}, true},
+		{"insufficient balance", &MiniMaxError{Code: ErrorInsufficient}, true},
+		{"auth failed", &MiniMaxError{Code: ErrorAuthFailed}, false},
+		{"http 402", fmt.Errorf("wrapped: %w", &HTTPError{StatusCode: http.StatusPaymentRequired}), true},
+		{"http 429", &HTTPError{StatusCode: http.StatusTooManyRequests}, true},
+		{"http 502", &HTTPError{StatusCode: http.StatusBadGateway}, true},
+		{"http 400", &HTTPError{StatusCode: http.StatusBadRequest}, false},
+	}
+
+	for _, tt := range tests {
+		if got := llm.ShouldFailover(tt.err); got != tt.want {
+			t.Errorf("%s: expected ShouldFailover %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestProvider_StreamReadErrorIsUpstreamFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"你\"}}],\"base_resp\":{\"status_code\":0}}\n\n")
+		w.(http.Flusher).Flush()
+
+		// 输出首个片段后上游连接中断
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	service := NewMiniMaxService(MiniMaxConfig{APIKey: "test-api-key", BaseURL: server.URL})
+	chunkChan, err := NewProvider(service).ChatCompletionStream(context.Background(), llm.ChatRequest{
+		Model:    "MiniMax-M1",
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "你好"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to start stream: %v", err)
+	}
+
+	var streamErr error
+	for chunk := range chunkChan {
+		if chunk.Err != nil {
+			streamErr = chunk.Err
+		}
+	}
+	if streamErr == nil {
+		t.Fatal("Expected stream read error")
+	}
+	// 与非流式请求的网络错误一致，计入熔断统计
+	if !llm.IsUpstreamFailure(streamErr) {
+		t.Errorf("Expected stream read error to count as upstream failure, got %v", streamErr)
+	}
+}
+
+func TestProvider_PaymentRequiredFailsOverWithoutRetry(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusPaymentRequired)
+		fmt.Fprint(w, `{"error":"quota exceeded"}`)
+	}))
+	defer server.Close()
+
+	service := NewMiniMaxService(MiniMaxConfig{
+		APIKey:  "test-api-key",
+		BaseURL: server.URL,
+		Retry:   fastRetryPolicy(),
+	})
+	_, err := NewProvider(service).ChatCompletion(context.Background(), llm.ChatRequest{
+		Model:    "MiniMax-M1",
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "你好"}},
+	})
+	if !llm.ShouldFailover(err) {
+		t.Errorf("Expected HTTP 402 to fail over, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected no retry for HTTP 402, got %d requests", requests)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("3"); d != 3*time.Second {
+		t.Errorf("Expected 3s, got %v", d)
+	}
+	if d := parseRetryAfter(""); d != 0 {
+		t.Errorf("Expected 0 for empty header, got %v", d)
+	}
+	if d := parseRetryAfter("invalid"); d != 0 {
+		t.Errorf("Expected 0 for invalid header, got %v", d)
+	}
+	future := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	if d := parseRetryAfter(future); d <= 0 || d > 10*time.Second {
+		t.Errorf("Expected (0, 10s] for HTTP date, got %v", d)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond, Multiplier: 2}
+
+	for attempt, max := range map[int]time.Duration{1: 100, 2: 200, 3: 300, 4: 300} {
+		max *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			d := policy.backoff(attempt)
+			if d < max/2 || d > max {
+				t.Fatalf("attempt %d: backoff %v out of range [%v, %v]", attempt, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestMiniMaxService_ChatCompletionRetry(t *testing.T) {
+	service, fake := newRetryService(t, fakellm.Config{
+		Replies: []fakellm.Reply{
+			{ErrorCode: fakellm.ErrorRateLimit},
+			{StatusCode: http.StatusServiceUnavailable},
+			{Content: "重试成功"},
+		},
+	}, fastRetryPolicy())
+
+	content, err := service.SimpleChat(context.Background(), "你好")
+	if err != nil {
+		t.Fatalf("Expected retry to succeed, got: %v", err)
+	}
+	if content != "重试成功" {
+		t.Errorf("Expected content '重试成功', got '%s'", content)
+	}
+	if fake.RequestCount() != 3 {
+		t.Errorf("Expected 3 requests, got %d", fake.RequestCount())
+	}
+}
+
+func TestMiniMaxService_ChatCompletionRetryExhausted(t *testing.T) {
+	service, fake := newRetryService(t, fakellm.Config{ErrorCode: fakellm.ErrorInternal}, fastRetryPolicy())
+
+	request := NewChatCompletionRequest("MiniMax-M1", []ChatMessage{{Role: "user", Content: "你好"}})
+	response, err := service.ChatCompletion(context.Background(), *request)
+
+	var apiErr *MiniMaxError
+	if !errors.As(err, &apiErr) || apiErr.Code != ErrorInternal {
+		t.Fatalf("Expected MiniMaxError %d, got %v", ErrorInternal, err)
+	}
+	if response == nil || response.BaseResp.StatusCode != ErrorInternal {
+		t.Errorf("Expected response with base_resp %d, got %+v", ErrorInternal, response)
+	}
+	if fake.RequestCount() != 3 {
+		t.Errorf("Expected 3 requests, got %d", fake.RequestCount())
+	}
+}
+
+func TestMiniMaxService_ChatCompletionNoRetryOnAuthFailure(t *testing.T) {
+	service, fake := newRetryService(t, fakellm.Config{ErrorCode: fakellm.ErrorAuthFailed}, fastRetryPolicy())
+
+	if _, err := service.SimpleChat(context.Background(), "你好"); err == nil {
+		t.Fatal("Expected auth failure error")
+	}
+	if fake.RequestCount() != 1 {
+		t.Errorf("Expected 1 request for non-retryable error, got %d", fake.RequestCount())
+	}
+}
+
+func TestMiniMaxService_ChatCompletionRetryAfterExceedsBudget(t *testing.T) {
+	policy := fastRetryPolicy()
+	policy.Budget = time.Second
+
+	service, fake := newRetryService(t, fakellm.Config{
+		Replies: []fakellm.Reply{
+			{StatusCode: http.StatusTooManyRequests, RetryAfter: 10 * time.Second},
+			{Content: "不应到达"},
+		},
+	}, policy)
+
+	start := time.Now()
+	_, err := service.SimpleChat(context.Background(), "你好")
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected HTTP 429 error, got %v", err)
+	}
+	if httpErr.RetryAfter != 10*time.Second {
+		t.Errorf("Expected Retry-After 10s, got %v", httpErr.RetryAfter)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected immediate failure when Retry-After exceeds budget, took %v", elapsed)
+	}
+	if fake.RequestCount() != 1 {
+		t.Errorf("Expected 1 request, got %d", fake.RequestCount())
+	}
+}
+
+func TestMiniMaxService_ChatCompletionStreamRetryBeforeFirstToken(t *testing.T) {
+	service, fake := newRetryService(t, fakellm.Config{
+		Replies: []fakellm.Reply{
+			{ErrorCode: fakellm.ErrorRateLimit},
+			{Content: "流式重试成功"},
+		},
+	}, fastRetryPolicy())
+
+	request := NewChatCompletionRequest("MiniMax-M1", []ChatMessage{{Role: "user", Content: "你好"}})
+	responseChan, err := service.ChatCompletionStream(context.Background(), *request)
+	if err != nil {
+		t.Fatalf("Failed to start stream: %v", err)
+	}
+
+	var content string
+	for response := range responseChan {
+		if !response.IsSuccess() {
+			t.Fatalf("Unexpected stream error: %+v", response.BaseResp)
+		}
+		if len(response.Choices) > 0 && response.Choices[0].Delta != nil {
+			content += response.Choices[0].Delta.Content
+		}
+	}
+
+	if content != "流式重试成功" {
+		t.Errorf("Expected content '流式重试成功', got '%s'", content)
+	}
+	if fake.RequestCount() != 2 {
+		t.Errorf("Expected 2 requests, got %d", fake.RequestCount())
+	}
+}
+
+func TestMiniMaxService_ChatCompletionStreamRetryExhausted(t *testing.T) {
+	service, fake := newRetryService(t, fakellm.Config{ErrorCode: fakellm.ErrorRateLimit}, fastRetryPolicy())
+
+	request := NewChatCompletionRequest("MiniMax-M1", []ChatMessage{{Role: "user", Content: "你好"}})
+	responseChan, err := service.ChatCompletionStream(context.Background(), *request)
+	if err != nil {
+		t.Fatalf("Expected error delivered through channel, got: %v", err)
+	}
+
+	var responses []ChatCompletionResponse
+	for response := range responseChan {
+		responses = append(responses, response)
+	}
+	if len(responses) != 1 || responses[0].BaseResp.StatusCode != ErrorRateLimit {
+		t.Errorf("Expected single rate limit response, got %+v", responses)
+	}
+	if fake.RequestCount() != 3 {
+		t.Errorf("Expected 3 requests, got %d", fake.RequestCount())
+	}
+}
@@ -3,17 +3,22 @@ package minimax
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"time"
+	"strings"
+
+	"rabbit_ai/internal/llm"
 )
 
 // MiniMaxService MiniMax AI服务
 type MiniMaxService struct {
-	config MiniMaxConfig
-	client *http.Client
+	config       MiniMaxConfig
+	client       *http.Client
+	streamClient *http.Client // 流式请求专用，不设总超时
 }
 
 // NewMiniMaxService 创建MiniMax服务实例
@@ -21,36 +26,30 @@ func NewMiniMaxService(config MiniMaxConfig) *MiniMaxService {
 	return &MiniMaxService{
 		config: config,
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: llm.RequestTimeout,
 		},
+		streamClient: llm.NewStreamClient(),
 	}
 }
 
-// ChatCompletion 聊天完成
-func (s *MiniMaxService) ChatCompletion(request ChatCompletionRequest) (*ChatCompletionResponse, error) {
-	// 构建请求URL
-	url := fmt.Sprintf("%s/text/chatcompletion_v2", s.config.BaseURL)
-
-	// 序列化请求体
-	requestBody, err := json.Marshal(request)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// 创建HTTP请求
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// 设置请求头
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.config.APIKey))
-	req.Header.Set("Content-Type", "application/json")
+// ChatCompletion 聊天完成，ctx取消或超时时立即中止上游请求
+//
+// 限流、超时、服务内部错误等瞬时故障按 config.Retry 策略自动重试。
+func (s *MiniMaxService) ChatCompletion(ctx context.Context, request ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	var response *ChatCompletionResponse
+	err := s.withRetry(ctx, func() error {
+		var err error
+		response, err = s.chatCompletionOnce(ctx, request)
+		return err
+	})
+	return response, err
+}
 
-	// 发送请求
-	resp, err := s.client.Do(req)
+// chatCompletionOnce 发送一次聊天完成请求
+func (s *MiniMaxService) chatCompletionOnce(ctx context.Context, request ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	resp, err := s.doRequest(ctx, request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -62,7 +61,11 @@ func (s *MiniMaxService) ChatCompletion(request ChatCompletionRequest) (*ChatCom
 
 	// 检查HTTP状态码
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, &HTTPError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
 	// 解析响应
@@ -73,49 +76,39 @@ func (s *MiniMaxService) ChatCompletion(request ChatCompletionRequest) (*ChatCom
 
 	// 检查MiniMax API错误
 	if !response.IsSuccess() {
-		err := response.GetError()
-		return &response, fmt.Errorf("MiniMax API error: %d - %s", err.Code, err.Message)
+		apiErr := response.GetError()
+		apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		return &response, apiErr
 	}
 
 	return &response, nil
 }
 
 // ChatCompletionStream 流式聊天完成
-func (s *MiniMaxService) ChatCompletionStream(request ChatCompletionRequest) (<-chan ChatCompletionResponse, error) {
+//
+// ctx取消或超时时会中止上游连接，读取goroutine随之退出并关闭通道，
+// 调用方停止消费前应取消ctx，避免goroutine阻塞。
+// 瞬时故障仅在收到第一个数据块之前重试，已开始输出的流不会重放。
+func (s *MiniMaxService) ChatCompletionStream(ctx context.Context, request ChatCompletionRequest) (<-chan ChatCompletionResponse, error) {
 	// 确保启用流式响应
 	request.Stream = true
 
-	// 构建请求URL
-	url := fmt.Sprintf("%s/text/chatcompletion_v2", s.config.BaseURL)
-
-	// 序列化请求体
-	requestBody, err := json.Marshal(request)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// 创建HTTP请求
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// 设置请求头
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.config.APIKey))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "text/event-stream")
-
-	// 发送请求
-	resp, err := s.client.Do(req)
+	var stream *openedStream
+	err := s.withRetry(ctx, func() error {
+		var err error
+		stream, err = s.openStream(ctx, request)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-
-	// 检查HTTP状态码
-	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		// 重试耗尽的MiniMax错误仍按原方式通过通道返回，便于调用方读取错误码
+		var apiErr *MiniMaxError
+		if stream == nil || stream.first == nil || !errors.As(err, &apiErr) {
+			return nil, err
+		}
+		responseChan := make(chan ChatCompletionResponse, 1)
+		responseChan <- *stream.first
+		close(responseChan)
+		return responseChan, nil
 	}
 
 	// 创建响应通道
@@ -123,41 +116,55 @@ func (s *MiniMaxService) ChatCompletionStream(request ChatCompletionRequest) (<-
 
 	// 在goroutine中处理流式响应
 	go func() {
-		defer resp.Body.Close()
+		defer stream.body.Close()
 		defer close(responseChan)
 
-		reader := bufio.NewReader(resp.Body)
+		// send 发送响应，ctx结束时放弃发送并返回false
+		send := func(response ChatCompletionResponse) bool {
+			select {
+			case responseChan <- response:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if stream.first != nil && !send(*stream.first) {
+			return
+		}
+		if stream.done {
+			return
+		}
 
 		for {
-			line, err := reader.ReadString('\n')
+			line, err := stream.reader.ReadString('\n')
 			if err != nil {
 				if err == io.EOF {
 					break
 				}
+				// ctx取消导致的读取错误无需再通知调用方
+				if ctx.Err() != nil {
+					return
+				}
 				// 发送错误响应
-				responseChan <- ChatCompletionResponse{
+				send(ChatCompletionResponse{
 					BaseResp: BaseResponse{
-						StatusCode: -1,
+						StatusCode: ErrorStreamRead,
 						StatusMsg:  fmt.Sprintf("Stream read error: %v", err),
 					},
-				}
+				})
 				return
 			}
 
-			// 处理SSE格式的数据
-			if len(line) > 6 && line[:6] == "data: " {
-				data := line[6:]
-				if data == "[DONE]" {
-					break
-				}
-
-				// 解析JSON响应
-				var response ChatCompletionResponse
-				if err := json.Unmarshal([]byte(data), &response); err != nil {
-					continue // 跳过无效的JSON
-				}
-
-				responseChan <- response
+			response, done := parseStreamLine(line)
+			if done {
+				break
+			}
+			if response == nil {
+				continue
+			}
+			if !send(*response) {
+				return
 			}
 		}
 	}()
@@ -165,46 +172,144 @@ func (s *MiniMaxService) ChatCompletionStream(request ChatCompletionRequest) (<-
 	return responseChan, nil
 }
 
-// SimpleChat 简单聊天（便捷方法）
-func (s *MiniMaxService) SimpleChat(userMessage string) (string, error) {
-	request := NewChatCompletionRequest("MiniMax-M1", []ChatMessage{
-		{
-			Role:    "system",
-			Name:    "MiniMax AI",
-			Content: "",
-		},
-		{
-			Role:    "user",
-			Name:    "用户",
-			Content: userMessage,
-		},
-	})
+// openedStream 已建立并读取到首个数据块的流式连接
+type openedStream struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+	first  *ChatCompletionResponse // 首个数据块，流为空时为nil
+	done   bool                    // 首个数据块之前流已结束
+}
 
-	response, err := s.ChatCompletion(*request)
+// openStream 建立流式连接并读取首个数据块
+//
+// 首个数据块为MiniMax错误时关闭连接并返回错误（同时返回该数据块），以便在输出前重试。
+func (s *MiniMaxService) openStream(ctx context.Context, request ChatCompletionRequest) (*openedStream, error) {
+	resp, err := s.doRequest(ctx, request)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// 返回第一个选择的回复内容
-	return response.GetContent(), nil
+	// 检查HTTP状态码
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, &HTTPError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	stream := &openedStream{
+		body:   resp.Body,
+		reader: bufio.NewReader(resp.Body),
+	}
+	for {
+		line, err := stream.reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				stream.done = true
+				return stream, nil
+			}
+			resp.Body.Close()
+			return nil, fmt.Errorf("stream read error: %w", err)
+		}
+
+		response, done := parseStreamLine(line)
+		if done {
+			stream.done = true
+			return stream, nil
+		}
+		if response == nil {
+			continue
+		}
+
+		stream.first = response
+		if !response.IsSuccess() {
+			resp.Body.Close()
+			apiErr := response.GetError()
+			apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+			return stream, apiErr
+		}
+		return stream, nil
+	}
+}
+
+// doRequest 发送聊天完成HTTP请求
+func (s *MiniMaxService) doRequest(ctx context.Context, request ChatCompletionRequest) (*http.Response, error) {
+	// 构建请求URL
+	url := fmt.Sprintf("%s/text/chatcompletion_v2", s.config.BaseURL)
+
+	// 序列化请求体
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// 创建HTTP请求
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// 设置请求头
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.config.APIKey))
+	req.Header.Set("Content-Type", "application/json")
+	if request.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	// 发送请求，流式请求的响应体读取时间不受总超时限制
+	client := s.client
+	if request.Stream {
+		client = s.streamClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return resp, nil
+}
+
+// parseStreamLine 解析SSE数据行，非数据行或无效JSON返回nil；done表示收到结束标记
+func parseStreamLine(line string) (response *ChatCompletionResponse, done bool) {
+	if !strings.HasPrefix(line, "data:") {
+		return nil, false
+	}
+	data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+	if data == "[DONE]" {
+		return nil, true
+	}
+
+	// 解析JSON响应
+	var r ChatCompletionResponse
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		return nil, false // 跳过无效的JSON
+	}
+	return &r, false
+}
+
+// SimpleChat 简单聊天（便捷方法）
+func (s *MiniMaxService) SimpleChat(ctx context.Context, userMessage string) (string, error) {
+	return s.SimpleChatWithModel(ctx, "MiniMax-M1", userMessage, 0, 0)
 }
 
 // SimpleChatWithParams 带参数的简单聊天
-func (s *MiniMaxService) SimpleChatWithParams(userMessage string, temperature float64, maxTokens int) (string, error) {
-	request := NewChatCompletionRequest("MiniMax-M1", []ChatMessage{
-		{
-			Role:    "system",
-			Name:    "MiniMax AI",
-			Content: "",
-		},
-		{
-			Role:    "user",
-			Name:    "用户",
-			Content: userMessage,
-		},
-	}).WithTemperature(temperature).WithMaxTokens(maxTokens)
+func (s *MiniMaxService) SimpleChatWithParams(ctx context.Context, userMessage string, temperature float64, maxTokens int) (string, error) {
+	return s.SimpleChatWithModel(ctx, "MiniMax-M1", userMessage, temperature, maxTokens)
+}
+
+// SimpleChatWithModel 指定模型的简单聊天，temperature、maxTokens 为0时使用默认值
+func (s *MiniMaxService) SimpleChatWithModel(ctx context.Context, model, userMessage string, temperature float64, maxTokens int) (string, error) {
+	request := NewSimpleChatRequest(model, userMessage)
+	if temperature > 0 {
+		request.WithTemperature(temperature)
+	}
+	if maxTokens > 0 {
+		request.WithMaxTokens(maxTokens)
+	}
 
-	response, err := s.ChatCompletion(*request)
+	response, err := s.ChatCompletion(ctx, *request)
 	if err != nil {
 		return "", err
 	}
@@ -216,8 +321,7 @@ func (s *MiniMaxService) SimpleChatWithParams(userMessage string, temperature fl
 // GetResponseContent 获取响应内容
 func (s *MiniMaxService) GetResponseContent(response *ChatCompletionResponse) (string, error) {
 	if !response.IsSuccess() {
-		err := response.GetError()
-		return "", fmt.Errorf("MiniMax API error: %d - %s", err.Code, err.Message)
+		return "", response.GetError()
 	}
 
 	if len(response.Choices) == 0 {
@@ -0,0 +1,65 @@
+package minimax
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"rabbit_ai/internal/fakellm"
+)
+
+const answerFormat = `"response_format":{"type":"json_schema","schema":{"type":"object","properties":{"answer":{"type":"integer"}},"required":["answer"]},"max_retries":1}`
+
+func TestHandler_StructuredChat(t *testing.T) {
+	router, store, fake := newCachedHandler(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: `{"answer":"四"}`}, {Content: "```json\n{\"answer\": 4}\n```"}},
+	}, ResponseCachePolicy{Enabled: true})
+
+	recorder := postJSON(router, "/api/v1/ai/chat", `{"message":"2+2=?","temperature":0,`+answerFormat+`}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var body struct {
+		Data ChatResponse `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if string(body.Data.Output) != `{"answer":4}` || body.Data.Attempts != 2 {
+		t.Errorf("Expected parsed output after one retry, got %s in %d attempts", body.Data.Output, body.Data.Attempts)
+	}
+	if fake.RequestCount() != 2 || len(store.data) != 0 {
+		t.Errorf("Expected 2 uncached upstream requests, got %d requests and %d cache entries", fake.RequestCount(), len(store.data))
+	}
+}
+
+func TestHandler_StructuredChatFailure(t *testing.T) {
+	router, _, fake := newCachedHandler(t, fakellm.Config{
+		Replies: []fakellm.Reply{{Content: "不是JSON"}, {Content: `{"answer":"四"}`}},
+	}, ResponseCachePolicy{})
+
+	recorder := postJSON(router, "/api/v1/ai/chat", `{"message":"2+2=?",`+answerFormat+`}`)
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var body struct {
+		ErrorCode string `json:"error_code"`
+		Attempts  int    `json:"attempts"`
+		Raw       string `json:"raw"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if body.ErrorCode != "INVALID_STRUCTURED_OUTPUT" || body.Attempts != 2 || body.Raw != `{"answer":"四"}` {
+		t.Errorf("Unexpected failure body: %+v", body)
+	}
+	if fake.RequestCount() != 2 {
+		t.Errorf("Expected 2 upstream requests, got %d", fake.RequestCount())
+	}
+
+	// 不支持与流式同时使用
+	recorder = postJSON(router, "/api/v1/ai/chat", `{"message":"2+2=?","stream":true,`+answerFormat+`}`)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for streaming structured chat, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,490 @@
+package model
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 批量任务错误
+var (
+	ErrBatchJobNotFound  = errors.New("batch job not found")
+	ErrBatchJobFinished  = errors.New("batch job already finished")
+	ErrBatchItemNotFound = errors.New("batch item not found")
+)
+
+// 批量任务状态
+const (
+	BatchQueued    = "queued"    // 等待后台处理
+	BatchRunning   = "running"   // 至少一项已开始处理
+	BatchCompleted = "completed" // 全部条目已结束（成功或失败）
+	BatchCancelled = "cancelled" // 已取消，未开始的条目不再处理
+)
+
+// 批量条目状态
+const (
+	BatchItemPending   = "pending"   // 等待处理或等待重试
+	BatchItemRunning   = "running"   // 正在调用模型
+	BatchItemSucceeded = "succeeded" // 成功，回复见 Response
+	BatchItemFailed    = "failed"    // 失败，原因见 Error
+	BatchItemCancelled = "cancelled" // 任务取消时尚未开始
+)
+
+// BatchParams 批量任务中所有条目共用的生成参数
+type BatchParams struct {
+	SystemPrompt   string          `json:"system_prompt,omitempty"`
+	Temperature    *float64        `json:"temperature,omitempty"`
+	MaxTokens      int             `json:"max_tokens,omitempty"`
+	ResponseFormat json.RawMessage `json:"response_format,omitempty"` // 结构化输出格式，见 structured.ResponseFormat
+}
+
+// Value 实现driver.Valuer接口
+func (p BatchParams) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// Scan 实现sql.Scanner接口
+func (p *BatchParams) Scan(src interface{}) error {
+	switch data := src.(type) {
+	case nil:
+		*p = BatchParams{}
+		return nil
+	case []byte:
+		return json.Unmarshal(data, p)
+	case string:
+		return json.Unmarshal([]byte(data), p)
+	default:
+		return fmt.Errorf("unsupported type for BatchParams: %T", src)
+	}
+}
+
+// BatchJob 批量聊天任务，条目由后台工作池异步处理，计数与用量随条目结束累加
+type BatchJob struct {
+	ID               int64       `json:"id" db:"id"`
+	UserID           int64       `json:"user_id" db:"user_id"`
+	Name             string      `json:"name" db:"name"`
+	Model            string      `json:"model" db:"model"`
+	Params           BatchParams `json:"params" db:"params"`
+	Status           string      `json:"status" db:"status"` // queued/running/completed/cancelled
+	TotalItems       int         `json:"total_items" db:"total_items"`
+	SucceededItems   int         `json:"succeeded_items" db:"succeeded_items"`
+	FailedItems      int         `json:"failed_items" db:"failed_items"`
+	PromptTokens     int         `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int         `json:"completion_tokens" db:"completion_tokens"`
+	TotalTokens      int         `json:"total_tokens" db:"total_tokens"`
+	CreatedAt        time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time   `json:"updated_at" db:"updated_at"`
+	CompletedAt      *time.Time  `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// BatchItem 批量任务中的一条聊天请求及其结果
+type BatchItem struct {
+	ID               int64      `json:"id" db:"id"`
+	JobID            int64      `json:"job_id" db:"job_id"`
+	Index            int        `json:"index" db:"item_index"`      // 在提交列表中的序号，从0开始
+	CustomID         string     `json:"custom_id" db:"custom_id"`   // 调用方自定义的标识，用于对应结果
+	Content          string     `json:"content" db:"content"`       // 用户消息
+	Status           string     `json:"status" db:"status"`         // pending/running/succeeded/failed/cancelled
+	Response         string     `json:"response" db:"response"`     // 模型回复
+	Error            string     `json:"error,omitempty" db:"error"` // 最近一次失败原因
+	Attempts         int        `json:"attempts" db:"attempts"`     // 已调用模型的次数
+	Model            string     `json:"model,omitempty" db:"model"` // 实际回答的模型
+	PromptTokens     int        `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int        `json:"completion_tokens" db:"completion_tokens"`
+	TotalTokens      int        `json:"total_tokens" db:"total_tokens"`
+	AvailableAt      time.Time  `json:"available_at" db:"available_at"` // 最早可处理时间，重试时推迟
+	CompletedAt      *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// BatchRepository 批量任务数据访问接口
+type BatchRepository interface {
+	// CreateJob 在事务中创建任务及其全部条目
+	CreateJob(job *BatchJob, items []*BatchItem) error
+	GetJob(id int64) (*BatchJob, error)
+	// ListJobs 获取用户的任务列表，按创建时间倒序
+	ListJobs(userID int64, limit, offset int) ([]*BatchJob, error)
+	CountJobs(userID int64) (int, error)
+	// CancelJob 取消未结束的任务，尚未开始的条目标记为已取消；任务已结束时返回 ErrBatchJobFinished
+	CancelJob(id int64) error
+
+	// ListItems 获取任务的全部条目，按序号升序
+	ListItems(jobID int64) ([]*BatchItem, error)
+	// ClaimItem 领取待处理的条目并标记为处理中，尝试次数加1；处理中超过 staleAfter 的条目视为中断，可被重新领取。
+	// 条目已被领取、已结束或已取消时返回 nil
+	ClaimItem(id int64, staleAfter time.Duration) (*BatchItem, error)
+	// RetryItem 将处理中的条目放回待处理，availableAt 之前不再处理
+	RetryItem(id int64, errMsg string, availableAt time.Time) error
+	// FinishItem 保存处理中条目的结果（succeeded/failed），并累加任务的计数与用量；
+	// 全部条目结束时任务标记为已完成
+	FinishItem(item *BatchItem) error
+	// ListRecoverable 获取待处理及处理中超过 staleAfter 的条目，用于重新入队
+	ListRecoverable(staleAfter time.Duration, limit int) ([]*BatchItem, error)
+}
+
+// BatchRepositoryImpl 批量任务数据访问实现
+type BatchRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewBatchRepository 创建批量任务仓库实例
+func NewBatchRepository(db *sql.DB) BatchRepository {
+	return &BatchRepositoryImpl{db: db}
+}
+
+// batchJobColumns 任务表查询列
+const batchJobColumns = `id, user_id, name, model, params, status, total_items, succeeded_items, failed_items,
+	prompt_tokens, completion_tokens, total_tokens, created_at, updated_at, completed_at`
+
+// scanBatchJob 扫描一行任务
+func scanBatchJob(row rowScanner) (*BatchJob, error) {
+	job := &BatchJob{}
+	var completedAt sql.NullTime
+	err := row.Scan(
+		&job.ID,
+		&job.UserID,
+		&job.Name,
+		&job.Model,
+		&job.Params,
+		&job.Status,
+		&job.TotalItems,
+		&job.SucceededItems,
+		&job.FailedItems,
+		&job.PromptTokens,
+		&job.CompletionTokens,
+		&job.TotalTokens,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&completedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.Time
+	}
+	return job, nil
+}
+
+// batchItemColumns 条目表查询列
+const batchItemColumns = `id, job_id, item_index, custom_id, content, status, response, error, attempts, model,
+	prompt_tokens, completion_tokens, total_tokens, available_at, completed_at, created_at, updated_at`
+
+// scanBatchItem 扫描一行条目
+func scanBatchItem(row rowScanner) (*BatchItem, error) {
+	item := &BatchItem{}
+	var completedAt sql.NullTime
+	err := row.Scan(
+		&item.ID,
+		&item.JobID,
+		&item.Index,
+		&item.CustomID,
+		&item.Content,
+		&item.Status,
+		&item.Response,
+		&item.Error,
+		&item.Attempts,
+		&item.Model,
+		&item.PromptTokens,
+		&item.CompletionTokens,
+		&item.TotalTokens,
+		&item.AvailableAt,
+		&completedAt,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		item.CompletedAt = &completedAt.Time
+	}
+	return item, nil
+}
+
+// CreateJob 创建任务及其条目
+func (r *BatchRepositoryImpl) CreateJob(job *BatchJob, items []*BatchItem) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	job.Status = BatchQueued
+	job.TotalItems = len(items)
+	job.CreatedAt = now
+	job.UpdatedAt = now
+
+	err = tx.QueryRow(`
+		INSERT INTO batch_jobs (user_id, name, model, params, status, total_items, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`,
+		job.UserID, job.Name, job.Model, job.Params, job.Status, job.TotalItems, job.CreatedAt, job.UpdatedAt,
+	).Scan(&job.ID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO batch_items (job_id, item_index, custom_id, content, status, available_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`
+	for i, item := range items {
+		item.JobID = job.ID
+		item.Index = i
+		item.Status = BatchItemPending
+		item.AvailableAt = now
+		item.CreatedAt = now
+		item.UpdatedAt = now
+		err := tx.QueryRow(query, item.JobID, item.Index, item.CustomID, item.Content, item.Status, item.AvailableAt, item.CreatedAt, item.UpdatedAt).Scan(&item.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetJob 根据ID获取任务
+func (r *BatchRepositoryImpl) GetJob(id int64) (*BatchJob, error) {
+	query := `SELECT ` + batchJobColumns + ` FROM batch_jobs WHERE id = $1`
+
+	job, err := scanBatchJob(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrBatchJobNotFound
+		}
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// ListJobs 获取用户的任务列表
+func (r *BatchRepositoryImpl) ListJobs(userID int64, limit, offset int) ([]*BatchJob, error) {
+	query := `
+		SELECT ` + batchJobColumns + `
+		FROM batch_jobs
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.Query(query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*BatchJob
+	for rows.Next() {
+		job, err := scanBatchJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// CountJobs 统计用户的任务数
+func (r *BatchRepositoryImpl) CountJobs(userID int64) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM batch_jobs WHERE user_id = $1`, userID).Scan(&count)
+	return count, err
+}
+
+// CancelJob 在事务中取消任务及其未开始的条目；处理中的条目照常结束并计入结果
+func (r *BatchRepositoryImpl) CancelJob(id int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		UPDATE batch_jobs SET status = $1, updated_at = $2, completed_at = $2
+		WHERE id = $3 AND status IN ($4, $5)`,
+		BatchCancelled, now, id, BatchQueued, BatchRunning)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		if _, err := r.GetJob(id); err != nil {
+			return err
+		}
+		return ErrBatchJobFinished
+	}
+
+	_, err = tx.Exec(`
+		UPDATE batch_items SET status = $1, updated_at = $2
+		WHERE job_id = $3 AND status = $4`,
+		BatchItemCancelled, now, id, BatchItemPending)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListItems 获取任务的全部条目
+func (r *BatchRepositoryImpl) ListItems(jobID int64) ([]*BatchItem, error) {
+	query := `SELECT ` + batchItemColumns + ` FROM batch_items WHERE job_id = $1 ORDER BY item_index ASC`
+
+	rows, err := r.db.Query(query, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*BatchItem
+	for rows.Next() {
+		item, err := scanBatchItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// ClaimItem 领取条目，并将排队中的任务标记为处理中
+func (r *BatchRepositoryImpl) ClaimItem(id int64, staleAfter time.Duration) (*BatchItem, error) {
+	query := `
+		UPDATE batch_items SET status = $1, attempts = attempts + 1, updated_at = $2
+		WHERE id = $3 AND (status = $4 OR (status = $1 AND updated_at < $5))
+		RETURNING ` + batchItemColumns
+
+	now := time.Now()
+	item, err := scanBatchItem(r.db.QueryRow(query, BatchItemRunning, now, id, BatchItemPending, now.Add(-staleAfter)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	_, err = r.db.Exec(`UPDATE batch_jobs SET status = $1, updated_at = $2 WHERE id = $3 AND status = $4`,
+		BatchRunning, now, item.JobID, BatchQueued)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// RetryItem 将条目放回待处理
+func (r *BatchRepositoryImpl) RetryItem(id int64, errMsg string, availableAt time.Time) error {
+	query := `
+		UPDATE batch_items SET status = $1, error = $2, available_at = $3, updated_at = $4
+		WHERE id = $5 AND status = $6`
+
+	result, err := r.db.Exec(query, BatchItemPending, errMsg, availableAt, time.Now(), id, BatchItemRunning)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrBatchItemNotFound
+	}
+
+	return nil
+}
+
+// FinishItem 在事务中保存条目结果并更新任务计数与用量，已取消的任务保持取消状态
+func (r *BatchRepositoryImpl) FinishItem(item *BatchItem) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	item.UpdatedAt = now
+	item.CompletedAt = &now
+
+	result, err := tx.Exec(`
+		UPDATE batch_items SET status = $1, response = $2, error = $3, model = $4,
+			prompt_tokens = $5, completion_tokens = $6, total_tokens = $7, completed_at = $8, updated_at = $8
+		WHERE id = $9 AND status = $10`,
+		item.Status, item.Response, item.Error, item.Model,
+		item.PromptTokens, item.CompletionTokens, item.TotalTokens, now, item.ID, BatchItemRunning)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrBatchItemNotFound
+	}
+
+	succeeded, failed := 0, 0
+	if item.Status == BatchItemSucceeded {
+		succeeded = 1
+	} else {
+		failed = 1
+	}
+	_, err = tx.Exec(`
+		UPDATE batch_jobs SET
+			succeeded_items = succeeded_items + $1,
+			failed_items = failed_items + $2,
+			prompt_tokens = prompt_tokens + $3,
+			completion_tokens = completion_tokens + $4,
+			total_tokens = total_tokens + $5,
+			status = CASE WHEN status <> $6 AND succeeded_items + failed_items + $1 + $2 >= total_items THEN $7 ELSE status END,
+			completed_at = CASE WHEN status <> $6 AND succeeded_items + failed_items + $1 + $2 >= total_items THEN $8 ELSE completed_at END,
+			updated_at = $8
+		WHERE id = $9`,
+		succeeded, failed, item.PromptTokens, item.CompletionTokens, item.TotalTokens,
+		BatchCancelled, BatchCompleted, now, item.JobID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListRecoverable 获取可重新入队的条目
+func (r *BatchRepositoryImpl) ListRecoverable(staleAfter time.Duration, limit int) ([]*BatchItem, error) {
+	query := `
+		SELECT ` + batchItemColumns + `
+		FROM batch_items
+		WHERE status = $1 OR (status = $2 AND updated_at < $3)
+		ORDER BY id ASC
+		LIMIT $4`
+
+	rows, err := r.db.Query(query, BatchItemPending, BatchItemRunning, time.Now().Add(-staleAfter), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*BatchItem
+	for rows.Next() {
+		item, err := scanBatchItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
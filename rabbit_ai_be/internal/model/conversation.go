@@ -2,8 +2,15 @@ package model
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
+
+	"github.com/lib/pq"
+
+	"rabbit_ai/internal/textsearch"
 )
 
 // ErrConversationNotFound 对话未找到错误
@@ -14,26 +21,259 @@ var ErrMessageNotFound = errors.New("message not found")
 
 // Conversation 对话会话模型
 type Conversation struct {
-	ID            int64     `json:"id" db:"id"`
-	UserID        int64     `json:"user_id" db:"user_id"`
-	Title         string    `json:"title" db:"title"`                     // 对话标题
-	Status        int       `json:"status" db:"status"`                   // 1: 活跃, 0: 已删除
-	MessageCount  int       `json:"message_count" db:"message_count"`     // 消息数量
-	LastMessageAt time.Time `json:"last_message_at" db:"last_message_at"` // 最后消息时间
-	CreatedAt     time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+	ID            int64                `json:"id" db:"id"`
+	UserID        int64                `json:"user_id" db:"user_id"`
+	Title         string               `json:"title" db:"title"`                     // 对话标题
+	Status        int                  `json:"status" db:"status"`                   // 1: 活跃, 0: 已删除
+	MessageCount  int                  `json:"message_count" db:"message_count"`     // 消息数量
+	LastMessageAt time.Time            `json:"last_message_at" db:"last_message_at"` // 最后消息时间
+	Settings      ConversationSettings `json:"settings" db:"settings"`               // 对话级系统提示与生成参数
+	Summary       *ConversationSummary `json:"summary,omitempty" db:"summary"`       // 较早轮次的滚动摘要
+	ActiveLeafID  int64                `json:"active_leaf_id" db:"active_leaf_id"`   // 当前分支的叶子消息ID，对话历史为其到根的路径
+	CreatedAt     time.Time            `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at" db:"updated_at"`
+}
+
+// ConversationSettings 对话级设置，每次调用大模型时应用；未设置的参数使用服务默认值
+type ConversationSettings struct {
+	SystemPrompt string   `json:"system_prompt,omitempty"` // 系统提示
+	Model        string   `json:"model,omitempty"`         // 默认模型，发送消息时指定的模型优先
+	Temperature  *float64 `json:"temperature,omitempty"`   // 温度参数
+	TopP         *float64 `json:"top_p,omitempty"`         // 核采样参数
+	MaxTokens    int      `json:"max_tokens,omitempty"`    // 回复最大token数
+	Stop         []string `json:"stop,omitempty"`          // 停止词
+	// KnowledgeBaseIDs 关联的知识库，发送消息时从中检索相关片段作为上下文
+	KnowledgeBaseIDs []int64 `json:"knowledge_base_ids,omitempty"`
+	// TemplateID/TemplateVersion 以提示词模板创建对话时，生成系统提示的模板及版本；修改系统提示后清空
+	TemplateID      int64 `json:"template_id,omitempty"`
+	TemplateVersion int   `json:"template_version,omitempty"`
+}
+
+// Value 实现driver.Valuer接口
+func (s ConversationSettings) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan 实现sql.Scanner接口
+func (s *ConversationSettings) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = ConversationSettings{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("unsupported type for ConversationSettings: %T", src)
+	}
+}
+
+// ConversationSummary 对话滚动摘要，覆盖范围内的历史消息以摘要代替原文发送给模型
+type ConversationSummary struct {
+	Content          string    `json:"content"`            // 摘要正文
+	CoveredMessageID int64     `json:"covered_message_id"` // 摘要覆盖到的最后一条消息ID
+	CoveredMessages  int       `json:"covered_messages"`   // 摘要覆盖的消息数量
+	Model            string    `json:"model"`              // 生成摘要使用的模型
+	Tokens           int       `json:"tokens"`             // 最近一次生成摘要消耗的token数量
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// Value 实现driver.Valuer接口
+func (s *ConversationSummary) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return json.Marshal(s)
+}
+
+// Scan 实现sql.Scanner接口
+func (s *ConversationSummary) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("unsupported type for ConversationSummary: %T", src)
+	}
 }
 
 // Message 消息模型
 type Message struct {
-	ID             int64     `json:"id" db:"id"`
-	ConversationID int64     `json:"conversation_id" db:"conversation_id"`
-	Role           string    `json:"role" db:"role"`                   // user/assistant
-	Content        string    `json:"content" db:"content"`             // 消息内容
-	Tokens         int       `json:"tokens" db:"tokens"`               // 消耗的token数量
-	Model          string    `json:"model" db:"model"`                 // 使用的模型
-	FinishReason   string    `json:"finish_reason" db:"finish_reason"` // 结束原因
-	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	ID              int64          `json:"id" db:"id"`
+	ConversationID  int64          `json:"conversation_id" db:"conversation_id"`
+	Role            string         `json:"role" db:"role"`                                     // user/assistant/tool
+	Content         string         `json:"content" db:"content"`                               // 消息内容，含图片的消息为其中的文字部分
+	Parts           ContentParts   `json:"parts,omitempty" db:"parts"`                         // 图文消息按顺序的内容片段，纯文本消息为空
+	Tokens          int            `json:"tokens" db:"tokens"`                                 // 消耗的token数量
+	Model           string         `json:"model" db:"model"`                                   // 使用的模型
+	FinishReason    string         `json:"finish_reason" db:"finish_reason"`                   // 结束原因
+	ToolCalls       ToolCalls      `json:"tool_calls,omitempty" db:"tool_calls"`               // assistant消息请求的工具调用
+	ToolCallID      string         `json:"tool_call_id,omitempty" db:"tool_call_id"`           // tool消息对应的工具调用ID
+	ContextReport   *ContextReport `json:"context_report,omitempty" db:"context_report"`       // 生成该回复时的上下文裁剪报告，未裁剪时为空
+	ParentID        int64          `json:"parent_id" db:"parent_id"`                           // 上一条消息ID，0表示根消息
+	Moderation      string         `json:"moderation_status,omitempty" db:"moderation_status"` // 内容安全状态：flagged/blocked/approved，未命中时为空
+	Citations       Citations      `json:"citations,omitempty" db:"citations"`                 // AI回复引用的知识库片段
+	TemplateID      int64          `json:"template_id,omitempty" db:"template_id"`             // 生成该用户消息的提示词模板
+	TemplateVersion int            `json:"template_version,omitempty" db:"template_version"`   // 生成该用户消息的模板版本
+	CreatedAt       time.Time      `json:"created_at" db:"created_at"`
+}
+
+// ContextReport 上下文裁剪报告，记录为适应模型上下文窗口而丢弃的历史轮次
+type ContextReport struct {
+	Model             string  `json:"model"`
+	ContextWindow     int     `json:"context_window"`      // 模型上下文窗口
+	ReservedTokens    int     `json:"reserved_tokens"`     // 为回复预留的token数（MaxTokens）
+	BudgetTokens      int     `json:"budget_tokens"`       // prompt的估算token预算（扣除预留与估算误差余量）
+	OriginalTokens    int     `json:"original_tokens"`     // 裁剪前估算的prompt token数
+	FinalTokens       int     `json:"final_tokens"`        // 裁剪后估算的prompt token数
+	DroppedTurns      int     `json:"dropped_turns"`       // 丢弃的轮次数（一轮 = 用户消息及其后的回复）
+	DroppedMessageIDs []int64 `json:"dropped_message_ids"` // 丢弃的消息ID
+	OverBudget        bool    `json:"over_budget"`         // 仅保留系统提示和最新一轮后仍超出预算
+}
+
+// Value 实现driver.Valuer接口
+func (r *ContextReport) Value() (driver.Value, error) {
+	if r == nil {
+		return nil, nil
+	}
+	return json.Marshal(r)
+}
+
+// Scan 实现sql.Scanner接口
+func (r *ContextReport) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return fmt.Errorf("unsupported type for ContextReport: %T", src)
+	}
+}
+
+// 内容片段类型
+const (
+	PartText  = "text"
+	PartImage = "image"
+)
+
+// ContentPart 图文消息的内容片段
+type ContentPart struct {
+	Type    string `json:"type"`               // text/image
+	Text    string `json:"text,omitempty"`     // 文字片段内容
+	ImageID string `json:"image_id,omitempty"` // 图片片段引用的已上传图片ID
+}
+
+// ContentParts 内容片段列表，以JSONB存储
+type ContentParts []ContentPart
+
+// HasImages 是否包含图片片段
+func (p ContentParts) HasImages() bool {
+	for _, part := range p {
+		if part.Type == PartImage {
+			return true
+		}
+	}
+	return false
+}
+
+// Value 实现driver.Valuer接口
+func (p ContentParts) Value() (driver.Value, error) {
+	if len(p) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(p)
+}
+
+// Scan 实现sql.Scanner接口
+func (p *ContentParts) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("unsupported type for ContentParts: %T", src)
+	}
+}
+
+// Citation 回复引用的知识库片段
+type Citation struct {
+	Index           int     `json:"index"` // 注入上下文时的编号，回复中以 [编号] 引用
+	KnowledgeBaseID int64   `json:"knowledge_base_id"`
+	DocumentID      int64   `json:"document_id"`
+	DocumentName    string  `json:"document_name"`
+	ChunkID         int64   `json:"chunk_id"`
+	Content         string  `json:"content"` // 片段原文
+	Score           float64 `json:"score"`   // 与问题的余弦相似度
+}
+
+// Citations 引用列表，以JSONB存储
+type Citations []Citation
+
+// Value 实现driver.Valuer接口
+func (c Citations) Value() (driver.Value, error) {
+	if len(c) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+// Scan 实现sql.Scanner接口
+func (c *Citations) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	default:
+		return fmt.Errorf("unsupported type for Citations: %T", src)
+	}
+}
+
+// ToolCall 工具调用记录
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolCalls 工具调用列表，以JSONB存储
+type ToolCalls []ToolCall
+
+// Value 实现driver.Valuer接口
+func (t ToolCalls) Value() (driver.Value, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(t)
+}
+
+// Scan 实现sql.Scanner接口
+func (t *ToolCalls) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	default:
+		return fmt.Errorf("unsupported type for ToolCalls: %T", src)
+	}
 }
 
 // ConversationRepository 对话数据访问接口
@@ -44,6 +284,9 @@ type ConversationRepository interface {
 	Update(conversation *Conversation) error
 	Delete(id int64) error
 	GetUserConversationCount(userID int64) (int, error)
+	UpdateSummary(id int64, summary *ConversationSummary) error
+	UpdateSettings(id int64, settings ConversationSettings) error
+	UpdateTitle(id int64, from, to string) (bool, error)
 }
 
 // MessageRepository 消息数据访问接口
@@ -80,8 +323,8 @@ func NewMessageRepository(db *sql.DB) MessageRepository {
 // Create 创建对话
 func (r *ConversationRepositoryImpl) Create(conversation *Conversation) error {
 	query := `
-		INSERT INTO conversations (user_id, title, status, message_count, last_message_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO conversations (user_id, title, status, message_count, last_message_at, settings, active_leaf_id, created_at, updated_at, title_vector)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, array_to_tsvector($10::text[]))
 		RETURNING id`
 
 	now := time.Now()
@@ -96,29 +339,21 @@ func (r *ConversationRepositoryImpl) Create(conversation *Conversation) error {
 		conversation.Status,
 		conversation.MessageCount,
 		conversation.LastMessageAt,
+		conversation.Settings,
+		conversation.ActiveLeafID,
 		conversation.CreatedAt,
 		conversation.UpdatedAt,
+		pq.Array(textsearch.DocumentLexemes(conversation.Title)),
 	).Scan(&conversation.ID)
 }
 
 // GetByID 根据ID获取对话
 func (r *ConversationRepositoryImpl) GetByID(id int64) (*Conversation, error) {
-	conversation := &Conversation{}
 	query := `
-		SELECT id, user_id, title, status, message_count, last_message_at, created_at, updated_at
+		SELECT ` + conversationColumns + `
 		FROM conversations WHERE id = $1 AND status = 1`
 
-	err := r.db.QueryRow(query, id).Scan(
-		&conversation.ID,
-		&conversation.UserID,
-		&conversation.Title,
-		&conversation.Status,
-		&conversation.MessageCount,
-		&conversation.LastMessageAt,
-		&conversation.CreatedAt,
-		&conversation.UpdatedAt,
-	)
-
+	conversation, err := scanConversation(r.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrConversationNotFound
@@ -132,7 +367,7 @@ func (r *ConversationRepositoryImpl) GetByID(id int64) (*Conversation, error) {
 // GetByUserID 根据用户ID获取对话列表
 func (r *ConversationRepositoryImpl) GetByUserID(userID int64, limit, offset int) ([]*Conversation, error) {
 	query := `
-		SELECT id, user_id, title, status, message_count, last_message_at, created_at, updated_at
+		SELECT ` + conversationColumns + `
 		FROM conversations 
 		WHERE user_id = $1 AND status = 1
 		ORDER BY last_message_at DESC
@@ -146,17 +381,7 @@ func (r *ConversationRepositoryImpl) GetByUserID(userID int64, limit, offset int
 
 	var conversations []*Conversation
 	for rows.Next() {
-		conversation := &Conversation{}
-		err := rows.Scan(
-			&conversation.ID,
-			&conversation.UserID,
-			&conversation.Title,
-			&conversation.Status,
-			&conversation.MessageCount,
-			&conversation.LastMessageAt,
-			&conversation.CreatedAt,
-			&conversation.UpdatedAt,
-		)
+		conversation, err := scanConversation(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -166,21 +391,46 @@ func (r *ConversationRepositoryImpl) GetByUserID(userID int64, limit, offset int
 	return conversations, nil
 }
 
-// Update 更新对话
+// conversationColumns 对话表查询列
+const conversationColumns = `id, user_id, title, status, message_count, last_message_at, settings, summary, active_leaf_id, created_at, updated_at`
+
+// scanConversation 扫描一行对话
+func scanConversation(row rowScanner) (*Conversation, error) {
+	conversation := &Conversation{}
+	err := row.Scan(
+		&conversation.ID,
+		&conversation.UserID,
+		&conversation.Title,
+		&conversation.Status,
+		&conversation.MessageCount,
+		&conversation.LastMessageAt,
+		&conversation.Settings,
+		&conversation.Summary,
+		&conversation.ActiveLeafID,
+		&conversation.CreatedAt,
+		&conversation.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return conversation, nil
+}
+
+// Update 更新对话（不包含标题、设置和摘要，三者通过 UpdateTitle/UpdateSettings/UpdateSummary 单独更新，避免并发写覆盖）
 func (r *ConversationRepositoryImpl) Update(conversation *Conversation) error {
 	query := `
 		UPDATE conversations 
-		SET title = $1, status = $2, message_count = $3, last_message_at = $4, updated_at = $5
+		SET status = $1, message_count = $2, last_message_at = $3, active_leaf_id = $4, updated_at = $5
 		WHERE id = $6`
 
 	conversation.UpdatedAt = time.Now()
 
 	result, err := r.db.Exec(
 		query,
-		conversation.Title,
 		conversation.Status,
 		conversation.MessageCount,
 		conversation.LastMessageAt,
+		conversation.ActiveLeafID,
 		conversation.UpdatedAt,
 		conversation.ID,
 	)
@@ -221,6 +471,65 @@ func (r *ConversationRepositoryImpl) Delete(id int64) error {
 	return nil
 }
 
+// UpdateSummary 更新对话摘要
+func (r *ConversationRepositoryImpl) UpdateSummary(id int64, summary *ConversationSummary) error {
+	query := `UPDATE conversations SET summary = $1 WHERE id = $2 AND status = 1`
+
+	result, err := r.db.Exec(query, summary, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrConversationNotFound
+	}
+
+	return nil
+}
+
+// UpdateTitle 仅当标题仍为 from 时更新为 to，返回是否更新
+func (r *ConversationRepositoryImpl) UpdateTitle(id int64, from, to string) (bool, error) {
+	query := `UPDATE conversations SET title = $1, title_vector = array_to_tsvector($2::text[]), updated_at = $3 WHERE id = $4 AND title = $5 AND status = 1`
+
+	result, err := r.db.Exec(query, to, pq.Array(textsearch.DocumentLexemes(to)), time.Now(), id, from)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// UpdateSettings 更新对话设置
+func (r *ConversationRepositoryImpl) UpdateSettings(id int64, settings ConversationSettings) error {
+	query := `UPDATE conversations SET settings = $1, updated_at = $2 WHERE id = $3 AND status = 1`
+
+	result, err := r.db.Exec(query, settings, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrConversationNotFound
+	}
+
+	return nil
+}
+
 // GetUserConversationCount 获取用户对话数量
 func (r *ConversationRepositoryImpl) GetUserConversationCount(userID int64) (int, error) {
 	query := `SELECT COUNT(*) FROM conversations WHERE user_id = $1 AND status = 1`
@@ -234,11 +543,63 @@ func (r *ConversationRepositoryImpl) GetUserConversationCount(userID int64) (int
 	return count, nil
 }
 
+// messageColumns 消息表查询列
+const messageColumns = `id, conversation_id, role, content, parts, tokens, model, finish_reason, tool_calls, tool_call_id, context_report, parent_id, moderation_status, citations, template_id, template_version, created_at`
+
+// rowScanner 兼容 *sql.Row 与 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanMessage 扫描一行消息
+func scanMessage(row rowScanner) (*Message, error) {
+	message := &Message{}
+	err := row.Scan(
+		&message.ID,
+		&message.ConversationID,
+		&message.Role,
+		&message.Content,
+		&message.Parts,
+		&message.Tokens,
+		&message.Model,
+		&message.FinishReason,
+		&message.ToolCalls,
+		&message.ToolCallID,
+		&message.ContextReport,
+		&message.ParentID,
+		&message.Moderation,
+		&message.Citations,
+		&message.TemplateID,
+		&message.TemplateVersion,
+		&message.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// scanMessages 扫描多行消息
+func scanMessages(rows *sql.Rows) ([]*Message, error) {
+	defer rows.Close()
+
+	var messages []*Message
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
+
 // Create 创建消息
 func (r *MessageRepositoryImpl) Create(message *Message) error {
 	query := `
-		INSERT INTO messages (conversation_id, role, content, tokens, model, finish_reason, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO messages (conversation_id, role, content, parts, tokens, model, finish_reason, tool_calls, tool_call_id, context_report, parent_id, moderation_status, citations, template_id, template_version, created_at, search_vector)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, array_to_tsvector($17::text[]))
 		RETURNING id`
 
 	message.CreatedAt = time.Now()
@@ -248,31 +609,28 @@ func (r *MessageRepositoryImpl) Create(message *Message) error {
 		message.ConversationID,
 		message.Role,
 		message.Content,
+		message.Parts,
 		message.Tokens,
 		message.Model,
 		message.FinishReason,
+		message.ToolCalls,
+		message.ToolCallID,
+		message.ContextReport,
+		message.ParentID,
+		message.Moderation,
+		message.Citations,
+		message.TemplateID,
+		message.TemplateVersion,
 		message.CreatedAt,
+		pq.Array(textsearch.DocumentLexemes(message.Content)),
 	).Scan(&message.ID)
 }
 
 // GetByID 根据ID获取消息
 func (r *MessageRepositoryImpl) GetByID(id int64) (*Message, error) {
-	message := &Message{}
-	query := `
-		SELECT id, conversation_id, role, content, tokens, model, finish_reason, created_at
-		FROM messages WHERE id = $1`
-
-	err := r.db.QueryRow(query, id).Scan(
-		&message.ID,
-		&message.ConversationID,
-		&message.Role,
-		&message.Content,
-		&message.Tokens,
-		&message.Model,
-		&message.FinishReason,
-		&message.CreatedAt,
-	)
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE id = $1`
 
+	message, err := scanMessage(r.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrMessageNotFound
@@ -286,7 +644,7 @@ func (r *MessageRepositoryImpl) GetByID(id int64) (*Message, error) {
 // GetByConversationID 根据对话ID获取消息列表
 func (r *MessageRepositoryImpl) GetByConversationID(conversationID int64, limit, offset int) ([]*Message, error) {
 	query := `
-		SELECT id, conversation_id, role, content, tokens, model, finish_reason, created_at
+		SELECT ` + messageColumns + `
 		FROM messages 
 		WHERE conversation_id = $1
 		ORDER BY created_at ASC
@@ -296,34 +654,13 @@ func (r *MessageRepositoryImpl) GetByConversationID(conversationID int64, limit,
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var messages []*Message
-	for rows.Next() {
-		message := &Message{}
-		err := rows.Scan(
-			&message.ID,
-			&message.ConversationID,
-			&message.Role,
-			&message.Content,
-			&message.Tokens,
-			&message.Model,
-			&message.FinishReason,
-			&message.CreatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		messages = append(messages, message)
-	}
-
-	return messages, nil
+	return scanMessages(rows)
 }
 
 // GetConversationMessages 获取对话的所有消息
 func (r *MessageRepositoryImpl) GetConversationMessages(conversationID int64) ([]*Message, error) {
 	query := `
-		SELECT id, conversation_id, role, content, tokens, model, finish_reason, created_at
+		SELECT ` + messageColumns + `
 		FROM messages 
 		WHERE conversation_id = $1
 		ORDER BY created_at ASC`
@@ -332,44 +669,31 @@ func (r *MessageRepositoryImpl) GetConversationMessages(conversationID int64) ([
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var messages []*Message
-	for rows.Next() {
-		message := &Message{}
-		err := rows.Scan(
-			&message.ID,
-			&message.ConversationID,
-			&message.Role,
-			&message.Content,
-			&message.Tokens,
-			&message.Model,
-			&message.FinishReason,
-			&message.CreatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		messages = append(messages, message)
-	}
-
-	return messages, nil
+	return scanMessages(rows)
 }
 
 // Update 更新消息
 func (r *MessageRepositoryImpl) Update(message *Message) error {
 	query := `
 		UPDATE messages 
-		SET role = $1, content = $2, tokens = $3, model = $4, finish_reason = $5
-		WHERE id = $6`
+		SET role = $1, content = $2, parts = $3, tokens = $4, model = $5, finish_reason = $6, tool_calls = $7, tool_call_id = $8, context_report = $9,
+			moderation_status = $10, citations = $11, search_vector = array_to_tsvector($12::text[])
+		WHERE id = $13`
 
 	result, err := r.db.Exec(
 		query,
 		message.Role,
 		message.Content,
+		message.Parts,
 		message.Tokens,
 		message.Model,
 		message.FinishReason,
+		message.ToolCalls,
+		message.ToolCallID,
+		message.ContextReport,
+		message.Moderation,
+		message.Citations,
+		pq.Array(textsearch.DocumentLexemes(message.Content)),
 		message.ID,
 	)
 	if err != nil {
@@ -0,0 +1,133 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MessageEmbedding 消息向量，同一消息可保存多个向量模型的向量
+type MessageEmbedding struct {
+	MessageID      int64     `json:"message_id" db:"message_id"`
+	ConversationID int64     `json:"conversation_id" db:"conversation_id"`
+	Model          string    `json:"model" db:"model"`
+	Vector         []float32 `json:"-" db:"embedding"`
+	Error          string    `json:"error,omitempty" db:"error"` // 无法向量化时的错误信息，此时向量为空
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// EmbeddingRepository 消息向量数据访问接口
+type EmbeddingRepository interface {
+	// ListPending 获取尚未生成指定模型向量、也未记录为无法向量化的用户与AI回复消息（不含已删除对话和被屏蔽的消息），按ID升序
+	ListPending(model string, limit int) ([]*Message, error)
+	// Save 保存向量，已存在时覆盖
+	Save(embedding *MessageEmbedding) error
+	// MarkFailed 记录无法向量化的消息，之后不再列为待处理
+	MarkFailed(embedding *MessageEmbedding) error
+	// ListByUser 获取用户未删除对话中指定模型的全部消息向量
+	ListByUser(userID int64, model string) ([]*MessageEmbedding, error)
+}
+
+// EmbeddingRepositoryImpl 消息向量数据访问实现，向量以 REAL[] 保存，相似度在服务端计算
+type EmbeddingRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewEmbeddingRepository 创建消息向量仓库实例
+func NewEmbeddingRepository(db *sql.DB) EmbeddingRepository {
+	return &EmbeddingRepositoryImpl{db: db}
+}
+
+// ListPending 获取尚未生成指定模型向量的消息
+func (r *EmbeddingRepositoryImpl) ListPending(model string, limit int) ([]*Message, error) {
+	query := `
+		SELECT m.id, m.conversation_id, m.role, m.content, m.parts, m.tokens, m.model, m.finish_reason, m.tool_calls,
+			m.tool_call_id, m.context_report, m.parent_id, m.moderation_status, m.citations, m.template_id, m.template_version, m.created_at
+		FROM messages m
+		JOIN conversations c ON c.id = m.conversation_id
+		LEFT JOIN message_embeddings e ON e.message_id = m.id AND e.model = $1
+		WHERE e.message_id IS NULL
+			AND c.status = 1
+			AND m.role IN ('user', 'assistant')
+			AND m.content <> ''
+			AND m.moderation_status <> $2
+		ORDER BY m.id ASC
+		LIMIT $3`
+
+	rows, err := r.db.Query(query, model, ModerationBlocked, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanMessages(rows)
+}
+
+// Save 保存向量
+func (r *EmbeddingRepositoryImpl) Save(embedding *MessageEmbedding) error {
+	query := `
+		INSERT INTO message_embeddings (message_id, conversation_id, model, embedding, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (message_id, model) DO UPDATE SET embedding = EXCLUDED.embedding, error = '', created_at = EXCLUDED.created_at`
+
+	embedding.CreatedAt = time.Now()
+	_, err := r.db.Exec(
+		query,
+		embedding.MessageID,
+		embedding.ConversationID,
+		embedding.Model,
+		pq.Array(embedding.Vector),
+		embedding.CreatedAt,
+	)
+	return err
+}
+
+// MarkFailed 以空向量保存无法向量化的消息及错误信息
+func (r *EmbeddingRepositoryImpl) MarkFailed(embedding *MessageEmbedding) error {
+	query := `
+		INSERT INTO message_embeddings (message_id, conversation_id, model, embedding, error, created_at)
+		VALUES ($1, $2, $3, '{}', $4, $5)
+		ON CONFLICT (message_id, model) DO NOTHING`
+
+	embedding.Vector = nil
+	embedding.Error = TruncateError(embedding.Error)
+	embedding.CreatedAt = time.Now()
+	_, err := r.db.Exec(
+		query,
+		embedding.MessageID,
+		embedding.ConversationID,
+		embedding.Model,
+		embedding.Error,
+		embedding.CreatedAt,
+	)
+	return err
+}
+
+// ListByUser 获取用户的消息向量，不含无法向量化的消息
+func (r *EmbeddingRepositoryImpl) ListByUser(userID int64, model string) ([]*MessageEmbedding, error) {
+	query := `
+		SELECT e.message_id, e.conversation_id, e.model, e.embedding, e.created_at
+		FROM message_embeddings e
+		JOIN conversations c ON c.id = e.conversation_id
+		JOIN messages m ON m.id = e.message_id
+		WHERE c.user_id = $1 AND c.status = 1 AND e.model = $2 AND e.error = '' AND m.moderation_status <> $3`
+
+	rows, err := r.db.Query(query, userID, model, ModerationBlocked)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var embeddings []*MessageEmbedding
+	for rows.Next() {
+		embedding := &MessageEmbedding{}
+		var vector pq.Float32Array
+		if err := rows.Scan(&embedding.MessageID, &embedding.ConversationID, &embedding.Model, &vector, &embedding.CreatedAt); err != nil {
+			return nil, err
+		}
+		embedding.Vector = vector
+		embeddings = append(embeddings, embedding)
+	}
+
+	return embeddings, rows.Err()
+}
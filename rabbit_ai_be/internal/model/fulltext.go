@@ -0,0 +1,204 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"rabbit_ai/internal/textsearch"
+)
+
+// 全文检索命中类型
+const (
+	FullTextKindMessage      = "message"      // 消息内容命中
+	FullTextKindConversation = "conversation" // 对话标题命中
+)
+
+// FullTextQuery 全文检索条件
+type FullTextQuery struct {
+	UserID  int64
+	TSQuery string          // tsquery 表达式，见 textsearch.Query
+	Role    string          // 仅检索该角色的消息，为空时检索用户消息与AI回复
+	Model   string          // 仅检索该模型生成的消息
+	From    time.Time       // 时间下限（含），为零值时不限制
+	To      time.Time       // 时间上限（不含），为零值时不限制
+	After   *FullTextCursor // 从该位置之后继续
+	Limit   int
+}
+
+// FullTextCursor 全文检索分页游标，结果按 (CreatedAt, Kind, ID) 降序排列
+type FullTextCursor struct {
+	CreatedAt time.Time `json:"t"`
+	Kind      string    `json:"k"`
+	ID        int64     `json:"i"`
+}
+
+// FullTextHit 全文检索命中的消息或对话
+type FullTextHit struct {
+	Kind              string    // message/conversation
+	ID                int64     // 消息ID或对话ID
+	ConversationID    int64     // 所在对话ID
+	ConversationTitle string    // 所在对话标题
+	Role              string    // 消息角色，对话命中时为空
+	Model             string    // 消息模型，对话命中时为空
+	Content           string    // 消息内容或对话标题
+	CreatedAt         time.Time // 消息创建时间或对话最后消息时间
+}
+
+// Cursor 以该命中为位置的分页游标
+func (h *FullTextHit) Cursor() *FullTextCursor {
+	return &FullTextCursor{CreatedAt: h.CreatedAt, Kind: h.Kind, ID: h.ID}
+}
+
+// FullTextRepository 全文检索数据访问接口
+type FullTextRepository interface {
+	// Search 检索用户未删除对话中的消息内容与对话标题，按时间从新到旧排列
+	Search(query *FullTextQuery) ([]*FullTextHit, error)
+	// Backfill 为尚未建立全文索引的消息和对话补齐索引，返回处理的行数
+	Backfill(limit int) (int, error)
+}
+
+// FullTextRepositoryImpl 全文检索数据访问实现，词位由 textsearch 分词后写入 tsvector 列
+type FullTextRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewFullTextRepository 创建全文检索仓库实例
+func NewFullTextRepository(db *sql.DB) FullTextRepository {
+	return &FullTextRepositoryImpl{db: db}
+}
+
+// Search 全文检索
+//
+// 只有在未按角色或模型过滤时才检索对话标题；被屏蔽的消息不参与检索。
+func (r *FullTextRepositoryImpl) Search(query *FullTextQuery) ([]*FullTextHit, error) {
+	args := []interface{}{query.UserID, query.TSQuery}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	// filters 时间范围与游标条件
+	filters := func(timeColumn, kind, idColumn string) []string {
+		var conditions []string
+		if !query.From.IsZero() {
+			conditions = append(conditions, timeColumn+" >= "+arg(query.From))
+		}
+		if !query.To.IsZero() {
+			conditions = append(conditions, timeColumn+" < "+arg(query.To))
+		}
+		if query.After != nil {
+			conditions = append(conditions, fmt.Sprintf("(%s, '%s'::text, %s) < (%s, %s::text, %s)",
+				timeColumn, kind, idColumn, arg(query.After.CreatedAt), arg(query.After.Kind), arg(query.After.ID)))
+		}
+		return conditions
+	}
+
+	messageConditions := []string{
+		"c.user_id = $1",
+		"c.status = 1",
+		"m.search_vector @@ $2::tsquery",
+		"m.moderation_status <> '" + ModerationBlocked + "'",
+	}
+	if query.Role != "" {
+		messageConditions = append(messageConditions, "m.role = "+arg(query.Role))
+	} else {
+		messageConditions = append(messageConditions, "m.role IN ('user', 'assistant')")
+	}
+	if query.Model != "" {
+		messageConditions = append(messageConditions, "m.model = "+arg(query.Model))
+	}
+	messageConditions = append(messageConditions, filters("m.created_at", FullTextKindMessage, "m.id")...)
+
+	sqlQuery := `
+		SELECT '` + FullTextKindMessage + `'::text AS kind, m.id, m.conversation_id, c.title, m.role, m.model, m.content, m.created_at
+		FROM messages m
+		JOIN conversations c ON c.id = m.conversation_id
+		WHERE ` + strings.Join(messageConditions, " AND ")
+
+	if query.Role == "" && query.Model == "" {
+		conversationConditions := append([]string{
+			"c.user_id = $1",
+			"c.status = 1",
+			"c.title_vector @@ $2::tsquery",
+		}, filters("c.last_message_at", FullTextKindConversation, "c.id")...)
+
+		sqlQuery += `
+		UNION ALL
+		SELECT '` + FullTextKindConversation + `'::text AS kind, c.id, c.id, c.title, '', '', c.title, c.last_message_at
+		FROM conversations c
+		WHERE ` + strings.Join(conversationConditions, " AND ")
+	}
+	sqlQuery = `SELECT kind, id, conversation_id, title, role, model, content, created_at FROM (` + sqlQuery + `
+		) hits
+		ORDER BY created_at DESC, kind DESC, id DESC
+		LIMIT ` + arg(query.Limit)
+
+	rows, err := r.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []*FullTextHit
+	for rows.Next() {
+		hit := &FullTextHit{}
+		if err := rows.Scan(&hit.Kind, &hit.ID, &hit.ConversationID, &hit.ConversationTitle, &hit.Role, &hit.Model, &hit.Content, &hit.CreatedAt); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+
+	return hits, rows.Err()
+}
+
+// Backfill 为早期消息和对话补齐全文索引
+func (r *FullTextRepositoryImpl) Backfill(limit int) (int, error) {
+	messages, err := r.backfill(`SELECT id, content FROM messages WHERE search_vector IS NULL ORDER BY id LIMIT $1`,
+		`UPDATE messages SET search_vector = array_to_tsvector($1::text[]) WHERE id = $2`, limit)
+	if err != nil {
+		return messages, fmt.Errorf("failed to backfill messages: %w", err)
+	}
+
+	conversations, err := r.backfill(`SELECT id, title FROM conversations WHERE title_vector IS NULL ORDER BY id LIMIT $1`,
+		`UPDATE conversations SET title_vector = array_to_tsvector($1::text[]) WHERE id = $2`, limit)
+	if err != nil {
+		return messages + conversations, fmt.Errorf("failed to backfill conversations: %w", err)
+	}
+
+	return messages + conversations, nil
+}
+
+// backfill 读取一批缺少索引的行，分词后写回
+func (r *FullTextRepositoryImpl) backfill(selectQuery, updateQuery string, limit int) (int, error) {
+	rows, err := r.db.Query(selectQuery, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	texts := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var text string
+		if err := rows.Scan(&id, &text); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		texts[id] = text
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for id, text := range texts {
+		if _, err := r.db.Exec(updateQuery, pq.Array(textsearch.DocumentLexemes(text)), id); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,452 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// 知识库错误
+var (
+	ErrKnowledgeBaseNotFound     = errors.New("knowledge base not found")
+	ErrKnowledgeBaseExists       = errors.New("knowledge base name already exists")
+	ErrKnowledgeDocumentNotFound = errors.New("knowledge document not found")
+)
+
+// 知识库文档处理状态
+const (
+	DocumentPending    = "pending"    // 等待后台处理
+	DocumentProcessing = "processing" // 正在切分和向量化
+	DocumentReady      = "ready"      // 可被检索
+	DocumentFailed     = "failed"     // 处理失败，原因见 Error
+)
+
+// KnowledgeBase 用户的知识库
+type KnowledgeBase struct {
+	ID            int64     `json:"id" db:"id"`
+	UserID        int64     `json:"user_id" db:"user_id"`
+	Name          string    `json:"name" db:"name"` // 同一用户内唯一
+	Description   string    `json:"description" db:"description"`
+	DocumentCount int       `json:"document_count" db:"-"` // 文档数量，查询时统计
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// KnowledgeDocument 知识库中的文档，原文保存在库中供后台切分和向量化
+type KnowledgeDocument struct {
+	ID              int64     `json:"id" db:"id"`
+	KnowledgeBaseID int64     `json:"knowledge_base_id" db:"knowledge_base_id"`
+	UserID          int64     `json:"user_id" db:"user_id"`
+	Name            string    `json:"name" db:"name"`
+	Format          string    `json:"format" db:"format"` // text/markdown/pdf_text
+	Content         string    `json:"-" db:"content"`
+	Size            int       `json:"size" db:"size"`               // 原文字节数
+	Status          string    `json:"status" db:"status"`           // pending/processing/ready/failed
+	Error           string    `json:"error,omitempty" db:"error"`   // 处理失败原因
+	ChunkCount      int       `json:"chunk_count" db:"chunk_count"` // 切分得到的片段数
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// KnowledgeChunk 文档切分后的片段及其向量
+type KnowledgeChunk struct {
+	ID              int64     `json:"id" db:"id"`
+	DocumentID      int64     `json:"document_id" db:"document_id"`
+	DocumentName    string    `json:"document_name" db:"-"` // 所属文档名称，检索时关联查询
+	KnowledgeBaseID int64     `json:"knowledge_base_id" db:"knowledge_base_id"`
+	Index           int       `json:"index" db:"chunk_index"` // 在文档中的序号，从0开始
+	Content         string    `json:"content" db:"content"`
+	Model           string    `json:"model" db:"model"` // 向量模型
+	Vector          []float32 `json:"-" db:"embedding"`
+}
+
+// KnowledgeRepository 知识库数据访问接口
+type KnowledgeRepository interface {
+	CreateBase(base *KnowledgeBase) error
+	GetBase(id int64) (*KnowledgeBase, error)
+	ListBases(userID int64) ([]*KnowledgeBase, error)
+	UpdateBase(base *KnowledgeBase) error
+	DeleteBase(id int64) error
+
+	CreateDocument(document *KnowledgeDocument) error
+	GetDocument(id int64) (*KnowledgeDocument, error)
+	// ListDocuments 获取知识库的文档列表（不含原文），按ID升序
+	ListDocuments(knowledgeBaseID int64) ([]*KnowledgeDocument, error)
+	DeleteDocument(id int64) error
+	// ClaimDocument 领取一个待处理的文档并标记为处理中；处理中超过 staleAfter 的文档视为中断，可被重新领取。
+	// 没有待处理文档时返回 nil
+	ClaimDocument(staleAfter time.Duration) (*KnowledgeDocument, error)
+	UpdateDocumentStatus(id int64, status, errMsg string, chunkCount int) error
+
+	// ReplaceChunks 替换文档的全部片段
+	ReplaceChunks(documentID int64, chunks []*KnowledgeChunk) error
+	// ListChunks 获取用户指定知识库中已就绪文档的指定向量模型片段
+	ListChunks(userID int64, knowledgeBaseIDs []int64, model string) ([]*KnowledgeChunk, error)
+}
+
+// KnowledgeRepositoryImpl 知识库数据访问实现，向量以 REAL[] 保存，相似度在服务端计算
+type KnowledgeRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewKnowledgeRepository 创建知识库仓库实例
+func NewKnowledgeRepository(db *sql.DB) KnowledgeRepository {
+	return &KnowledgeRepositoryImpl{db: db}
+}
+
+// knowledgeBaseColumns 知识库表查询列，附带文档数量
+const knowledgeBaseColumns = `b.id, b.user_id, b.name, b.description,
+	(SELECT COUNT(*) FROM knowledge_documents d WHERE d.knowledge_base_id = b.id), b.created_at, b.updated_at`
+
+// scanKnowledgeBase 扫描一行知识库
+func scanKnowledgeBase(row rowScanner) (*KnowledgeBase, error) {
+	base := &KnowledgeBase{}
+	err := row.Scan(
+		&base.ID,
+		&base.UserID,
+		&base.Name,
+		&base.Description,
+		&base.DocumentCount,
+		&base.CreatedAt,
+		&base.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return base, nil
+}
+
+// isUniqueViolation 判断是否违反唯一约束
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// CreateBase 创建知识库
+func (r *KnowledgeRepositoryImpl) CreateBase(base *KnowledgeBase) error {
+	query := `
+		INSERT INTO knowledge_bases (user_id, name, description, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	now := time.Now()
+	base.CreatedAt = now
+	base.UpdatedAt = now
+
+	err := r.db.QueryRow(query, base.UserID, base.Name, base.Description, base.CreatedAt, base.UpdatedAt).Scan(&base.ID)
+	if isUniqueViolation(err) {
+		return ErrKnowledgeBaseExists
+	}
+	return err
+}
+
+// GetBase 根据ID获取知识库
+func (r *KnowledgeRepositoryImpl) GetBase(id int64) (*KnowledgeBase, error) {
+	query := `SELECT ` + knowledgeBaseColumns + ` FROM knowledge_bases b WHERE b.id = $1`
+
+	base, err := scanKnowledgeBase(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrKnowledgeBaseNotFound
+		}
+		return nil, err
+	}
+
+	return base, nil
+}
+
+// ListBases 获取用户的知识库列表，按创建时间倒序
+func (r *KnowledgeRepositoryImpl) ListBases(userID int64) ([]*KnowledgeBase, error) {
+	query := `
+		SELECT ` + knowledgeBaseColumns + `
+		FROM knowledge_bases b
+		WHERE b.user_id = $1
+		ORDER BY b.created_at DESC, b.id DESC`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bases []*KnowledgeBase
+	for rows.Next() {
+		base, err := scanKnowledgeBase(rows)
+		if err != nil {
+			return nil, err
+		}
+		bases = append(bases, base)
+	}
+
+	return bases, rows.Err()
+}
+
+// UpdateBase 更新知识库名称和描述
+func (r *KnowledgeRepositoryImpl) UpdateBase(base *KnowledgeBase) error {
+	query := `UPDATE knowledge_bases SET name = $1, description = $2, updated_at = $3 WHERE id = $4`
+
+	base.UpdatedAt = time.Now()
+
+	result, err := r.db.Exec(query, base.Name, base.Description, base.UpdatedAt, base.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrKnowledgeBaseExists
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrKnowledgeBaseNotFound
+	}
+
+	return nil
+}
+
+// DeleteBase 删除知识库及其文档和片段
+func (r *KnowledgeRepositoryImpl) DeleteBase(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM knowledge_bases WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrKnowledgeBaseNotFound
+	}
+
+	return nil
+}
+
+// knowledgeDocumentColumns 文档表查询列（不含原文）
+const knowledgeDocumentColumns = `id, knowledge_base_id, user_id, name, format, size, status, error, chunk_count, created_at, updated_at`
+
+// scanKnowledgeDocument 扫描一行文档，withContent 为 true 时最后一列为原文
+func scanKnowledgeDocument(row rowScanner, withContent bool) (*KnowledgeDocument, error) {
+	document := &KnowledgeDocument{}
+	dest := []interface{}{
+		&document.ID,
+		&document.KnowledgeBaseID,
+		&document.UserID,
+		&document.Name,
+		&document.Format,
+		&document.Size,
+		&document.Status,
+		&document.Error,
+		&document.ChunkCount,
+		&document.CreatedAt,
+		&document.UpdatedAt,
+	}
+	if withContent {
+		dest = append(dest, &document.Content)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// CreateDocument 创建文档
+func (r *KnowledgeRepositoryImpl) CreateDocument(document *KnowledgeDocument) error {
+	query := `
+		INSERT INTO knowledge_documents (knowledge_base_id, user_id, name, format, content, size, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`
+
+	now := time.Now()
+	document.CreatedAt = now
+	document.UpdatedAt = now
+	if document.Status == "" {
+		document.Status = DocumentPending
+	}
+
+	return r.db.QueryRow(
+		query,
+		document.KnowledgeBaseID,
+		document.UserID,
+		document.Name,
+		document.Format,
+		document.Content,
+		document.Size,
+		document.Status,
+		document.CreatedAt,
+		document.UpdatedAt,
+	).Scan(&document.ID)
+}
+
+// GetDocument 根据ID获取文档（含原文）
+func (r *KnowledgeRepositoryImpl) GetDocument(id int64) (*KnowledgeDocument, error) {
+	query := `SELECT ` + knowledgeDocumentColumns + `, content FROM knowledge_documents WHERE id = $1`
+
+	document, err := scanKnowledgeDocument(r.db.QueryRow(query, id), true)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrKnowledgeDocumentNotFound
+		}
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// ListDocuments 获取知识库的文档列表
+func (r *KnowledgeRepositoryImpl) ListDocuments(knowledgeBaseID int64) ([]*KnowledgeDocument, error) {
+	query := `
+		SELECT ` + knowledgeDocumentColumns + `
+		FROM knowledge_documents
+		WHERE knowledge_base_id = $1
+		ORDER BY id ASC`
+
+	rows, err := r.db.Query(query, knowledgeBaseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []*KnowledgeDocument
+	for rows.Next() {
+		document, err := scanKnowledgeDocument(rows, false)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+
+	return documents, rows.Err()
+}
+
+// DeleteDocument 删除文档及其片段
+func (r *KnowledgeRepositoryImpl) DeleteDocument(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM knowledge_documents WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrKnowledgeDocumentNotFound
+	}
+
+	return nil
+}
+
+// ClaimDocument 领取待处理的文档，SKIP LOCKED 保证多实例不会领取同一文档
+func (r *KnowledgeRepositoryImpl) ClaimDocument(staleAfter time.Duration) (*KnowledgeDocument, error) {
+	query := `
+		UPDATE knowledge_documents SET status = $1, error = '', updated_at = $2
+		WHERE id = (
+			SELECT id FROM knowledge_documents
+			WHERE status = $3 OR (status = $1 AND updated_at < $4)
+			ORDER BY id ASC
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + knowledgeDocumentColumns + `, content`
+
+	now := time.Now()
+	document, err := scanKnowledgeDocument(r.db.QueryRow(query, DocumentProcessing, now, DocumentPending, now.Add(-staleAfter)), true)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return document, err
+}
+
+// UpdateDocumentStatus 更新文档处理状态
+func (r *KnowledgeRepositoryImpl) UpdateDocumentStatus(id int64, status, errMsg string, chunkCount int) error {
+	query := `UPDATE knowledge_documents SET status = $1, error = $2, chunk_count = $3, updated_at = $4 WHERE id = $5`
+
+	result, err := r.db.Exec(query, status, errMsg, chunkCount, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrKnowledgeDocumentNotFound
+	}
+
+	return nil
+}
+
+// ReplaceChunks 在事务中删除文档原有片段并写入新片段
+func (r *KnowledgeRepositoryImpl) ReplaceChunks(documentID int64, chunks []*KnowledgeChunk) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM knowledge_chunks WHERE document_id = $1`, documentID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO knowledge_chunks (document_id, knowledge_base_id, chunk_index, content, model, embedding)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	for _, chunk := range chunks {
+		chunk.DocumentID = documentID
+		err := tx.QueryRow(
+			query,
+			chunk.DocumentID,
+			chunk.KnowledgeBaseID,
+			chunk.Index,
+			chunk.Content,
+			chunk.Model,
+			pq.Array(chunk.Vector),
+		).Scan(&chunk.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListChunks 获取知识库片段及其向量
+func (r *KnowledgeRepositoryImpl) ListChunks(userID int64, knowledgeBaseIDs []int64, model string) ([]*KnowledgeChunk, error) {
+	query := `
+		SELECT c.id, c.document_id, d.name, c.knowledge_base_id, c.chunk_index, c.content, c.model, c.embedding
+		FROM knowledge_chunks c
+		JOIN knowledge_documents d ON d.id = c.document_id
+		JOIN knowledge_bases b ON b.id = c.knowledge_base_id
+		WHERE b.user_id = $1 AND c.knowledge_base_id = ANY($2) AND c.model = $3 AND d.status = $4`
+
+	rows, err := r.db.Query(query, userID, pq.Array(knowledgeBaseIDs), model, DocumentReady)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunks []*KnowledgeChunk
+	for rows.Next() {
+		chunk := &KnowledgeChunk{}
+		var vector pq.Float32Array
+		err := rows.Scan(&chunk.ID, &chunk.DocumentID, &chunk.DocumentName, &chunk.KnowledgeBaseID, &chunk.Index, &chunk.Content, &chunk.Model, &vector)
+		if err != nil {
+			return nil, err
+		}
+		chunk.Vector = vector
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, rows.Err()
+}
@@ -0,0 +1,199 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrModerationEventNotFound 审核事件未找到错误
+var ErrModerationEventNotFound = errors.New("moderation event not found")
+
+// 消息内容安全状态
+const (
+	ModerationFlagged  = "flagged"  // 命中护栏但放行，等待复核
+	ModerationBlocked  = "blocked"  // 命中护栏被屏蔽，消息内容已替换为提示语
+	ModerationApproved = "approved" // 管理员复核后放行，屏蔽的消息恢复原始内容
+)
+
+// 审核事件复核状态
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// ModerationEvent 内容安全审核事件，记录命中护栏的输入或输出
+type ModerationEvent struct {
+	ID             int64      `json:"id" db:"id"`
+	ConversationID int64      `json:"conversation_id" db:"conversation_id"`
+	MessageID      int64      `json:"message_id" db:"message_id"`
+	UserID         int64      `json:"user_id" db:"user_id"`
+	Stage          string     `json:"stage" db:"stage"`                 // input/output
+	Action         string     `json:"action" db:"action"`               // flag/block
+	Guard          string     `json:"guard" db:"guard"`                 // 命中的护栏名称
+	Reason         string     `json:"reason" db:"reason"`               // 命中原因
+	Content        string     `json:"content" db:"content"`             // 原始内容，屏蔽的消息只在此保存原文
+	ReviewStatus   string     `json:"review_status" db:"review_status"` // pending/approved/rejected
+	ReviewerID     int64      `json:"reviewer_id" db:"reviewer_id"`
+	ReviewNote     string     `json:"review_note" db:"review_note"`
+	ReviewedAt     *time.Time `json:"reviewed_at,omitempty" db:"reviewed_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ModerationRepository 审核事件数据访问接口
+type ModerationRepository interface {
+	Create(event *ModerationEvent) error
+	GetByID(id int64) (*ModerationEvent, error)
+	List(reviewStatus string, limit, offset int) ([]*ModerationEvent, error)
+	Count(reviewStatus string) (int, error)
+	Review(id int64, reviewStatus string, reviewerID int64, note string) error
+}
+
+// ModerationRepositoryImpl 审核事件数据访问实现
+type ModerationRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewModerationRepository 创建审核事件仓库实例
+func NewModerationRepository(db *sql.DB) ModerationRepository {
+	return &ModerationRepositoryImpl{db: db}
+}
+
+// moderationColumns 审核事件表查询列
+const moderationColumns = `id, conversation_id, message_id, user_id, stage, action, guard, reason, content, review_status, reviewer_id, review_note, reviewed_at, created_at`
+
+// scanModerationEvent 扫描一行审核事件
+func scanModerationEvent(row rowScanner) (*ModerationEvent, error) {
+	event := &ModerationEvent{}
+	var reviewedAt sql.NullTime
+	err := row.Scan(
+		&event.ID,
+		&event.ConversationID,
+		&event.MessageID,
+		&event.UserID,
+		&event.Stage,
+		&event.Action,
+		&event.Guard,
+		&event.Reason,
+		&event.Content,
+		&event.ReviewStatus,
+		&event.ReviewerID,
+		&event.ReviewNote,
+		&reviewedAt,
+		&event.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if reviewedAt.Valid {
+		event.ReviewedAt = &reviewedAt.Time
+	}
+	return event, nil
+}
+
+// Create 创建审核事件
+func (r *ModerationRepositoryImpl) Create(event *ModerationEvent) error {
+	query := `
+		INSERT INTO moderation_events (conversation_id, message_id, user_id, stage, action, guard, reason, content, review_status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id`
+
+	event.CreatedAt = time.Now()
+	if event.ReviewStatus == "" {
+		event.ReviewStatus = ReviewPending
+	}
+
+	return r.db.QueryRow(
+		query,
+		event.ConversationID,
+		event.MessageID,
+		event.UserID,
+		event.Stage,
+		event.Action,
+		event.Guard,
+		event.Reason,
+		event.Content,
+		event.ReviewStatus,
+		event.CreatedAt,
+	).Scan(&event.ID)
+}
+
+// GetByID 根据ID获取审核事件
+func (r *ModerationRepositoryImpl) GetByID(id int64) (*ModerationEvent, error) {
+	query := `SELECT ` + moderationColumns + ` FROM moderation_events WHERE id = $1`
+
+	event, err := scanModerationEvent(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrModerationEventNotFound
+		}
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// List 按复核状态获取审核事件列表，reviewStatus 为空时返回全部，按创建时间倒序
+func (r *ModerationRepositoryImpl) List(reviewStatus string, limit, offset int) ([]*ModerationEvent, error) {
+	query := `
+		SELECT ` + moderationColumns + `
+		FROM moderation_events
+		WHERE ($1 = '' OR review_status = $1)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.Query(query, reviewStatus, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*ModerationEvent
+	for rows.Next() {
+		event, err := scanModerationEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// Count 按复核状态统计审核事件数量，reviewStatus 为空时统计全部
+func (r *ModerationRepositoryImpl) Count(reviewStatus string) (int, error) {
+	query := `SELECT COUNT(*) FROM moderation_events WHERE ($1 = '' OR review_status = $1)`
+
+	var count int
+	err := r.db.QueryRow(query, reviewStatus).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Review 记录复核结果
+func (r *ModerationRepositoryImpl) Review(id int64, reviewStatus string, reviewerID int64, note string) error {
+	query := `
+		UPDATE moderation_events
+		SET review_status = $1, reviewer_id = $2, review_note = $3, reviewed_at = $4
+		WHERE id = $5`
+
+	result, err := r.db.Exec(query, reviewStatus, reviewerID, note, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrModerationEventNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,352 @@
+package model
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 提示词模板错误
+var (
+	ErrPromptTemplateNotFound        = errors.New("prompt template not found")
+	ErrPromptTemplateExists          = errors.New("prompt template name already exists")
+	ErrPromptTemplateVersionNotFound = errors.New("prompt template version not found")
+	ErrInvalidTemplateVariables      = errors.New("invalid template variables")
+)
+
+// SystemOwnerID 系统模板的所有者ID，系统模板对所有用户可见，只有管理员可以修改
+const SystemOwnerID int64 = 0
+
+// TemplateVariable 模板变量，模板文本中以 {{name}} 引用
+type TemplateVariable struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"` // 必填变量须提供非空值
+	Default     string `json:"default,omitempty"`  // 未提供时使用的值
+}
+
+// TemplateVariables 模板变量列表，以JSONB存储
+type TemplateVariables []TemplateVariable
+
+// Value 实现driver.Valuer接口
+func (v TemplateVariables) Value() (driver.Value, error) {
+	if v == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(v)
+}
+
+// Scan 实现sql.Scanner接口
+func (v *TemplateVariables) Scan(src interface{}) error {
+	switch data := src.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(data, v)
+	case string:
+		return json.Unmarshal([]byte(data), v)
+	default:
+		return fmt.Errorf("unsupported type for TemplateVariables: %T", src)
+	}
+}
+
+// PromptTemplate 提示词模板，每次修改生成新版本，历史版本保留在 prompt_template_versions 中
+type PromptTemplate struct {
+	ID           int64             `json:"id" db:"id"`
+	OwnerID      int64             `json:"owner_id" db:"owner_id"` // 0 表示系统模板
+	Name         string            `json:"name" db:"name"`         // 同一所有者内唯一
+	Description  string            `json:"description" db:"description"`
+	SystemPrompt string            `json:"system_prompt" db:"system_prompt"` // 以模板创建对话时作为系统提示
+	Content      string            `json:"content" db:"content"`             // 以模板发送消息时作为用户消息
+	Variables    TemplateVariables `json:"variables" db:"variables"`
+	Version      int               `json:"version" db:"version"` // 当前版本，从1开始
+	CreatedAt    time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// IsSystem 是否为系统模板
+func (t *PromptTemplate) IsSystem() bool {
+	return t.OwnerID == SystemOwnerID
+}
+
+// PromptTemplateVersion 模板的历史版本
+type PromptTemplateVersion struct {
+	TemplateID   int64             `json:"template_id" db:"template_id"`
+	Version      int               `json:"version" db:"version"`
+	SystemPrompt string            `json:"system_prompt" db:"system_prompt"`
+	Content      string            `json:"content" db:"content"`
+	Variables    TemplateVariables `json:"variables" db:"variables"`
+	CreatedAt    time.Time         `json:"created_at" db:"created_at"`
+}
+
+// RenderedPrompt 按变量渲染后的模板
+type RenderedPrompt struct {
+	TemplateID   int64  `json:"template_id"`
+	Version      int    `json:"version"`
+	Name         string `json:"name"`
+	SystemPrompt string `json:"system_prompt"`
+	Content      string `json:"content"`
+}
+
+// PromptTemplateRepository 提示词模板仓库接口
+type PromptTemplateRepository interface {
+	Create(template *PromptTemplate) error
+	GetByID(id int64) (*PromptTemplate, error)
+	List(ownerID int64) ([]*PromptTemplate, error)
+	Update(template *PromptTemplate) error
+	Delete(id int64) error
+	GetVersion(templateID int64, version int) (*PromptTemplateVersion, error)
+	ListVersions(templateID int64) ([]*PromptTemplateVersion, error)
+}
+
+// PromptTemplateRepositoryImpl 提示词模板仓库实现
+type PromptTemplateRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewPromptTemplateRepository 创建提示词模板仓库实例
+func NewPromptTemplateRepository(db *sql.DB) PromptTemplateRepository {
+	return &PromptTemplateRepositoryImpl{db: db}
+}
+
+// promptTemplateColumns 模板表查询列
+const promptTemplateColumns = `id, owner_id, name, description, system_prompt, content, variables, version, created_at, updated_at`
+
+// scanPromptTemplate 扫描一行模板
+func scanPromptTemplate(row rowScanner) (*PromptTemplate, error) {
+	template := &PromptTemplate{}
+	err := row.Scan(
+		&template.ID,
+		&template.OwnerID,
+		&template.Name,
+		&template.Description,
+		&template.SystemPrompt,
+		&template.Content,
+		&template.Variables,
+		&template.Version,
+		&template.CreatedAt,
+		&template.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// insertVersion 保存模板当前内容为一个版本
+func insertVersion(tx *sql.Tx, template *PromptTemplate) error {
+	_, err := tx.Exec(`
+		INSERT INTO prompt_template_versions (template_id, version, system_prompt, content, variables, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		template.ID,
+		template.Version,
+		template.SystemPrompt,
+		template.Content,
+		template.Variables,
+		template.UpdatedAt,
+	)
+	return err
+}
+
+// Create 创建模板及其第1个版本
+func (r *PromptTemplateRepositoryImpl) Create(template *PromptTemplate) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	template.Version = 1
+	template.CreatedAt = now
+	template.UpdatedAt = now
+
+	err = tx.QueryRow(`
+		INSERT INTO prompt_templates (owner_id, name, description, system_prompt, content, variables, version, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`,
+		template.OwnerID,
+		template.Name,
+		template.Description,
+		template.SystemPrompt,
+		template.Content,
+		template.Variables,
+		template.Version,
+		template.CreatedAt,
+		template.UpdatedAt,
+	).Scan(&template.ID)
+	if isUniqueViolation(err) {
+		return ErrPromptTemplateExists
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := insertVersion(tx, template); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetByID 根据ID获取模板（当前版本）
+func (r *PromptTemplateRepositoryImpl) GetByID(id int64) (*PromptTemplate, error) {
+	query := `SELECT ` + promptTemplateColumns + ` FROM prompt_templates WHERE id = $1`
+
+	template, err := scanPromptTemplate(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPromptTemplateNotFound
+		}
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// List 获取系统模板和指定用户的模板，系统模板在前，同类按名称排序
+func (r *PromptTemplateRepositoryImpl) List(ownerID int64) ([]*PromptTemplate, error) {
+	query := `
+		SELECT ` + promptTemplateColumns + `
+		FROM prompt_templates
+		WHERE owner_id = $1 OR owner_id = $2
+		ORDER BY owner_id = $1 DESC, name ASC, id ASC`
+
+	rows, err := r.db.Query(query, SystemOwnerID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []*PromptTemplate
+	for rows.Next() {
+		template, err := scanPromptTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+
+	return templates, rows.Err()
+}
+
+// Update 修改模板，版本号加1并保存新版本
+func (r *PromptTemplateRepositoryImpl) Update(template *PromptTemplate) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	template.UpdatedAt = time.Now()
+	err = tx.QueryRow(`
+		UPDATE prompt_templates
+		SET name = $1, description = $2, system_prompt = $3, content = $4, variables = $5, version = version + 1, updated_at = $6
+		WHERE id = $7
+		RETURNING version`,
+		template.Name,
+		template.Description,
+		template.SystemPrompt,
+		template.Content,
+		template.Variables,
+		template.UpdatedAt,
+		template.ID,
+	).Scan(&template.Version)
+	if isUniqueViolation(err) {
+		return ErrPromptTemplateExists
+	}
+	if err == sql.ErrNoRows {
+		return ErrPromptTemplateNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := insertVersion(tx, template); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete 删除模板及其历史版本
+func (r *PromptTemplateRepositoryImpl) Delete(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM prompt_templates WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrPromptTemplateNotFound
+	}
+
+	return nil
+}
+
+// promptTemplateVersionColumns 版本表查询列
+const promptTemplateVersionColumns = `template_id, version, system_prompt, content, variables, created_at`
+
+// scanPromptTemplateVersion 扫描一行模板版本
+func scanPromptTemplateVersion(row rowScanner) (*PromptTemplateVersion, error) {
+	version := &PromptTemplateVersion{}
+	err := row.Scan(
+		&version.TemplateID,
+		&version.Version,
+		&version.SystemPrompt,
+		&version.Content,
+		&version.Variables,
+		&version.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// GetVersion 获取模板的指定版本
+func (r *PromptTemplateRepositoryImpl) GetVersion(templateID int64, version int) (*PromptTemplateVersion, error) {
+	query := `SELECT ` + promptTemplateVersionColumns + ` FROM prompt_template_versions WHERE template_id = $1 AND version = $2`
+
+	result, err := scanPromptTemplateVersion(r.db.QueryRow(query, templateID, version))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPromptTemplateVersionNotFound
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ListVersions 获取模板的全部版本，新版本在前
+func (r *PromptTemplateRepositoryImpl) ListVersions(templateID int64) ([]*PromptTemplateVersion, error) {
+	query := `
+		SELECT ` + promptTemplateVersionColumns + `
+		FROM prompt_template_versions
+		WHERE template_id = $1
+		ORDER BY version DESC`
+
+	rows, err := r.db.Query(query, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*PromptTemplateVersion
+	for rows.Next() {
+		version, err := scanPromptTemplateVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
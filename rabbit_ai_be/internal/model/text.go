@@ -0,0 +1,20 @@
+package model
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxErrorLength 保存到数据库的错误信息最大字符数
+const MaxErrorLength = 500
+
+// TruncateError 按字符截断过长的错误信息，并替换无效的UTF-8字节，保证可写入 TEXT 列
+//
+// 上游错误响应体可能包含多字节文本或不完整的字节序列，按字节截断会产生数据库拒绝的无效UTF-8。
+func TruncateError(message string) string {
+	message = strings.ToValidUTF8(message, "�")
+	if utf8.RuneCountInString(message) <= MaxErrorLength {
+		return message
+	}
+	return string([]rune(message)[:MaxErrorLength])
+}
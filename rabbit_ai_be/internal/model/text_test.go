@@ -0,0 +1,24 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateError(t *testing.T) {
+	if got := TruncateError("upstream error"); got != "upstream error" {
+		t.Errorf("Expected short message unchanged, got %q", got)
+	}
+
+	// 多字节字符按字符截断，结果仍为有效UTF-8
+	long := "a" + strings.Repeat("内容审核未通过", 100)
+	got := TruncateError(long)
+	if !utf8.ValidString(got) || utf8.RuneCountInString(got) != MaxErrorLength {
+		t.Errorf("Expected %d valid runes, got %d (valid %v)", MaxErrorLength, utf8.RuneCountInString(got), utf8.ValidString(got))
+	}
+
+	if got := TruncateError("bad \xe5\x86 bytes"); !utf8.ValidString(got) {
+		t.Errorf("Expected invalid bytes to be replaced, got %q", got)
+	}
+}
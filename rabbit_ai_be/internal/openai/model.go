@@ -0,0 +1,162 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ChatCompletionRequest OpenAI兼容聊天完成请求
+type ChatCompletionRequest struct {
+	Model         string         `json:"model"`
+	Messages      []ChatMessage  `json:"messages"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	Temperature   float64        `json:"temperature,omitempty"`
+	TopP          float64        `json:"top_p,omitempty"`
+	MaxTokens     int            `json:"max_tokens,omitempty"`
+	Stop          []string       `json:"stop,omitempty"`
+	User          string         `json:"user,omitempty"`
+	Tools         []Tool         `json:"tools,omitempty"`
+}
+
+// Tool 工具定义
+type Tool struct {
+	Type     string       `json:"type"` // 目前仅支持 "function"
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction 函数工具定义
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"` // 参数的JSON Schema
+}
+
+// ToolCall 工具调用
+type ToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// StreamOptions 流式响应选项
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"` // 在最后一个片段中返回使用统计
+}
+
+// ChatMessage 聊天消息
+type ChatMessage struct {
+	Role       string        `json:"role"`
+	Name       string        `json:"name,omitempty"`
+	Content    string        `json:"content"`
+	Parts      []ContentPart `json:"-"` // 图文内容片段，非空时以数组形式代替 Content 发送
+	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`
+	ToolCallID string        `json:"tool_call_id,omitempty"`
+}
+
+// MarshalJSON 有图文内容片段时将 content 编码为片段数组
+func (m ChatMessage) MarshalJSON() ([]byte, error) {
+	type plain ChatMessage
+	if len(m.Parts) == 0 {
+		return json.Marshal(plain(m))
+	}
+	return json.Marshal(struct {
+		plain
+		Content []ContentPart `json:"content"`
+	}{plain(m), m.Parts})
+}
+
+// ContentPart 图文消息的内容片段
+type ContentPart struct {
+	Type     string    `json:"type"` // text/image_url
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL 图片地址，支持 data URL（data:image/png;base64,...）
+type ImageURL struct {
+	URL string `json:"url"`
+}
+
+// ChatCompletionResponse OpenAI兼容聊天完成响应（流式片段复用该结构）
+type ChatCompletionResponse struct {
+	ID      string   `json:"id"`
+	Object  string   `json:"object"`
+	Created int64    `json:"created"`
+	Model   string   `json:"model"`
+	Choices []Choice `json:"choices"`
+	Usage   *Usage   `json:"usage,omitempty"`
+}
+
+// Choice 选择项
+type Choice struct {
+	Index        int          `json:"index"`
+	Message      ChatMessage  `json:"message"`
+	Delta        *ChatMessage `json:"delta,omitempty"` // 流式响应增量
+	FinishReason string       `json:"finish_reason"`
+}
+
+// Usage 使用统计
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ErrorResponse 错误响应
+type ErrorResponse struct {
+	Error struct {
+		Message string      `json:"message"`
+		Type    string      `json:"type"`
+		Code    interface{} `json:"code"`
+	} `json:"error"`
+}
+
+// APIError 上游返回非200 HTTP状态码
+type APIError struct {
+	StatusCode int
+	Message    string // 错误响应中的错误信息，无法解析时为原始响应体
+	Parsed     bool   // 是否为OpenAI格式的错误响应
+}
+
+// Error 实现error接口
+func (e *APIError) Error() string {
+	if e.Parsed {
+		return fmt.Sprintf("OpenAI API error: status %d - %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Message)
+}
+
+// Failover 限流（含额度用尽）、需要付费与5xx错误时切换到备用模型
+func (e *APIError) Failover() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusPaymentRequired || e.StatusCode >= http.StatusInternalServerError
+}
+
+// ModelList 模型列表响应
+type ModelList struct {
+	Object string `json:"object"`
+	Data   []struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		OwnedBy string `json:"owned_by"`
+	} `json:"data"`
+}
+
+// Config OpenAI兼容接口配置
+type Config struct {
+	APIKey  string
+	BaseURL string
+	Models  []string // 可选，静态模型列表；为空时调用 /models 接口获取
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig(apiKey string) Config {
+	return Config{
+		APIKey:  apiKey,
+		BaseURL: "https://api.openai.com/v1",
+	}
+}
@@ -0,0 +1,317 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"rabbit_ai/internal/llm"
+)
+
+// ProviderName OpenAI兼容提供方名称
+const ProviderName = "openai"
+
+// Provider OpenAI兼容接口（OpenAI、DeepSeek、vLLM、Ollama等）的llm.Provider实现
+type Provider struct {
+	config       Config
+	client       *http.Client
+	streamClient *http.Client // 流式请求专用，不设总超时
+}
+
+// NewProvider 创建OpenAI兼容提供方实例
+func NewProvider(config Config) *Provider {
+	return &Provider{
+		config: config,
+		client: &http.Client{
+			Timeout: llm.RequestTimeout,
+		},
+		streamClient: llm.NewStreamClient(),
+	}
+}
+
+// Name 提供方名称
+func (p *Provider) Name() string {
+	return ProviderName
+}
+
+// ChatCompletion 聊天完成
+func (p *Provider) ChatCompletion(ctx context.Context, request llm.ChatRequest) (*llm.ChatResponse, error) {
+	resp, err := p.doRequest(ctx, p.client, "POST", "/chat/completions", toChatCompletionRequest(request, false))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// 读取响应体
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseError(resp.StatusCode, body)
+	}
+
+	// 解析响应
+	var response ChatCompletionResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	result := &llm.ChatResponse{
+		ID:    response.ID,
+		Model: response.Model,
+	}
+	if len(response.Choices) > 0 {
+		choice := response.Choices[0]
+		result.Message = fromChatMessage(choice.Message)
+		result.FinishReason = choice.FinishReason
+	}
+	if response.Usage != nil {
+		result.Usage = fromUsage(*response.Usage)
+	}
+
+	return result, nil
+}
+
+// ChatCompletionStream 流式聊天完成
+func (p *Provider) ChatCompletionStream(ctx context.Context, request llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	resp, err := p.doRequest(ctx, p.streamClient, "POST", "/chat/completions", toChatCompletionRequest(request, true))
+	if err != nil {
+		return nil, err
+	}
+
+	// 检查HTTP状态码
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, parseError(resp.StatusCode, body)
+	}
+
+	chunkChan := make(chan llm.StreamChunk, 10)
+
+	// 在goroutine中处理流式响应
+	go func() {
+		defer resp.Body.Close()
+		defer close(chunkChan)
+
+		// send 发送片段，ctx结束时放弃发送并返回false
+		send := func(chunk llm.StreamChunk) bool {
+			select {
+			case chunkChan <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				// ctx取消导致的读取错误无需再通知调用方
+				if err != io.EOF && ctx.Err() == nil {
+					send(llm.StreamChunk{Err: fmt.Errorf("stream read error: %w", err)})
+				}
+				return
+			}
+
+			// 处理SSE格式的数据
+			line = strings.TrimSpace(line)
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "[DONE]" {
+				return
+			}
+
+			var response ChatCompletionResponse
+			if err := json.Unmarshal([]byte(data), &response); err != nil {
+				continue // 跳过无效的JSON
+			}
+
+			var chunk llm.StreamChunk
+			if len(response.Choices) > 0 {
+				choice := response.Choices[0]
+				if choice.Delta != nil {
+					chunk.Content = choice.Delta.Content
+				}
+				chunk.FinishReason = choice.FinishReason
+			}
+			if response.Usage != nil {
+				usage := fromUsage(*response.Usage)
+				chunk.Usage = &usage
+			}
+			if !send(chunk) {
+				return
+			}
+		}
+	}()
+
+	return chunkChan, nil
+}
+
+// ListModels 获取可用模型列表
+func (p *Provider) ListModels(ctx context.Context) ([]llm.ModelInfo, error) {
+	// 配置了静态模型列表时直接返回，避免依赖 /models 接口
+	if len(p.config.Models) > 0 {
+		models := make([]llm.ModelInfo, 0, len(p.config.Models))
+		for _, id := range p.config.Models {
+			models = append(models, llm.ModelInfo{ID: id, Provider: ProviderName})
+		}
+		return models, nil
+	}
+
+	resp, err := p.doRequest(ctx, p.client, "GET", "/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseError(resp.StatusCode, body)
+	}
+
+	var list ModelList
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal model list: %w", err)
+	}
+
+	models := make([]llm.ModelInfo, 0, len(list.Data))
+	for _, m := range list.Data {
+		models = append(models, llm.ModelInfo{ID: m.ID, Provider: ProviderName})
+	}
+	return models, nil
+}
+
+// doRequest 使用指定客户端发送HTTP请求
+func (p *Provider) doRequest(ctx context.Context, client *http.Client, method, path string, payload interface{}) (*http.Response, error) {
+	url := strings.TrimRight(p.config.BaseURL, "/") + path
+
+	var body io.Reader
+	if payload != nil {
+		requestBody, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewBuffer(requestBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// 设置请求头
+	if p.config.APIKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.config.APIKey))
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return resp, nil
+}
+
+// toChatCompletionRequest 将通用请求转换为OpenAI兼容请求
+func toChatCompletionRequest(request llm.ChatRequest, stream bool) *ChatCompletionRequest {
+	messages := make([]ChatMessage, 0, len(request.Messages))
+	for _, msg := range request.Messages {
+		message := ChatMessage{
+			Role:       msg.Role,
+			Name:       msg.Name,
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, part := range msg.Parts {
+			if part.Type == llm.PartImageURL {
+				message.Parts = append(message.Parts, ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: part.ImageURL}})
+			} else {
+				message.Parts = append(message.Parts, ContentPart{Type: "text", Text: part.Text})
+			}
+		}
+		for _, call := range msg.ToolCalls {
+			toolCall := ToolCall{ID: call.ID, Type: "function"}
+			toolCall.Function.Name = call.Name
+			toolCall.Function.Arguments = call.Arguments
+			message.ToolCalls = append(message.ToolCalls, toolCall)
+		}
+		messages = append(messages, message)
+	}
+
+	req := &ChatCompletionRequest{
+		Model:       request.Model,
+		Messages:    messages,
+		Stream:      stream,
+		Temperature: request.Temperature,
+		TopP:        request.TopP,
+		MaxTokens:   request.MaxTokens,
+		Stop:        request.Stop,
+		User:        request.User,
+	}
+	if stream {
+		req.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+	for _, tool := range request.Tools {
+		req.Tools = append(req.Tools, Tool{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return req
+}
+
+// fromChatMessage 将OpenAI消息转换为通用消息
+func fromChatMessage(msg ChatMessage) llm.Message {
+	message := llm.Message{
+		Role:       msg.Role,
+		Name:       msg.Name,
+		Content:    msg.Content,
+		ToolCallID: msg.ToolCallID,
+	}
+	for _, call := range msg.ToolCalls {
+		message.ToolCalls = append(message.ToolCalls, llm.ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return message
+}
+
+// parseError 解析错误响应
+func parseError(statusCode int, body []byte) error {
+	var errResp ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+		return &APIError{StatusCode: statusCode, Message: errResp.Error.Message, Parsed: true}
+	}
+	return &APIError{StatusCode: statusCode, Message: string(body)}
+}
+
+// fromUsage 将OpenAI使用统计转换为通用使用统计
+func fromUsage(usage Usage) llm.Usage {
+	return llm.Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+}
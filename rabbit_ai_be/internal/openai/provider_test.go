@@ -0,0 +1,232 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rabbit_ai/internal/llm"
+)
+
+func TestProvider_ChatCompletion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("Expected path /chat/completions, got %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("Expected bearer auth header, got %s", r.Header.Get("Authorization"))
+		}
+
+		var req ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if req.Model != "gpt-4o-mini" {
+			t.Errorf("Expected model gpt-4o-mini, got %s", req.Model)
+		}
+		if len(req.Messages) != 1 || req.Messages[0].Content != "你好" {
+			t.Errorf("Unexpected messages: %+v", req.Messages)
+		}
+
+		json.NewEncoder(w).Encode(ChatCompletionResponse{
+			ID:    "chatcmpl-1",
+			Model: req.Model,
+			Choices: []Choice{
+				{
+					Message:      ChatMessage{Role: "assistant", Content: "你好！"},
+					FinishReason: "stop",
+				},
+			},
+			Usage: &Usage{PromptTokens: 5, CompletionTokens: 3, TotalTokens: 8},
+		})
+	}))
+	defer server.Close()
+
+	provider := NewProvider(Config{APIKey: "test-key", BaseURL: server.URL})
+
+	response, err := provider.ChatCompletion(context.Background(), llm.ChatRequest{
+		Model:    "gpt-4o-mini",
+		Messages: []llm.Message{{Role: "user", Content: "你好"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to chat: %v", err)
+	}
+
+	if response.GetContent() != "你好！" {
+		t.Errorf("Expected content '你好！', got '%s'", response.GetContent())
+	}
+	if response.FinishReason != "stop" {
+		t.Errorf("Expected finish reason stop, got %s", response.FinishReason)
+	}
+	if response.Usage.TotalTokens != 8 {
+		t.Errorf("Expected total tokens 8, got %d", response.Usage.TotalTokens)
+	}
+}
+
+func TestProvider_ChatCompletionError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"invalid api key","type":"invalid_request_error"}}`))
+	}))
+	defer server.Close()
+
+	provider := NewProvider(Config{APIKey: "bad-key", BaseURL: server.URL})
+
+	_, err := provider.ChatCompletion(context.Background(), llm.ChatRequest{
+		Model:    "gpt-4o-mini",
+		Messages: []llm.Message{{Role: "user", Content: "你好"}},
+	})
+	if err == nil {
+		t.Fatal("Expected error for unauthorized response")
+	}
+}
+
+func TestProvider_ChatCompletionStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"你\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"好\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":2,\"total_tokens\":7}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider := NewProvider(Config{BaseURL: server.URL})
+
+	chunkChan, err := provider.ChatCompletionStream(context.Background(), llm.ChatRequest{
+		Model:    "gpt-4o-mini",
+		Messages: []llm.Message{{Role: "user", Content: "你好"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to start stream: %v", err)
+	}
+
+	var content, finishReason string
+	var usage *llm.Usage
+	for chunk := range chunkChan {
+		if chunk.Err != nil {
+			t.Fatalf("Unexpected stream error: %v", chunk.Err)
+		}
+		content += chunk.Content
+		if chunk.FinishReason != "" {
+			finishReason = chunk.FinishReason
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+
+	if content != "你好" {
+		t.Errorf("Expected content '你好', got '%s'", content)
+	}
+	if finishReason != "stop" {
+		t.Errorf("Expected finish reason stop, got %s", finishReason)
+	}
+	if usage == nil || usage.TotalTokens != 7 {
+		t.Errorf("Expected usage with 7 total tokens, got %+v", usage)
+	}
+}
+
+func TestProvider_ChatCompletionStreamOutlastsClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for _, content := range []string{"你", "好", "呀"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"%s\"}}]}\n\n", content)
+			flusher.Flush()
+			time.Sleep(100 * time.Millisecond)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider := NewProvider(Config{BaseURL: server.URL})
+	// 整个流约需300ms，超过非流式请求的总超时
+	provider.client.Timeout = 100 * time.Millisecond
+
+	chunkChan, err := provider.ChatCompletionStream(context.Background(), llm.ChatRequest{
+		Model:    "gpt-4o-mini",
+		Messages: []llm.Message{{Role: "user", Content: "你好"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to start stream: %v", err)
+	}
+
+	var content string
+	for chunk := range chunkChan {
+		if chunk.Err != nil {
+			t.Fatalf("Expected stream not to be cut off, got %v", chunk.Err)
+		}
+		content += chunk.Content
+	}
+	if content != "你好呀" {
+		t.Errorf("Expected content '你好呀', got '%s'", content)
+	}
+}
+
+func TestProvider_ListModels(t *testing.T) {
+	// 静态模型列表
+	provider := NewProvider(Config{Models: []string{"deepseek-chat", "deepseek-reasoner"}})
+	models, err := provider.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list models: %v", err)
+	}
+	if len(models) != 2 || models[0].ID != "deepseek-chat" {
+		t.Errorf("Unexpected models: %+v", models)
+	}
+
+	// 从 /models 接口获取
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models" {
+			t.Errorf("Expected path /models, got %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"object":"list","data":[{"id":"qwen2.5-7b","object":"model","owned_by":"vllm"}]}`))
+	}))
+	defer server.Close()
+
+	provider = NewProvider(Config{BaseURL: server.URL})
+	models, err = provider.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list models: %v", err)
+	}
+	if len(models) != 1 || models[0].ID != "qwen2.5-7b" || models[0].Provider != ProviderName {
+		t.Errorf("Unexpected models: %+v", models)
+	}
+}
+
+func TestChatMessage_MarshalParts(t *testing.T) {
+	req := toChatCompletionRequest(llm.ChatRequest{
+		Model: "gpt-4o",
+		Messages: []llm.Message{
+			{Role: "system", Content: "你是助手"},
+			{Role: "user", Content: "这是什么", Parts: []llm.Part{
+				{Type: llm.PartText, Text: "这是什么"},
+				{Type: llm.PartImageURL, ImageURL: "data:image/png;base64,AA=="},
+			}},
+		},
+	}, false)
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	var wire struct {
+		Messages []struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		t.Fatalf("Failed to decode request: %v", err)
+	}
+	if string(wire.Messages[0].Content) != `"你是助手"` {
+		t.Errorf("Expected plain string content, got %s", wire.Messages[0].Content)
+	}
+	expected := `[{"type":"text","text":"这是什么"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AA=="}}]`
+	if string(wire.Messages[1].Content) != expected {
+		t.Errorf("Expected content parts, got %s", wire.Messages[1].Content)
+	}
+}
@@ -0,0 +1,323 @@
+package prompt
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"rabbit_ai/internal/model"
+)
+
+// Handler 提示词模板处理器
+type Handler struct {
+	service *Service
+}
+
+// NewHandler 创建提示词模板处理器实例
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// RegisterRoutes 注册用户模板路由
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	group := r.Group("/prompt-templates")
+	{
+		group.GET("", h.List)
+		group.POST("", h.Create)
+		group.GET("/:id", h.Get)
+		group.PUT("/:id", h.Update)
+		group.DELETE("/:id", h.Delete)
+		group.GET("/:id/versions", h.ListVersions)
+		group.POST("/:id/render", h.Render)
+	}
+}
+
+// RegisterAdminRoutes 注册系统模板管理路由，须挂载在管理员路由组下
+func (h *Handler) RegisterAdminRoutes(r *gin.RouterGroup) {
+	group := r.Group("/prompt-templates")
+	{
+		group.GET("", h.ListSystem)
+		group.POST("", h.CreateSystem)
+		group.PUT("/:id", h.UpdateSystem)
+		group.DELETE("/:id", h.DeleteSystem)
+	}
+}
+
+// List 获取可用模板列表（系统模板与自己的模板）
+func (h *Handler) List(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	h.list(c, userID)
+}
+
+// ListSystem 获取系统模板列表
+func (h *Handler) ListSystem(c *gin.Context) {
+	h.list(c, model.SystemOwnerID)
+}
+
+func (h *Handler) list(c *gin.Context, ownerID int64) {
+	templates, err := h.service.List(c.Request.Context(), ownerID)
+	if err != nil {
+		respondError(c, err, "Failed to list prompt templates")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"templates": templates,
+		},
+	})
+}
+
+// Create 创建用户模板
+func (h *Handler) Create(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	h.create(c, userID)
+}
+
+// CreateSystem 创建系统模板
+func (h *Handler) CreateSystem(c *gin.Context) {
+	h.create(c, model.SystemOwnerID)
+}
+
+func (h *Handler) create(c *gin.Context, ownerID int64) {
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+	req.OwnerID = ownerID
+
+	template, err := h.service.Create(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err, "Failed to create prompt template")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    template,
+	})
+}
+
+// Get 获取模板，可通过 version 参数获取历史版本
+func (h *Handler) Get(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	templateID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	version := 0
+	if raw := c.Query("version"); raw != "" {
+		var err error
+		version, err = strconv.Atoi(raw)
+		if err != nil || version < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid template version",
+			})
+			return
+		}
+	}
+
+	template, err := h.service.Get(c.Request.Context(), userID, templateID, version)
+	if err != nil {
+		respondError(c, err, "Failed to get prompt template")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    template,
+	})
+}
+
+// Update 修改用户模板，生成新版本
+func (h *Handler) Update(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	h.update(c, userID)
+}
+
+// UpdateSystem 修改系统模板，生成新版本
+func (h *Handler) UpdateSystem(c *gin.Context) {
+	h.update(c, model.SystemOwnerID)
+}
+
+func (h *Handler) update(c *gin.Context, ownerID int64) {
+	templateID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+	req.OwnerID = ownerID
+	req.TemplateID = templateID
+
+	template, err := h.service.Update(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err, "Failed to update prompt template")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    template,
+	})
+}
+
+// Delete 删除用户模板
+func (h *Handler) Delete(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	h.delete(c, userID)
+}
+
+// DeleteSystem 删除系统模板
+func (h *Handler) DeleteSystem(c *gin.Context) {
+	h.delete(c, model.SystemOwnerID)
+}
+
+func (h *Handler) delete(c *gin.Context, ownerID int64) {
+	templateID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), ownerID, templateID); err != nil {
+		respondError(c, err, "Failed to delete prompt template")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Prompt template deleted successfully",
+	})
+}
+
+// ListVersions 获取模板版本历史
+func (h *Handler) ListVersions(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	templateID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	versions, err := h.service.ListVersions(c.Request.Context(), userID, templateID)
+	if err != nil {
+		respondError(c, err, "Failed to list prompt template versions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"versions": versions,
+		},
+	})
+}
+
+// Render 预览模板按变量渲染的结果
+func (h *Handler) Render(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	templateID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	var req RenderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	rendered, err := h.service.Render(c.Request.Context(), userID, templateID, req.Version, req.Variables)
+	if err != nil {
+		respondError(c, err, "Failed to render prompt template")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    rendered,
+	})
+}
+
+// currentUser 从JWT中获取用户ID，未认证时响应401
+func currentUser(c *gin.Context) (int64, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return 0, false
+	}
+	return userID.(int64), true
+}
+
+// pathID 解析路径中的模板ID，无效时响应400
+func pathID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid template ID",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// respondError 按错误类型响应
+func respondError(c *gin.Context, err error, message string) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidTemplate), errors.Is(err, model.ErrInvalidTemplateVariables):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrReadOnly):
+		status = http.StatusForbidden
+	case errors.Is(err, model.ErrPromptTemplateNotFound), errors.Is(err, model.ErrPromptTemplateVersionNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, model.ErrPromptTemplateExists):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,113 @@
+package prompt
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"rabbit_ai/internal/model"
+)
+
+// 模板限制
+const (
+	maxNameLength         = 100   // 模板名称最大字符数
+	maxDescriptionLength  = 1000  // 模板描述最大字符数
+	maxTextLength         = 20000 // 系统提示与内容各自的最大字符数
+	maxVariables          = 20    // 模板最多声明的变量数
+	maxVariableValueRunes = 20000 // 单个变量值最大字符数
+)
+
+// placeholderPattern 模板中的变量占位符，如 {{text}}、{{ target_language }}
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// variableNamePattern 变量名格式
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateTemplate 校验并规范化模板：名称、长度限制，变量名合法且不重复，
+// 模板文本中引用的变量都已声明
+func validateTemplate(template *model.PromptTemplate) error {
+	template.Name = strings.TrimSpace(template.Name)
+	template.Description = strings.TrimSpace(template.Description)
+	template.SystemPrompt = strings.TrimSpace(template.SystemPrompt)
+	template.Content = strings.TrimSpace(template.Content)
+
+	if template.Name == "" || utf8.RuneCountInString(template.Name) > maxNameLength {
+		return fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidTemplate, maxNameLength)
+	}
+	if utf8.RuneCountInString(template.Description) > maxDescriptionLength {
+		return fmt.Errorf("%w: description exceeds %d characters", ErrInvalidTemplate, maxDescriptionLength)
+	}
+	if template.SystemPrompt == "" && template.Content == "" {
+		return fmt.Errorf("%w: system_prompt or content is required", ErrInvalidTemplate)
+	}
+	if utf8.RuneCountInString(template.SystemPrompt) > maxTextLength || utf8.RuneCountInString(template.Content) > maxTextLength {
+		return fmt.Errorf("%w: system_prompt and content must not exceed %d characters", ErrInvalidTemplate, maxTextLength)
+	}
+	if len(template.Variables) > maxVariables {
+		return fmt.Errorf("%w: at most %d variables", ErrInvalidTemplate, maxVariables)
+	}
+
+	declared := map[string]bool{}
+	for i := range template.Variables {
+		variable := &template.Variables[i]
+		variable.Name = strings.TrimSpace(variable.Name)
+		variable.Description = strings.TrimSpace(variable.Description)
+		if !variableNamePattern.MatchString(variable.Name) {
+			return fmt.Errorf("%w: invalid variable name %q", ErrInvalidTemplate, variable.Name)
+		}
+		if declared[variable.Name] {
+			return fmt.Errorf("%w: duplicate variable %q", ErrInvalidTemplate, variable.Name)
+		}
+		declared[variable.Name] = true
+	}
+
+	for _, text := range []string{template.SystemPrompt, template.Content} {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			if !declared[match[1]] {
+				return fmt.Errorf("%w: variable %q is not declared", ErrInvalidTemplate, match[1])
+			}
+		}
+	}
+	if template.Variables == nil {
+		template.Variables = model.TemplateVariables{}
+	}
+	return nil
+}
+
+// resolveVariables 合并调用方提供的变量值与默认值：未声明的变量、缺少的必填变量均报错
+func resolveVariables(variables model.TemplateVariables, values map[string]string) (map[string]string, error) {
+	declared := make(map[string]bool, len(variables))
+	for _, variable := range variables {
+		declared[variable.Name] = true
+	}
+	for name, value := range values {
+		if !declared[name] {
+			return nil, fmt.Errorf("%w: unknown variable %q", model.ErrInvalidTemplateVariables, name)
+		}
+		if utf8.RuneCountInString(value) > maxVariableValueRunes {
+			return nil, fmt.Errorf("%w: variable %q exceeds %d characters", model.ErrInvalidTemplateVariables, name, maxVariableValueRunes)
+		}
+	}
+
+	resolved := make(map[string]string, len(variables))
+	for _, variable := range variables {
+		value := values[variable.Name]
+		if strings.TrimSpace(value) == "" {
+			value = variable.Default
+		}
+		if variable.Required && strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("%w: variable %q is required", model.ErrInvalidTemplateVariables, variable.Name)
+		}
+		resolved[variable.Name] = value
+	}
+	return resolved, nil
+}
+
+// render 替换模板文本中的占位符；只替换一遍，变量值中的 {{...}} 原样保留
+func render(text string, values map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+		return values[name]
+	})
+}
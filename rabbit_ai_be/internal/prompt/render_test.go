@@ -0,0 +1,62 @@
+package prompt
+
+import (
+	"errors"
+	"testing"
+
+	"rabbit_ai/internal/model"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	template := &model.PromptTemplate{
+		Name:    "  翻译 ",
+		Content: "把下面的内容翻译成{{ language }}：\n{{text}}",
+		Variables: model.TemplateVariables{
+			{Name: "text", Required: true},
+			{Name: " language ", Default: "英文"},
+		},
+	}
+	if err := validateTemplate(template); err != nil {
+		t.Fatalf("Expected valid template, got %v", err)
+	}
+	if template.Name != "翻译" || template.Variables[1].Name != "language" {
+		t.Errorf("Expected name and variables trimmed, got %+v", template)
+	}
+
+	invalid := map[string]*model.PromptTemplate{
+		"empty name":         {Content: "x"},
+		"empty text":         {Name: "t"},
+		"undeclared":         {Name: "t", Content: "{{text}}"},
+		"invalid name":       {Name: "t", Content: "x", Variables: model.TemplateVariables{{Name: "1x"}}},
+		"duplicate variable": {Name: "t", Content: "x", Variables: model.TemplateVariables{{Name: "a"}, {Name: "a"}}},
+	}
+	for name, template := range invalid {
+		if err := validateTemplate(template); !errors.Is(err, ErrInvalidTemplate) {
+			t.Errorf("%s: expected ErrInvalidTemplate, got %v", name, err)
+		}
+	}
+}
+
+func TestResolveVariablesAndRender(t *testing.T) {
+	variables := model.TemplateVariables{
+		{Name: "text", Required: true},
+		{Name: "language", Default: "英文"},
+		{Name: "tone"},
+	}
+
+	values, err := resolveVariables(variables, map[string]string{"text": "你好 {{language}}"})
+	if err != nil {
+		t.Fatalf("Failed to resolve variables: %v", err)
+	}
+	rendered := render("翻译成{{language}}{{ tone }}：{{text}}", values)
+	if rendered != "翻译成英文：你好 {{language}}" {
+		t.Errorf("Expected defaults applied and values not re-expanded, got %q", rendered)
+	}
+
+	if _, err := resolveVariables(variables, map[string]string{"language": "法文"}); !errors.Is(err, model.ErrInvalidTemplateVariables) {
+		t.Errorf("Expected missing required variable error, got %v", err)
+	}
+	if _, err := resolveVariables(variables, map[string]string{"text": "x", "extra": "y"}); !errors.Is(err, model.ErrInvalidTemplateVariables) {
+		t.Errorf("Expected unknown variable error, got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package prompt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"rabbit_ai/internal/model"
+)
+
+// 提示词模板错误
+var (
+	ErrInvalidTemplate = errors.New("invalid prompt template")
+	ErrReadOnly        = errors.New("system prompt templates are read-only")
+)
+
+// TemplateRequest 创建或修改模板请求
+type TemplateRequest struct {
+	OwnerID      int64                   `json:"-"` // 所有者，系统模板为 model.SystemOwnerID
+	TemplateID   int64                   `json:"-"` // 修改时的模板ID
+	Name         string                  `json:"name" binding:"required"`
+	Description  string                  `json:"description"`
+	SystemPrompt string                  `json:"system_prompt"`
+	Content      string                  `json:"content"`
+	Variables    model.TemplateVariables `json:"variables"`
+}
+
+// RenderRequest 渲染模板请求
+type RenderRequest struct {
+	Version   int               `json:"version"` // 模板版本，为0时使用当前版本
+	Variables map[string]string `json:"variables"`
+}
+
+// Service 提示词模板服务：管理系统模板与用户模板，按变量渲染指定版本
+//
+// 系统模板对所有用户可见，只能通过管理员接口修改；用户模板只对所有者可见。
+// 每次修改模板生成新版本，旧版本保留，可按版本渲染。
+type Service struct {
+	repo model.PromptTemplateRepository
+}
+
+// NewService 创建提示词模板服务实例
+func NewService(repo model.PromptTemplateRepository) *Service {
+	return &Service{
+		repo: repo,
+	}
+}
+
+// Create 创建模板，同一所有者的模板名称不能重复
+func (s *Service) Create(ctx context.Context, req *TemplateRequest) (*model.PromptTemplate, error) {
+	template := &model.PromptTemplate{
+		OwnerID:      req.OwnerID,
+		Name:         req.Name,
+		Description:  req.Description,
+		SystemPrompt: req.SystemPrompt,
+		Content:      req.Content,
+		Variables:    req.Variables,
+	}
+	if err := validateTemplate(template); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(template); err != nil {
+		return nil, fmt.Errorf("failed to create prompt template: %w", err)
+	}
+	return template, nil
+}
+
+// List 获取用户可用的模板：系统模板在前，其后为用户自己的模板
+func (s *Service) List(ctx context.Context, userID int64) ([]*model.PromptTemplate, error) {
+	templates, err := s.repo.List(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompt templates: %w", err)
+	}
+	if templates == nil {
+		templates = []*model.PromptTemplate{}
+	}
+	return templates, nil
+}
+
+// Get 获取模板的指定版本，version 为0时返回当前版本
+func (s *Service) Get(ctx context.Context, userID, templateID int64, version int) (*model.PromptTemplate, error) {
+	template, err := s.getReadable(userID, templateID)
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 || version == template.Version {
+		return template, nil
+	}
+
+	history, err := s.repo.GetVersion(templateID, version)
+	if err != nil {
+		return nil, err
+	}
+	template.Version = history.Version
+	template.SystemPrompt = history.SystemPrompt
+	template.Content = history.Content
+	template.Variables = history.Variables
+	template.UpdatedAt = history.CreatedAt
+	return template, nil
+}
+
+// Update 修改模板内容，生成新版本
+func (s *Service) Update(ctx context.Context, req *TemplateRequest) (*model.PromptTemplate, error) {
+	template, err := s.getWritable(req.OwnerID, req.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+
+	template.Name = req.Name
+	template.Description = req.Description
+	template.SystemPrompt = req.SystemPrompt
+	template.Content = req.Content
+	template.Variables = req.Variables
+	if err := validateTemplate(template); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(template); err != nil {
+		return nil, fmt.Errorf("failed to update prompt template: %w", err)
+	}
+	return template, nil
+}
+
+// Delete 删除模板及其全部版本；已由模板生成的消息保留其模板ID与版本号
+func (s *Service) Delete(ctx context.Context, ownerID, templateID int64) error {
+	if _, err := s.getWritable(ownerID, templateID); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(templateID); err != nil {
+		return fmt.Errorf("failed to delete prompt template: %w", err)
+	}
+	return nil
+}
+
+// ListVersions 获取模板的版本历史，新版本在前
+func (s *Service) ListVersions(ctx context.Context, userID, templateID int64) ([]*model.PromptTemplateVersion, error) {
+	if _, err := s.getReadable(userID, templateID); err != nil {
+		return nil, err
+	}
+	versions, err := s.repo.ListVersions(templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompt template versions: %w", err)
+	}
+	if versions == nil {
+		versions = []*model.PromptTemplateVersion{}
+	}
+	return versions, nil
+}
+
+// Render 按变量渲染模板的指定版本，version 为0时使用当前版本
+func (s *Service) Render(ctx context.Context, userID, templateID int64, version int, variables map[string]string) (*model.RenderedPrompt, error) {
+	template, err := s.Get(ctx, userID, templateID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := resolveVariables(template.Variables, variables)
+	if err != nil {
+		return nil, err
+	}
+	return &model.RenderedPrompt{
+		TemplateID:   template.ID,
+		Version:      template.Version,
+		Name:         template.Name,
+		SystemPrompt: render(template.SystemPrompt, values),
+		Content:      render(template.Content, values),
+	}, nil
+}
+
+// getReadable 获取用户可读的模板（系统模板或自己的模板），其他用户的模板返回未找到
+func (s *Service) getReadable(userID, templateID int64) (*model.PromptTemplate, error) {
+	template, err := s.repo.GetByID(templateID)
+	if err != nil {
+		return nil, err
+	}
+	if !template.IsSystem() && template.OwnerID != userID {
+		return nil, model.ErrPromptTemplateNotFound
+	}
+	return template, nil
+}
+
+// getWritable 获取所有者可修改的模板，用户修改系统模板时返回 ErrReadOnly
+func (s *Service) getWritable(ownerID, templateID int64) (*model.PromptTemplate, error) {
+	template, err := s.getReadable(ownerID, templateID)
+	if err != nil {
+		return nil, err
+	}
+	if template.OwnerID != ownerID {
+		return nil, ErrReadOnly
+	}
+	return template, nil
+}
@@ -0,0 +1,201 @@
+package prompt
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rabbit_ai/internal/model"
+)
+
+// MockPromptTemplateRepository 模拟提示词模板仓库
+type MockPromptTemplateRepository struct {
+	templates map[int64]*model.PromptTemplate
+	versions  map[int64][]*model.PromptTemplateVersion
+	nextID    int64
+}
+
+func NewMockPromptTemplateRepository() *MockPromptTemplateRepository {
+	return &MockPromptTemplateRepository{
+		templates: make(map[int64]*model.PromptTemplate),
+		versions:  make(map[int64][]*model.PromptTemplateVersion),
+		nextID:    1,
+	}
+}
+
+func (m *MockPromptTemplateRepository) Create(template *model.PromptTemplate) error {
+	for _, existing := range m.templates {
+		if existing.OwnerID == template.OwnerID && existing.Name == template.Name {
+			return model.ErrPromptTemplateExists
+		}
+	}
+	template.ID = m.nextID
+	template.Version = 1
+	m.nextID++
+	stored := *template
+	m.templates[template.ID] = &stored
+	m.addVersion(template)
+	return nil
+}
+
+func (m *MockPromptTemplateRepository) addVersion(template *model.PromptTemplate) {
+	m.versions[template.ID] = append(m.versions[template.ID], &model.PromptTemplateVersion{
+		TemplateID:   template.ID,
+		Version:      template.Version,
+		SystemPrompt: template.SystemPrompt,
+		Content:      template.Content,
+		Variables:    template.Variables,
+	})
+}
+
+func (m *MockPromptTemplateRepository) GetByID(id int64) (*model.PromptTemplate, error) {
+	template, ok := m.templates[id]
+	if !ok {
+		return nil, model.ErrPromptTemplateNotFound
+	}
+	copied := *template
+	return &copied, nil
+}
+
+func (m *MockPromptTemplateRepository) List(ownerID int64) ([]*model.PromptTemplate, error) {
+	var templates []*model.PromptTemplate
+	for id := int64(1); id < m.nextID; id++ {
+		if template, ok := m.templates[id]; ok && (template.IsSystem() || template.OwnerID == ownerID) {
+			templates = append(templates, template)
+		}
+	}
+	return templates, nil
+}
+
+func (m *MockPromptTemplateRepository) Update(template *model.PromptTemplate) error {
+	if _, ok := m.templates[template.ID]; !ok {
+		return model.ErrPromptTemplateNotFound
+	}
+	template.Version++
+	stored := *template
+	m.templates[template.ID] = &stored
+	m.addVersion(template)
+	return nil
+}
+
+func (m *MockPromptTemplateRepository) Delete(id int64) error {
+	if _, ok := m.templates[id]; !ok {
+		return model.ErrPromptTemplateNotFound
+	}
+	delete(m.templates, id)
+	delete(m.versions, id)
+	return nil
+}
+
+func (m *MockPromptTemplateRepository) GetVersion(templateID int64, version int) (*model.PromptTemplateVersion, error) {
+	for _, v := range m.versions[templateID] {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return nil, model.ErrPromptTemplateVersionNotFound
+}
+
+func (m *MockPromptTemplateRepository) ListVersions(templateID int64) ([]*model.PromptTemplateVersion, error) {
+	versions := m.versions[templateID]
+	reversed := make([]*model.PromptTemplateVersion, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		reversed = append(reversed, versions[i])
+	}
+	return reversed, nil
+}
+
+// newTestService 创建带一个系统翻译模板的服务
+func newTestService(t *testing.T) (*Service, *model.PromptTemplate) {
+	service := NewService(NewMockPromptTemplateRepository())
+	system, err := service.Create(context.Background(), &TemplateRequest{
+		OwnerID:      model.SystemOwnerID,
+		Name:         "翻译",
+		SystemPrompt: "你是专业译者。",
+		Content:      "翻译成{{language}}：{{text}}",
+		Variables:    model.TemplateVariables{{Name: "text", Required: true}, {Name: "language", Default: "英文"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create system template: %v", err)
+	}
+	return service, system
+}
+
+func TestService_Ownership(t *testing.T) {
+	service, system := newTestService(t)
+	ctx := context.Background()
+
+	own, err := service.Create(ctx, &TemplateRequest{OwnerID: 1, Name: "周报", Content: "写一份周报"})
+	if err != nil {
+		t.Fatalf("Failed to create user template: %v", err)
+	}
+	if _, err := service.Create(ctx, &TemplateRequest{OwnerID: 1, Name: "周报", Content: "x"}); !errors.Is(err, model.ErrPromptTemplateExists) {
+		t.Errorf("Expected duplicate name error, got %v", err)
+	}
+
+	templates, _ := service.List(ctx, 2)
+	if len(templates) != 1 || templates[0].ID != system.ID {
+		t.Errorf("Expected other users to see only system templates, got %+v", templates)
+	}
+	if _, err := service.Get(ctx, 2, own.ID, 0); !errors.Is(err, model.ErrPromptTemplateNotFound) {
+		t.Errorf("Expected other user's template hidden, got %v", err)
+	}
+	if _, err := service.Get(ctx, 2, system.ID, 0); err != nil {
+		t.Errorf("Expected system template readable, got %v", err)
+	}
+
+	update := &TemplateRequest{OwnerID: 2, TemplateID: system.ID, Name: "翻译", Content: "x"}
+	if _, err := service.Update(ctx, update); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected system template read-only for users, got %v", err)
+	}
+	if err := service.Delete(ctx, model.SystemOwnerID, own.ID); !errors.Is(err, model.ErrPromptTemplateNotFound) {
+		t.Errorf("Expected admin routes to manage only system templates, got %v", err)
+	}
+}
+
+func TestService_VersionedRender(t *testing.T) {
+	service, system := newTestService(t)
+	ctx := context.Background()
+
+	updated, err := service.Update(ctx, &TemplateRequest{
+		OwnerID:    model.SystemOwnerID,
+		TemplateID: system.ID,
+		Name:       "翻译",
+		Content:    "请译为{{language}}：{{text}}",
+		Variables:  system.Variables,
+	})
+	if err != nil {
+		t.Fatalf("Failed to update template: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Errorf("Expected version 2, got %d", updated.Version)
+	}
+
+	rendered, err := service.Render(ctx, 1, system.ID, 0, map[string]string{"text": "你好"})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if rendered.Version != 2 || rendered.Content != "请译为英文：你好" || rendered.SystemPrompt != "" {
+		t.Errorf("Unexpected latest rendering: %+v", rendered)
+	}
+
+	rendered, err = service.Render(ctx, 1, system.ID, 1, map[string]string{"text": "你好", "language": "日文"})
+	if err != nil {
+		t.Fatalf("Failed to render version 1: %v", err)
+	}
+	if rendered.Version != 1 || rendered.Content != "翻译成日文：你好" || rendered.SystemPrompt != "你是专业译者。" {
+		t.Errorf("Unexpected version 1 rendering: %+v", rendered)
+	}
+
+	if _, err := service.Render(ctx, 1, system.ID, 3, nil); !errors.Is(err, model.ErrPromptTemplateVersionNotFound) {
+		t.Errorf("Expected missing version error, got %v", err)
+	}
+	if _, err := service.Render(ctx, 1, system.ID, 0, nil); !errors.Is(err, model.ErrInvalidTemplateVariables) {
+		t.Errorf("Expected missing variable error, got %v", err)
+	}
+
+	versions, _ := service.ListVersions(ctx, 1, system.ID)
+	if len(versions) != 2 || versions[0].Version != 2 {
+		t.Errorf("Expected versions newest first, got %+v", versions)
+	}
+}
@@ -0,0 +1,69 @@
+package search
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler 语义搜索处理器
+type Handler struct {
+	service *Service
+}
+
+// NewHandler 创建语义搜索处理器实例
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// RegisterRoutes 注册路由
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/search", h.Search)
+}
+
+// Search 语义搜索对话历史
+func (h *Handler) Search(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(DefaultLimit)))
+	if err != nil {
+		limit = DefaultLimit
+	}
+
+	req := &SearchRequest{
+		UserID: userID.(int64),
+		Query:  c.Query("q"),
+		Limit:  limit,
+	}
+
+	response, err := h.service.Search(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, ErrEmptyQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid search query",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to search messages",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    response,
+	})
+}
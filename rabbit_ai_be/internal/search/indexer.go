@@ -0,0 +1,164 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+)
+
+// 向量化任务参数
+const (
+	DefaultIndexBatchSize = 16
+	DefaultIndexInterval  = 10 * time.Second
+	maxEmbeddingRunes     = 2000 // 单条消息参与向量化的最大字符数
+)
+
+// IndexerConfig 向量化任务配置
+type IndexerConfig struct {
+	BatchSize int           // 每批向量化的消息数
+	Interval  time.Duration // 没有待处理消息或出错后等待的时间
+}
+
+// Indexer 后台向量化任务，持续为新消息生成向量
+type Indexer struct {
+	embeddingRepo model.EmbeddingRepository
+	embedder      llm.Embedder
+	config        IndexerConfig
+}
+
+// NewIndexer 创建向量化任务实例
+func NewIndexer(embeddingRepo model.EmbeddingRepository, embedder llm.Embedder, config IndexerConfig) *Indexer {
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultIndexBatchSize
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultIndexInterval
+	}
+	return &Indexer{
+		embeddingRepo: embeddingRepo,
+		embedder:      embedder,
+		config:        config,
+	}
+}
+
+// Run 循环处理待向量化的消息，直到ctx结束
+func (x *Indexer) Run(ctx context.Context) {
+	for {
+		count, err := x.IndexPending(ctx)
+		if err != nil {
+			log.Printf("failed to index messages: %v", err)
+		}
+		// 一批处理满时立即处理下一批，否则等待新消息
+		if err == nil && count == x.config.BatchSize {
+			continue
+		}
+
+		timer := time.NewTimer(x.config.Interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// IndexPending 为一批尚未向量化的消息生成并保存向量，返回处理的消息数
+//
+// 上游故障时整批稍后重试；其他错误通常由个别消息导致（如被向量接口的内容过滤拒绝），
+// 此时逐条向量化，仍然失败的消息记录为无法向量化，避免阻塞后续消息。
+func (x *Indexer) IndexPending(ctx context.Context) (int, error) {
+	messages, err := x.embeddingRepo.ListPending(x.embedder.Model(), x.config.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pending messages: %w", err)
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	texts := make([]string, len(messages))
+	for i, message := range messages {
+		texts[i] = truncateRunes(message.Content, maxEmbeddingRunes)
+	}
+	vectors, err := x.embedder.Embed(ctx, texts, llm.EmbeddingDocument)
+	if err != nil {
+		if ctx.Err() != nil || llm.IsUpstreamFailure(err) {
+			return 0, fmt.Errorf("failed to embed messages: %w", err)
+		}
+		log.Printf("failed to embed %d messages, retrying one by one: %v", len(messages), err)
+		return x.indexEach(ctx, messages, texts)
+	}
+
+	for i, message := range messages {
+		if err := x.save(message, vectors[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(messages), nil
+}
+
+// indexEach 逐条向量化消息，无法向量化的消息记录错误后跳过
+//
+// 所有消息都失败时（如鉴权失败）视为整体故障，不记录失败，稍后整批重试；
+// 被拒绝的消息会在之后与新消息同批处理时被记录。
+func (x *Indexer) indexEach(ctx context.Context, messages []*model.Message, texts []string) (int, error) {
+	var rejected []*model.MessageEmbedding
+	var lastErr error
+	for i, message := range messages {
+		vectors, err := x.embedder.Embed(ctx, texts[i:i+1], llm.EmbeddingDocument)
+		if err != nil {
+			if ctx.Err() != nil || llm.IsUpstreamFailure(err) {
+				return 0, fmt.Errorf("failed to embed message %d: %w", message.ID, err)
+			}
+			lastErr = err
+			rejected = append(rejected, &model.MessageEmbedding{
+				MessageID:      message.ID,
+				ConversationID: message.ConversationID,
+				Model:          x.embedder.Model(),
+				Error:          err.Error(),
+			})
+			continue
+		}
+		if err := x.save(message, vectors[0]); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(rejected) == len(messages) {
+		return 0, fmt.Errorf("failed to embed messages: %w", lastErr)
+	}
+	for _, embedding := range rejected {
+		log.Printf("skipping embedding of message %d: %s", embedding.MessageID, embedding.Error)
+		if err := x.embeddingRepo.MarkFailed(embedding); err != nil {
+			return 0, fmt.Errorf("failed to mark embedding of message %d as failed: %w", embedding.MessageID, err)
+		}
+	}
+	return len(messages), nil
+}
+
+// save 保存消息向量
+func (x *Indexer) save(message *model.Message, vector []float32) error {
+	err := x.embeddingRepo.Save(&model.MessageEmbedding{
+		MessageID:      message.ID,
+		ConversationID: message.ConversationID,
+		Model:          x.embedder.Model(),
+		Vector:         vector,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save embedding of message %d: %w", message.ID, err)
+	}
+	return nil
+}
+
+// truncateRunes 按字符截断文本
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) > limit {
+		return string(runes[:limit])
+	}
+	return text
+}
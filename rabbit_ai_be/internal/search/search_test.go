@@ -0,0 +1,254 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"rabbit_ai/internal/fakellm"
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+)
+
+// hashEmbedder 使用模拟服务的确定性向量，字面重叠越多的文本越相似
+type hashEmbedder struct {
+	calls  int
+	reject string // 包含该内容的请求返回 err，为空时所有请求都返回 err
+	err    error
+}
+
+func (e *hashEmbedder) Model() string { return "embo-01" }
+
+func (e *hashEmbedder) Embed(ctx context.Context, texts []string, purpose llm.EmbeddingPurpose) ([][]float32, error) {
+	e.calls++
+	for _, text := range texts {
+		if e.err != nil && strings.Contains(text, e.reject) {
+			return nil, e.err
+		}
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = fakellm.Embed(text)
+	}
+	return vectors, nil
+}
+
+// MockEmbeddingRepository 模拟消息向量仓库
+type MockEmbeddingRepository struct {
+	messages   []*model.Message
+	embeddings map[int64]*model.MessageEmbedding
+	owners     map[int64]int64 // 对话ID -> 用户ID
+}
+
+func (m *MockEmbeddingRepository) ListPending(modelName string, limit int) ([]*model.Message, error) {
+	var pending []*model.Message
+	for _, message := range m.messages {
+		if _, ok := m.embeddings[message.ID]; !ok && len(pending) < limit {
+			pending = append(pending, message)
+		}
+	}
+	return pending, nil
+}
+
+func (m *MockEmbeddingRepository) Save(embedding *model.MessageEmbedding) error {
+	m.embeddings[embedding.MessageID] = embedding
+	return nil
+}
+
+func (m *MockEmbeddingRepository) MarkFailed(embedding *model.MessageEmbedding) error {
+	m.embeddings[embedding.MessageID] = embedding
+	return nil
+}
+
+func (m *MockEmbeddingRepository) ListByUser(userID int64, modelName string) ([]*model.MessageEmbedding, error) {
+	var embeddings []*model.MessageEmbedding
+	for _, embedding := range m.embeddings {
+		if m.owners[embedding.ConversationID] == userID && embedding.Model == modelName && embedding.Error == "" {
+			embeddings = append(embeddings, embedding)
+		}
+	}
+	return embeddings, nil
+}
+
+// MockMessageRepository 模拟消息仓库，只实现搜索用到的方法
+type MockMessageRepository struct {
+	model.MessageRepository
+	messages []*model.Message
+}
+
+func (m *MockMessageRepository) GetByID(id int64) (*model.Message, error) {
+	for _, message := range m.messages {
+		if message.ID == id {
+			return message, nil
+		}
+	}
+	return nil, errors.New("message not found")
+}
+
+// MockConversationRepository 模拟对话仓库，只实现搜索用到的方法
+type MockConversationRepository struct {
+	model.ConversationRepository
+	conversations map[int64]*model.Conversation
+}
+
+func (m *MockConversationRepository) GetByID(id int64) (*model.Conversation, error) {
+	if conversation, ok := m.conversations[id]; ok {
+		return conversation, nil
+	}
+	return nil, errors.New("conversation not found")
+}
+
+// newTestStore 创建两个用户的对话与消息：用户1有两段对话，用户2有一段
+func newTestStore() (*MockEmbeddingRepository, *MockMessageRepository, *MockConversationRepository) {
+	messages := []*model.Message{
+		{ID: 1, ConversationID: 1, Role: "user", Content: "红烧肉怎么做"},
+		{ID: 2, ConversationID: 1, ParentID: 1, Role: "assistant", Content: "红烧肉的做法：五花肉焯水后加冰糖炒色"},
+		{ID: 3, ConversationID: 2, Role: "user", Content: "Go的goroutine怎么用"},
+		{ID: 4, ConversationID: 2, ParentID: 3, Role: "assistant", Content: "goroutine是Go的轻量级线程"},
+		{ID: 5, ConversationID: 3, Role: "user", Content: "红烧肉需要炖多久"},
+	}
+	embeddingRepo := &MockEmbeddingRepository{
+		messages:   messages,
+		embeddings: map[int64]*model.MessageEmbedding{},
+		owners:     map[int64]int64{1: 1, 2: 1, 3: 2},
+	}
+	conversationRepo := &MockConversationRepository{conversations: map[int64]*model.Conversation{
+		1: {ID: 1, UserID: 1, Title: "家常菜"},
+		2: {ID: 2, UserID: 1, Title: "Go并发"},
+		3: {ID: 3, UserID: 2, Title: "炖菜"},
+	}}
+	return embeddingRepo, &MockMessageRepository{messages: messages}, conversationRepo
+}
+
+func TestIndexer_IndexPending(t *testing.T) {
+	embeddingRepo, _, _ := newTestStore()
+	embedder := &hashEmbedder{}
+	indexer := NewIndexer(embeddingRepo, embedder, IndexerConfig{BatchSize: 3})
+	ctx := context.Background()
+
+	count, err := indexer.IndexPending(ctx)
+	if err != nil || count != 3 {
+		t.Fatalf("Expected first batch of 3, got %d, %v", count, err)
+	}
+	count, err = indexer.IndexPending(ctx)
+	if err != nil || count != 2 {
+		t.Fatalf("Expected remaining 2 messages, got %d, %v", count, err)
+	}
+	if count, _ := indexer.IndexPending(ctx); count != 0 {
+		t.Errorf("Expected nothing pending, got %d", count)
+	}
+	if embedder.calls != 2 {
+		t.Errorf("Expected one embedding request per batch, got %d", embedder.calls)
+	}
+
+	embedding := embeddingRepo.embeddings[2]
+	if embedding == nil || embedding.ConversationID != 1 || embedding.Model != "embo-01" || len(embedding.Vector) != fakellm.EmbeddingDimension {
+		t.Errorf("Unexpected embedding: %+v", embedding)
+	}
+}
+
+// upstreamError 模拟上游故障
+type upstreamError struct{}
+
+func (e *upstreamError) Error() string  { return "rate limited" }
+func (e *upstreamError) Failover() bool { return true }
+
+func TestIndexer_SkipsRejectedMessages(t *testing.T) {
+	embeddingRepo, _, _ := newTestStore()
+	embedder := &hashEmbedder{reject: "goroutine是", err: errors.New("content rejected by embedding API")}
+	indexer := NewIndexer(embeddingRepo, embedder, IndexerConfig{BatchSize: 5})
+	ctx := context.Background()
+
+	// 整批失败后逐条重试，被拒绝的消息记录为失败，其余消息正常向量化
+	count, err := indexer.IndexPending(ctx)
+	if err != nil || count != 5 {
+		t.Fatalf("Expected all 5 messages processed, got %d, %v", count, err)
+	}
+	if embedding := embeddingRepo.embeddings[4]; embedding == nil || embedding.Error == "" || embedding.Vector != nil {
+		t.Errorf("Expected message 4 to be marked as failed, got %+v", embedding)
+	}
+	if embedding := embeddingRepo.embeddings[5]; embedding == nil || len(embedding.Vector) != fakellm.EmbeddingDimension {
+		t.Errorf("Expected message 5 to be embedded, got %+v", embedding)
+	}
+	if count, _ := indexer.IndexPending(ctx); count != 0 {
+		t.Errorf("Expected failed message not to be retried, got %d pending", count)
+	}
+	if embeddings, _ := embeddingRepo.ListByUser(1, "embo-01"); len(embeddings) != 3 {
+		t.Errorf("Expected failed message to be excluded from search, got %d embeddings", len(embeddings))
+	}
+}
+
+func TestIndexer_RetriesWhenAllMessagesFail(t *testing.T) {
+	embeddingRepo, _, _ := newTestStore()
+	embedder := &hashEmbedder{err: errors.New("authentication failed")}
+	indexer := NewIndexer(embeddingRepo, embedder, IndexerConfig{BatchSize: 5})
+
+	// 所有消息都失败时视为整体故障，不记录失败，稍后整批重试
+	if _, err := indexer.IndexPending(context.Background()); err == nil {
+		t.Fatal("Expected error when every message fails")
+	}
+	if len(embeddingRepo.embeddings) != 0 {
+		t.Errorf("Expected no messages marked as failed, got %d", len(embeddingRepo.embeddings))
+	}
+}
+
+func TestIndexer_RetriesUpstreamFailures(t *testing.T) {
+	embeddingRepo, _, _ := newTestStore()
+	embedder := &hashEmbedder{reject: "goroutine是", err: &upstreamError{}}
+	indexer := NewIndexer(embeddingRepo, embedder, IndexerConfig{BatchSize: 5})
+
+	// 上游故障时整批稍后重试，不记录失败
+	if _, err := indexer.IndexPending(context.Background()); err == nil {
+		t.Fatal("Expected upstream failure to be returned")
+	}
+	if len(embeddingRepo.embeddings) != 0 || embedder.calls != 1 {
+		t.Errorf("Expected no embeddings and a single request, got %d embeddings in %d requests", len(embeddingRepo.embeddings), embedder.calls)
+	}
+}
+
+func TestService_Search(t *testing.T) {
+	embeddingRepo, messageRepo, conversationRepo := newTestStore()
+	embedder := &hashEmbedder{}
+	if _, err := NewIndexer(embeddingRepo, embedder, IndexerConfig{}).IndexPending(context.Background()); err != nil {
+		t.Fatalf("Failed to index messages: %v", err)
+	}
+	service := NewService(embeddingRepo, messageRepo, conversationRepo, embedder)
+
+	response, err := service.Search(context.Background(), &SearchRequest{UserID: 1, Query: "红烧肉的做法", Limit: 2})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(response.Hits) != 2 {
+		t.Fatalf("Expected 2 hits, got %d", len(response.Hits))
+	}
+
+	top := response.Hits[0]
+	if top.Message.ID != 2 || top.Conversation.Title != "家常菜" {
+		t.Errorf("Expected recipe answer ranked first, got message %d in %+v", top.Message.ID, top.Conversation)
+	}
+	if top.Parent == nil || top.Parent.ID != 1 {
+		t.Errorf("Expected question attached as context, got %+v", top.Parent)
+	}
+	if response.Hits[1].Score > top.Score {
+		t.Errorf("Expected hits sorted by score, got %f > %f", response.Hits[1].Score, top.Score)
+	}
+	for _, hit := range response.Hits {
+		if hit.Message.ID == 5 {
+			t.Error("Expected other users' messages to be excluded")
+		}
+	}
+}
+
+func TestService_SearchEmptyQuery(t *testing.T) {
+	embeddingRepo, messageRepo, conversationRepo := newTestStore()
+	embedder := &hashEmbedder{}
+	service := NewService(embeddingRepo, messageRepo, conversationRepo, embedder)
+
+	if _, err := service.Search(context.Background(), &SearchRequest{UserID: 1, Query: "  "}); !errors.Is(err, ErrEmptyQuery) {
+		t.Errorf("Expected ErrEmptyQuery, got %v", err)
+	}
+	if embedder.calls != 0 {
+		t.Errorf("Expected no embedding request for empty query, got %d", embedder.calls)
+	}
+}
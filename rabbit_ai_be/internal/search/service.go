@@ -0,0 +1,143 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"rabbit_ai/internal/llm"
+	"rabbit_ai/internal/model"
+)
+
+// 搜索结果数量
+const (
+	DefaultLimit = 10
+	MaxLimit     = 50
+)
+
+// ErrEmptyQuery 查询为空
+var ErrEmptyQuery = errors.New("search query is required")
+
+// SearchRequest 语义搜索请求
+type SearchRequest struct {
+	UserID int64  `json:"user_id"`
+	Query  string `json:"query"`
+	Limit  int    `json:"limit"`
+}
+
+// ConversationRef 命中消息所在的对话
+type ConversationRef struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+// Hit 语义搜索命中的消息
+type Hit struct {
+	Score        float64         `json:"score"` // 余弦相似度
+	Message      *model.Message  `json:"message"`
+	Parent       *model.Message  `json:"parent,omitempty"` // 上一条消息，例如AI回复对应的提问
+	Conversation ConversationRef `json:"conversation"`
+}
+
+// SearchResponse 语义搜索响应
+type SearchResponse struct {
+	Hits []*Hit `json:"hits"`
+}
+
+// Service 对话历史语义搜索服务
+type Service struct {
+	embeddingRepo    model.EmbeddingRepository
+	messageRepo      model.MessageRepository
+	conversationRepo model.ConversationRepository
+	embedder         llm.Embedder
+}
+
+// NewService 创建语义搜索服务实例
+func NewService(embeddingRepo model.EmbeddingRepository, messageRepo model.MessageRepository, conversationRepo model.ConversationRepository, embedder llm.Embedder) *Service {
+	return &Service{
+		embeddingRepo:    embeddingRepo,
+		messageRepo:      messageRepo,
+		conversationRepo: conversationRepo,
+		embedder:         embedder,
+	}
+}
+
+// Search 在用户的对话历史中按语义相似度检索消息
+//
+// 查询向量与用户全部消息向量逐一计算余弦相似度，按相似度从高到低返回。
+// 尚未被后台任务向量化的新消息暂时不会出现在结果中。
+func (s *Service) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	query := strings.TrimSpace(req.Query)
+	if query == "" {
+		return nil, ErrEmptyQuery
+	}
+	if req.Limit <= 0 || req.Limit > MaxLimit {
+		req.Limit = DefaultLimit
+	}
+
+	vectors, err := s.embedder.Embed(ctx, []string{truncateRunes(query, maxEmbeddingRunes)}, llm.EmbeddingQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	embeddings, err := s.embeddingRepo.ListByUser(req.UserID, s.embedder.Model())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embeddings: %w", err)
+	}
+
+	type scored struct {
+		embedding *model.MessageEmbedding
+		score     float64
+	}
+	candidates := make([]scored, 0, len(embeddings))
+	for _, embedding := range embeddings {
+		if score := llm.CosineSimilarity(vectors[0], embedding.Vector); score > 0 {
+			candidates = append(candidates, scored{embedding: embedding, score: score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	hits := []*Hit{}
+	conversations := map[int64]*model.Conversation{}
+	for _, candidate := range candidates {
+		if len(hits) >= req.Limit {
+			break
+		}
+
+		message, err := s.messageRepo.GetByID(candidate.embedding.MessageID)
+		if err != nil {
+			// 消息可能在检索过程中被删除
+			continue
+		}
+
+		conversation, ok := conversations[message.ConversationID]
+		if !ok {
+			conversation, err = s.conversationRepo.GetByID(message.ConversationID)
+			if err != nil {
+				continue
+			}
+			conversations[message.ConversationID] = conversation
+		}
+		if conversation.UserID != req.UserID {
+			continue
+		}
+
+		hit := &Hit{
+			Score:        candidate.score,
+			Message:      message,
+			Conversation: ConversationRef{ID: conversation.ID, Title: conversation.Title},
+		}
+		if message.ParentID != 0 {
+			if parent, err := s.messageRepo.GetByID(message.ParentID); err == nil && parent.Moderation != model.ModerationBlocked {
+				hit.Parent = parent
+			}
+		}
+		hits = append(hits, hit)
+	}
+
+	return &SearchResponse{Hits: hits}, nil
+}
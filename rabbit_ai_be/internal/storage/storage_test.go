@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "uploads")
+	store, err := NewLocalStorage(root)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "images/1/a.png", []byte("png")); err != nil {
+		t.Fatalf("Failed to put object: %v", err)
+	}
+	data, err := store.Get(ctx, "images/1/a.png")
+	if err != nil || string(data) != "png" {
+		t.Fatalf("Expected stored object, got %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "images", "1", "a.png")); err != nil {
+		t.Errorf("Expected file under root: %v", err)
+	}
+
+	// 覆盖写入
+	if err := store.Put(ctx, "images/1/a.png", []byte("new")); err != nil {
+		t.Fatalf("Failed to overwrite object: %v", err)
+	}
+	if data, _ := store.Get(ctx, "images/1/a.png"); string(data) != "new" {
+		t.Errorf("Expected overwritten object, got %q", data)
+	}
+
+	if err := store.Delete(ctx, "images/1/a.png"); err != nil {
+		t.Fatalf("Failed to delete object: %v", err)
+	}
+	if _, err := store.Get(ctx, "images/1/a.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if err := store.Delete(ctx, "images/1/a.png"); err != nil {
+		t.Errorf("Expected deleting missing object to succeed, got %v", err)
+	}
+}
+
+func TestLocalStorage_InvalidKey(t *testing.T) {
+	store, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	for _, key := range []string{"", "/etc/passwd", "../secret", "images/../../x", "images//a", `images\a`, "images/.hidden", "images/"} {
+		if err := store.Put(context.Background(), key, []byte("x")); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q): expected ErrInvalidKey, got %v", key, err)
+		}
+	}
+}
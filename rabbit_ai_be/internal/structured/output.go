@@ -0,0 +1,212 @@
+package structured
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"rabbit_ai/internal/llm"
+)
+
+// FormatJSONSchema 按 JSON Schema 返回结构化结果的回复格式
+const FormatJSONSchema = "json_schema"
+
+// 重新提示次数
+const (
+	DefaultMaxRetries = 2 // 回复未通过校验时默认的重新提示次数
+	MaxRetriesLimit   = 5 // 允许设置的最大重新提示次数
+	maxReportedErrors = 10
+)
+
+// ErrInvalidFormat 回复格式参数无效
+var ErrInvalidFormat = errors.New("invalid response format")
+
+// ErrCodeInvalidOutput 重新提示达到上限后回复仍未通过 Schema 校验时返回给客户端的错误码
+const ErrCodeInvalidOutput = "INVALID_STRUCTURED_OUTPUT"
+
+// ResponseFormat 聊天接口的回复格式参数
+type ResponseFormat struct {
+	Type       string          `json:"type"`                  // 目前只支持 json_schema
+	Name       string          `json:"name,omitempty"`        // 结果名称，用于提示模型
+	Schema     json.RawMessage `json:"schema"`                // 回复须满足的 JSON Schema
+	MaxRetries *int            `json:"max_retries,omitempty"` // 校验失败后重新提示的次数，默认2，最大5
+}
+
+// OutputError 重新提示达到上限后回复仍不是满足 Schema 的JSON
+type OutputError struct {
+	Attempts int               `json:"attempts"` // 调用模型的次数
+	Errors   []ValidationError `json:"errors"`   // 最后一次回复的校验错误
+	Raw      string            `json:"raw"`      // 最后一次回复的原文
+	Usage    llm.Usage         `json:"usage"`    // 全部尝试累计的token用量
+}
+
+// Error 实现error接口
+func (e *OutputError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, validationErr := range e.Errors {
+		messages = append(messages, validationErr.String())
+	}
+	return fmt.Sprintf("structured output failed validation after %d attempts: %s", e.Attempts, strings.Join(messages, "; "))
+}
+
+// Result 通过校验的结构化结果
+type Result struct {
+	Response *llm.ChatResponse // 最后一次（通过校验的）模型回复，Usage 为全部尝试的累计用量
+	Value    json.RawMessage   // 解析出的JSON
+	Attempts int               // 调用模型的次数
+}
+
+// Output 编译后的回复格式
+type Output struct {
+	name       string
+	schema     *Schema
+	rawSchema  json.RawMessage
+	maxRetries int
+}
+
+// NewOutput 校验并编译回复格式
+func NewOutput(format *ResponseFormat) (*Output, error) {
+	if format.Type != FormatJSONSchema {
+		return nil, fmt.Errorf("%w: unsupported type %q", ErrInvalidFormat, format.Type)
+	}
+	schema, err := CompileSchema(format.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	maxRetries := DefaultMaxRetries
+	if format.MaxRetries != nil {
+		maxRetries = *format.MaxRetries
+	}
+	if maxRetries < 0 || maxRetries > MaxRetriesLimit {
+		return nil, fmt.Errorf("%w: max_retries must be in [0, %d]", ErrInvalidFormat, MaxRetriesLimit)
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, format.Schema); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFormat, err)
+	}
+	return &Output{
+		name:       strings.TrimSpace(format.Name),
+		schema:     schema,
+		rawSchema:  compacted.Bytes(),
+		maxRetries: maxRetries,
+	}, nil
+}
+
+// Instruction 要求模型按 Schema 输出JSON的系统提示
+func (o *Output) Instruction() string {
+	var sb strings.Builder
+	sb.WriteString("请只输出一个符合以下 JSON Schema 的JSON值，不要输出任何解释、前后缀文字或Markdown代码块。")
+	if o.name != "" {
+		sb.WriteString("结果名称：")
+		sb.WriteString(o.name)
+		sb.WriteString("。")
+	}
+	sb.WriteString("\nJSON Schema：\n")
+	sb.Write(o.rawSchema)
+	return sb.String()
+}
+
+// Parse 从回复中解析JSON并按 Schema 校验，允许回复包在 ```json 代码块中
+func (o *Output) Parse(content string) (json.RawMessage, []ValidationError) {
+	text := extractJSON(content)
+	value, err := decodeJSON([]byte(text))
+	if err != nil {
+		return nil, []ValidationError{{Path: "$", Message: "reply is not valid JSON: " + err.Error()}}
+	}
+	if errs := o.schema.Validate(value); len(errs) > 0 {
+		return nil, errs
+	}
+	return json.RawMessage(text), nil
+}
+
+// retryPrompt 回复未通过校验时要求模型修正的提示
+func retryPrompt(errs []ValidationError) string {
+	var sb strings.Builder
+	sb.WriteString("你的上一条回复不符合要求的 JSON Schema，错误如下：\n")
+	for i, validationErr := range errs {
+		if i == maxReportedErrors {
+			fmt.Fprintf(&sb, "- 以及其他 %d 处错误\n", len(errs)-maxReportedErrors)
+			break
+		}
+		sb.WriteString("- ")
+		sb.WriteString(validationErr.String())
+		sb.WriteString("\n")
+	}
+	sb.WriteString("请修正后重新输出完整的JSON，只输出JSON本身。")
+	return sb.String()
+}
+
+// Generate 调用模型生成结构化结果
+//
+// 请求的系统提示末尾追加 Schema 说明；回复不是满足 Schema 的JSON时，把回复和校验错误
+// 追加到对话中重新提示，最多 maxRetries 次，仍失败时返回 *OutputError。
+// 请求不应声明工具。
+func (o *Output) Generate(ctx context.Context, provider llm.Provider, request llm.ChatRequest) (*Result, error) {
+	request.Messages = o.withInstruction(request.Messages)
+
+	var usage llm.Usage
+	for attempt := 1; ; attempt++ {
+		response, err := provider.ChatCompletion(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		usage.PromptTokens += response.Usage.PromptTokens
+		usage.CompletionTokens += response.Usage.CompletionTokens
+		usage.TotalTokens += response.Usage.TotalTokens
+
+		content := response.GetContent()
+		value, errs := o.Parse(content)
+		if len(errs) == 0 {
+			response.Usage = usage
+			return &Result{Response: response, Value: value, Attempts: attempt}, nil
+		}
+		if attempt > o.maxRetries {
+			if len(errs) > maxReportedErrors {
+				errs = errs[:maxReportedErrors]
+			}
+			return nil, &OutputError{Attempts: attempt, Errors: errs, Raw: content, Usage: usage}
+		}
+
+		request.Messages = append(request.Messages,
+			llm.Message{Role: llm.RoleAssistant, Content: content},
+			llm.Message{Role: llm.RoleUser, Content: retryPrompt(errs)},
+		)
+	}
+}
+
+// withInstruction 在第一条系统消息末尾追加 Schema 说明，没有系统消息时在最前面插入
+func (o *Output) withInstruction(messages []llm.Message) []llm.Message {
+	result := make([]llm.Message, 0, len(messages)+1)
+	if len(messages) > 0 && messages[0].Role == llm.RoleSystem && messages[0].Content != "" {
+		first := messages[0]
+		first.Content += "\n\n" + o.Instruction()
+		result = append(result, first)
+		return append(result, messages[1:]...)
+	}
+	if len(messages) > 0 && messages[0].Role == llm.RoleSystem {
+		messages = messages[1:]
+	}
+	result = append(result, llm.Message{Role: llm.RoleSystem, Content: o.Instruction()})
+	return append(result, messages...)
+}
+
+// extractJSON 去掉回复两端的空白和Markdown代码块标记
+func extractJSON(content string) string {
+	text := strings.TrimSpace(content)
+	if strings.HasPrefix(text, "```") && strings.HasSuffix(text, "```") && len(text) >= 6 {
+		text = strings.TrimSuffix(text[3:], "```")
+		if newline := strings.IndexByte(text, '\n'); newline >= 0 {
+			lang := strings.TrimSpace(text[:newline])
+			if lang == "" || strings.EqualFold(lang, "json") {
+				text = text[newline+1:]
+			}
+		}
+		text = strings.TrimSpace(text)
+	}
+	return text
+}
@@ -0,0 +1,120 @@
+package structured
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"rabbit_ai/internal/llm"
+)
+
+// stubProvider 依次返回预设回复并记录请求的提供方
+type stubProvider struct {
+	replies  []string
+	requests []llm.ChatRequest
+}
+
+func (p *stubProvider) Name() string { return "stub" }
+
+func (p *stubProvider) ChatCompletion(ctx context.Context, request llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.requests = append(p.requests, request)
+	if len(p.requests) > len(p.replies) {
+		return nil, errors.New("no more replies")
+	}
+	return &llm.ChatResponse{
+		Model:   request.Model,
+		Message: llm.Message{Role: llm.RoleAssistant, Content: p.replies[len(p.requests)-1]},
+		Usage:   llm.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}, nil
+}
+
+func (p *stubProvider) ChatCompletionStream(ctx context.Context, request llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p *stubProvider) ListModels(ctx context.Context) ([]llm.ModelInfo, error) {
+	return nil, nil
+}
+
+func newQuizOutput(t *testing.T, maxRetries *int) *Output {
+	t.Helper()
+	output, err := NewOutput(&ResponseFormat{Type: FormatJSONSchema, Name: "quiz", Schema: json.RawMessage(quizSchema), MaxRetries: maxRetries})
+	if err != nil {
+		t.Fatalf("Failed to create output: %v", err)
+	}
+	return output
+}
+
+func TestNewOutput_Invalid(t *testing.T) {
+	tooMany := MaxRetriesLimit + 1
+	invalid := map[string]*ResponseFormat{
+		"type":        {Type: "json_object", Schema: json.RawMessage(`{}`)},
+		"no schema":   {Type: FormatJSONSchema},
+		"max retries": {Type: FormatJSONSchema, Schema: json.RawMessage(`{}`), MaxRetries: &tooMany},
+	}
+	for name, format := range invalid {
+		if _, err := NewOutput(format); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("%s: expected ErrInvalidFormat, got %v", name, err)
+		}
+	}
+}
+
+func TestOutput_GenerateRetries(t *testing.T) {
+	provider := &stubProvider{replies: []string{
+		"好的，这是题目：{}",
+		`{"title":"Go","questions":[]}`,
+		"```json\n{\"title\":\"Go\",\"questions\":[{\"question\":\"q\",\"options\":[\"a\",\"b\"],\"answer\":0}]}\n```",
+	}}
+	output := newQuizOutput(t, nil)
+
+	result, err := output.Generate(context.Background(), provider, llm.ChatRequest{
+		Model:    "MiniMax-M1",
+		Messages: []llm.Message{{Role: llm.RoleSystem, Content: "你是出题老师"}, {Role: llm.RoleUser, Content: "出一道Go题"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	if result.Attempts != 3 || result.Response.Usage.TotalTokens != 45 {
+		t.Errorf("Expected 3 attempts with summed usage, got %d / %+v", result.Attempts, result.Response.Usage)
+	}
+	var quiz struct {
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(result.Value, &quiz); err != nil || quiz.Title != "Go" {
+		t.Errorf("Expected fenced JSON parsed, got %s, %v", result.Value, err)
+	}
+
+	first := provider.requests[0].Messages
+	if len(first) != 2 || !strings.HasPrefix(first[0].Content, "你是出题老师\n\n") || !strings.Contains(first[0].Content, `"minItems":1`) {
+		t.Errorf("Expected schema instruction appended to system prompt, got %+v", first)
+	}
+	last := provider.requests[2].Messages
+	if len(last) != 6 || last[4].Role != llm.RoleAssistant || !strings.Contains(last[5].Content, "$.questions: must have at least 1 items") {
+		t.Errorf("Expected previous reply and validation errors in retry prompt, got %+v", last)
+	}
+}
+
+func TestOutput_GenerateFailure(t *testing.T) {
+	provider := &stubProvider{replies: []string{"not json", `{"title":"Go"}`}}
+	retries := 1
+	output := newQuizOutput(t, &retries)
+
+	_, err := output.Generate(context.Background(), provider, llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "出题"}},
+	})
+	var outputErr *OutputError
+	if !errors.As(err, &outputErr) {
+		t.Fatalf("Expected OutputError, got %v", err)
+	}
+	if outputErr.Attempts != 2 || outputErr.Raw != `{"title":"Go"}` || outputErr.Usage.TotalTokens != 30 {
+		t.Errorf("Unexpected failure details: %+v", outputErr)
+	}
+	if len(outputErr.Errors) != 1 || outputErr.Errors[0].Message != `missing required property "questions"` {
+		t.Errorf("Expected last validation errors, got %v", outputErr.Errors)
+	}
+	if provider.requests[0].Messages[0].Role != llm.RoleSystem {
+		t.Errorf("Expected instruction inserted as system message, got %+v", provider.requests[0].Messages)
+	}
+}
@@ -0,0 +1,495 @@
+package structured
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema 限制
+const (
+	maxSchemaBytes = 64 << 10 // Schema JSON 最大字节数
+	maxSchemaDepth = 32       // Schema 最大嵌套层数
+)
+
+// 支持的 JSON 类型
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+	TypeNull    = "null"
+)
+
+// annotationKeywords 只起说明作用、不参与校验的关键字
+var annotationKeywords = map[string]bool{
+	"$schema":     true,
+	"$id":         true,
+	"$comment":    true,
+	"title":       true,
+	"description": true,
+	"default":     true,
+	"examples":    true,
+	"format":      true,
+}
+
+// Schema 编译后的 JSON Schema
+//
+// 支持常用的校验关键字：type、properties、required、additionalProperties、items、
+// minItems、maxItems、enum、const、minLength、maxLength、pattern、minimum、maximum、
+// exclusiveMinimum、exclusiveMaximum、allOf、anyOf、oneOf。
+// 不支持 $ref 等引用类关键字，编译时报错，避免静默放过无法校验的约束。
+type Schema struct {
+	types                []string
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema // 为nil时允许任意额外属性
+	noAdditional         bool    // additionalProperties 为 false
+	items                *Schema
+	minItems, maxItems   *int
+	enum                 []interface{}
+	constValue           interface{}
+	hasConst             bool
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+	minimum, maximum     *float64
+	exclusiveMinimum     *float64
+	exclusiveMaximum     *float64
+	allOf, anyOf, oneOf  []*Schema
+}
+
+// ValidationError 校验失败的位置和原因
+type ValidationError struct {
+	Path    string `json:"path"` // 出错的位置，如 $.questions[0].answer
+	Message string `json:"message"`
+}
+
+// String 以“位置: 原因”的形式输出
+func (e ValidationError) String() string {
+	return e.Path + ": " + e.Message
+}
+
+// CompileSchema 解析并编译 JSON Schema
+func CompileSchema(raw json.RawMessage) (*Schema, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%w: schema is required", ErrInvalidFormat)
+	}
+	if len(raw) > maxSchemaBytes {
+		return nil, fmt.Errorf("%w: schema exceeds %d bytes", ErrInvalidFormat, maxSchemaBytes)
+	}
+	value, err := decodeJSON(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: schema is not valid JSON: %v", ErrInvalidFormat, err)
+	}
+	schema, err := compile(value, "$", 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFormat, err)
+	}
+	return schema, nil
+}
+
+// decodeJSON 解码JSON，数字保留为 json.Number 以区分整数
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return value, nil
+}
+
+// compile 编译 Schema 节点，path 用于报告 Schema 自身的错误位置
+func compile(value interface{}, path string, depth int) (*Schema, error) {
+	if depth > maxSchemaDepth {
+		return nil, fmt.Errorf("%s: schema nested deeper than %d levels", path, maxSchemaDepth)
+	}
+	node, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: schema must be an object", path)
+	}
+
+	schema := &Schema{}
+	keys := make([]string, 0, len(node))
+	for key := range node {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		raw := node[key]
+		at := path + "." + key
+		var err error
+		switch key {
+		case "type":
+			schema.types, err = compileTypes(raw, at)
+		case "properties":
+			properties, ok := raw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: must be an object", at)
+			}
+			schema.properties = make(map[string]*Schema, len(properties))
+			for name, property := range properties {
+				if schema.properties[name], err = compile(property, at+"."+name, depth+1); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			schema.required, err = stringList(raw, at)
+		case "additionalProperties":
+			if allowed, ok := raw.(bool); ok {
+				schema.noAdditional = !allowed
+			} else {
+				schema.additionalProperties, err = compile(raw, at, depth+1)
+			}
+		case "items":
+			schema.items, err = compile(raw, at, depth+1)
+		case "minItems":
+			schema.minItems, err = nonNegativeInt(raw, at)
+		case "maxItems":
+			schema.maxItems, err = nonNegativeInt(raw, at)
+		case "minLength":
+			schema.minLength, err = nonNegativeInt(raw, at)
+		case "maxLength":
+			schema.maxLength, err = nonNegativeInt(raw, at)
+		case "enum":
+			values, ok := raw.([]interface{})
+			if !ok || len(values) == 0 {
+				return nil, fmt.Errorf("%s: must be a non-empty array", at)
+			}
+			schema.enum = values
+		case "const":
+			schema.constValue, schema.hasConst = raw, true
+		case "pattern":
+			pattern, ok := raw.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: must be a string", at)
+			}
+			if schema.pattern, err = regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("%s: invalid pattern: %v", at, err)
+			}
+		case "minimum":
+			schema.minimum, err = number(raw, at)
+		case "maximum":
+			schema.maximum, err = number(raw, at)
+		case "exclusiveMinimum":
+			schema.exclusiveMinimum, err = number(raw, at)
+		case "exclusiveMaximum":
+			schema.exclusiveMaximum, err = number(raw, at)
+		case "allOf":
+			schema.allOf, err = compileList(raw, at, depth)
+		case "anyOf":
+			schema.anyOf, err = compileList(raw, at, depth)
+		case "oneOf":
+			schema.oneOf, err = compileList(raw, at, depth)
+		default:
+			if !annotationKeywords[key] {
+				return nil, fmt.Errorf("%s: unsupported keyword", at)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range schema.required {
+		if schema.properties[name] == nil && schema.noAdditional {
+			return nil, fmt.Errorf("%s.required: %q is not allowed by additionalProperties", path, name)
+		}
+	}
+	return schema, nil
+}
+
+// compileTypes 解析 type 关键字，可以是单个类型或类型数组
+func compileTypes(raw interface{}, path string) ([]string, error) {
+	var types []string
+	switch value := raw.(type) {
+	case string:
+		types = []string{value}
+	case []interface{}:
+		var err error
+		if types, err = stringList(value, path); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%s: must be a string or an array of strings", path)
+	}
+	for _, t := range types {
+		switch t {
+		case TypeObject, TypeArray, TypeString, TypeNumber, TypeInteger, TypeBoolean, TypeNull:
+		default:
+			return nil, fmt.Errorf("%s: unknown type %q", path, t)
+		}
+	}
+	return types, nil
+}
+
+// compileList 编译 Schema 数组（allOf/anyOf/oneOf）
+func compileList(raw interface{}, path string, depth int) ([]*Schema, error) {
+	values, ok := raw.([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, fmt.Errorf("%s: must be a non-empty array", path)
+	}
+	schemas := make([]*Schema, 0, len(values))
+	for i, value := range values {
+		schema, err := compile(value, fmt.Sprintf("%s[%d]", path, i), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
+
+func stringList(raw interface{}, path string) ([]string, error) {
+	values, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: must be an array of strings", path)
+	}
+	list := make([]string, 0, len(values))
+	for _, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: must be an array of strings", path)
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+func number(raw interface{}, path string) (*float64, error) {
+	n, ok := raw.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a number", path)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("%s: must be a number", path)
+	}
+	return &f, nil
+}
+
+func nonNegativeInt(raw interface{}, path string) (*int, error) {
+	n, ok := raw.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a non-negative integer", path)
+	}
+	i, err := strconv.Atoi(n.String())
+	if err != nil || i < 0 {
+		return nil, fmt.Errorf("%s: must be a non-negative integer", path)
+	}
+	return &i, nil
+}
+
+// Validate 校验JSON值（由 decodeJSON 解码），返回全部校验错误，通过时返回nil
+func (s *Schema) Validate(value interface{}) []ValidationError {
+	var errs []ValidationError
+	s.validate(value, "$", &errs)
+	return errs
+}
+
+func (s *Schema) validate(value interface{}, path string, errs *[]ValidationError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.types) > 0 && !matchesType(value, s.types) {
+		fail("expected %s, got %s", strings.Join(s.types, " or "), typeOf(value))
+		return
+	}
+	if s.hasConst && !equal(value, s.constValue) {
+		fail("must be %s", compactJSON(s.constValue))
+	}
+	if len(s.enum) > 0 {
+		matched := false
+		for _, candidate := range s.enum {
+			if equal(value, candidate) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("must be one of %s", compactJSON(s.enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		s.validateObject(v, path, errs)
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			fail("must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			fail("must have at most %d items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.minLength != nil && length < *s.minLength {
+			fail("must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			fail("must be at most %d characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("must match pattern %q", s.pattern.String())
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		if s.minimum != nil && f < *s.minimum {
+			fail("must be >= %v", *s.minimum)
+		}
+		if s.maximum != nil && f > *s.maximum {
+			fail("must be <= %v", *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && f <= *s.exclusiveMinimum {
+			fail("must be > %v", *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && f >= *s.exclusiveMaximum {
+			fail("must be < %v", *s.exclusiveMaximum)
+		}
+	}
+
+	for _, sub := range s.allOf {
+		sub.validate(value, path, errs)
+	}
+	if len(s.anyOf) > 0 && countMatches(s.anyOf, value) == 0 {
+		fail("must match at least one schema in anyOf")
+	}
+	if len(s.oneOf) > 0 {
+		if matches := countMatches(s.oneOf, value); matches != 1 {
+			fail("must match exactly one schema in oneOf, matched %d", matches)
+		}
+	}
+}
+
+func (s *Schema) validateObject(object map[string]interface{}, path string, errs *[]ValidationError) {
+	for _, name := range s.required {
+		if _, ok := object[name]; !ok {
+			*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf("missing required property %q", name)})
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		at := path + "." + name
+		if property := s.properties[name]; property != nil {
+			property.validate(object[name], at, errs)
+			continue
+		}
+		if s.noAdditional {
+			*errs = append(*errs, ValidationError{Path: at, Message: "additional property is not allowed"})
+		} else if s.additionalProperties != nil {
+			s.additionalProperties.validate(object[name], at, errs)
+		}
+	}
+}
+
+// countMatches 值满足的子 Schema 个数
+func countMatches(schemas []*Schema, value interface{}) int {
+	matches := 0
+	for _, schema := range schemas {
+		if len(schema.Validate(value)) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+// matchesType 值是否属于任一类型，整数同时属于 number
+func matchesType(value interface{}, types []string) bool {
+	actual := typeOf(value)
+	for _, t := range types {
+		if t == actual || (t == TypeNumber && actual == TypeInteger) {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf 值的 JSON 类型，没有小数部分的数字为 integer
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return TypeObject
+	case []interface{}:
+		return TypeArray
+	case string:
+		return TypeString
+	case bool:
+		return TypeBoolean
+	case nil:
+		return TypeNull
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return TypeInteger
+		}
+		return TypeNumber
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// equal 比较两个JSON值，数字按数值比较
+func equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, ok := y[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+func compactJSON(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
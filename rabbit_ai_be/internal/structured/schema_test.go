@@ -0,0 +1,122 @@
+package structured
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const quizSchema = `{
+	"type": "object",
+	"properties": {
+		"title": {"type": "string", "minLength": 1},
+		"questions": {
+			"type": "array",
+			"minItems": 1,
+			"items": {
+				"type": "object",
+				"properties": {
+					"question": {"type": "string"},
+					"options": {"type": "array", "items": {"type": "string"}, "minItems": 2, "maxItems": 4},
+					"answer": {"type": "integer", "minimum": 0, "maximum": 3},
+					"difficulty": {"enum": ["easy", "hard"]}
+				},
+				"required": ["question", "options", "answer"],
+				"additionalProperties": false
+			}
+		}
+	},
+	"required": ["title", "questions"]
+}`
+
+func mustCompile(t *testing.T, raw string) *Schema {
+	t.Helper()
+	schema, err := CompileSchema(json.RawMessage(raw))
+	if err != nil {
+		t.Fatalf("Failed to compile schema: %v", err)
+	}
+	return schema
+}
+
+func mustDecode(t *testing.T, raw string) interface{} {
+	t.Helper()
+	value, err := decodeJSON([]byte(raw))
+	if err != nil {
+		t.Fatalf("Failed to decode %s: %v", raw, err)
+	}
+	return value
+}
+
+func TestSchema_Validate(t *testing.T) {
+	schema := mustCompile(t, quizSchema)
+
+	valid := `{"title":"Go","questions":[{"question":"q","options":["a","b"],"answer":1,"difficulty":"easy"}]}`
+	if errs := schema.Validate(mustDecode(t, valid)); len(errs) != 0 {
+		t.Errorf("Expected valid document, got %v", errs)
+	}
+
+	tests := []struct {
+		name     string
+		document string
+		path     string
+		message  string
+	}{
+		{"missing required", `{"title":"Go"}`, "$", `missing required property "questions"`},
+		{"wrong type", `{"title":1,"questions":[{"question":"q","options":["a","b"],"answer":0}]}`, "$.title", "expected string, got integer"},
+		{"min items", `{"title":"Go","questions":[]}`, "$.questions", "must have at least 1 items"},
+		{"integer", `{"title":"Go","questions":[{"question":"q","options":["a","b"],"answer":1.5}]}`, "$.questions[0].answer", "expected integer, got number"},
+		{"maximum", `{"title":"Go","questions":[{"question":"q","options":["a","b"],"answer":4}]}`, "$.questions[0].answer", "must be <= 3"},
+		{"enum", `{"title":"Go","questions":[{"question":"q","options":["a","b"],"answer":0,"difficulty":"mid"}]}`, "$.questions[0].difficulty", `must be one of ["easy","hard"]`},
+		{"additional", `{"title":"Go","questions":[{"question":"q","options":["a","b"],"answer":0,"hint":"x"}]}`, "$.questions[0].hint", "additional property is not allowed"},
+		{"min length", `{"title":"","questions":[{"question":"q","options":["a","b"],"answer":0}]}`, "$.title", "must be at least 1 characters"},
+	}
+	for _, tt := range tests {
+		errs := schema.Validate(mustDecode(t, tt.document))
+		if len(errs) != 1 || errs[0].Path != tt.path || errs[0].Message != tt.message {
+			t.Errorf("%s: expected %s: %s, got %v", tt.name, tt.path, tt.message, errs)
+		}
+	}
+}
+
+func TestSchema_Combinators(t *testing.T) {
+	schema := mustCompile(t, `{"oneOf":[{"type":"integer"},{"type":"string","pattern":"^[a-z]+$"}]}`)
+	if errs := schema.Validate(mustDecode(t, `"abc"`)); len(errs) != 0 {
+		t.Errorf("Expected string branch to match, got %v", errs)
+	}
+	if errs := schema.Validate(mustDecode(t, `"ABC"`)); len(errs) != 1 {
+		t.Errorf("Expected no branch to match, got %v", errs)
+	}
+
+	nullable := mustCompile(t, `{"type":["string","null"],"anyOf":[{"const":null},{"maxLength":3}]}`)
+	if errs := nullable.Validate(mustDecode(t, `null`)); len(errs) != 0 {
+		t.Errorf("Expected null to be allowed, got %v", errs)
+	}
+	if errs := nullable.Validate(mustDecode(t, `"abcd"`)); len(errs) != 1 {
+		t.Errorf("Expected anyOf failure, got %v", errs)
+	}
+}
+
+func TestCompileSchema_Invalid(t *testing.T) {
+	invalid := map[string]string{
+		"not json":            `{`,
+		"not object":          `[]`,
+		"unknown type":        `{"type":"date"}`,
+		"unsupported keyword": `{"$ref":"#/definitions/x"}`,
+		"bad pattern":         `{"pattern":"("}`,
+		"negative length":     `{"minLength":-1}`,
+		"empty enum":          `{"enum":[]}`,
+		"nested invalid":      `{"properties":{"a":{"items":1}}}`,
+		"required forbidden":  `{"properties":{},"required":["a"],"additionalProperties":false}`,
+	}
+	for name, raw := range invalid {
+		if _, err := CompileSchema(json.RawMessage(raw)); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("%s: expected ErrInvalidFormat, got %v", name, err)
+		}
+	}
+
+	deep := strings.Repeat(`{"items":`, maxSchemaDepth+2) + `{}` + strings.Repeat(`}`, maxSchemaDepth+2)
+	if _, err := CompileSchema(json.RawMessage(deep)); err == nil {
+		t.Error("Expected error for deeply nested schema")
+	}
+}
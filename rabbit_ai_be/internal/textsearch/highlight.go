@@ -0,0 +1,103 @@
+package textsearch
+
+import (
+	"html"
+	"sort"
+	"strings"
+)
+
+// 高亮标记
+const (
+	HighlightStart = "<mark>"
+	HighlightEnd   = "</mark>"
+)
+
+// DefaultSnippetRunes 摘要片段的默认字符数
+const DefaultSnippetRunes = 120
+
+// span 命中的字符区间
+type span struct {
+	start, end int
+}
+
+// matchSpans 查询词在文本中出现的区间，重叠或相邻的区间合并
+func matchSpans(runes []rune, terms []string) []span {
+	var spans []span
+	for _, term := range terms {
+		pattern := []rune(term)
+		for i := 0; i+len(pattern) <= len(runes); i++ {
+			if string(runes[i:i+len(pattern)]) == term {
+				spans = append(spans, span{start: i, end: i + len(pattern)})
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].start < spans[j].start
+	})
+	merged := []span{spans[0]}
+	for _, s := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if s.start <= last.end {
+			if s.end > last.end {
+				last.end = s.end
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// Snippet 截取文本中第一个命中处附近的片段并高亮查询词
+//
+// 片段最多 maxRunes 个字符，截断处以省略号表示；原文经过 HTML 转义，
+// 命中部分以 <mark></mark> 包裹，可直接在页面中渲染。没有命中时返回文本开头。
+func Snippet(text, query string, maxRunes int) string {
+	if maxRunes <= 0 {
+		maxRunes = DefaultSnippetRunes
+	}
+	runes := []rune(text)
+	spans := matchSpans(lower(text), QueryLexemes(query))
+
+	// 命中位置前保留约四分之一的上下文
+	start := 0
+	if len(spans) > 0 {
+		start = spans[0].start - maxRunes/4
+	}
+	if start > len(runes)-maxRunes {
+		start = len(runes) - maxRunes
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + maxRunes
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, s := range spans {
+		if s.end <= start || s.start >= end {
+			continue
+		}
+		s.start, s.end = max(s.start, start), min(s.end, end)
+		b.WriteString(html.EscapeString(string(runes[pos:s.start])))
+		b.WriteString(HighlightStart)
+		b.WriteString(html.EscapeString(string(runes[s.start:s.end])))
+		b.WriteString(HighlightEnd)
+		pos = s.end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:end])))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package textsearch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDocumentLexemes(t *testing.T) {
+	lexemes := DocumentLexemes("Go语言的goroutine，GO！")
+	expected := []string{"go", "goroutine", "的", "言", "言的", "语", "语言"}
+	if !reflect.DeepEqual(lexemes, expected) {
+		t.Errorf("Expected %v, got %v", expected, lexemes)
+	}
+
+	if lexemes := DocumentLexemes("  ，。!"); len(lexemes) != 0 {
+		t.Errorf("Expected no lexemes for punctuation, got %v", lexemes)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := map[string]string{
+		"红烧肉":        "'烧肉' & '红烧'",
+		"肉":          "'肉'",
+		"Redis 缓存 肉": "'redis' & '缓存' & '肉'",
+		"it's":       "'it' & 's'",
+		"？？":         "",
+	}
+	for text, expected := range tests {
+		if query := Query(text); query != expected {
+			t.Errorf("Query(%q): expected %q, got %q", text, expected, query)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	snippet := Snippet("五花肉焯水后加冰糖炒色，红烧肉就做好了", "红烧肉", 0)
+	if snippet != "五花肉焯水后加冰糖炒色，<mark>红烧肉</mark>就做好了" {
+		t.Errorf("Expected merged bigram highlight, got %q", snippet)
+	}
+
+	if snippet := Snippet("Use <b>Redis</b> cache", "redis", 0); snippet != "Use &lt;b&gt;<mark>Redis</mark>&lt;/b&gt; cache" {
+		t.Errorf("Expected escaped case-insensitive highlight, got %q", snippet)
+	}
+
+	long := strings.Repeat("前文", 50) + "关键词" + strings.Repeat("后文", 50)
+	snippet = Snippet(long, "关键词", 20)
+	if !strings.HasPrefix(snippet, "…") || !strings.HasSuffix(snippet, "…") || !strings.Contains(snippet, "<mark>关键词</mark>") {
+		t.Errorf("Expected window around match, got %q", snippet)
+	}
+	if plain := strings.NewReplacer(HighlightStart, "", HighlightEnd, "", "…", "").Replace(snippet); len([]rune(plain)) != 20 {
+		t.Errorf("Expected 20 runes of text, got %q", plain)
+	}
+
+	if snippet := Snippet("没有命中", "别的", 0); snippet != "没有命中" {
+		t.Errorf("Expected text start without match, got %q", snippet)
+	}
+}
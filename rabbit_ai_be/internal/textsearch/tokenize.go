@@ -0,0 +1,128 @@
+// Package textsearch 全文检索的分词与高亮
+//
+// PostgreSQL 内置的文本搜索配置不能切分中文，这里在 Go 中完成分词：
+// 连续的中日韩文字按单字和相邻两字（bigram）切分，其他字母数字按单词切分并转为小写。
+// 文档的词位直接写入 tsvector 列，查询时用 bigram 组成 tsquery，因此无需数据库扩展。
+package textsearch
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// token 文本中的一个词及其在原文中的字符位置
+type token struct {
+	text  string
+	start int // 起始字符下标
+	end   int // 结束字符下标（不含）
+}
+
+// isCJK 判断是否为按字切分的中日韩文字
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// segment 将文本切分为连续的中日韩文字片段与单词，返回小写文本和字符位置
+func segment(runes []rune) []token {
+	var segments []token
+	start := -1
+	cjk := false
+	flush := func(end int) {
+		if start >= 0 {
+			segments = append(segments, token{text: string(runes[start:end]), start: start, end: end})
+			start = -1
+		}
+	}
+	for i, r := range runes {
+		switch {
+		case isCJK(r):
+			if start >= 0 && !cjk {
+				flush(i)
+			}
+			if start < 0 {
+				start, cjk = i, true
+			}
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if start >= 0 && cjk {
+				flush(i)
+			}
+			if start < 0 {
+				start, cjk = i, false
+			}
+		default:
+			flush(i)
+		}
+	}
+	flush(len(runes))
+	return segments
+}
+
+// lower 按字符转小写，保证与原文字符位置一一对应
+func lower(text string) []rune {
+	runes := []rune(text)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+// tokenize 切分文本；withUnigrams 为 true 时中日韩片段同时输出单字
+func tokenize(text string, withUnigrams bool) []token {
+	runes := lower(text)
+	var tokens []token
+	for _, seg := range segment(runes) {
+		if !isCJK(runes[seg.start]) {
+			tokens = append(tokens, seg)
+			continue
+		}
+		length := seg.end - seg.start
+		for i := seg.start; i < seg.end; i++ {
+			if withUnigrams || length == 1 {
+				tokens = append(tokens, token{text: string(runes[i]), start: i, end: i + 1})
+			}
+			if i+1 < seg.end {
+				tokens = append(tokens, token{text: string(runes[i : i+2]), start: i, end: i + 2})
+			}
+		}
+	}
+	return tokens
+}
+
+// unique 去重并排序
+func unique(tokens []token) []string {
+	seen := make(map[string]bool, len(tokens))
+	lexemes := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		if !seen[t.text] {
+			seen[t.text] = true
+			lexemes = append(lexemes, t.text)
+		}
+	}
+	sort.Strings(lexemes)
+	return lexemes
+}
+
+// DocumentLexemes 文档的词位（去重），用于 array_to_tsvector 建立索引
+//
+// 中日韩文字同时索引单字和 bigram，使单字查询也能命中。
+func DocumentLexemes(text string) []string {
+	return unique(tokenize(text, true))
+}
+
+// QueryLexemes 查询的词位（去重）；长度不小于2的中日韩片段只使用 bigram
+func QueryLexemes(text string) []string {
+	return unique(tokenize(text, false))
+}
+
+// Query 将查询文本转为 tsquery 表达式，所有词位须同时出现；没有可检索的词时返回空字符串
+//
+// 词位只包含字母和数字，可直接加引号作为 tsquery 字面量，不会被再次分词。
+func Query(text string) string {
+	lexemes := QueryLexemes(text)
+	quoted := make([]string, len(lexemes))
+	for i, lexeme := range lexemes {
+		quoted[i] = "'" + lexeme + "'"
+	}
+	return strings.Join(quoted, " & ")
+}
@@ -0,0 +1,43 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RegisterBuiltins 注册内置工具
+func RegisterBuiltins(r *Registry) {
+	r.MustRegister(Tool{
+		Name:        "get_current_time",
+		Description: "获取指定时区的当前日期和时间",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"timezone": {"type": "string", "description": "IANA时区名称，例如 Asia/Shanghai，默认 Asia/Shanghai"}
+			}
+		}`),
+		Func: currentTime,
+	})
+}
+
+// currentTime 返回指定时区的当前时间
+func currentTime(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if args.Timezone == "" {
+		args.Timezone = "Asia/Shanghai"
+	}
+
+	location, err := time.LoadLocation(args.Timezone)
+	if err != nil {
+		return "", fmt.Errorf("unknown timezone %s: %w", args.Timezone, err)
+	}
+	now := time.Now().In(location)
+	return fmt.Sprintf(`{"timezone":%q,"time":%q,"weekday":%q}`, args.Timezone, now.Format(time.RFC3339), now.Weekday().String()), nil
+}
@@ -0,0 +1,148 @@
+// Package tool 提供服务端工具（函数调用）注册表，供对话服务在模型请求时执行。
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"rabbit_ai/internal/llm"
+)
+
+// ErrToolNotFound 工具未注册
+var ErrToolNotFound = errors.New("tool not found")
+
+// namePattern 工具名称格式，与主流模型接口的函数名限制保持一致
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Func 工具实现，arguments 为模型生成的JSON参数，返回值作为 tool 消息内容回传给模型
+type Func func(ctx context.Context, arguments json.RawMessage) (string, error)
+
+// Tool 工具定义
+type Tool struct {
+	Name        string          // 工具名称
+	Description string          // 工具用途说明，模型据此决定是否调用
+	Parameters  json.RawMessage // 参数的JSON Schema，顶层必须为 object
+	Func        Func            // 工具实现
+}
+
+// schema 参数JSON Schema中注册时需要校验的部分
+type schema struct {
+	Type       string                     `json:"type"`
+	Properties map[string]json.RawMessage `json:"properties"`
+	Required   []string                   `json:"required"`
+}
+
+// Registry 工具注册表
+type Registry struct {
+	mu      sync.RWMutex
+	tools   map[string]Tool
+	schemas map[string]schema
+}
+
+// NewRegistry 创建工具注册表实例
+func NewRegistry() *Registry {
+	return &Registry{
+		tools:   make(map[string]Tool),
+		schemas: make(map[string]schema),
+	}
+}
+
+// Register 注册工具，名称重复或参数Schema无效时返回错误
+func (r *Registry) Register(tool Tool) error {
+	if !namePattern.MatchString(tool.Name) {
+		return fmt.Errorf("invalid tool name: %q", tool.Name)
+	}
+	if tool.Func == nil {
+		return fmt.Errorf("tool %s has no implementation", tool.Name)
+	}
+	if len(tool.Parameters) == 0 {
+		tool.Parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+
+	var s schema
+	if err := json.Unmarshal(tool.Parameters, &s); err != nil {
+		return fmt.Errorf("invalid parameters schema for tool %s: %w", tool.Name, err)
+	}
+	if s.Type != "object" {
+		return fmt.Errorf("parameters schema for tool %s must be of type object", tool.Name)
+	}
+	for _, name := range s.Required {
+		if _, ok := s.Properties[name]; !ok {
+			return fmt.Errorf("required parameter %s of tool %s is not declared in properties", name, tool.Name)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tools[tool.Name]; exists {
+		return fmt.Errorf("tool %s already registered", tool.Name)
+	}
+	r.tools[tool.Name] = tool
+	r.schemas[tool.Name] = s
+	return nil
+}
+
+// MustRegister 注册工具，失败时panic，用于启动时注册内置工具
+func (r *Registry) MustRegister(tool Tool) {
+	if err := r.Register(tool); err != nil {
+		panic(err)
+	}
+}
+
+// Definitions 获取所有工具的定义（按名称排序），用于填充 llm.ChatRequest.Tools
+func (r *Registry) Definitions() []llm.Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	definitions := make([]llm.Tool, 0, len(r.tools))
+	for _, tool := range r.tools {
+		definitions = append(definitions, llm.Tool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  tool.Parameters,
+		})
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Name < definitions[j].Name
+	})
+	return definitions
+}
+
+// Len 已注册的工具数量
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.tools)
+}
+
+// Call 校验参数并执行工具
+func (r *Registry) Call(ctx context.Context, name, arguments string) (string, error) {
+	r.mu.RLock()
+	tool, ok := r.tools[name]
+	s := r.schemas[name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrToolNotFound, name)
+	}
+
+	if arguments == "" {
+		arguments = "{}"
+	}
+	var args map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", fmt.Errorf("invalid arguments for tool %s: %w", name, err)
+	}
+	for _, required := range s.Required {
+		if _, ok := args[required]; !ok {
+			return "", fmt.Errorf("missing required argument %s for tool %s", required, name)
+		}
+	}
+
+	return tool.Func(ctx, json.RawMessage(arguments))
+}
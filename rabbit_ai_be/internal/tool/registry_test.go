@@ -0,0 +1,90 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func echoFunc(ctx context.Context, arguments json.RawMessage) (string, error) {
+	return string(arguments), nil
+}
+
+func TestRegistry_Register(t *testing.T) {
+	registry := NewRegistry()
+
+	valid := Tool{
+		Name:       "echo",
+		Parameters: json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}},"required":["text"]}`),
+		Func:       echoFunc,
+	}
+	if err := registry.Register(valid); err != nil {
+		t.Fatalf("Failed to register valid tool: %v", err)
+	}
+	if err := registry.Register(valid); err == nil {
+		t.Error("Expected error for duplicate tool")
+	}
+
+	invalid := []Tool{
+		{Name: "bad name", Func: echoFunc},
+		{Name: "no_func"},
+		{Name: "not_object", Parameters: json.RawMessage(`{"type":"string"}`), Func: echoFunc},
+		{Name: "bad_json", Parameters: json.RawMessage(`{`), Func: echoFunc},
+		{Name: "undeclared", Parameters: json.RawMessage(`{"type":"object","required":["x"]}`), Func: echoFunc},
+	}
+	for _, tool := range invalid {
+		if err := registry.Register(tool); err == nil {
+			t.Errorf("Expected error registering %q", tool.Name)
+		}
+	}
+
+	// 未提供参数Schema时使用空对象
+	if err := registry.Register(Tool{Name: "no_params", Func: echoFunc}); err != nil {
+		t.Fatalf("Failed to register tool without parameters: %v", err)
+	}
+
+	definitions := registry.Definitions()
+	if len(definitions) != 2 || definitions[0].Name != "echo" || definitions[1].Name != "no_params" {
+		t.Errorf("Unexpected definitions: %+v", definitions)
+	}
+}
+
+func TestRegistry_Call(t *testing.T) {
+	registry := NewRegistry()
+	registry.MustRegister(Tool{
+		Name:       "echo",
+		Parameters: json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}},"required":["text"]}`),
+		Func:       echoFunc,
+	})
+
+	result, err := registry.Call(context.Background(), "echo", `{"text":"你好"}`)
+	if err != nil || result != `{"text":"你好"}` {
+		t.Errorf("Unexpected result %q, err %v", result, err)
+	}
+
+	if _, err := registry.Call(context.Background(), "missing", `{}`); !errors.Is(err, ErrToolNotFound) {
+		t.Errorf("Expected ErrToolNotFound, got %v", err)
+	}
+	if _, err := registry.Call(context.Background(), "echo", `{}`); err == nil || !strings.Contains(err.Error(), "text") {
+		t.Errorf("Expected missing argument error, got %v", err)
+	}
+	if _, err := registry.Call(context.Background(), "echo", `not json`); err == nil {
+		t.Error("Expected invalid arguments error")
+	}
+}
+
+func TestRegisterBuiltins(t *testing.T) {
+	registry := NewRegistry()
+	RegisterBuiltins(registry)
+
+	result, err := registry.Call(context.Background(), "get_current_time", `{"timezone":"UTC"}`)
+	if err != nil {
+		t.Fatalf("Failed to call get_current_time: %v", err)
+	}
+	var parsed map[string]string
+	if err := json.Unmarshal([]byte(result), &parsed); err != nil || parsed["timezone"] != "UTC" {
+		t.Errorf("Unexpected result %q, err %v", result, err)
+	}
+}
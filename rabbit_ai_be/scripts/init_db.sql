@@ -55,6 +55,244 @@ CREATE TABLE IF NOT EXISTS messages (
     FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
 );
 
+-- 工具调用：assistant消息记录请求的工具调用，tool消息记录对应的调用ID
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS tool_calls JSONB;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS tool_call_id VARCHAR(100) NOT NULL DEFAULT '';
+
+-- 上下文裁剪报告：记录生成回复时为适应上下文窗口丢弃的历史轮次
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS context_report JSONB;
+
+-- 消息树：消息通过 parent_id 指向上一条消息（0 表示根），对话记录当前分支的叶子消息
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS parent_id INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS active_leaf_id INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_messages_parent_id ON messages(parent_id);
+
+-- 早期对话迁移为消息树。此前的重新生成以 reply_to_id 记录回复所属轮次的用户消息、
+-- 以 inactive 标记被替换的备选回复；新建的数据库没有这两列，先补齐以便统一处理，迁移后删除
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS reply_to_id INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS inactive BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- 当前使用的消息按创建顺序串成单一分支
+UPDATE messages m SET parent_id = p.prev_id
+FROM (
+    SELECT id, LAG(id) OVER (PARTITION BY conversation_id ORDER BY created_at, id) AS prev_id
+    FROM messages
+    WHERE NOT inactive
+) p, conversations c
+WHERE m.id = p.id AND p.prev_id IS NOT NULL AND c.id = m.conversation_id AND c.active_leaf_id = 0;
+
+-- 备选回复挂在所属轮次的用户消息下，成为当前回复的兄弟分支；
+-- 同一次生成中工具调用之后的消息接在前一条消息之后
+UPDATE messages m SET parent_id = CASE
+        WHEN p.prev_role = 'tool' OR p.prev_tool_calls IS NOT NULL THEN p.prev_id
+        ELSE m.reply_to_id
+    END
+FROM (
+    SELECT id,
+        LAG(id) OVER w AS prev_id,
+        LAG(role) OVER w AS prev_role,
+        LAG(tool_calls) OVER w AS prev_tool_calls
+    FROM messages
+    WHERE inactive AND reply_to_id <> 0
+    WINDOW w AS (PARTITION BY reply_to_id ORDER BY created_at, id)
+) p, conversations c
+WHERE m.id = p.id AND c.id = m.conversation_id AND c.active_leaf_id = 0;
+
+UPDATE conversations c SET active_leaf_id = (SELECT MAX(id) FROM messages m WHERE m.conversation_id = c.id AND NOT m.inactive)
+WHERE c.active_leaf_id = 0 AND EXISTS (SELECT 1 FROM messages m WHERE m.conversation_id = c.id AND NOT m.inactive);
+
+ALTER TABLE messages DROP COLUMN IF EXISTS reply_to_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS inactive;
+
+-- 滚动摘要：较早轮次压缩后的摘要及其覆盖范围
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS summary JSONB;
+
+-- 对话级设置：系统提示、模型与生成参数
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS settings JSONB NOT NULL DEFAULT '{}';
+
+-- 内容安全：消息的审核状态，以及命中护栏的审核事件（保存原始内容供管理员复核）
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS moderation_status VARCHAR(20) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS moderation_events (
+    id SERIAL PRIMARY KEY,
+    conversation_id INTEGER NOT NULL DEFAULT 0,
+    message_id INTEGER NOT NULL DEFAULT 0,
+    user_id INTEGER NOT NULL DEFAULT 0,
+    stage VARCHAR(20) NOT NULL, -- input/output
+    action VARCHAR(20) NOT NULL, -- flag/block
+    guard VARCHAR(50) NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    review_status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending/approved/rejected
+    reviewer_id INTEGER NOT NULL DEFAULT 0,
+    review_note TEXT NOT NULL DEFAULT '',
+    reviewed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_events_review_status ON moderation_events(review_status, created_at);
+
+-- 语义搜索：消息向量，以 REAL[] 保存（无需扩展），相似度在服务端计算
+CREATE TABLE IF NOT EXISTS message_embeddings (
+    message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    conversation_id INTEGER NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    model VARCHAR(50) NOT NULL,
+    embedding REAL[] NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (message_id, model)
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_embeddings_conversation_id ON message_embeddings(conversation_id);
+-- 无法向量化的消息（如被向量接口拒绝）保存空向量和错误信息，不再重试
+ALTER TABLE message_embeddings ADD COLUMN IF NOT EXISTS error TEXT NOT NULL DEFAULT '';
+
+-- 全文检索：分词在服务端完成（中文按单字与bigram切分），词位直接写入 tsvector 列；
+-- 早期数据的列为 NULL，由服务启动后的后台任务补齐
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS title_vector TSVECTOR;
+CREATE INDEX IF NOT EXISTS idx_messages_search_vector ON messages USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_conversations_title_vector ON conversations USING GIN (title_vector);
+
+-- 知识库：用户上传的文档切分为片段并向量化，对话可关联知识库，AI回复记录引用的片段
+CREATE TABLE IF NOT EXISTS knowledge_bases (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS knowledge_documents (
+    id SERIAL PRIMARY KEY,
+    knowledge_base_id INTEGER NOT NULL REFERENCES knowledge_bases(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    format VARCHAR(20) NOT NULL, -- text/markdown/pdf_text
+    content TEXT NOT NULL,
+    size INTEGER NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending/processing/ready/failed
+    error TEXT NOT NULL DEFAULT '',
+    chunk_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_knowledge_documents_base_id ON knowledge_documents(knowledge_base_id);
+CREATE INDEX IF NOT EXISTS idx_knowledge_documents_status ON knowledge_documents(status, id);
+
+CREATE TABLE IF NOT EXISTS knowledge_chunks (
+    id SERIAL PRIMARY KEY,
+    document_id INTEGER NOT NULL REFERENCES knowledge_documents(id) ON DELETE CASCADE,
+    knowledge_base_id INTEGER NOT NULL REFERENCES knowledge_bases(id) ON DELETE CASCADE,
+    chunk_index INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    model VARCHAR(50) NOT NULL,
+    embedding REAL[] NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_knowledge_chunks_base_id ON knowledge_chunks(knowledge_base_id);
+CREATE INDEX IF NOT EXISTS idx_knowledge_chunks_document_id ON knowledge_chunks(document_id);
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS citations JSONB;
+
+-- 图文消息的内容片段（文字与图片引用），纯文本消息为空
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS parts JSONB;
+
+-- 提示词模板：owner_id 为 0 的是系统模板（所有用户可见，仅管理员可修改），其余为用户模板；
+-- 每次修改生成新版本，历史版本保存在 prompt_template_versions，消息记录生成它的模板及版本
+CREATE TABLE IF NOT EXISTS prompt_templates (
+    id SERIAL PRIMARY KEY,
+    owner_id INTEGER NOT NULL DEFAULT 0,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    system_prompt TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL DEFAULT '',
+    variables JSONB NOT NULL DEFAULT '[]',
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (owner_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS prompt_template_versions (
+    template_id INTEGER NOT NULL REFERENCES prompt_templates(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    system_prompt TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL DEFAULT '',
+    variables JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (template_id, version)
+);
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS template_id INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS template_version INTEGER NOT NULL DEFAULT 0;
+
+-- 内置系统模板
+INSERT INTO prompt_templates (owner_id, name, description, system_prompt, content, variables)
+VALUES
+    (0, '翻译', '将文本翻译为目标语言', '你是一名专业译者，译文需准确、通顺，保留原文格式，只输出译文。',
+     E'请将以下内容翻译成{{target_language}}：\n\n{{text}}',
+     '[{"name":"text","description":"待翻译的文本","required":true},{"name":"target_language","description":"目标语言","default":"英文"}]'),
+    (0, '总结', '提炼文本要点', '你擅长信息提炼，总结需忠实原文，不添加原文没有的内容。',
+     E'请用{{length}}总结以下内容的要点：\n\n{{text}}',
+     '[{"name":"text","description":"待总结的文本","required":true},{"name":"length","description":"总结篇幅","default":"不超过五条要点"}]'),
+    (0, '润色', '改善文本的表达', '你是一名资深编辑，在不改变原意的前提下改善文字表达，只输出润色后的文本。',
+     E'请以{{style}}的风格润色以下内容：\n\n{{text}}',
+     '[{"name":"text","description":"待润色的文本","required":true},{"name":"style","description":"目标风格","default":"简洁专业"}]')
+ON CONFLICT (owner_id, name) DO NOTHING;
+
+INSERT INTO prompt_template_versions (template_id, version, system_prompt, content, variables, created_at)
+SELECT id, version, system_prompt, content, variables, updated_at
+FROM prompt_templates
+WHERE owner_id = 0
+ON CONFLICT (template_id, version) DO NOTHING;
+
+-- 批量聊天任务：条目由后台工作池从Redis队列领取处理，计数与用量随条目结束累加到任务
+CREATE TABLE IF NOT EXISTS batch_jobs (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL DEFAULT '',
+    model VARCHAR(50) NOT NULL,
+    params JSONB NOT NULL DEFAULT '{}', -- 系统提示、温度、最大token数、结构化输出格式
+    status VARCHAR(20) NOT NULL DEFAULT 'queued', -- queued/running/completed/cancelled
+    total_items INTEGER NOT NULL DEFAULT 0,
+    succeeded_items INTEGER NOT NULL DEFAULT 0,
+    failed_items INTEGER NOT NULL DEFAULT 0,
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    total_tokens INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_batch_jobs_user_id ON batch_jobs(user_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS batch_items (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER NOT NULL REFERENCES batch_jobs(id) ON DELETE CASCADE,
+    item_index INTEGER NOT NULL,
+    custom_id VARCHAR(100) NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending/running/succeeded/failed/cancelled
+    response TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    model VARCHAR(50) NOT NULL DEFAULT '', -- 实际回答的模型
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    total_tokens INTEGER NOT NULL DEFAULT 0,
+    available_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, -- 最早可处理时间，重试时推迟
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (job_id, item_index)
+);
+
+CREATE INDEX IF NOT EXISTS idx_batch_items_status ON batch_items(status, id);
+
 -- 创建索引
 CREATE INDEX IF NOT EXISTS idx_conversations_user_id ON conversations(user_id);
 CREATE INDEX IF NOT EXISTS idx_conversations_status ON conversations(status);